@@ -1,11 +1,16 @@
 package api
 
 import (
+	"context"
 	common "scheduler/appointment-service/internal"
 	"scheduler/appointment-service/internal/auth/oidc"
+	"scheduler/appointment-service/internal/dbase"
 	dbauth "scheduler/appointment-service/internal/dbase/auth"
 	"scheduler/appointment-service/internal/dbase/backend/slots"
 	"scheduler/appointment-service/internal/dbase/bots"
+	"scheduler/appointment-service/internal/dbase/idempotency"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"scheduler/appointment-service/internal/auth"
@@ -20,17 +25,38 @@ type api struct {
 		OneOffTokens *dbauth.OneOffTokenStorage
 		TimeSlots    *slots.TimeSlotsStorage
 		Bots         *bots.BotsStorage
+		Idempotency  *idempotency.IdempotencyStorage
 	}
 
-	cookieAuth        *CookieAuth
-	userSignIn        *oidc.UserSignIn
-	userSessionsStore *auth.UserSessionStore
+	cookieAuth         *CookieAuth
+	userSignIn         *oidc.UserSignIn
+	userSessionsStore  *auth.UserSessionStore
+	resourcePicker     *common.ResourcePicker
+	holdsSweeper       *common.PeriodicCallback
+	requestsSweeper    *common.PeriodicCallback
+	coverageChecker    *common.PeriodicCallback
+	idempotencySweeper *common.PeriodicCallback
+	idempotencyTTL     time.Duration
+	rateLimiter        *rateLimiter
+
+	botAuthOnce      sync.Once
+	botAuth          AuthorizationMethodFunc
+	botTokensSweeper *common.PeriodicCallback
+
+	// latestMigration is the schema version checked by /readyz
+	latestMigration uint
+	draining        atomic.Bool
+
+	// backgroundJobs are jobs of requests running after the response
+	backgroundJobs sync.WaitGroup
 }
 
 func NewAPI(
 	oauthCfgPath string,
 	userSessionsStore *auth.UserSessionStore,
 	db *sqlx.DB,
+	idempotencyTTL time.Duration,
+	rateLimits *RateLimitsConfig,
 ) (*api, error) {
 	var a api
 
@@ -41,6 +67,13 @@ func NewAPI(
 	a.storages.OneOffTokens = &dbauth.OneOffTokenStorage{DB: db}
 	a.storages.TimeSlots = &slots.TimeSlotsStorage{DB: db}
 	a.storages.Bots = &bots.BotsStorage{DB: db}
+	a.storages.Idempotency = &idempotency.IdempotencyStorage{DB: db}
+
+	latestMigration, err := dbase.LatestMigration()
+	if err != nil {
+		return nil, err
+	}
+	a.latestMigration = latestMigration
 
 	oidcUserSignIn, err := newUserSignIn(a.storages.Auth, a.userSessionsStore, oauthCfgPath)
 	if err != nil {
@@ -56,5 +89,68 @@ func NewAPI(
 	userCheck := userCheckWrap{a.storages.Auth, restrictionTable}
 	a.cookieAuth = &CookieAuth{a.userSessionsStore, userCheck}
 
+	a.resourcePicker = common.NewResourcePicker()
+
+	a.idempotencyTTL = idempotencyTTL
+	if a.idempotencyTTL == 0 {
+		a.idempotencyTTL = common.DefaultIdempotencyTTL
+	}
+
+	limits := DefaultRateLimits()
+	if rateLimits != nil {
+		proxies := rateLimits.TrustedProxies
+		if rateLimits.Default != nil || rateLimits.Routes != nil {
+			limits = *rateLimits
+		}
+		limits.TrustedProxies = proxies
+	}
+	a.rateLimiter = newRateLimiter(limits)
+
+	// Background jobs are started last, nothing fails after them and Stop owns them
+	a.holdsSweeper = common.NewPeriodicCallback(common.SlotHoldSweepInterval, a.sweepExpiredHolds)
+	a.requestsSweeper = common.NewPeriodicCallback(common.SlotHoldSweepInterval, a.expireBookingRequests)
+	a.coverageChecker = common.NewPeriodicCallback(common.RuleCoverageCheckInterval, a.checkAllRuleCoverage)
+	a.idempotencySweeper = common.NewPeriodicCallback(common.IdempotencySweepInterval, a.cleanupIdempotencyKeys)
+	for _, job := range []*common.PeriodicCallback{a.holdsSweeper, a.requestsSweeper, a.coverageChecker, a.idempotencySweeper, a.rateLimiter.sweeper} {
+		job.Start()
+	}
+
 	return &a, nil
 }
+
+// Drain makes /readyz fail, so load balancers stop sending requests before shutdown
+func (a *api) Drain() {
+	a.draining.Store(true)
+}
+
+// Stop stops background jobs and waits for running ones. It is called after
+// the server has drained in-flight requests.
+func (a *api) Stop() {
+	jobs := []*common.PeriodicCallback{
+		a.holdsSweeper,
+		a.requestsSweeper,
+		a.coverageChecker,
+		a.idempotencySweeper,
+		a.botTokensSweeper,
+	}
+	if a.rateLimiter != nil {
+		jobs = append(jobs, a.rateLimiter.sweeper)
+	}
+	for _, job := range jobs {
+		if job != nil {
+			job.Stop()
+		}
+	}
+	a.backgroundJobs.Wait()
+}
+
+// runInBackground runs the job of the request after the response. The job keeps values
+// of the request context, such as the request ID, but is not cancelled with it.
+func (a *api) runInBackground(ctx context.Context, job func(ctx context.Context)) {
+	ctx = context.WithoutCancel(ctx)
+	a.backgroundJobs.Add(1)
+	go func() {
+		defer a.backgroundJobs.Done()
+		job(ctx)
+	}()
+}
@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"time"
 
@@ -17,27 +18,141 @@ import (
 	"scheduler/appointment-service/internal/dbase/auth"
 	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
 	botsdb "scheduler/appointment-service/internal/dbase/bots"
+	"scheduler/appointment-service/internal/metrics"
 
 	"github.com/gorilla/mux"
 )
 
 type businessSlotSettingsPayload struct {
-	DefaultChunkMinutes int `json:"default_chunk_minutes"`
-	MaxChunkMinutes     int `json:"max_chunk_minutes"`
+	DefaultChunkMinutes int                  `json:"default_chunk_minutes"`
+	MaxChunkMinutes     int                  `json:"max_chunk_minutes"`
+	Policy              bookingPolicyPayload `json:"policy"`
+	BookingMode         common.BookingMode   `json:"booking_mode,omitempty"`
+	Duration            *durationPayload     `json:"duration,omitempty"`
+	RequiresApproval    bool                 `json:"requires_approval"`
+	PendingBlocksSlot   bool                 `json:"pending_blocks_slot"`
+	// ApprovalTimeoutMinutes of zero means common.DefaultApprovalTimeout
+	ApprovalTimeoutMinutes int `json:"approval_timeout_minutes"`
+	// TimeZone is an IANA name, UTC if empty
+	TimeZone string `json:"time_zone"`
+	// MaxQuerySpanMinutes of zero means common.MaxSlotsQuerySpan
+	MaxQuerySpanMinutes int `json:"max_query_span_minutes"`
+}
+
+type durationPayload struct {
+	MinMinutes  int `json:"min_minutes"`
+	MaxMinutes  int `json:"max_minutes"`
+	StepMinutes int `json:"step_minutes"`
+}
+
+func encodeDuration(r common.DurationRange) *durationPayload {
+	return &durationPayload{
+		MinMinutes:  int(r.Min.Minutes()),
+		MaxMinutes:  int(r.Max.Minutes()),
+		StepMinutes: int(r.Step.Minutes()),
+	}
+}
+
+type bookingPolicyPayload struct {
+	MinLeadMinutes            int  `json:"min_lead_minutes"`
+	MaxHorizonMinutes         int  `json:"max_horizon_minutes"`
+	MaxActiveBookings         int  `json:"max_active_bookings"`
+	MaxBookingsPerDay         int  `json:"max_bookings_per_day"`
+	MaxBookingsPerWeek        int  `json:"max_bookings_per_week"`
+	OneBookingPerDay          bool `json:"one_booking_per_day"`
+	MinGapMinutes             int  `json:"min_gap_minutes"`
+	CancellationCutoffMinutes int  `json:"cancellation_cutoff_minutes"`
+}
+
+type policyViolationsResult struct {
+	problem
+	Violations []common.PolicyViolation `json:"violations"`
 }
 
 func defaultBusinessSlotSettings() slotsdb.BusinessSlotSettings {
 	return slotsdb.BusinessSlotSettings{
 		DefaultChunk: common.DefaultBookingSlotChunk,
 		MaxChunk:     common.DefaultMaxBookingSlotChunk,
+		Mode:         common.BookingChunks,
 	}
 }
 
 func encodeBusinessSlotSettings(settings slotsdb.BusinessSlotSettings) businessSlotSettingsPayload {
-	return businessSlotSettingsPayload{
+	p := settings.Policy
+	out := businessSlotSettingsPayload{
 		DefaultChunkMinutes: int(settings.DefaultChunk.Minutes()),
 		MaxChunkMinutes:     int(settings.MaxChunk.Minutes()),
+		Policy: bookingPolicyPayload{
+			MinLeadMinutes:            int(p.MinLeadTime.Minutes()),
+			MaxHorizonMinutes:         int(p.MaxHorizon.Minutes()),
+			MaxActiveBookings:         p.MaxActiveBookings,
+			MaxBookingsPerDay:         p.MaxBookingsPerDay,
+			MaxBookingsPerWeek:        p.MaxBookingsPerWeek,
+			OneBookingPerDay:          p.OneBookingPerDay,
+			MinGapMinutes:             int(p.MinGap.Minutes()),
+			CancellationCutoffMinutes: int(p.CancellationCutoff.Minutes()),
+		},
+		BookingMode:            settings.Mode,
+		RequiresApproval:       settings.RequiresApproval,
+		PendingBlocksSlot:      settings.PendingBlocksSlot,
+		ApprovalTimeoutMinutes: int(settings.ApprovalTimeout.Minutes()),
+		TimeZone:               settings.TimeZone,
+		MaxQuerySpanMinutes:    int(settings.MaxQuerySpan.Minutes()),
+	}
+	if settings.IsRangeMode() {
+		out.Duration = encodeDuration(settings.Duration)
+	}
+	return out
+}
+
+func decodeBusinessSlotSettings(req businessSlotSettingsPayload) slotsdb.BusinessSlotSettings {
+	p := req.Policy
+	out := slotsdb.BusinessSlotSettings{
+		DefaultChunk: time.Duration(req.DefaultChunkMinutes) * time.Minute,
+		MaxChunk:     time.Duration(req.MaxChunkMinutes) * time.Minute,
+		Policy: common.BookingPolicy{
+			MinLeadTime:        time.Duration(p.MinLeadMinutes) * time.Minute,
+			MaxHorizon:         time.Duration(p.MaxHorizonMinutes) * time.Minute,
+			MaxActiveBookings:  p.MaxActiveBookings,
+			MaxBookingsPerDay:  p.MaxBookingsPerDay,
+			MaxBookingsPerWeek: p.MaxBookingsPerWeek,
+			OneBookingPerDay:   p.OneBookingPerDay,
+			MinGap:             time.Duration(p.MinGapMinutes) * time.Minute,
+			CancellationCutoff: time.Duration(p.CancellationCutoffMinutes) * time.Minute,
+		},
+		Mode:              req.BookingMode,
+		RequiresApproval:  req.RequiresApproval,
+		PendingBlocksSlot: req.PendingBlocksSlot,
+		ApprovalTimeout:   time.Duration(req.ApprovalTimeoutMinutes) * time.Minute,
+		TimeZone:          req.TimeZone,
+		MaxQuerySpan:      time.Duration(req.MaxQuerySpanMinutes) * time.Minute,
+	}
+	if req.Duration != nil {
+		out.Duration = common.DurationRange{
+			Min:  time.Duration(req.Duration.MinMinutes) * time.Minute,
+			Max:  time.Duration(req.Duration.MaxMinutes) * time.Minute,
+			Step: time.Duration(req.Duration.StepMinutes) * time.Minute,
+		}
+	}
+	return out
+}
+
+// getBusinessSlotSettings returns stored settings or defaults if the business has not set them
+func (a *api) getBusinessSlotSettings(businessID common.ID) (slotsdb.BusinessSlotSettings, error) {
+	settings, err := a.storages.TimeSlots.GetBusinessSlotSettings(businessID)
+	if err == sql.ErrNoRows {
+		return defaultBusinessSlotSettings(), nil
 	}
+	return settings, err
+}
+
+// writePolicyViolations responds with 409 and machine-readable reasons
+func writePolicyViolations(w http.ResponseWriter, r *http.Request, violations []common.PolicyViolation) {
+	slog.WarnContext(r.Context(), "booking policy violated", "violations", violations)
+	writeProblemBody(w, r, http.StatusConflict, policyViolationsResult{
+		problem:    newProblem(r, http.StatusConflict, codePolicyViolation, "booking policy violated"),
+		Violations: violations,
+	})
 }
 
 func parseTime(s string) (time.Time, error) {
@@ -78,12 +193,12 @@ func getSlotChunkFromURL(v url.Values, defaults slotsdb.BusinessSlotSettings) (t
 
 	chunkMinutes, err := strconv.Atoi(chunkMinutesStr)
 	if err != nil {
-		return 0, fmt.Errorf("chunk_minutes invalid")
+		return 0, fmt.Errorf("%w: chunk_minutes invalid", common.ErrInvalidArgument)
 	}
 
 	chunk := time.Duration(chunkMinutes) * time.Minute
 	if chunk < common.MinBookingSlotChunk || chunk > defaults.MaxChunk {
-		return 0, fmt.Errorf("chunk_minutes out of range")
+		return 0, fmt.Errorf("%w: %v is not in [%v, %v]", errChunkOutOfRange, chunk, common.MinBookingSlotChunk, defaults.MaxChunk)
 	}
 
 	return chunk, nil
@@ -96,7 +211,7 @@ func (a *api) SlotsBusinessIdGetFunc() http.HandlerFunc {
 		//w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		vars := mux.Vars(r)
 		businessID := vars["business_id"]
-		a.getSlotsByBusinessID(w, r, businessID)
+		a.getSlotsByBusinessID(w, r, businessID, "")
 	}
 }
 
@@ -109,7 +224,7 @@ func (a *api) SlotsBusinessWebAppGetFunc(au AddSlotsAuth) http.HandlerFunc {
 			return
 		}
 
-		a.getSlotsByBusinessID(w, r, string(authResult.Business))
+		a.getSlotsByBusinessID(w, r, string(authResult.Business), authResult.Customer)
 	}
 }
 
@@ -158,12 +273,15 @@ func (a *api) CustomerAppointmentsGetFunc(au AddSlotsAuth) http.HandlerFunc {
 			return
 		}
 
-		var response swagger.AvailableSlots
+		var response customerAppointmentsResponse
 		response.QueryId = r.Context().Value(RequestIdKey{}).(string)
 		for _, appt := range appointments {
-			response.Slots = append(response.Slots, swagger.Slot{
-				TpStart: appt.Start,
-				Len:     int32(appt.End.Sub(appt.Start).Minutes()),
+			response.Slots = append(response.Slots, customerAppointment{
+				Slot: swagger.Slot{
+					TpStart: appt.Start,
+					Len:     int32(appt.End.Sub(appt.Start).Minutes()),
+				},
+				BookingCode: appt.BookingCode,
 			})
 		}
 
@@ -175,74 +293,256 @@ func (a *api) CustomerAppointmentsGetFunc(au AddSlotsAuth) http.HandlerFunc {
 	}
 }
 
-func (a *api) getSlotsByBusinessID(w http.ResponseWriter, r *http.Request, businessID string) {
-	if businessID == "" {
-		slog.WarnContext(r.Context(), "business_id not found")
-		w.WriteHeader(http.StatusBadRequest)
-		return
+type customerAppointment struct {
+	swagger.Slot
+	BookingCode string `json:"booking_code,omitempty"`
+}
+
+type customerAppointmentsResponse struct {
+	QueryId string                `json:"query_id,omitempty"`
+	Slots   []customerAppointment `json:"slots,omitempty"`
+}
+
+// CustomerAppointmentDeleteFunc cancels the customer appointment started at date_start.
+// Freed time is offered to the waitlist.
+func (a *api) CustomerAppointmentDeleteFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[CustomerAppointmentDelete]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		dateStart, err := getTimeFromURL("date_start", r.URL.Query())
+		if err != nil {
+			slog.WarnContext(r.Context(), "[CustomerAppointmentDelete]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		settings, err := a.getBusinessSlotSettings(authResult.Business)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[CustomerAppointmentDelete]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if violations := settings.Policy.CheckCancellation(time.Now(), dateStart); len(violations) != 0 {
+			writePolicyViolations(w, r, violations)
+			return
+		}
+
+		err = a.storages.TimeSlots.CancelAppointment(authResult.Business, authResult.Customer, dateStart)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[CustomerAppointmentDelete]", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		a.evaluateWaitlist(r.Context(), authResult.Business)
+		w.WriteHeader(http.StatusOK)
 	}
+}
 
-	query := r.URL.Query()
-	dateStart, err := getTimeFromURL("date_start", query)
+// getSlotsByBusinessID writes slots allowed by the booking policy of the business.
+// customerID is optional, limits of the customer bookings are checked if it is set.
+func (a *api) getSlotsByBusinessID(w http.ResponseWriter, r *http.Request, businessID string, customerID common.ID) {
+	queryID := r.Context().Value(RequestIdKey{}).(string)
+	response, err := a.businessSlots(businessID, r.URL.Query(), customerID, queryID)
 	if err != nil {
 		slog.WarnContext(r.Context(), err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	dateEnd, err := getTimeFromURL("date_end", query)
+
+	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		slog.WarnContext(r.Context(), err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+}
+
+// businessSlots returns a page of slots of the business for the query: slotsPage or
+// rangeSlotsResponse in the range mode. Errors of the query are common.ErrInvalidArgument.
+func (a *api) businessSlots(businessID common.ID, query url.Values, customerID common.ID, queryID string) (any, error) {
+	if businessID == "" {
+		return nil, fmt.Errorf("%w: business_id not found", common.ErrInvalidArgument)
+	}
 
-	chunkSettings, err := a.storages.TimeSlots.GetBusinessSlotSettings(businessID)
+	pageQuery, err := getSlotsPageFromURL(query)
 	if err != nil {
-		if err != sql.ErrNoRows {
-			slog.WarnContext(r.Context(), err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		chunkSettings = defaultBusinessSlotSettings()
+		return nil, err
+	}
+
+	chunkSettings, err := a.getBusinessSlotSettings(businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := getSlotsRangeFromURL(query, chunkSettings)
+	if err != nil {
+		return nil, err
 	}
 
 	slotChunk, err := getSlotChunkFromURL(query, chunkSettings)
 	if err != nil {
-		slog.WarnContext(r.Context(), err.Error())
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return nil, err
 	}
 
-	slots, err := a.storages.TimeSlots.GetAvailableSlotsInRange(businessID, common.Interval{Start: dateStart, End: dateEnd})
+	resources, err := a.requestedResources(businessID, query)
 	if err != nil {
-		slog.WarnContext(r.Context(), err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("%w: %w", common.ErrInvalidArgument, err)
 	}
 
-	slots = common.ChunkIntervals(slots, slotChunk)
+	defer metrics.AvailabilityTimer("slots").ObserveDuration()
+	byResource, err := a.storages.TimeSlots.GetResourcesAvailabilityInRange(businessID, resources, interval)
+	if err != nil {
+		return nil, err
+	}
 
-	var response swagger.AvailableSlots
-	response.QueryId = r.Context().Value(RequestIdKey{}).(string)
+	now := time.Now()
+	var booked common.Intervals
+	if customerID != "" {
+		booked, err = a.customerBookings(businessID, customerID, chunkSettings.Location(), now)
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	if chunkSettings.IsRangeMode() {
+		slots, free := rangeSlots(byResource, chunkSettings.Duration, chunkSettings.Policy, chunkSettings.Location(), now, booked, customerID != "")
+		slots, nextCursor := pageQuery.page(slots)
+		return rangeSlotsResponse{
+			slotsPage: slotsPage{
+				AvailableSlots: swagger.AvailableSlots{QueryId: queryID, Slots: slots},
+				NextCursor:     nextCursor,
+			},
+			BookingMode: common.BookingRange,
+			Duration:    encodeDuration(chunkSettings.Duration),
+			Free:        pageFree(free, slots),
+		}, nil
+	}
+	slots := allowedSlots(unitedSlots(byResource, slotChunk), chunkSettings.Policy, chunkSettings.Location(), now, booked, customerID != "")
+	slots, nextCursor := pageQuery.page(slots)
+	return slotsPage{
+		AvailableSlots:  swagger.AvailableSlots{QueryId: queryID, Slots: slots},
+		NextCursor:      nextCursor,
+		MaxChunkMinutes: int32(chunkSettings.MaxChunk / time.Minute),
+	}, nil
+}
+
+// rangeSlotsResponse lists free time for variable-length bookings. Slots are the
+// shortest bookings at allowed start times for clients unaware of the range mode.
+type rangeSlotsResponse struct {
+	slotsPage
+	BookingMode common.BookingMode `json:"booking_mode"`
+	Duration    *durationPayload   `json:"duration"`
+	Free        []freeInterval     `json:"free"`
+}
+
+type freeInterval struct {
+	TpStart time.Time   `json:"tp_start"`
+	TpEnd   time.Time   `json:"tp_end"`
+	Starts  []time.Time `json:"starts"`
+}
+
+// rangeSlots returns the shortest bookings allowed by the policy and free intervals of
+// resources with start times of these bookings. Intervals are not united across resources,
+// so any booking fitting one of them can be hosted by a single resource. Equal intervals
+// of several resources are listed once. Group sessions are not offered.
+func rangeSlots(byResource map[common.ID]slotsdb.Availability, duration common.DurationRange,
+	policy common.BookingPolicy, loc *time.Location, now time.Time, booked common.Intervals, withCustomer bool) ([]swagger.Slot, []freeInterval) {
+	startsSet := make(map[time.Time]struct{})
+	intervalStarts := make(map[common.Interval][]time.Time)
+	for _, availability := range byResource {
+		for _, interval := range availability.Free {
+			if _, ok := intervalStarts[interval]; ok {
+				continue
+			}
+			starts := duration.Starts(common.Intervals{interval})
+			intervalStarts[interval] = starts
+			for _, start := range starts {
+				startsSet[start] = struct{}{}
+			}
+		}
+	}
+
+	candidates := make([]swagger.Slot, 0, len(startsSet))
+	for start := range startsSet {
+		candidates = append(candidates, swagger.Slot{TpStart: start, Len: int32(duration.Min.Minutes()), SeatsLeft: 1})
+	}
+	slices.SortFunc(candidates, func(a, b swagger.Slot) int { return a.TpStart.Compare(b.TpStart) })
+	slots := allowedSlots(candidates, policy, loc, now, booked, withCustomer)
+
+	allowed := make(map[time.Time]struct{}, len(slots))
 	for _, slot := range slots {
-		response.Slots = append(response.Slots, swagger.Slot{
-			TpStart: slot.Start,
-			Len:     int32(slot.End.Sub(slot.Start).Minutes()),
-		})
+		allowed[slot.TpStart] = struct{}{}
+	}
+	out := make([]freeInterval, 0, len(intervalStarts))
+	for interval, starts := range intervalStarts {
+		fi := freeInterval{TpStart: interval.Start, TpEnd: interval.End}
+		for _, start := range starts {
+			if _, ok := allowed[start]; ok {
+				fi.Starts = append(fi.Starts, start)
+			}
+		}
+		if len(fi.Starts) != 0 {
+			out = append(out, fi)
+		}
 	}
+	slices.SortFunc(out, func(a, b freeInterval) int {
+		if c := a.TpStart.Compare(b.TpStart); c != 0 {
+			return c
+		}
+		return a.TpEnd.Compare(b.TpEnd)
+	})
+	return slots, out
+}
 
-	err = json.NewEncoder(w).Encode(response)
+// allowedSlots drops slots violating the policy. Customer limits are checked
+// against booked appointments only if withCustomer is set, days and weeks are of loc.
+func allowedSlots(slots []swagger.Slot, policy common.BookingPolicy, loc *time.Location, now time.Time, booked common.Intervals, withCustomer bool) []swagger.Slot {
+	out := make([]swagger.Slot, 0, len(slots))
+	for _, slot := range slots {
+		interval := common.Interval{Start: slot.TpStart, End: slot.TpStart.Add(time.Duration(slot.Len) * time.Minute)}
+		var violations []common.PolicyViolation
+		if withCustomer {
+			violations = policy.CheckBooking(now, loc, booked, common.Intervals{interval})
+		} else {
+			violations = policy.CheckSlot(now, interval)
+		}
+		if len(violations) == 0 {
+			out = append(out, slot)
+		}
+	}
+	return out
+}
+
+// customerBookings returns customer appointments which may affect policy checks:
+// all appointments since the beginning of the current week in loc
+func (a *api) customerBookings(businessID common.ID, customerID common.ID, loc *time.Location, now time.Time) (common.Intervals, error) {
+	weekStart := common.NextMonday(now.In(loc)).AddDate(0, 0, -7)
+	appointments, err := a.storages.TimeSlots.GetCustomerAppointmentsInRange(businessID, customerID, common.Interval{Start: weekStart})
 	if err != nil {
-		slog.WarnContext(r.Context(), err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, err
 	}
+	out := make(common.Intervals, 0, len(appointments))
+	for _, appt := range appointments {
+		out = append(out, appt.Interval)
+	}
+	return out, nil
 }
 
 type AuthResult struct {
 	Business common.ID
 	Customer common.ID
+	// Client is the mini app the customer is authorized by, empty for other channels
+	Client common.ID
 }
 
 type AddSlotsAuth interface {
@@ -316,104 +616,399 @@ func (a AddSlotsAuthTgWebApp) Authorization(r *http.Request) (AuthResult, error)
 
 	result.Business = common.ID(bot.BusinessId)
 	result.Customer = common.ID(strconv.FormatInt(initData.User.ID, 10))
+	result.Client = common.ID(clientID)
 	return result, nil
 }
 
-// TODO Fix it, change swagger.Slot, prepare error, prepare QueryId
-// TODO Bug: May be race condition between GetAvailableSlotsInRange and AddSlots
-func (a *api) SlotsBusinessIdPostFunc(au AddSlotsAuth) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+type bookingRequest struct {
+	auth         AuthResult
+	slots        common.Intervals
+	between      common.Interval
+	strategy     common.PickStrategy
+	serviceID    common.ID
+	availability map[common.ID]slotsdb.Availability
+	answers      common.Answers
+	settings     slotsdb.BusinessSlotSettings
+}
 
-		authResult, err := au.Authorization(r)
-		if err != nil {
-			slog.WarnContext(r.Context(), "[SlotsBusinessIdPost]", "err", err.Error())
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+// parseBookingRequest authorizes the customer, validates requested slots and
+// loads availability of resources able to serve them. Errors are written to w.
+func (a *api) parseBookingRequest(w http.ResponseWriter, r *http.Request, au AddSlotsAuth) (bookingRequest, bool) {
+	var req bookingRequest
 
-		var jsonSlots []swagger.Slot
-		err = json.NewDecoder(r.Body).Decode(&jsonSlots)
-		if err != nil {
-			slog.WarnContext(r.Context(), err.Error())
-			w.WriteHeader(http.StatusBadRequest) //TODO Use http.Error()?
-			return
+	authResult, err := au.Authorization(r)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[SlotsBusinessIdPost]", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return req, false
+	}
+	req.auth = authResult
+	if !a.checkCustomerAllowed(w, r, authResult) {
+		return req, false
+	}
+
+	var payload bookingPayload
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), err.Error())
+		writeError(w, r, fmt.Errorf("%w: %s", errInvalidBody, err.Error()))
+		return req, false
+	}
+	jsonSlots := payload.Slots
+
+	if len(jsonSlots) == 0 {
+		slog.WarnContext(r.Context(), "Missed slots")
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidArgument, "slots are missed")
+		return req, false
+	}
+
+	tpInterval := common.Interval{Start: jsonSlots[0].TpStart, End: jsonSlots[0].TpStart}
+	slots := make(common.Intervals, 0, len(jsonSlots))
+	for i := 0; i < len(jsonSlots); i++ {
+		if jsonSlots[i].TpStart.IsZero() {
+			slog.WarnContext(r.Context(), "Nullable variable")
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidArgument, "tp_start is missed")
+			return req, false
+		}
+		if jsonSlots[i].TpStart.Before(time.Now()) {
+			slog.WarnContext(r.Context(), "slot in the past", slog.Any("slot", jsonSlots[i]))
+			writeError(w, r, errSlotInPast)
+			return req, false
 		}
 
-		if len(jsonSlots) == 0 {
-			slog.WarnContext(r.Context(), "Missed slots")
-			w.WriteHeader(http.StatusBadRequest)
-			return
+		end := jsonSlots[i].TpStart.Add(time.Duration(jsonSlots[i].Len) * time.Minute)
+		slots = append(slots, common.Interval{
+			Start: jsonSlots[i].TpStart,
+			End:   end})
+		if tpInterval.Start.After(jsonSlots[i].TpStart) {
+			tpInterval.Start = jsonSlots[i].TpStart
 		}
+		if tpInterval.End.Before(end) {
+			tpInterval.End = end
+		}
+	}
 
-		tpInterval := common.Interval{Start: jsonSlots[0].TpStart, End: jsonSlots[0].TpStart}
-		slots := make(common.Intervals, 0, len(jsonSlots))
-		for i := 0; i < len(jsonSlots); i++ {
-			if jsonSlots[i].TpStart.IsZero() {
-				slog.WarnContext(r.Context(), "Nullable variable")
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
-			if jsonSlots[i].TpStart.Before(time.Now()) {
-				slog.WarnContext(r.Context(), "slot in the past", slog.Any("slot", jsonSlots[i]))
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
+	slots.SortByStart()
 
-			end := jsonSlots[i].TpStart.Add(time.Duration(jsonSlots[i].Len) * time.Minute)
-			slots = append(slots, common.Interval{
-				Start: jsonSlots[i].TpStart,
-				End:   end})
-			if tpInterval.Start.After(jsonSlots[i].TpStart) {
-				tpInterval.Start = jsonSlots[i].TpStart
-			}
-			if tpInterval.End.Before(end) {
-				tpInterval.End = end
-			}
+	if slots.HasOverlaps() {
+		slog.ErrorContext(r.Context(), "Appointment slots have overlap")
+		writeError(w, r, errSlotsOverlap)
+		return req, false
+	}
+
+	settings, err := a.getBusinessSlotSettings(authResult.Business)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return req, false
+	}
+	req.settings = settings
+
+	maxLen := settings.MaxChunk
+	if settings.IsRangeMode() {
+		maxLen = settings.Duration.Max
+	}
+	// Adjacent chunks are booked as one appointment, separate slots stay separate appointments
+	slots = slots.JoinAdjacent()
+	for _, slot := range slots {
+		if slot.Duration() > maxLen {
+			slog.WarnContext(r.Context(), "Appointment is too long", "max", maxLen)
+			writeError(w, r, fmt.Errorf("%w: longer than %v", errBookingTooLong, maxLen))
+			return req, false
 		}
+		if settings.IsRangeMode() && !settings.Duration.Allows(slot.Duration()) {
+			slog.WarnContext(r.Context(), "Appointment duration is not allowed", "duration", slot.Duration())
+			writeError(w, r, fmt.Errorf("%w: %v", errDurationNotAllowed, slot.Duration()))
+			return req, false
+		}
+	}
 
-		slots.SortByStart()
+	slog.InfoContext(r.Context(), fmt.Sprint(slots))
+	req.slots = slots
+	req.between = tpInterval
 
-		if slots.HasOverlaps() {
-			slog.ErrorContext(r.Context(), "Appointment slots have overlap")
-			w.WriteHeader(http.StatusBadRequest)
-			return
+	now := time.Now()
+	booked, err := a.customerBookings(authResult.Business, authResult.Customer, settings.Location(), now)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return req, false
+	}
+	if violations := settings.Policy.CheckBooking(now, settings.Location(), booked, slots); len(violations) != 0 {
+		writePolicyViolations(w, r, violations)
+		return req, false
+	}
+
+	query := r.URL.Query()
+	req.serviceID = query.Get("service_id")
+	req.strategy, err = common.ParsePickStrategy(query.Get("strategy"))
+	if err != nil {
+		slog.WarnContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return req, false
+	}
+
+	fields, err := a.storages.TimeSlots.GetServiceBookingFields(authResult.Business, req.serviceID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return req, false
+	}
+	answers, fieldErrors := common.ValidateAnswers(fields, payload.Answers)
+	if len(fieldErrors) != 0 {
+		writeFieldErrors(w, r, fieldErrors)
+		return req, false
+	}
+	req.answers = answers
+
+	candidates, err := a.requestedResources(authResult.Business, query)
+	if err != nil {
+		slog.WarnContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return req, false
+	}
+
+	req.availability, err = a.storages.TimeSlots.GetResourcesAvailabilityInRange(authResult.Business, candidates, tpInterval)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return req, false
+	}
+
+	return req, true
+}
+
+// fitResource picks one of resources which free time fits all requested slots.
+// Errors are written to w.
+func (a *api) fitResource(w http.ResponseWriter, r *http.Request, req bookingRequest) (common.ID, bool) {
+	availableSlots := make(map[common.ID]common.Intervals, len(req.availability))
+	for resource, av := range req.availability {
+		if len(av.Free) != 0 {
+			availableSlots[resource] = av.Free
 		}
+	}
 
-		slog.InfoContext(r.Context(), fmt.Sprint(slots))
+	if len(availableSlots) == 0 {
+		slog.WarnContext(r.Context(), "No available slots")
+		writeError(w, r, errSlotUnavailable)
+		return "", false
+	}
 
-		availableSlots, err := a.storages.TimeSlots.GetAvailableSlotsInRange(authResult.Business, tpInterval)
-		if err != nil {
-			slog.ErrorContext(r.Context(), err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	// TODO Not optimal
+	var fitResources []common.ID
+	for resource, available := range availableSlots {
+		if slices.IndexFunc(req.slots, func(el common.Interval) bool { return !available.IsFit(el) }) == -1 {
+			fitResources = append(fitResources, resource)
 		}
+	}
+	if len(fitResources) == 0 {
+		slog.WarnContext(r.Context(), "Conflict with available slot")
+		writeError(w, r, errSlotUnavailable)
+		return "", false
+	}
 
-		if len(availableSlots) == 0 {
-			slog.WarnContext(r.Context(), "No available slots")
-			w.WriteHeader(http.StatusConflict)
+	resource, err := a.pickResource(req.auth.Business, req.serviceID, req.strategy, fitResources, req.between)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return "", false
+	}
+	return resource, true
+}
+
+// SlotsBusinessIdPostFunc books slots for the customer. If the business requires
+// approval the booking is stored as a pending request instead.
+func (a *api) SlotsBusinessIdPostFunc(au AddSlotsAuth) http.HandlerFunc {
+	return a.slotsPostFunc(au, true)
+}
+
+// OwnerSlotsPostFunc books slots on behalf of the business owner, approval is not required
+func (a *api) OwnerSlotsPostFunc(au AddSlotsAuth) http.HandlerFunc {
+	return a.slotsPostFunc(au, false)
+}
+
+// TODO Fix it, change swagger.Slot, prepare error, prepare QueryId
+// TODO Bug: May be race condition between GetAvailableSlotsInRange and AddSlots
+func (a *api) slotsPostFunc(au AddSlotsAuth, withApproval bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		req, ok := a.parseBookingRequest(w, r, au)
+		if !ok {
 			return
 		}
 
-		// TODO Not optimal
-		for _, el := range slots {
-			if !availableSlots.IsFit(el) {
-				slog.WarnContext(r.Context(), "Conflict with available slot")
-				w.WriteHeader(http.StatusConflict)
+		requiresApproval := withApproval && req.settings.RequiresApproval
+		if len(req.slots) == 1 {
+			if sessions := sessionResources(req.availability, req.slots[0]); len(sessions) != 0 {
+				resource, ok := a.pickSession(w, r, req, sessions)
+				if !ok {
+					return
+				}
+				if requiresApproval {
+					a.requestBooking(w, r, req, resource, sessions[resource].Capacity)
+					return
+				}
+				a.bookSeat(w, r, req, resource, sessions[resource])
 				return
 			}
 		}
 
-		a.storages.TimeSlots.AddSlots(slotsdb.AddSlotsData{
-			Business: authResult.Business,
-			Customer: authResult.Customer,
-			Slots:    slots,
+		resource, ok := a.fitResource(w, r, req)
+		if !ok {
+			return
+		}
+
+		if requiresApproval {
+			a.requestBooking(w, r, req, resource, 0)
+			return
+		}
+
+		err := a.storages.TimeSlots.AddSlots(slotsdb.AddSlotsData{
+			Business: req.auth.Business,
+			Customer: req.auth.Customer,
+			Resource: resource,
+			Slots:    req.slots,
+			Answers:  req.answers,
 		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "AddSlots", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
 
-		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(bookingResult{ResourceId: resource}); err != nil {
+			slog.WarnContext(r.Context(), "[SlotsBusinessIdPost] encode", "err", err.Error())
+		}
 	}
 }
 
+type pendingBookingResult struct {
+	RequestId  common.ID `json:"request_id"`
+	Status     string    `json:"status"`
+	ResourceId common.ID `json:"resource_id,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+const bookingStatusPending = "pending"
+
+// approvalExpiresAt returns the time when pending request made at now expires
+func approvalExpiresAt(settings slotsdb.BusinessSlotSettings, now time.Time) time.Time {
+	timeout := settings.ApprovalTimeout
+	if timeout == 0 {
+		timeout = common.DefaultApprovalTimeout
+	}
+	return now.Add(timeout)
+}
+
+func writePendingBooking(w http.ResponseWriter, r *http.Request, request slotsdb.BookingRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusAccepted)
+	result := pendingBookingResult{
+		RequestId:  request.Id,
+		Status:     bookingStatusPending,
+		ResourceId: request.Resource,
+		ExpiresAt:  request.ExpiresAt.UTC(),
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.WarnContext(r.Context(), "[SlotsBusinessIdPost] encode", "err", err.Error())
+	}
+}
+
+// requestBooking stores the booking as pending until the owner approves it.
+// capacity is set if a seat of the group session is requested.
+// Only one slot is requested at once, separate slots are decided one by one.
+func (a *api) requestBooking(w http.ResponseWriter, r *http.Request, req bookingRequest, resource common.ID, capacity int) {
+	if len(req.slots) != 1 {
+		slog.WarnContext(r.Context(), "Separate slots require approval", "slots", len(req.slots))
+		writeError(w, r, fmt.Errorf("%w: booking of %d separate slots can not be requested", common.ErrInvalidArgument, len(req.slots)))
+		return
+	}
+
+	request := slotsdb.BookingRequest{
+		Business:   req.auth.Business,
+		Customer:   req.auth.Customer,
+		Resource:   resource,
+		Interval:   req.slots[0],
+		Answers:    req.answers,
+		BlocksSlot: req.settings.PendingBlocksSlot && capacity == 0,
+		Capacity:   capacity,
+		ExpiresAt:  approvalExpiresAt(req.settings, time.Now()),
+	}
+	id, err := a.storages.TimeSlots.AddBookingRequest(request)
+	if err != nil {
+		slog.WarnContext(r.Context(), "AddBookingRequest", "err", err.Error())
+		writeError(w, r, err)
+		return
+	}
+	request.Id = id
+	writePendingBooking(w, r, request)
+}
+
+type bookingResult struct {
+	ResourceId common.ID `json:"resource_id,omitempty"`
+	Seat       *int      `json:"seat,omitempty"`
+}
+
+// pickSession picks one of resources having the group session. Errors are written to w.
+func (a *api) pickSession(w http.ResponseWriter, r *http.Request, req bookingRequest, sessions map[common.ID]slotsdb.Session) (common.ID, bool) {
+	candidates := make([]common.ID, 0, len(sessions))
+	for resource := range sessions {
+		candidates = append(candidates, resource)
+	}
+
+	resource, err := a.pickResource(req.auth.Business, req.serviceID, req.strategy, candidates, req.between)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return "", false
+	}
+	return resource, true
+}
+
+// bookSeat books a seat of the group session on the resource
+func (a *api) bookSeat(w http.ResponseWriter, r *http.Request, req bookingRequest, resource common.ID, session slotsdb.Session) {
+	seat, err := a.storages.TimeSlots.BookSeat(slotsdb.BookSeatData{
+		Business: req.auth.Business,
+		Customer: req.auth.Customer,
+		Resource: resource,
+		Session:  session,
+		Answers:  req.answers,
+	})
+	if err != nil {
+		slog.WarnContext(r.Context(), "BookSeat", "err", err.Error())
+		writeError(w, r, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(bookingResult{ResourceId: resource, Seat: &seat}); err != nil {
+		slog.WarnContext(r.Context(), "[SlotsBusinessIdPost] encode", "err", err.Error())
+	}
+}
+
+// pickResource selects resource for the booking. Load of resources is counted
+// for the whole days covered by the booking.
+func (a *api) pickResource(businessID common.ID, serviceID common.ID, strategy common.PickStrategy, candidates []common.ID, booking common.Interval) (common.ID, error) {
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	var load map[common.ID]time.Duration
+	if strategy == common.PickLeastLoaded {
+		days := common.Interval{
+			Start: common.DayBeginning(booking.Start),
+			End:   common.DayBeginning(booking.End).AddDate(0, 0, 1),
+		}
+		var err error
+		load, err = a.storages.TimeSlots.GetResourcesLoad(businessID, days)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return a.resourcePicker.Pick(businessID+"/"+serviceID, strategy, candidates, load)
+}
+
 func (a *api) GetBusinessSlotSettingsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := GetUserID(r.Context())
@@ -421,14 +1016,11 @@ func (a *api) GetBusinessSlotSettingsHandler() http.HandlerFunc {
 			panic("uid not found")
 		}
 
-		settings, err := a.storages.TimeSlots.GetBusinessSlotSettings(uid)
+		settings, err := a.getBusinessSlotSettings(uid)
 		if err != nil {
-			if err != sql.ErrNoRows {
-				slog.WarnContext(r.Context(), "GetBusinessSlotSettings", "err", err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			settings = defaultBusinessSlotSettings()
+			slog.WarnContext(r.Context(), "GetBusinessSlotSettings", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -454,11 +1046,7 @@ func (a *api) SetBusinessSlotSettingsHandler() http.HandlerFunc {
 			return
 		}
 
-		settings := slotsdb.BusinessSlotSettings{
-			DefaultChunk: time.Duration(req.DefaultChunkMinutes) * time.Minute,
-			MaxChunk:     time.Duration(req.MaxChunkMinutes) * time.Minute,
-		}
-
+		settings := decodeBusinessSlotSettings(req)
 		if err := a.storages.TimeSlots.SetBusinessSlotSettings(uid, settings); err != nil {
 			slog.WarnContext(r.Context(), "SetBusinessSlotSettings", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
@@ -1,8 +1,15 @@
 package api
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	swagger "scheduler/appointment-service/api/types"
+	common "scheduler/appointment-service/internal"
 	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+	"strings"
 	"testing"
 	"time"
 )
@@ -42,3 +49,98 @@ func TestGetSlotChunkFromURL(t *testing.T) {
 		})
 	}
 }
+
+func TestRangeSlots(t *testing.T) {
+	start := time.Now().UTC().Truncate(time.Hour).Add(24 * time.Hour)
+	byResource := map[common.ID]slotsdb.Availability{
+		"r1": {Free: common.Intervals{{Start: start, End: start.Add(time.Hour)}}},
+		"r2": {Free: common.Intervals{{Start: start.Add(30 * time.Minute), End: start.Add(90 * time.Minute)}}},
+	}
+	duration := common.DurationRange{Min: 30 * time.Minute, Max: 2 * time.Hour, Step: 15 * time.Minute}
+
+	slots, free := rangeSlots(byResource, duration, common.BookingPolicy{}, time.UTC, time.Now(), nil, false)
+	if len(slots) != 5 || slots[0].Len != 30 {
+		t.Fatalf("unexpected slots: %v", slots)
+	}
+	// Free time is not united across resources: 0-90 can not be hosted by any of them
+	if len(free) != 2 || !free[0].TpStart.Equal(start) || !free[0].TpEnd.Equal(start.Add(time.Hour)) || len(free[0].Starts) != 3 ||
+		!free[1].TpStart.Equal(start.Add(30*time.Minute)) || !free[1].TpEnd.Equal(start.Add(90*time.Minute)) || len(free[1].Starts) != 3 {
+		t.Fatalf("unexpected free intervals: %+v", free)
+	}
+
+	byResource["r3"] = byResource["r1"]
+	if _, free := rangeSlots(byResource, duration, common.BookingPolicy{}, time.UTC, time.Now(), nil, false); len(free) != 2 {
+		t.Fatalf("equal free intervals of resources must be listed once: %+v", free)
+	}
+
+	now := time.Now()
+	policy := common.BookingPolicy{MaxHorizon: start.Add(20 * time.Minute).Sub(now)}
+	slots, free = rangeSlots(byResource, duration, policy, time.UTC, now, nil, false)
+	if len(slots) == 0 || len(slots) >= 5 || len(free) != 1 || len(free[0].Starts) != len(slots) {
+		t.Fatalf("policy must limit starts: %v %+v", slots, free)
+	}
+}
+
+func TestAllowedSlotsBusinessDay(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 20:00 UTC is the next day in Tokyo, the same day as the candidate slot
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(48 * time.Hour)
+	booked := common.Intervals{{Start: day.Add(20 * time.Hour), End: day.Add(21 * time.Hour)}}
+	candidates := []swagger.Slot{{TpStart: day.Add(24 * time.Hour), Len: 60}}
+	policy := common.BookingPolicy{OneBookingPerDay: true}
+
+	if slots := allowedSlots(candidates, policy, time.UTC, time.Now(), booked, true); len(slots) != 1 {
+		t.Fatalf("slot on the next UTC day must be allowed: %v", slots)
+	}
+	if slots := allowedSlots(candidates, policy, loc, time.Now(), booked, true); len(slots) != 0 {
+		t.Fatalf("slot on the same business day must be rejected: %v", slots)
+	}
+}
+
+func TestSlotsPostJoinsAdjacentSlots(t *testing.T) {
+	a := newTestAPI(t)
+
+	workStart := tomorrow().Add(9 * time.Hour)
+	addWorkingHours(t, a, "b1", workStart, 1, 4*time.Hour)
+
+	book := func(starts ...time.Time) *httptest.ResponseRecorder {
+		slots := make([]string, 0, len(starts))
+		for _, start := range starts {
+			slots = append(slots, fmt.Sprintf(`{"tp_start":%q,"len":30}`, start.Format(time.RFC3339)))
+		}
+		req := httptest.NewRequest("POST", "/slots?customer_id=c1", bytes.NewBufferString("["+strings.Join(slots, ",")+"]"))
+		w := httptest.NewRecorder()
+		a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{})(w, withBusiness(req))
+		return w
+	}
+
+	// Adjacent chunks are one appointment, a separate slot is another one
+	if w := book(workStart, workStart.Add(30*time.Minute), workStart.Add(2*time.Hour)); w.Code != http.StatusOK {
+		t.Fatalf("expected booking, got %d %s", w.Code, w.Body)
+	}
+	appointments, err := a.storages.TimeSlots.GetBusinessAppointmentsInRange("b1", common.Interval{Start: workStart, End: workStart.Add(4 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []common.Interval{
+		{Start: workStart, End: workStart.Add(time.Hour)},
+		{Start: workStart.Add(2 * time.Hour), End: workStart.Add(150 * time.Minute)},
+	}
+	if len(appointments) != len(expected) {
+		t.Fatalf("unexpected appointments %+v", appointments)
+	}
+	for i, appointment := range appointments {
+		if !appointment.Interval.Start.Equal(expected[i].Start) || !appointment.Interval.End.Equal(expected[i].End) {
+			t.Fatalf("unexpected appointment %+v, expected %v", appointment, expected[i])
+		}
+	}
+
+	// Adjacent chunks longer than the max chunk
+	w := book(workStart.Add(150*time.Minute), workStart.Add(3*time.Hour), workStart.Add(210*time.Minute))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected too long booking to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+type bookingRequestPayload struct {
+	Id         common.ID      `json:"id"`
+	CustomerId common.ID      `json:"customer_id"`
+	ResourceId common.ID      `json:"resource_id"`
+	TpStart    time.Time      `json:"tp_start"`
+	Len        int32          `json:"len"`
+	Answers    common.Answers `json:"answers,omitempty"`
+	BlocksSlot bool           `json:"blocks_slot"`
+	Capacity   int            `json:"capacity,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+}
+
+type rejectPayload struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+func encodeBookingRequest(in slotsdb.BookingRequest) bookingRequestPayload {
+	return bookingRequestPayload{
+		Id:         in.Id,
+		CustomerId: in.Customer,
+		ResourceId: in.Resource,
+		TpStart:    in.Interval.Start.UTC(),
+		Len:        int32(in.Interval.Duration().Minutes()),
+		Answers:    in.Answers,
+		BlocksSlot: in.BlocksSlot,
+		Capacity:   in.Capacity,
+		CreatedAt:  in.CreatedAt.UTC(),
+		ExpiresAt:  in.ExpiresAt.UTC(),
+	}
+}
+
+// BookingRequestsGetHandler lists pending bookings of the business
+func (a *api) BookingRequestsGetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		requests, err := a.storages.TimeSlots.GetBookingRequests(uid)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[BookingRequestsGet]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		out := make([]bookingRequestPayload, 0, len(requests))
+		for _, request := range requests {
+			out = append(out, encodeBookingRequest(request))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			slog.WarnContext(r.Context(), "[BookingRequestsGet] encode", "err", err.Error())
+		}
+	}
+}
+
+// BookingRequestApproveHandler turns the pending booking into an appointment
+func (a *api) BookingRequestApproveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		request, err := a.storages.TimeSlots.ApproveBookingRequest(uid, mux.Vars(r)["id"])
+		if err != nil {
+			slog.WarnContext(r.Context(), "[BookingRequestApprove]", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(bookingResult{ResourceId: request.Resource}); err != nil {
+			slog.WarnContext(r.Context(), "[BookingRequestApprove] encode", "err", err.Error())
+		}
+	}
+}
+
+// BookingRequestRejectHandler removes the pending booking, the comment is passed to the customer
+func (a *api) BookingRequestRejectHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req rejectPayload
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.WarnContext(r.Context(), "[BookingRequestReject] decode", "err", err.Error())
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		request, err := a.storages.TimeSlots.RejectBookingRequest(uid, mux.Vars(r)["id"], req.Comment)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[BookingRequestReject]", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
+
+		if request.BlocksSlot {
+			a.evaluateWaitlist(r.Context(), uid)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// expireBookingRequests removes requests the owner has not acted on in time.
+// Customers are notified by the storage, slots released by blocking requests
+// are offered to the waitlist.
+func (a *api) expireBookingRequests() {
+	expired, err := a.storages.TimeSlots.ExpireBookingRequests(time.Now())
+	if err != nil {
+		slog.Warn("[ExpireBookingRequests]", "err", err.Error())
+		return
+	}
+
+	released := make(map[common.ID]struct{})
+	for _, request := range expired {
+		if request.BlocksSlot {
+			released[request.Business] = struct{}{}
+		}
+	}
+	for business := range released {
+		a.evaluateWaitlist(context.Background(), business)
+	}
+	slog.Debug("[ExpireBookingRequests]", "expired", len(expired))
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSlotsPostRequiresApproval(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	workStart := day.Add(9 * time.Hour)
+	addWorkingHours(t, a, "b1", workStart, 1, 3*time.Hour)
+	settings := defaultBusinessSlotSettings()
+	settings.RequiresApproval = true
+	settings.PendingBlocksSlot = true
+	if err := a.storages.TimeSlots.SetBusinessSlotSettings("b1", settings); err != nil {
+		t.Fatal(err)
+	}
+
+	book := func(handler http.HandlerFunc, customer string, start time.Time) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`[{"tp_start":%q,"len":60}]`, start.Format(time.RFC3339))
+		req := httptest.NewRequest("POST", "/slots?customer_id="+customer, bytes.NewBufferString(body))
+		req = withBusiness(req)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	w := book(a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{}), "c1", workStart)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected pending booking, got %d", w.Code)
+	}
+	var pending pendingBookingResult
+	if err := json.NewDecoder(w.Body).Decode(&pending); err != nil || pending.Status != bookingStatusPending || pending.RequestId == "" {
+		t.Fatalf("unexpected pending result: %+v %v", pending, err)
+	}
+	if d := time.Until(pending.ExpiresAt); d <= 0 || d > common.DefaultApprovalTimeout {
+		t.Fatalf("unexpected expiration: %v", pending.ExpiresAt)
+	}
+
+	// Pending request blocks the slot
+	w = book(a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{}), "c2", workStart)
+	var p problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil || w.Code != http.StatusConflict || p.Code != codeSlotUnavailable {
+		t.Fatalf("expected conflict with pending request, got %d %+v", w.Code, p)
+	}
+
+	// Owner books without approval
+	if w := book(a.OwnerSlotsPostFunc(AddSlotsAuthFromUrl{}), "c2", workStart.Add(time.Hour)); w.Code != http.StatusOK {
+		t.Fatalf("expected owner booking, got %d", w.Code)
+	}
+
+	decide := func(action string, id string) int {
+		req := httptest.NewRequest("POST", "/booking_requests/"+id+"/"+action, nil)
+		req = withBusiness(req)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		if action == "approve" {
+			a.BookingRequestApproveHandler()(w, req)
+		} else {
+			a.BookingRequestRejectHandler()(w, req)
+		}
+		return w.Code
+	}
+	if code := decide("approve", pending.RequestId); code != http.StatusOK {
+		t.Fatalf("approve: %d", code)
+	}
+	if code := decide("reject", pending.RequestId); code != http.StatusNotFound {
+		t.Fatalf("decided request must be removed, got %d", code)
+	}
+
+	busy, err := a.storages.TimeSlots.GetBusySlotsInRange("b1", common.Interval{Start: day, End: day.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 2 {
+		t.Fatalf("unexpected appointments: %v", busy)
+	}
+}
+
+func TestSlotsPostApprovalRejectsSeparateSlots(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	workStart := day.Add(9 * time.Hour)
+	addWorkingHours(t, a, "b1", workStart, 1, 3*time.Hour)
+	settings := defaultBusinessSlotSettings()
+	settings.RequiresApproval = true
+	if err := a.storages.TimeSlots.SetBusinessSlotSettings("b1", settings); err != nil {
+		t.Fatal(err)
+	}
+
+	body := fmt.Sprintf(`[{"tp_start":%q,"len":60},{"tp_start":%q,"len":60}]`,
+		workStart.Format(time.RFC3339), workStart.Add(2*time.Hour).Format(time.RFC3339))
+	req := httptest.NewRequest("POST", "/slots?customer_id=c1", bytes.NewBufferString(body))
+	req = withBusiness(req)
+	w := httptest.NewRecorder()
+	a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{})(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("separate slots can not wait for approval together, got %d", w.Code)
+	}
+
+	requests, err := a.storages.TimeSlots.GetBookingRequests("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("no requests expected: %+v", requests)
+	}
+}
+
+func TestSeatBookingRequiresApproval(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	classStart := day.Add(18 * time.Hour)
+	class := dailyRule(t, classStart, 1, time.Hour, common.Inclusion)
+	class.Capacity = 2
+	if _, err := a.storages.TimeSlots.AddBusinessRule("b1", class); err != nil {
+		t.Fatal(err)
+	}
+	settings := defaultBusinessSlotSettings()
+	settings.RequiresApproval = true
+	settings.PendingBlocksSlot = true
+	if err := a.storages.TimeSlots.SetBusinessSlotSettings("b1", settings); err != nil {
+		t.Fatal(err)
+	}
+
+	book := func(handler http.HandlerFunc, customer string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`[{"tp_start":%q,"len":60}]`, classStart.Format(time.RFC3339))
+		req := httptest.NewRequest("POST", "/slots?customer_id="+customer, bytes.NewBufferString(body))
+		req = withBusiness(req)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	approve := func(id string) int {
+		req := httptest.NewRequest("POST", "/booking_requests/"+id+"/approve", nil)
+		req = withBusiness(req)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		a.BookingRequestApproveHandler()(w, req)
+		return w.Code
+	}
+	pendingID := func(w *httptest.ResponseRecorder) string {
+		t.Helper()
+		var pending pendingBookingResult
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected pending seat booking, got %d %s", w.Code, w.Body.String())
+		}
+		if err := json.NewDecoder(w.Body).Decode(&pending); err != nil || pending.RequestId == "" {
+			t.Fatalf("unexpected pending result: %+v %v", pending, err)
+		}
+		return pending.RequestId
+	}
+	appointments := func() int {
+		busy, err := a.storages.TimeSlots.GetBusySlotsInRange("b1", common.Interval{Start: day, End: day.Add(24 * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(busy)
+	}
+
+	first := pendingID(book(a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{}), "c1"))
+	// Seat requests do not block the session
+	second := pendingID(book(a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{}), "c2"))
+	if n := appointments(); n != 0 {
+		t.Fatalf("seat must not be booked before approval, got %d appointments", n)
+	}
+
+	if code := approve(first); code != http.StatusOK {
+		t.Fatalf("approve: %d", code)
+	}
+	// Owner books the last seat without approval
+	if w := book(a.OwnerSlotsPostFunc(AddSlotsAuthFromUrl{}), "c3"); w.Code != http.StatusOK {
+		t.Fatalf("expected owner seat booking, got %d", w.Code)
+	}
+	if code := approve(second); code != http.StatusConflict {
+		t.Fatalf("session is full, expected conflict, got %d", code)
+	}
+	if n := appointments(); n != 2 {
+		t.Fatalf("unexpected appointments: %d", n)
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+// customerBlockedReason is returned to blocked customers so clients can show a localized message
+const customerBlockedReason = string(codeCustomerBlocked)
+
+// refusedResult keeps reason of earlier responses along with the problem code
+type refusedResult struct {
+	problem
+	Reason string `json:"reason"`
+}
+
+// checkCustomerAllowed responds with 403 if the business blocked the customer
+func (a *api) checkCustomerAllowed(w http.ResponseWriter, r *http.Request, auth AuthResult) bool {
+	err := a.storages.TimeSlots.CheckCustomerAllowed(auth.Business, auth.Customer)
+	if err == nil {
+		return true
+	}
+
+	slog.WarnContext(r.Context(), "[CheckCustomerAllowed]", "err", err.Error())
+	if !errors.Is(err, slotsdb.ErrCustomerBlocked) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	writeProblemBody(w, r, http.StatusForbidden, refusedResult{
+		problem: newProblem(r, http.StatusForbidden, codeCustomerBlocked, "the business does not accept bookings of the customer"),
+		Reason:  customerBlockedReason,
+	})
+	return false
+}
+
+type checkInPayload struct {
+	BookingCode string            `json:"booking_code,omitempty"`
+	CustomerId  common.ID         `json:"customer_id,omitempty"`
+	TpStart     time.Time         `json:"tp_start,omitempty"`
+	Attendance  common.Attendance `json:"attendance,omitempty"`
+}
+
+type checkInResult struct {
+	CustomerId  common.ID         `json:"customer_id"`
+	ResourceId  common.ID         `json:"resource_id,omitempty"`
+	TpStart     time.Time         `json:"tp_start"`
+	Len         int32             `json:"len"`
+	BookingCode string            `json:"booking_code"`
+	Attendance  common.Attendance `json:"attendance"`
+}
+
+// CheckInHandler marks the customer attended or no-show. The appointment is found
+// by booking_code or by customer_id with tp_start, attendance defaults to attended.
+func (a *api) CheckInHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req checkInPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "[CheckIn] decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Attendance == "" {
+			req.Attendance = common.Attended
+		}
+
+		slot, err := a.storages.TimeSlots.MarkAttendance(slotsdb.CheckIn{
+			Business:    uid,
+			BookingCode: req.BookingCode,
+			Customer:    req.CustomerId,
+			Start:       req.TpStart,
+			Attendance:  req.Attendance,
+		}, time.Now())
+		if err != nil {
+			slog.WarnContext(r.Context(), "[CheckIn]", "err", err.Error())
+			switch {
+			case errors.Is(err, common.ErrInvalidArgument):
+				w.WriteHeader(http.StatusBadRequest)
+			case errors.Is(err, common.ErrNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		result := checkInResult{
+			CustomerId:  slot.Customer,
+			ResourceId:  slot.Resource,
+			TpStart:     slot.Start.UTC(),
+			Len:         int32(slot.Duration().Minutes()),
+			BookingCode: slot.BookingCode,
+			Attendance:  slot.Attendance,
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.WarnContext(r.Context(), "[CheckIn] encode", "err", err.Error())
+		}
+	}
+}
+
+type blockedCustomerPayload struct {
+	CustomerId common.ID  `json:"customer_id,omitempty"`
+	Reason     string     `json:"reason"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+func GetBlocklistHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		blocked, err := s.GetBlockedCustomers(uid)
+		if err != nil {
+			slog.WarnContext(r.Context(), "GetBlocklist", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		out := make([]blockedCustomerPayload, 0, len(blocked))
+		for _, b := range blocked {
+			createdAt := b.CreatedAt.UTC()
+			out = append(out, blockedCustomerPayload{CustomerId: b.Customer, Reason: b.Reason, CreatedAt: &createdAt})
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			slog.WarnContext(r.Context(), "GetBlocklist encode", "err", err.Error())
+		}
+	}
+}
+
+// BlockCustomerHandler adds the customer to the blocklist, the body with a reason is optional
+func BlockCustomerHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req blockedCustomerPayload
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.WarnContext(r.Context(), "BlockCustomer decode", "err", err.Error())
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := s.BlockCustomer(uid, mux.Vars(r)["customer_id"], req.Reason); err != nil {
+			slog.WarnContext(r.Context(), "BlockCustomer", "err", err.Error())
+			if errors.Is(err, common.ErrInvalidArgument) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func UnblockCustomerHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		if err := s.UnblockCustomer(uid, mux.Vars(r)["customer_id"]); err != nil {
+			slog.WarnContext(r.Context(), "UnblockCustomer", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBlockedCustomerBooking(t *testing.T) {
+	a := newTestAPI(t)
+
+	start := tomorrow().Add(9 * time.Hour)
+	addWorkingHours(t, a, "b1", start, 1, 3*time.Hour)
+
+	req := httptest.NewRequest("PUT", "/blocklist/c1", bytes.NewBufferString(`{"reason":"no-shows"}`))
+	req = mux.SetURLVars(withBusiness(req), map[string]string{"customer_id": "c1"})
+	w := httptest.NewRecorder()
+	BlockCustomerHandler(a.storages.TimeSlots)(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("block: %d", w.Code)
+	}
+
+	body := fmt.Sprintf(`[{"tp_start":%q,"len":60}]`, start.Format(time.RFC3339))
+	req = withBusiness(httptest.NewRequest("POST", "/slots?customer_id=c1", bytes.NewBufferString(body)))
+	w = httptest.NewRecorder()
+	a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{})(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("blocked customer booking: %d", w.Code)
+	}
+	var refused refusedResult
+	if err := json.NewDecoder(w.Body).Decode(&refused); err != nil || refused.Reason != customerBlockedReason {
+		t.Fatalf("unexpected refusal: %+v %v", refused, err)
+	}
+
+	req = mux.SetURLVars(withBusiness(httptest.NewRequest("DELETE", "/blocklist/c1", nil)), map[string]string{"customer_id": "c1"})
+	w = httptest.NewRecorder()
+	UnblockCustomerHandler(a.storages.TimeSlots)(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unblock: %d", w.Code)
+	}
+
+	req = withBusiness(httptest.NewRequest("POST", "/slots?customer_id=c1", bytes.NewBufferString(body)))
+	w = httptest.NewRecorder()
+	a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{})(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unblocked customer booking: %d", w.Code)
+	}
+
+	busy, err := a.storages.TimeSlots.GetCustomerAppointmentsInRange("b1", "c1", common.Interval{Start: start})
+	if err != nil || len(busy) != 1 {
+		t.Fatalf("unexpected appointments: %v %v", busy, err)
+	}
+
+	// Check-in before the start is allowed, no-show is not
+	checkIn := func(attendance common.Attendance) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"booking_code":%q,"attendance":%q}`, busy[0].BookingCode, attendance)
+		req := withBusiness(httptest.NewRequest("POST", "/appointments/check_in", bytes.NewBufferString(body)))
+		w := httptest.NewRecorder()
+		a.CheckInHandler()(w, req)
+		return w
+	}
+	if w := checkIn(common.NoShow); w.Code != http.StatusBadRequest {
+		t.Fatalf("no-show before start: %d", w.Code)
+	}
+	w = checkIn(common.Attended)
+	if w.Code != http.StatusOK {
+		t.Fatalf("check in: %d", w.Code)
+	}
+	var result checkInResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || result.CustomerId != "c1" || result.Attendance != common.Attended {
+		t.Fatalf("unexpected check in result: %+v %v", result, err)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+
+	"github.com/gorilla/mux"
+)
+
+type attentionItemPayload struct {
+	BookingCode string    `json:"booking_code"`
+	CustomerId  common.ID `json:"customer_id"`
+	ResourceId  common.ID `json:"resource_id,omitempty"`
+	TpStart     time.Time `json:"tp_start"`
+	Len         int32     `json:"len"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+type reschedulePayload struct {
+	TpStart time.Time `json:"tp_start"`
+}
+
+// checkRuleCoverage updates attention items of the business, errors are only logged
+func (a *api) checkRuleCoverage(ctx context.Context, businessID common.ID) {
+	items, err := a.storages.TimeSlots.CheckRuleCoverage(businessID, time.Now())
+	if err != nil {
+		slog.WarnContext(ctx, "[CheckRuleCoverage]", "business", businessID, "err", err.Error())
+		return
+	}
+	if len(items) != 0 {
+		slog.InfoContext(ctx, "[CheckRuleCoverage] appointments need attention", "business", businessID, "count", len(items))
+	}
+}
+
+func (a *api) checkAllRuleCoverage() {
+	open, err := a.storages.TimeSlots.CheckAllRuleCoverage(time.Now())
+	if err != nil {
+		slog.Warn("[CheckAllRuleCoverage]", "err", err.Error())
+	}
+	slog.Debug("[CheckAllRuleCoverage]", "open", open)
+}
+
+func (a *api) AttentionItemsGetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		items, err := a.storages.TimeSlots.GetAttentionItems(uid)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[AttentionItemsGet]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		out := make([]attentionItemPayload, 0, len(items))
+		for _, item := range items {
+			out = append(out, attentionItemPayload{
+				BookingCode: item.BookingCode,
+				CustomerId:  item.Customer,
+				ResourceId:  item.Resource,
+				TpStart:     item.Interval.Start.UTC(),
+				Len:         int32(item.Interval.Duration().Minutes()),
+				DetectedAt:  item.DetectedAt.UTC(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			slog.WarnContext(r.Context(), "[AttentionItemsGet] encode", "err", err.Error())
+		}
+	}
+}
+
+// AttentionKeepHandler accepts the appointment outside working time, it is not reported again
+func (a *api) AttentionKeepHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		if err := a.storages.TimeSlots.KeepAttentionItem(uid, mux.Vars(r)["code"]); err != nil {
+			slog.WarnContext(r.Context(), "[AttentionKeep]", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// AttentionRescheduleHandler moves the appointment into working time and notifies the customer
+func (a *api) AttentionRescheduleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req reschedulePayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "[AttentionReschedule] decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		slot, err := a.storages.TimeSlots.RescheduleAppointment(uid, mux.Vars(r)["code"], req.TpStart, time.Now())
+		if err != nil {
+			slog.WarnContext(r.Context(), "[AttentionReschedule]", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
+		// The previous time may be wanted by the waitlist
+		a.evaluateWaitlist(r.Context(), uid)
+
+		result := appointmentResult{
+			CustomerId:  slot.Customer,
+			ResourceId:  slot.Resource,
+			TpStart:     slot.Start.UTC(),
+			Len:         int32(slot.Duration().Minutes()),
+			BookingCode: slot.BookingCode,
+			Attendance:  slot.Attendance,
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.WarnContext(r.Context(), "[AttentionReschedule] encode", "err", err.Error())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRuleDeletionNeedsAttention(t *testing.T) {
+	a := newTestAPI(t)
+
+	workStart := tomorrow().Add(9 * time.Hour)
+	ruleID := addWorkingHours(t, a, "b1", workStart, 1, 3*time.Hour)
+	err := a.storages.TimeSlots.AddSlots(slotsdb.AddSlotsData{
+		Business: "b1",
+		Customer: "c1",
+		Slots:    common.Intervals{{Start: workStart, End: workStart.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getItems := func() []attentionItemPayload {
+		t.Helper()
+		w := httptest.NewRecorder()
+		a.AttentionItemsGetHandler()(w, withBusiness(httptest.NewRequest("GET", "/attention", nil)))
+		var items []attentionItemPayload
+		if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+			t.Fatal(err)
+		}
+		return items
+	}
+
+	req := mux.SetURLVars(withBusiness(httptest.NewRequest("DELETE", "/rrules/"+ruleID, nil)), map[string]string{"id": ruleID})
+	w := httptest.NewRecorder()
+	DelBusinessRuleHandler(a.storages.TimeSlots, a)(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete rule: %d", w.Code)
+	}
+	a.backgroundJobs.Wait()
+
+	items := getItems()
+	if len(items) != 1 || items[0].CustomerId != "c1" || !items[0].TpStart.Equal(workStart) || items[0].BookingCode == "" {
+		t.Fatalf("orphaned appointment must need attention: %+v", items)
+	}
+
+	keep := func(code string) int {
+		req := mux.SetURLVars(withBusiness(httptest.NewRequest("POST", "/attention/"+code+"/keep", nil)), map[string]string{"code": code})
+		w := httptest.NewRecorder()
+		a.AttentionKeepHandler()(w, req)
+		return w.Code
+	}
+	if code := keep(items[0].BookingCode); code != http.StatusOK {
+		t.Fatalf("keep: %d", code)
+	}
+	if code := keep(items[0].BookingCode); code != http.StatusNotFound {
+		t.Fatalf("kept item: %d", code)
+	}
+	if items := getItems(); len(items) != 0 {
+		t.Fatalf("kept item must be hidden: %+v", items)
+	}
+}
@@ -73,7 +73,11 @@ func AuthHandler(authMethod AuthorizationMethod, next http.Handler, noAuth http.
 			return
 		}
 
-		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), UserIdKey{}, uid)))
+		r = r.WithContext(context.WithValue(r.Context(), UserIdKey{}, uid))
+		if !checkPendingRateLimits(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
 	}
 }
 
@@ -110,10 +114,10 @@ func PasswordLoginHandler(ses *auth.UserSessionStore, ac AuthUserCheck) http.Han
 			case errors.Is(err, common.ErrNotFound), errors.Is(err, common.ErrUnauthorized):
 				session.DelAuthStatus()
 				session.Save(r, w)
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "")
 			case errors.Is(err, ErrSecurityRestriction):
 				// TODO need notification about brute force attack
-				http.Error(w, "please try later", http.StatusTooManyRequests)
+				writeProblem(w, r, http.StatusTooManyRequests, codeSecurityRestriction, "please try later")
 			default:
 				w.WriteHeader(http.StatusInternalServerError)
 				slog.WarnContext(r.Context(), "[LoginHandler] user check", "err", err.Error())
@@ -127,7 +131,7 @@ func PasswordLoginHandler(ses *auth.UserSessionStore, ac AuthUserCheck) http.Han
 		if err != nil {
 		}
 
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized) //TODO?
+		writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "") //TODO?
 	}
 }
 
@@ -178,7 +182,7 @@ func DeleteUserHandler(store *auth.UserSessionStore, deleteUser func(common.ID,
 		err = store.Reset(w, r)
 		if err != nil {
 			slog.WarnContext(r.Context(), "[DeleteUserHandler]", "err", err.Error())
-			http.Error(w, "Remove session", http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, codeInternal, "remove session")
 			return
 		}
 
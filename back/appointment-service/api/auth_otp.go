@@ -53,7 +53,7 @@ func ValidateOTPassword(sesStore *auth.UserSessionStore, secretStore OTPSecretGe
 		passcode := r.PostForm.Get("passcode")
 		if passcode == "" {
 			slog.DebugContext(r.Context(), "[ValidateOTPassword] passcode not found")
-			http.Error(w, "passcode not found", http.StatusBadRequest)
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidArgument, "passcode not found")
 			return
 		}
 
@@ -70,10 +70,10 @@ func ValidateOTPassword(sesStore *auth.UserSessionStore, secretStore OTPSecretGe
 			return
 		} else if status == auth.StatusAuthenticated {
 			slog.WarnContext(r.Context(), "[ValidateOTPassword] already authorized")
-			http.Error(w, "already authorized?", http.StatusBadRequest)
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidArgument, "already authorized")
 		} else if status != auth.Status2faRequired {
 			slog.WarnContext(r.Context(), "[ValidateOTPassword]", "unexpected status", status)
-			http.Error(w, "login step missed?", http.StatusUnauthorized)
+			writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "login step missed")
 			return
 		}
 
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	swagger "scheduler/appointment-service/api/types"
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+// bookingPayload is a body of booking requests. Plain array of slots
+// is accepted for clients which do not send answers to booking fields.
+type bookingPayload struct {
+	Slots   []swagger.Slot `json:"slots"`
+	Answers common.Answers `json:"answers"`
+}
+
+func (p *bookingPayload) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) != 0 && trimmed[0] == '[' {
+		p.Answers = nil
+		return json.Unmarshal(trimmed, &p.Slots)
+	}
+	type plain bookingPayload
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+type fieldErrorsResult struct {
+	problem
+	Errors []common.FieldError `json:"errors"`
+}
+
+// writeFieldErrors responds with 400 and wrong answers to booking fields
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, errs []common.FieldError) {
+	slog.WarnContext(r.Context(), "invalid booking field answers", "errors", errs)
+	writeProblemBody(w, r, http.StatusBadRequest, fieldErrorsResult{
+		problem: newProblem(r, http.StatusBadRequest, codeInvalidAnswers, "invalid booking field answers"),
+		Errors:  errs,
+	})
+}
+
+type appointmentResult struct {
+	CustomerId common.ID      `json:"customer_id"`
+	ResourceId common.ID      `json:"resource_id,omitempty"`
+	Seat       int            `json:"seat"`
+	TpStart    time.Time      `json:"tp_start"`
+	Len        int32          `json:"len"`
+	Answers    common.Answers `json:"answers,omitempty"`
+	SeriesId   common.ID      `json:"series_id,omitempty"`
+	// BookingCode is shown to the customer and used at check-in
+	BookingCode string            `json:"booking_code,omitempty"`
+	Attendance  common.Attendance `json:"attendance,omitempty"`
+}
+
+func AddBookingFieldHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req common.BookingField
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "AddBookingField decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.AddBookingField(uid, req)
+		if err != nil {
+			slog.WarnContext(r.Context(), "AddBookingField", "err", err.Error())
+			if errors.Is(err, common.ErrInvalidArgument) || errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(idResult{Id: id}); err != nil {
+			slog.WarnContext(r.Context(), "AddBookingField encode", "err", err.Error())
+		}
+	}
+}
+
+func GetBookingFieldsHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		fields, err := s.GetBookingFields(uid)
+		if err != nil {
+			slog.WarnContext(r.Context(), "GetBookingFields", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(fields); err != nil {
+			slog.WarnContext(r.Context(), "GetBookingFields encode", "err", err.Error())
+		}
+	}
+}
+
+func DeleteBookingFieldHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		if err := s.DeleteBookingField(uid, mux.Vars(r)["id"]); err != nil {
+			slog.WarnContext(r.Context(), "DeleteBookingField", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// PublicBookingFieldsGetFunc returns the booking form of the service,
+// without service_id only business-wide fields are returned
+func PublicBookingFieldsGetFunc(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fields, err := s.GetServiceBookingFields(mux.Vars(r)["business_id"], r.URL.Query().Get("service_id"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "PublicBookingFieldsGet", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(fields); err != nil {
+			slog.WarnContext(r.Context(), "PublicBookingFieldsGet encode", "err", err.Error())
+		}
+	}
+}
+
+// BusinessAppointmentsGetHandler lists appointments of the business between date_start and date_end
+func BusinessAppointmentsGetHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		query := r.URL.Query()
+		dateStart, err := getTimeFromURL("date_start", query)
+		if err != nil {
+			slog.WarnContext(r.Context(), "BusinessAppointmentsGet", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		dateEnd, err := getTimeFromURL("date_end", query)
+		if err != nil || !dateEnd.After(dateStart) {
+			slog.WarnContext(r.Context(), "BusinessAppointmentsGet: invalid date_end")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		appointments, err := s.GetBusinessAppointmentsInRange(uid, common.Interval{Start: dateStart, End: dateEnd})
+		if err != nil {
+			slog.WarnContext(r.Context(), "BusinessAppointmentsGet", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		out := make([]appointmentResult, 0, len(appointments))
+		for _, appt := range appointments {
+			out = append(out, appointmentResult{
+				CustomerId:  appt.Customer,
+				ResourceId:  appt.Resource,
+				Seat:        appt.Seat,
+				TpStart:     appt.Interval.Start.UTC(),
+				Len:         int32(appt.Interval.End.Sub(appt.Interval.Start).Minutes()),
+				Answers:     appt.Answers,
+				SeriesId:    appt.Series,
+				BookingCode: appt.BookingCode,
+				Attendance:  appt.Attendance,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			slog.WarnContext(r.Context(), "BusinessAppointmentsGet encode", "err", err.Error())
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+)
+
+func TestRequiredFieldsEnforced(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	workStart := day.Add(9 * time.Hour)
+	addWorkingHours(t, a, "b1", workStart, 1, 3*time.Hour)
+	_, err := a.storages.TimeSlots.AddBookingField("b1", common.BookingField{Key: "phone", Label: "Phone", Type: common.FieldPhone, Required: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book := func(body string) (int, problem) {
+		req := httptest.NewRequest("POST", "/slots?customer_id=c1", bytes.NewBufferString(body))
+		req = withBusiness(req)
+		w := httptest.NewRecorder()
+		a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{})(w, req)
+		var p problem
+		_ = json.NewDecoder(w.Body).Decode(&p)
+		return w.Code, p
+	}
+	slots := fmt.Sprintf(`[{"tp_start":%q,"len":60}]`, workStart.Format(time.RFC3339))
+
+	// Plain array of slots has no answers to the required field
+	for _, body := range []string{slots, `{"slots":` + slots + `,"answers":{}}`, `{"slots":` + slots + `,"answers":{"phone":"not a phone"}}`} {
+		if code, p := book(body); code != http.StatusBadRequest || p.Code != codeInvalidAnswers {
+			t.Fatalf("%s: invalid answers expected, got %d %+v", body, code, p)
+		}
+	}
+	if code, _ := book(`{"slots":` + slots + `,"answers":{"phone":"+15551234567"}}`); code != http.StatusOK {
+		t.Fatalf("booking with answers expected, got %d", code)
+	}
+}
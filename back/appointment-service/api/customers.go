@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+type customerPayload struct {
+	Id          common.ID                  `json:"id,omitempty"`
+	DisplayName string                     `json:"display_name"`
+	Phone       string                     `json:"phone"`
+	Email       string                     `json:"email"`
+	Language    string                     `json:"language"`
+	TimeZone    string                     `json:"time_zone"`
+	CreatedAt   *time.Time                 `json:"created_at,omitempty"`
+	Identities  []slotsdb.CustomerIdentity `json:"identities,omitempty"`
+	Attended    int                        `json:"attended"`
+	NoShows     int                        `json:"no_shows"`
+}
+
+type mergeCustomersPayload struct {
+	SourceId common.ID `json:"source_id"`
+}
+
+func encodeCustomer(c slotsdb.Customer) customerPayload {
+	createdAt := c.CreatedAt.UTC()
+	return customerPayload{
+		Id:          c.Id,
+		DisplayName: c.DisplayName,
+		Phone:       c.Phone,
+		Email:       c.Email,
+		Language:    c.Language,
+		TimeZone:    c.TimeZone,
+		CreatedAt:   &createdAt,
+		Identities:  c.Identities,
+		Attended:    c.Attended,
+		NoShows:     c.NoShows,
+	}
+}
+
+// customerAuth maps the channel identity of the authorized customer to
+// the business customer, so the same person gets the same customer ID in all channels
+type customerAuth struct {
+	AddSlotsAuth
+	channel slotsdb.CustomerChannel
+	storage *slotsdb.TimeSlotsStorage
+}
+
+func (a *api) customerAuth(channel slotsdb.CustomerChannel, au AddSlotsAuth) customerAuth {
+	return customerAuth{AddSlotsAuth: au, channel: channel, storage: a.storages.TimeSlots}
+}
+
+type authorizedKey struct{}
+
+type authorized struct {
+	result AuthResult
+	err    error
+}
+
+// authorizedCustomer returns the customer authorized before the handler of the route
+func authorizedCustomer(r *http.Request) (AuthResult, bool) {
+	a, ok := r.Context().Value(authorizedKey{}).(authorized)
+	return a.result, ok && a.err == nil
+}
+
+// Authorization returns the result of the authorization done by handler
+func (a customerAuth) Authorization(r *http.Request) (AuthResult, error) {
+	if done, ok := r.Context().Value(authorizedKey{}).(authorized); ok {
+		return done.result, done.err
+	}
+	result, err := a.AddSlotsAuth.Authorization(r)
+	if err != nil {
+		return result, err
+	}
+
+	result.Customer, err = a.storage.ResolveCustomer(result.Business, a.channel, result.Customer)
+	return result, err
+}
+
+// handler authorizes the customer once before the handler of the route, so limits of
+// the client and the customer are checked with authorized keys only.
+// Failed authorization is reported by the route handler.
+func (a customerAuth) handler(route func(AddSlotsAuth) http.HandlerFunc) http.HandlerFunc {
+	next := route(a)
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := a.Authorization(r)
+		r = r.WithContext(context.WithValue(r.Context(), authorizedKey{}, authorized{result, err}))
+		if err == nil && !checkPendingRateLimits(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// GetCustomersHandler looks up customers of the business.
+// Query parameters name, phone, email and channel with external_id are combined with AND.
+func GetCustomersHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		query := r.URL.Query()
+		filter := slotsdb.CustomerFilter{
+			Name:  query.Get("name"),
+			Phone: query.Get("phone"),
+			Email: query.Get("email"),
+		}
+		if channel := query.Get("channel"); channel != "" || query.Get("external_id") != "" {
+			identity := slotsdb.CustomerIdentity{Channel: slotsdb.CustomerChannel(channel), ExternalId: query.Get("external_id")}
+			if err := identity.Channel.Validate(); err != nil || identity.ExternalId == "" {
+				slog.WarnContext(r.Context(), "GetCustomers: channel and external_id are required together")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			filter.Identity = &identity
+		}
+
+		customers, err := s.FindCustomers(uid, filter)
+		if err != nil {
+			slog.WarnContext(r.Context(), "GetCustomers", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		out := make([]customerPayload, 0, len(customers))
+		for _, c := range customers {
+			out = append(out, encodeCustomer(c))
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			slog.WarnContext(r.Context(), "GetCustomers encode", "err", err.Error())
+		}
+	}
+}
+
+func GetCustomerHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		customer, err := s.GetCustomer(uid, mux.Vars(r)["id"])
+		if err != nil {
+			slog.WarnContext(r.Context(), "GetCustomer", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(encodeCustomer(customer)); err != nil {
+			slog.WarnContext(r.Context(), "GetCustomer encode", "err", err.Error())
+		}
+	}
+}
+
+func UpdateCustomerHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req customerPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "UpdateCustomer decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err := s.UpdateCustomer(slotsdb.Customer{
+			Id:          mux.Vars(r)["id"],
+			Business:    uid,
+			DisplayName: req.DisplayName,
+			Phone:       req.Phone,
+			Email:       req.Email,
+			Language:    req.Language,
+			TimeZone:    req.TimeZone,
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "UpdateCustomer", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// MergeCustomersHandler merges the customer from the request body into the customer from the path
+func MergeCustomersHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req mergeCustomersPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SourceId == "" {
+			slog.WarnContext(r.Context(), "MergeCustomers: source_id is required")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err := s.MergeCustomers(uid, mux.Vars(r)["id"], req.SourceId)
+		if err != nil {
+			slog.WarnContext(r.Context(), "MergeCustomers", "err", err.Error())
+			switch {
+			case errors.Is(err, slotsdb.ErrSharedBooking):
+				writeError(w, r, err)
+			case errors.Is(err, common.ErrInvalidArgument):
+				w.WriteHeader(http.StatusBadRequest)
+			case errors.Is(err, common.ErrNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
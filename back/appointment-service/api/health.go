@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"scheduler/appointment-service/internal/dbase"
+	"scheduler/appointment-service/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// healthCheckTimeout bounds checks of one probe request
+const healthCheckTimeout = 2 * time.Second
+
+var errDraining = errors.New("server is shutting down")
+
+type healthResult struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// Health probes are not versioned, they belong to the deployment rather than to the API
+func (a *api) addHealthHandlers(r *mux.Router) {
+	addRoutes(r,
+		Route{
+			"Healthz",
+			"GET",
+			"/healthz",
+			a.HealthzHandler(),
+		},
+		Route{
+			"Readyz",
+			"GET",
+			"/readyz",
+			a.ReadyzHandler(),
+		})
+}
+
+// MetricsRouter serves metrics scraped by Prometheus. They are not authenticated,
+// so the router is served on an internal address apart from Router.
+func (a *api) MetricsRouter() *mux.Router {
+	r := mux.NewRouter()
+	addRoutes(r,
+		Route{
+			"Metrics",
+			"GET",
+			"/metrics",
+			metrics.Handler(),
+		})
+	return r
+}
+
+func (a *api) checkDB(ctx context.Context) error {
+	return a.storages.TimeSlots.PingContext(ctx)
+}
+
+func (a *api) checkMigrations(ctx context.Context) error {
+	return dbase.CheckSchema(ctx, a.storages.TimeSlots.DB, a.latestMigration)
+}
+
+func (a *api) checkNotDraining(context.Context) error {
+	if a.draining.Load() {
+		return errDraining
+	}
+	return nil
+}
+
+// HealthzHandler reports whether the service is alive and the database is reachable
+func (a *api) HealthzHandler() http.HandlerFunc {
+	return healthHandler("Healthz", []healthCheck{
+		{"db", a.checkDB},
+	})
+}
+
+// ReadyzHandler reports whether the service can take requests: the database is reachable,
+// all migrations are applied and the server is not shutting down
+func (a *api) ReadyzHandler() http.HandlerFunc {
+	return healthHandler("Readyz", []healthCheck{
+		{"db", a.checkDB},
+		{"migrations", a.checkMigrations},
+		{"server", a.checkNotDraining},
+	})
+}
+
+// healthHandler runs all checks and responds with 503 if any of them fails
+func healthHandler(name string, checks []healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		result := healthResult{Status: "ok", Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for _, c := range checks {
+			if err := c.check(ctx); err != nil {
+				slog.WarnContext(r.Context(), "["+name+"]", "check", c.name, "err", err.Error())
+				result.Checks[c.name] = err.Error()
+				result.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			result.Checks[c.name] = "ok"
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.WarnContext(r.Context(), "["+name+"] encode", "err", err.Error())
+		}
+	}
+}
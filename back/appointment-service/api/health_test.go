@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"scheduler/appointment-service/internal/dbase"
+)
+
+func TestHealthProbes(t *testing.T) {
+	a := newTestAPI(t)
+	latest, err := dbase.LatestMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.latestMigration = latest
+	r := a.Router()
+	defer a.Stop()
+
+	probe := func(path string) (int, healthResult) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var result healthResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return w.Code, result
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if code, result := probe(path); code != http.StatusOK || result.Status != "ok" {
+			t.Fatalf("%s: %d %+v", path, code, result)
+		}
+	}
+
+	a.latestMigration = latest + 1
+	if code, result := probe("/readyz"); code != http.StatusServiceUnavailable || result.Checks["migrations"] == "ok" {
+		t.Fatalf("pending migration must fail readiness: %d %+v", code, result)
+	}
+	if code, _ := probe("/healthz"); code != http.StatusOK {
+		t.Fatalf("liveness does not depend on migrations: %d", code)
+	}
+	a.latestMigration = latest
+
+	a.Drain()
+	if code, result := probe("/readyz"); code != http.StatusServiceUnavailable || result.Checks["server"] == "ok" {
+		t.Fatalf("draining server must not be ready: %d %+v", code, result)
+	}
+
+	a.storages.TimeSlots.Close()
+	if code, result := probe("/healthz"); code != http.StatusServiceUnavailable || result.Checks["db"] == "ok" {
+		t.Fatalf("closed db must fail liveness: %d %+v", code, result)
+	}
+}
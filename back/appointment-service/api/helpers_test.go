@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+	"scheduler/appointment-service/internal/dbase/test"
+
+	"github.com/teambition/rrule-go"
+)
+
+// newTestAPI returns the api with slots storage on a temporary database
+func newTestAPI(t *testing.T) *api {
+	var a api
+	a.storages.TimeSlots = &slotsdb.TimeSlotsStorage{DB: test.InitTmpDB(t)}
+	a.resourcePicker = common.NewResourcePicker()
+	return &a
+}
+
+// tomorrow returns the beginning of the next UTC day
+func tomorrow() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+func dailyRule(t *testing.T, start time.Time, count int, length time.Duration, ruleType common.IntervalType) common.IntervalRRuleWithType {
+	t.Helper()
+	rr, err := rrule.NewRRule(rrule.ROption{Dtstart: start, Freq: rrule.DAILY, Count: count})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return common.IntervalRRuleWithType{
+		Rule: common.IntervalRRule{RRule: rr, Len: common.Seconds(length / time.Second)},
+		Type: ruleType,
+	}
+}
+
+// addWorkingHours adds daily working time of the business
+func addWorkingHours(t *testing.T, a *api, businessID common.ID, start time.Time, count int, length time.Duration) slotsdb.RuleID {
+	t.Helper()
+	id, err := a.storages.TimeSlots.AddBusinessRule(businessID, dailyRule(t, start, count, length, common.Inclusion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// withBusiness authenticates the request as the owner of the business b1
+func withBusiness(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), UserIdKey{}, "b1"))
+}
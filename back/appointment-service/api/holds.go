@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+type holdResult struct {
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	ResourceId common.ID `json:"resource_id,omitempty"`
+}
+
+// SlotsHoldPostFunc reserves requested slots for common.DefaultSlotHoldTTL
+// so the customer can finish checkout. Body and query are the same as for booking.
+func (a *api) SlotsHoldPostFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		req, ok := a.parseBookingRequest(w, r, au)
+		if !ok {
+			return
+		}
+
+		if len(req.slots) == 1 && len(sessionResources(req.availability, req.slots[0])) != 0 {
+			slog.WarnContext(r.Context(), "[SlotsHoldPost] group session can not be held")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resource, ok := a.fitResource(w, r, req)
+		if !ok {
+			return
+		}
+
+		expiresAt := time.Now().Add(common.DefaultSlotHoldTTL)
+		token, err := a.storages.TimeSlots.AddHold(slotsdb.HoldData{
+			Business:  req.auth.Business,
+			Customer:  req.auth.Customer,
+			Resource:  resource,
+			Slots:     req.slots,
+			ExpiresAt: expiresAt,
+			Answers:   req.answers,
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SlotsHoldPost]", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
+
+		result := holdResult{Token: token, ExpiresAt: expiresAt.UTC().Truncate(time.Second), ResourceId: resource}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.WarnContext(r.Context(), "[SlotsHoldPost] encode", "err", err.Error())
+		}
+	}
+}
+
+// SlotsHoldConfirmFunc turns the hold into appointments
+func (a *api) SlotsHoldConfirmFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SlotsHoldConfirm]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !a.checkCustomerAllowed(w, r, authResult) {
+			return
+		}
+
+		settings, err := a.getBusinessSlotSettings(authResult.Business)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "[SlotsHoldConfirm]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		token := mux.Vars(r)["token"]
+		if settings.RequiresApproval {
+			request, err := a.storages.TimeSlots.RequestHold(authResult.Business, authResult.Customer, token,
+				settings.PendingBlocksSlot, approvalExpiresAt(settings, time.Now()))
+			if err != nil {
+				slog.WarnContext(r.Context(), "[SlotsHoldConfirm] RequestHold", "err", err.Error())
+				writeError(w, r, err)
+				return
+			}
+			writePendingBooking(w, r, request)
+			return
+		}
+
+		resource, err := a.storages.TimeSlots.ConfirmHold(authResult.Business, authResult.Customer, token)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SlotsHoldConfirm]", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(bookingResult{ResourceId: resource}); err != nil {
+			slog.WarnContext(r.Context(), "[SlotsHoldConfirm] encode", "err", err.Error())
+		}
+	}
+}
+
+// SlotsHoldDeleteFunc releases the hold before expiration
+func (a *api) SlotsHoldDeleteFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SlotsHoldDelete]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = a.storages.TimeSlots.CancelHold(authResult.Business, authResult.Customer, mux.Vars(r)["token"])
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SlotsHoldDelete]", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// sweepExpiredHolds removes expired holds. Expired holds are ignored by availability
+// even before removal, so the sweeper only keeps the table small.
+func (a *api) sweepExpiredHolds() {
+	n, err := a.storages.TimeSlots.DeleteExpiredHolds(time.Now())
+	if err != nil {
+		slog.Warn("[SweepExpiredHolds]", "err", err.Error())
+		return
+	}
+	slog.Debug("[SweepExpiredHolds]", "cleared", n)
+}
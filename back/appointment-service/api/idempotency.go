@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestFingerprint identifies the payload of the request
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyPrincipal identifies the caller, so keys of different callers never collide.
+// The middleware runs before authorization of routes: the user of a valid session cookie
+// is taken, otherwise credentials of bots and web apps are. Requests with wrong
+// credentials are refused by authorization and their responses are not stored.
+func (a *api) idempotencyPrincipal(r *http.Request) string {
+	h := sha256.New()
+	if a.userSessionsStore != nil {
+		if uid, err := a.userSessionsStore.AuthenticationCheck(r); err == nil {
+			h.Write([]byte("user"))
+			h.Write([]byte{0})
+			h.Write([]byte(uid))
+			return hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	for _, part := range []string{"credentials", r.Header.Get("Authorization"), r.Header.Get("X-Client-ID")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isStoredStatus reports whether the response is replayed on retries. Server errors and
+// requests refused before they are handled (authorization, rate limits) can be retried.
+func isStoredStatus(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// recordingResponseWriter keeps the status and the body to store them with the key
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// IdempotentRequests replays the stored response of a mutating request repeated with the
+// same Idempotency-Key by the same caller. The key with another payload is refused with 422.
+// Server errors, 401, 403 and 429 are not stored, so such requests can be retried with the same key.
+func (a *api) IdempotentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || !isMutatingMethod(r.Method) || a.storages.Idempotency == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidArgument, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[Idempotency] read body", "err", err.Error())
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidBody, "")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := r.Method + " " + r.URL.Path + " " + a.idempotencyPrincipal(r)
+		fingerprint := requestFingerprint(r, body)
+		entry, started, err := a.storages.Idempotency.Begin(key, scope, fingerprint, time.Now().Add(a.idempotencyTTL))
+		if err != nil {
+			slog.WarnContext(r.Context(), "[Idempotency] begin", "err", err.Error())
+			writeProblem(w, r, http.StatusInternalServerError, codeInternal, "")
+			return
+		}
+
+		if !started {
+			switch {
+			case entry.Fingerprint != fingerprint:
+				writeProblem(w, r, http.StatusUnprocessableEntity, codeIdempotencyKeyReused,
+					"Idempotency-Key is already used with another request")
+			case entry.IsPending():
+				writeProblem(w, r, http.StatusConflict, codeIdempotencyInProgress,
+					"request with the Idempotency-Key is in progress")
+			default:
+				slog.InfoContext(r.Context(), "[Idempotency] replay", "key", key, "status", entry.Status)
+				if entry.ContentType != "" {
+					w.Header().Set("Content-Type", entry.ContentType)
+				}
+				w.Header().Set(idempotentReplayedHeader, "true")
+				w.WriteHeader(entry.Status)
+				w.Write(entry.Body)
+			}
+			return
+		}
+
+		rw := &recordingResponseWriter{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := a.storages.Idempotency.Release(key, scope); err != nil {
+				slog.WarnContext(r.Context(), "[Idempotency] release", "err", err.Error())
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		if !isStoredStatus(rw.status) {
+			return
+		}
+		err = a.storages.Idempotency.Complete(key, scope, rw.status, rw.Header().Get("Content-Type"), rw.body.Bytes())
+		if err != nil {
+			slog.WarnContext(r.Context(), "[Idempotency] complete", "err", err.Error())
+			return
+		}
+		completed = true
+	})
+}
+
+func (a *api) cleanupIdempotencyKeys() {
+	n, err := a.storages.Idempotency.CleanupExpired()
+	if err != nil {
+		slog.Warn("[CleanupIdempotencyKeys]", "err", err.Error())
+		return
+	}
+	slog.Debug("[CleanupIdempotencyKeys]", "cleared", n)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"scheduler/appointment-service/internal/auth"
+	"scheduler/appointment-service/internal/dbase/idempotency"
+	"scheduler/appointment-service/internal/dbase/test"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+func TestIdempotentRequests(t *testing.T) {
+	var a api
+	a.storages.Idempotency = &idempotency.IdempotencyStorage{DB: test.InitTmpDB(t)}
+	a.idempotencyTTL = time.Hour
+
+	calls := 0
+	status := http.StatusOK
+	r := mux.NewRouter()
+	addRoutes(r, Route{"Book", "POST", "/slots", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Write([]byte(`{"booked":true}`))
+	})})
+	r.Use(PassRequestIdToCtx)
+	r.Use(a.IdempotentRequests)
+	r.Use(ProblemResponses)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/slots", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("k1", `[{"len":60}]`)
+	repeat := post("k1", `[{"len":60}]`)
+	if calls != 1 || repeat.Code != http.StatusOK || repeat.Body.String() != first.Body.String() ||
+		repeat.Header().Get(idempotentReplayedHeader) != "true" || repeat.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatalf("stored response expected: calls %d, %d %q %v", calls, repeat.Code, repeat.Body.String(), repeat.Header())
+	}
+
+	if w := post("k1", `[{"len":30}]`); w.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Fatalf("reused key must be refused: %d, calls %d", w.Code, calls)
+	}
+
+	// Client errors are replayed, server errors are not stored
+	status = http.StatusConflict
+	post("k2", "{}")
+	if w := post("k2", "{}"); w.Code != http.StatusConflict || calls != 2 {
+		t.Fatalf("stored conflict expected: %d, calls %d", w.Code, calls)
+	}
+	status = http.StatusInternalServerError
+	post("k3", "{}")
+	post("k3", "{}")
+	if calls != 4 {
+		t.Fatalf("failed request must be retried: calls %d", calls)
+	}
+
+	post("", "{}")
+	post("", "{}")
+	if calls != 6 {
+		t.Fatalf("requests without the key are not deduplicated: calls %d", calls)
+	}
+}
+
+func TestIdempotencyKeysScopedToCaller(t *testing.T) {
+	var a api
+	a.storages.Idempotency = &idempotency.IdempotencyStorage{DB: test.InitTmpDB(t)}
+	a.idempotencyTTL = time.Hour
+	a.userSessionsStore = auth.NewUserSessionStore(sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef")))
+
+	calls := 0
+	status := http.StatusOK
+	r := mux.NewRouter()
+	addRoutes(r, Route{"Book", "POST", "/slots", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	})})
+	r.Use(a.IdempotentRequests)
+
+	sessionCookie := func(uid string) string {
+		w := httptest.NewRecorder()
+		if err := a.userSessionsStore.Authenticate(uid, w, httptest.NewRequest("GET", "/", nil)); err != nil {
+			t.Fatal(err)
+		}
+		return w.Header().Get("Set-Cookie")
+	}
+	post := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/slots", bytes.NewBufferString("{}"))
+		req.Header.Set(idempotencyKeyHeader, "k1")
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, caller := range [][2]string{
+		{"Authorization", "Bearer bot1"},
+		{"Authorization", "Bearer bot2"},
+		{"Cookie", sessionCookie("owner1")},
+		{"Cookie", sessionCookie("owner2")},
+	} {
+		if w := post(caller[0], caller[1]); w.Header().Get(idempotentReplayedHeader) != "" || calls != i+1 {
+			t.Fatalf("key of caller %d must not replay responses of others: calls %d", i, calls)
+		}
+	}
+	if w := post("Cookie", sessionCookie("owner1")); w.Header().Get(idempotentReplayedHeader) != "true" || calls != 4 {
+		t.Fatalf("new cookie of the same user must replay the response: calls %d", calls)
+	}
+
+	// Refused requests are retried after the caller re-authenticates
+	for _, status = range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests} {
+		before := calls
+		post("Authorization", "Bearer refused")
+		post("Authorization", "Bearer refused")
+		if calls != before+2 {
+			t.Fatalf("status %d must not be stored: calls %d", status, calls-before)
+		}
+	}
+}
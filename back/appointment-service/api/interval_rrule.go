@@ -2,7 +2,9 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
@@ -16,12 +18,34 @@ type RRuleWithType = common.IntervalRRuleWithType
 type RRuleResult = slots.DbBusinessRule
 
 type RRuleStorageI interface {
-	AddBusinessRule(user common.ID, rule RRuleWithType) (slots.RuleID, error)
+	AddResourceRule(user common.ID, resource common.ID, rule RRuleWithType) (slots.RuleID, error)
 	DeleteBusinessRule(user common.ID, ruleId common.ID) error
 	GetBusinessRules(user common.ID) ([]RRuleResult, error)
 }
 
-func AddBusinessRuleHandler(rs RRuleStorageI) http.HandlerFunc {
+// RulesObserver is notified after working time of the business is changed
+type RulesObserver interface {
+	RuleAdded(ctx context.Context, businessID common.ID, rule RRuleWithType)
+	RuleDeleted(ctx context.Context, businessID common.ID)
+}
+
+// RuleAdded evaluates the waitlist after working time of the business is extended.
+// Appointments left outside working time are looked for in the background.
+func (a *api) RuleAdded(ctx context.Context, businessID common.ID, rule RRuleWithType) {
+	if rule.Type == common.Inclusion {
+		a.evaluateWaitlist(ctx, businessID)
+	}
+	a.runInBackground(ctx, func(ctx context.Context) { a.checkRuleCoverage(ctx, businessID) })
+}
+
+// RuleDeleted evaluates the waitlist, deleted exclusion frees working time of the business.
+// Appointments left outside working time are looked for in the background.
+func (a *api) RuleDeleted(ctx context.Context, businessID common.ID) {
+	a.evaluateWaitlist(ctx, businessID)
+	a.runInBackground(ctx, func(ctx context.Context) { a.checkRuleCoverage(ctx, businessID) })
+}
+
+func AddBusinessRuleHandler(rs RRuleStorageI, observer RulesObserver) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := GetUserID(r.Context())
 		if !ok {
@@ -31,7 +55,7 @@ func AddBusinessRuleHandler(rs RRuleStorageI) http.HandlerFunc {
 		bodyBytes, err := io.ReadAll(r.Body)
 		if err != nil {
 			slog.WarnContext(r.Context(), "Read HTTP body", "err", err.Error())
-			http.Error(w, "Invalid body", http.StatusBadRequest)
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidBody, "")
 			return
 		}
 		defer func() {
@@ -47,16 +71,26 @@ func AddBusinessRuleHandler(rs RRuleStorageI) http.HandlerFunc {
 		var rule RRuleWithType
 		if err := json.Unmarshal(bodyBytes, &rule); err != nil {
 			slog.WarnContext(r.Context(), "decoding JSON", "err", err.Error())
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidBody, "invalid JSON")
 			return
 		}
 
-		_, err = rs.AddBusinessRule(uid, rule)
+		// Without resource_id the rule belongs to the business-wide calendar
+		_, err = rs.AddResourceRule(uid, r.URL.Query().Get("resource_id"), rule)
 		if err != nil {
 			slog.WarnContext(r.Context(), "AddRule", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				writeProblem(w, r, http.StatusBadRequest, codeUnknownResource, "")
+				return
+			}
+			if errors.Is(err, common.ErrInvalidArgument) {
+				writeProblem(w, r, http.StatusBadRequest, codeInvalidArgument, "invalid rule")
+				return
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		observer.RuleAdded(r.Context(), uid, rule)
 
 		w.WriteHeader(http.StatusOK)
 	}
@@ -86,7 +120,7 @@ func GetBusinessRulesHandler(rs RRuleStorageI) http.HandlerFunc {
 	}
 }
 
-func DelBusinessRuleHandler(rs RRuleStorageI) http.HandlerFunc {
+func DelBusinessRuleHandler(rs RRuleStorageI, observer RulesObserver) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := GetUserID(r.Context())
 		if !ok {
@@ -102,6 +136,7 @@ func DelBusinessRuleHandler(rs RRuleStorageI) http.HandlerFunc {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		observer.RuleDeleted(r.Context(), uid)
 
 		w.WriteHeader(http.StatusOK)
 	}
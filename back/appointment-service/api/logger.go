@@ -4,18 +4,106 @@ import (
 	"log/slog"
 	"net/http"
 	"time"
+
+	"scheduler/appointment-service/internal/metrics"
+
+	"github.com/gorilla/mux"
 )
 
-// TODO Expand this to middleware. Remove "name" ? Add function for logging for each handler
-func Logger(inner http.Handler, name string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+// unmatchedRoute names requests not matching any route
+const unmatchedRoute = "Unmatched"
+
+// bookingRoutes are routes whose outcomes are counted as bookings
+var bookingRoutes = map[string]struct{}{
+	"SlotsBusinessIdPost":           {},
+	"SlotsBusinessIdPostOneOff":     {},
+	"SlotsBusinessIdPostFromBot":    {},
+	"SlotsBusinessIdPostFromWebApp": {},
+	"SlotsHoldConfirmFromBot":       {},
+	"SlotsHoldConfirmFromWebApp":    {},
+	"SeriesPostFromBot":             {},
+	"SeriesPostFromWebApp":          {},
+}
+
+// accessLogWriter records the status and the size of the response
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+		return route.GetName()
+	}
+	return unmatchedRoute
+}
+
+func bookingOutcome(status int) string {
+	switch {
+	case status == http.StatusAccepted:
+		return metrics.BookingPending
+	case status < http.StatusBadRequest:
+		return metrics.BookingBooked
+	case status == http.StatusConflict:
+		return metrics.BookingConflict
+	case status < http.StatusInternalServerError:
+		return metrics.BookingRejected
+	default:
+		return metrics.BookingFailed
+	}
+}
+
+// AccessLog logs requests after they are served with the status, size and duration
+// and records request metrics by the route name. Replayed idempotent responses are
+// not counted as bookings again.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		slog.InfoContext(r.Context(), "request",
+		lw := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		duration := time.Since(start)
+
+		status := lw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := routeName(r)
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		slog.Log(r.Context(), level, "request",
 			"method", r.Method,
 			"uri", r.RequestURI,
-			"request", name,
-			"start", time.Since(start))
+			"request", route,
+			"status", status,
+			"size", lw.size,
+			"duration", duration)
 
-		inner.ServeHTTP(w, r)
-	}
+		metrics.ObserveRequest(route, r.Method, status, duration)
+		if _, ok := bookingRoutes[route]; ok && lw.Header().Get(idempotentReplayedHeader) == "" {
+			metrics.ObserveBooking(route, bookingOutcome(status))
+		}
+	})
 }
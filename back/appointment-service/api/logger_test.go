@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"scheduler/appointment-service/internal/metrics"
+)
+
+func TestAccessLogAndMetrics(t *testing.T) {
+	a := newTestAPI(t)
+	r := a.Router()
+	defer a.Stop()
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	day := time.Now().Format(time.DateOnly)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/v1/slots/b1/summary?date_from="+day+"&date_to="+day, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/no_such_path", nil))
+
+	line := logs.String()
+	for _, want := range []string{"request=SlotsSummaryGet", "status=200", "size=", "duration=", "request=Unmatched", "status=404"} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("%q expected in access log: %s", want, line)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("metrics are not expected on the API router: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	a.MetricsRouter().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`scheduler_http_requests_total{method="GET",route="SlotsSummaryGet",status="200"}`,
+		`scheduler_http_requests_total{method="GET",route="Unmatched",status="404"}`,
+		`scheduler_http_request_duration_seconds_bucket{method="GET",route="SlotsSummaryGet",status="200"`,
+		`scheduler_availability_computation_seconds_count{query="summary"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("%s expected in metrics", want)
+		}
+	}
+}
+
+func TestBookingOutcome(t *testing.T) {
+	tests := map[int]string{
+		http.StatusOK:                  metrics.BookingBooked,
+		http.StatusAccepted:            metrics.BookingPending,
+		http.StatusConflict:            metrics.BookingConflict,
+		http.StatusBadRequest:          metrics.BookingRejected,
+		http.StatusInternalServerError: metrics.BookingFailed,
+	}
+	for status, outcome := range tests {
+		if got := bookingOutcome(status); got != outcome {
+			t.Fatalf("%d: expected %s, got %s", status, outcome, got)
+		}
+	}
+}
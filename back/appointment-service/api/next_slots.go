@@ -0,0 +1,308 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	swagger "scheduler/appointment-service/api/types"
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+	"scheduler/appointment-service/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultNextSlotsCount = 1
+	maxNextSlotsCount     = 50
+	// The first search window, every next window is twice as long
+	nextSlotsFirstWindow = 24 * time.Hour
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// slotFilter limits found slots to weekdays and a time of day window in the location.
+// Empty weekdays allow any day, zero window allows any time.
+type slotFilter struct {
+	Location *time.Location
+	Weekdays []time.Weekday
+	// Minutes since midnight, the slot must start not before From and end not after To
+	From int
+	To   int
+}
+
+func (f slotFilter) isZeroWindow() bool {
+	return f.From == 0 && f.To == 0
+}
+
+func (f slotFilter) Match(slot swagger.Slot) bool {
+	start := slot.TpStart.In(f.Location)
+	if len(f.Weekdays) != 0 && !slices.Contains(f.Weekdays, start.Weekday()) {
+		return false
+	}
+	if f.isZeroWindow() {
+		return true
+	}
+	from := start.Hour()*60 + start.Minute()
+	return from >= f.From && from+int(slot.Len) <= f.To
+}
+
+// parseTimeOfDay parses "HH:MM" into minutes since midnight, "24:00" is allowed
+func parseTimeOfDay(s string) (int, error) {
+	hourStr, minStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("%w: time of day %q", common.ErrInvalidArgument, s)
+	}
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil {
+		return 0, fmt.Errorf("%w: time of day %q", common.ErrInvalidArgument, s)
+	}
+	minute, err := strconv.Atoi(minStr)
+	if err != nil || minute < 0 || minute > 59 || hour < 0 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("%w: time of day %q", common.ErrInvalidArgument, s)
+	}
+	return hour*60 + minute, nil
+}
+
+// getSlotFilterFromURL parses the filter, loc is used if tz is not set
+func getSlotFilterFromURL(v url.Values, loc *time.Location) (slotFilter, error) {
+	f := slotFilter{Location: loc}
+	if tz := v.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return f, fmt.Errorf("%w: tz: %s", common.ErrInvalidArgument, err.Error())
+		}
+		f.Location = loc
+	}
+
+	if days := v.Get("weekdays"); days != "" {
+		for _, name := range strings.Split(days, ",") {
+			day, ok := weekdayNames[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return f, fmt.Errorf("%w: weekdays: unknown day %q", common.ErrInvalidArgument, name)
+			}
+			f.Weekdays = append(f.Weekdays, day)
+		}
+	}
+
+	from, to := v.Get("time_from"), v.Get("time_to")
+	if from == "" && to == "" {
+		return f, nil
+	}
+	f.To = 24 * 60
+	var err error
+	if from != "" {
+		if f.From, err = parseTimeOfDay(from); err != nil {
+			return f, err
+		}
+	}
+	if to != "" {
+		if f.To, err = parseTimeOfDay(to); err != nil {
+			return f, err
+		}
+	}
+	if f.From >= f.To {
+		return f, fmt.Errorf("%w: time_from must be before time_to", common.ErrInvalidArgument)
+	}
+	return f, nil
+}
+
+func getNextSlotsCountFromURL(v url.Values) (int, error) {
+	countStr := v.Get("count")
+	if countStr == "" {
+		return defaultNextSlotsCount, nil
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 || count > maxNextSlotsCount {
+		return 0, fmt.Errorf("%w: count must be between 1 and %d", common.ErrInvalidArgument, maxNextSlotsCount)
+	}
+	return count, nil
+}
+
+// nextSlotsHorizon returns the end of the search: the policy horizon or common.DefaultNextSlotsHorizon
+func nextSlotsHorizon(policy common.BookingPolicy, now time.Time) time.Time {
+	if policy.MaxHorizon != 0 {
+		return now.Add(policy.MaxHorizon)
+	}
+	return now.Add(common.DefaultNextSlotsHorizon)
+}
+
+// bookableSlots returns slots allowed by the policy, the shortest bookings in the range mode
+func bookableSlots(byResource map[common.ID]slotsdb.Availability, settings slotsdb.BusinessSlotSettings, chunk time.Duration,
+	now time.Time, booked common.Intervals, withCustomer bool) []swagger.Slot {
+	if settings.IsRangeMode() {
+		slots, _ := rangeSlots(byResource, settings.Duration, settings.Policy, settings.Location(), now, booked, withCustomer)
+		return slots
+	}
+	return allowedSlots(unitedSlots(byResource, chunk), settings.Policy, settings.Location(), now, booked, withCustomer)
+}
+
+// nextWindowStart returns where the window after the end continues the search. Free intervals
+// reaching the end are cut by the window, the next window starts at the earliest of them
+// (and of intervals crossing that start) to chunk them again from their real starts.
+func nextWindowStart(byResource map[common.ID]slotsdb.Availability, end time.Time) time.Time {
+	start := end
+	for moved := true; moved; {
+		moved = false
+		for _, availability := range byResource {
+			for _, interval := range availability.Free {
+				if interval.Start.Before(start) && !interval.End.Before(start) {
+					start, moved = interval.Start, true
+				}
+			}
+		}
+	}
+	return start
+}
+
+// findNextSlots searches count slots matching the filter after the time. Every window
+// continues the search where the previous one ended and is twice as long, until
+// enough slots are found or the horizon is reached.
+func (a *api) findNextSlots(businessID common.ID, resources []common.ID, settings slotsdb.BusinessSlotSettings, chunk time.Duration,
+	after time.Time, count int, filter slotFilter, now time.Time, booked common.Intervals, withCustomer bool) ([]swagger.Slot, error) {
+	defer metrics.AvailabilityTimer("next").ObserveDuration()
+	horizon := nextSlotsHorizon(settings.Policy, now)
+	out := make([]swagger.Slot, 0, count)
+	for window, start := nextSlotsFirstWindow, after; start.Before(horizon); window *= 2 {
+		between := common.Interval{Start: start, End: start.Add(window)}
+		if between.End.After(horizon) {
+			between.End = horizon
+		}
+
+		byResource, err := a.storages.TimeSlots.GetResourcesAvailabilityInRange(businessID, resources, between)
+		if err != nil {
+			return nil, err
+		}
+
+		// Slots after the start are found again by this window
+		out = slices.DeleteFunc(out, func(slot swagger.Slot) bool { return !slot.TpStart.Before(start) })
+		for _, slot := range bookableSlots(byResource, settings, chunk, now, booked, withCustomer) {
+			if filter.Match(slot) {
+				out = append(out, slot)
+			}
+		}
+		if len(out) >= count || !between.End.Before(horizon) {
+			break
+		}
+		start = nextWindowStart(byResource, between.End)
+	}
+	return out[:min(len(out), count)], nil
+}
+
+// getNextSlots writes the earliest count slots after the time allowed by the booking policy.
+// customerID is optional, limits of the customer bookings are checked if it is set.
+func (a *api) getNextSlots(w http.ResponseWriter, r *http.Request, businessID string, customerID common.ID) {
+	if businessID == "" {
+		slog.WarnContext(r.Context(), "business_id not found")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	query := r.URL.Query()
+	after, err := getTimeFromURLOptional("after", query)
+	if err != nil && !errors.Is(err, common.ErrNotFound) {
+		slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if after.Before(now) {
+		after = now
+	}
+
+	count, err := getNextSlotsCountFromURL(query)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	settings, err := a.getBusinessSlotSettings(businessID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	filter, err := getSlotFilterFromURL(query, settings.Location())
+	if err != nil {
+		slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	chunk, err := getSlotChunkFromURL(query, settings)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+		writeError(w, r, err)
+		return
+	}
+	resources, err := a.requestedResources(businessID, query)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var booked common.Intervals
+	if customerID != "" {
+		booked, err = a.customerBookings(businessID, customerID, settings.Location(), now)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	slots, err := a.findNextSlots(businessID, resources, settings, chunk, after, count, filter, now, booked, customerID != "")
+	if err != nil {
+		slog.WarnContext(r.Context(), "[NextSlots]", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := slotsPage{AvailableSlots: swagger.AvailableSlots{
+		QueryId: r.Context().Value(RequestIdKey{}).(string),
+		Slots:   slots,
+	}}
+	if !settings.IsRangeMode() {
+		response.MaxChunkMinutes = int32(settings.MaxChunk / time.Minute)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.WarnContext(r.Context(), "[NextSlots] encode", "err", err.Error())
+	}
+}
+
+func (a *api) NextSlotsGetFunc() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.getNextSlots(w, r, mux.Vars(r)["business_id"], "")
+	}
+}
+
+func (a *api) NextSlotsWebAppGetFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[NextSlotsWebAppGet]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		a.getNextSlots(w, r, string(authResult.Business), authResult.Customer)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	swagger "scheduler/appointment-service/api/types"
+
+	"github.com/gorilla/mux"
+)
+
+func TestNextSlots(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	addWorkingHours(t, a, "b1", day.Add(9*time.Hour), 10, 12*time.Hour)
+
+	get := func(query string) (int, []swagger.Slot) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/slots/b1/next?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"business_id": "b1"})
+		req = req.WithContext(context.WithValue(req.Context(), RequestIdKey{}, "q1"))
+		w := httptest.NewRecorder()
+		a.NextSlotsGetFunc()(w, req)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		var slots swagger.AvailableSlots
+		if err := json.NewDecoder(w.Body).Decode(&slots); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, slots.Slots
+	}
+
+	_, slots := get("count=2&chunk_minutes=60")
+	if len(slots) != 2 || !slots[0].TpStart.Equal(day.Add(9*time.Hour)) || !slots[1].TpStart.Equal(day.Add(10*time.Hour)) {
+		t.Fatalf("earliest slots expected: %+v", slots)
+	}
+
+	// Evenings of a day found after several windows
+	evening := day.AddDate(0, 0, 5)
+	weekday := strings.ToLower(evening.Weekday().String()[:3])
+	_, slots = get("count=3&chunk_minutes=60&tz=UTC&time_from=18:00&time_to=21:00&weekdays=" + weekday)
+	if len(slots) != 3 || !slots[0].TpStart.Equal(evening.Add(18*time.Hour)) || !slots[2].TpStart.Equal(evening.Add(20*time.Hour)) {
+		t.Fatalf("evening slots expected: %+v", slots)
+	}
+
+	// Working hours cut by the first window are chunked from their start in the next one
+	after := day.Add(9*time.Hour + 30*time.Minute).Format(time.RFC3339)
+	_, slots = get("count=14&chunk_minutes=60&after=" + after)
+	next := day.AddDate(0, 0, 1)
+	if len(slots) != 14 || !slots[10].TpStart.Equal(day.Add(19*time.Hour+30*time.Minute)) ||
+		!slots[11].TpStart.Equal(next.Add(9*time.Hour)) || !slots[13].TpStart.Equal(next.Add(11*time.Hour)) {
+		t.Fatalf("slots of two days expected: %+v", slots)
+	}
+
+	// Fewer slots than requested within the horizon
+	_, slots = get("count=50&chunk_minutes=60&time_from=20:00")
+	if len(slots) != 10 {
+		t.Fatalf("one evening slot per working day expected: %+v", slots)
+	}
+
+	for _, query := range []string{"count=0", "time_from=25:00", "time_from=18:00&time_to=09:00", "weekdays=xyz", "tz=Nowhere/City"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Fatalf("%s: unexpected status %d", query, code)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/auth"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+)
+
+// problemContentType is the media type of all error responses, RFC 7807
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix makes the problem type URI from the error code
+const problemTypePrefix = "urn:scheduler:problem:"
+
+// errorCode is a stable machine-readable reason of the error
+type errorCode string
+
+const (
+	codeInvalidArgument  errorCode = "invalid_argument"
+	codeInvalidBody      errorCode = "invalid_body"
+	codeUnauthorized     errorCode = "unauthorized"
+	codeSessionExpired   errorCode = "session_expired"
+	codeLoginRequired    errorCode = "login_required"
+	codeForbidden        errorCode = "forbidden"
+	codeNotFound         errorCode = "not_found"
+	codeMethodNotAllowed errorCode = "method_not_allowed"
+	codeConflict         errorCode = "conflict"
+	codeGone             errorCode = "gone"
+	codeTooManyRequests  errorCode = "too_many_requests"
+	codeInternal         errorCode = "internal"
+	codeUnknown          errorCode = "error"
+
+	codeSlotInPast          errorCode = "slot_in_past"
+	codeSlotsOverlap        errorCode = "slots_overlap"
+	codeBookingTooLong      errorCode = "booking_too_long"
+	codeDurationNotAllowed  errorCode = "duration_not_allowed"
+	codeChunkOutOfRange     errorCode = "chunk_out_of_range"
+	codeInvalidRange        errorCode = "invalid_range"
+	codeRangeTooLong        errorCode = "range_too_long"
+	codeLimitOutOfRange     errorCode = "limit_out_of_range"
+	codeInvalidCursor       errorCode = "invalid_cursor"
+	codeUnknownResource     errorCode = "unknown_resource"
+	codeSlotUnavailable     errorCode = "slot_unavailable"
+	codeSlotTaken           errorCode = "slot_taken"
+	codeNoSeatsLeft         errorCode = "no_seats_left"
+	codeAlreadyBooked       errorCode = "already_booked"
+	codeHoldExpired         errorCode = "hold_expired"
+	codeRequestExpired      errorCode = "request_expired"
+	codeCustomerBlocked     errorCode = "customer_blocked"
+	codeIdentityTaken       errorCode = "identity_taken"
+	codeSharedBooking       errorCode = "shared_booking"
+	codeImpactChanged       errorCode = "impact_changed"
+	codePolicyViolation     errorCode = "policy_violation"
+	codeInvalidAnswers      errorCode = "invalid_answers"
+	codeSeriesConflict      errorCode = "series_conflict"
+	codeSecurityRestriction errorCode = "security_restriction"
+
+	codeIdempotencyInProgress errorCode = "idempotency_in_progress"
+	codeIdempotencyKeyReused  errorCode = "idempotency_key_reused"
+)
+
+// problem is the body of error responses. Extension members are added by embedding.
+type problem struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    int       `json:"status"`
+	Detail    string    `json:"detail,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Code      errorCode `json:"code"`
+	RequestId string    `json:"request_id,omitempty"`
+}
+
+func newProblem(r *http.Request, status int, code errorCode, detail string) problem {
+	return problem{
+		Type:      problemTypePrefix + string(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestId: GetRequestID(r.Context()),
+	}
+}
+
+// codedError is an API error with its own code. It wraps a common error,
+// so errors.Is(err, common.ErrInvalidArgument) still works.
+type codedError struct {
+	status int
+	code   errorCode
+	msg    string
+	base   error
+}
+
+func (e *codedError) Error() string { return e.msg }
+func (e *codedError) Unwrap() error { return e.base }
+
+var (
+	errSlotInPast         = &codedError{http.StatusBadRequest, codeSlotInPast, "slot in the past", common.ErrInvalidArgument}
+	errSlotsOverlap       = &codedError{http.StatusBadRequest, codeSlotsOverlap, "slots overlap", common.ErrInvalidArgument}
+	errBookingTooLong     = &codedError{http.StatusBadRequest, codeBookingTooLong, "booking is too long", common.ErrInvalidArgument}
+	errDurationNotAllowed = &codedError{http.StatusBadRequest, codeDurationNotAllowed, "duration is not allowed", common.ErrInvalidArgument}
+	errChunkOutOfRange    = &codedError{http.StatusBadRequest, codeChunkOutOfRange, "chunk_minutes out of range", common.ErrInvalidArgument}
+	errInvalidRange       = &codedError{http.StatusBadRequest, codeInvalidRange, "date_end must be after date_start", common.ErrInvalidArgument}
+	errRangeTooLong       = &codedError{http.StatusBadRequest, codeRangeTooLong, "date range is too long", common.ErrInvalidArgument}
+	errLimitOutOfRange    = &codedError{http.StatusBadRequest, codeLimitOutOfRange, "limit out of range", common.ErrInvalidArgument}
+	errInvalidCursor      = &codedError{http.StatusBadRequest, codeInvalidCursor, "invalid cursor", common.ErrInvalidArgument}
+	errInvalidBody        = &codedError{http.StatusBadRequest, codeInvalidBody, "invalid body", common.ErrInvalidArgument}
+	errSlotUnavailable    = &codedError{http.StatusConflict, codeSlotUnavailable, "requested time is not available", nil}
+)
+
+// errorMappings are checked in order, the first matching error defines the response
+var errorMappings = []struct {
+	err    error
+	status int
+	code   errorCode
+}{
+	{slotsdb.ErrCustomerBlocked, http.StatusForbidden, codeCustomerBlocked},
+	{slotsdb.ErrSlotTaken, http.StatusConflict, codeSlotTaken},
+	{slotsdb.ErrNoSeatsLeft, http.StatusConflict, codeNoSeatsLeft},
+	{slotsdb.ErrAlreadyBooked, http.StatusConflict, codeAlreadyBooked},
+	{slotsdb.ErrIdentityTaken, http.StatusConflict, codeIdentityTaken},
+	{slotsdb.ErrSharedBooking, http.StatusConflict, codeSharedBooking},
+	{slotsdb.ErrImpactChanged, http.StatusConflict, codeImpactChanged},
+	{slotsdb.ErrOutsideWorkingTime, http.StatusConflict, codeSlotUnavailable},
+	{slotsdb.ErrHoldExpired, http.StatusGone, codeHoldExpired},
+	{slotsdb.ErrRequestExpired, http.StatusGone, codeRequestExpired},
+	{auth.ErrSessionExpired, http.StatusUnauthorized, codeSessionExpired},
+	{ErrSecurityRestriction, http.StatusForbidden, codeSecurityRestriction},
+	{common.ErrInvalidArgument, http.StatusBadRequest, codeInvalidArgument},
+	{common.ErrNotFound, http.StatusNotFound, codeNotFound},
+	{common.ErrUnauthorized, http.StatusUnauthorized, codeUnauthorized},
+	{common.ErrNotAllowed, http.StatusForbidden, codeForbidden},
+}
+
+// statusCodes are codes of responses without a specific reason
+var statusCodes = map[int]errorCode{
+	http.StatusBadRequest:                    codeInvalidArgument,
+	http.StatusUnauthorized:                  codeUnauthorized,
+	http.StatusForbidden:                     codeForbidden,
+	http.StatusNotFound:                      codeNotFound,
+	http.StatusMethodNotAllowed:              codeMethodNotAllowed,
+	http.StatusConflict:                      codeConflict,
+	http.StatusGone:                          codeGone,
+	http.StatusTooManyRequests:               codeTooManyRequests,
+	http.StatusNetworkAuthenticationRequired: codeLoginRequired,
+}
+
+func statusCode(status int) errorCode {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return codeInternal
+	}
+	return codeUnknown
+}
+
+// errorStatus maps the error to the response status and code, unknown errors are internal
+func errorStatus(err error) (int, errorCode) {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.status, coded.code
+	}
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, m.code
+		}
+	}
+	return http.StatusInternalServerError, codeInternal
+}
+
+// problemFromError makes the problem of the error. Details of internal errors are not exposed.
+func problemFromError(r *http.Request, err error) problem {
+	status, code := errorStatus(err)
+	if status >= http.StatusInternalServerError {
+		return newProblem(r, status, code, "")
+	}
+	return newProblem(r, status, code, err.Error())
+}
+
+// writeProblemBody writes the problem or a struct embedding it with extension members
+func writeProblemBody(w http.ResponseWriter, r *http.Request, status int, body any) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.WarnContext(r.Context(), "[Problem] encode", "err", err.Error())
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code errorCode, detail string) {
+	writeProblemBody(w, r, status, newProblem(r, status, code, detail))
+}
+
+// writeError responds with the problem mapped from the error
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFromError(r, err)
+	writeProblemBody(w, r, p.Status, p)
+}
+
+// problemResponseWriter delays error statuses until the body is written.
+// Errors without a body are completed with a problem of the status.
+type problemResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	status      int
+	wroteHeader bool
+}
+
+func (w *problemResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader || w.status != 0 {
+		return
+	}
+	if status >= http.StatusBadRequest {
+		w.status = status
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *problemResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.status != 0 {
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *problemResponseWriter) finish() {
+	if w.wroteHeader || w.status == 0 {
+		return
+	}
+	w.wroteHeader = true
+	writeProblem(w.ResponseWriter, w.r, w.status, statusCode(w.status), "")
+}
+
+// ProblemResponses makes every error response of handlers application/problem+json
+func ProblemResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw := &problemResponseWriter{ResponseWriter: w, r: r}
+		next.ServeHTTP(pw, r)
+		pw.finish()
+	})
+}
+
+func notFoundProblem(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+}
+
+func methodNotAllowedProblem(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "")
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestProblemResponses(t *testing.T) {
+	a := newTestAPI(t)
+
+	r := mux.NewRouter()
+	addRoutes(r,
+		Route{"Bare", "GET", "/bare", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+		})},
+		Route{"Ok", "GET", "/ok", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+		})},
+		Route{"Book", "POST", "/slots", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), UserIdKey{}, "b1"))
+			a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{})(w, r)
+		})},
+	)
+	r.NotFoundHandler = PassRequestIdToCtx(http.HandlerFunc(notFoundProblem))
+	r.Use(PassRequestIdToCtx)
+	r.Use(ProblemResponses)
+
+	do := func(method, target, body string) (int, problem) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		if w.Code < http.StatusBadRequest {
+			return w.Code, problem{}
+		}
+		if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+			t.Fatalf("%s %s: unexpected content type %q", method, target, ct)
+		}
+		var p problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != w.Code || p.RequestId == "" || !strings.HasPrefix(target, p.Instance) {
+			t.Fatalf("%s %s: unexpected problem %+v", method, target, p)
+		}
+		return w.Code, p
+	}
+
+	if code, p := do("GET", "/bare", ""); code != http.StatusConflict || p.Code != codeConflict {
+		t.Fatalf("problem of the status expected: %d %+v", code, p)
+	}
+	if code, _ := do("GET", "/ok", ""); code != http.StatusCreated {
+		t.Fatalf("success must pass through: %d", code)
+	}
+	if code, p := do("GET", "/missing", ""); code != http.StatusNotFound || p.Code != codeNotFound {
+		t.Fatalf("not found expected: %d %+v", code, p)
+	}
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	for _, tc := range []struct {
+		body string
+		code errorCode
+	}{
+		{"[", codeInvalidBody},
+		{fmt.Sprintf(`[{"tp_start":%q,"len":60}]`, past), codeSlotInPast},
+	} {
+		code, p := do("POST", "/slots?customer_id=c1", tc.body)
+		if code != http.StatusBadRequest || p.Code != tc.code || p.Detail == "" {
+			t.Fatalf("%s: unexpected problem %d %+v", tc.body, code, p)
+		}
+	}
+}
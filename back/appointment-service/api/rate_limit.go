@@ -0,0 +1,386 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitKey is the subject limited by the rule
+type RateLimitKey string
+
+const (
+	// RateLimitByIP uses X-Forwarded-For of requests from trusted proxies
+	RateLimitByIP RateLimitKey = "ip"
+	// X-Client-ID of mini apps with validated init data. Bots are limited by their business.
+	RateLimitByClient RateLimitKey = "client"
+	// business_id of the path, the authenticated owner or the business of the authorized customer
+	RateLimitByBusiness RateLimitKey = "business"
+	// the authorized customer of the business
+	RateLimitByCustomer RateLimitKey = "customer"
+)
+
+// RateLimit allows Requests per Per with bursts up to Burst requests for every key.
+// Burst defaults to Requests.
+type RateLimit struct {
+	Key      RateLimitKey  `cfg:"key"`
+	Requests int           `cfg:"requests"`
+	Per      time.Duration `cfg:"per"`
+	Burst    int           `cfg:"burst"`
+}
+
+func (l RateLimit) Validate() error {
+	switch l.Key {
+	case RateLimitByIP, RateLimitByClient, RateLimitByBusiness, RateLimitByCustomer:
+	default:
+		return fmt.Errorf("unknown rate limit key %q", l.Key)
+	}
+	if l.Requests <= 0 || l.Per <= 0 || l.Burst < 0 {
+		return fmt.Errorf("rate limit of %s: requests and per must be positive", l.Key)
+	}
+	return nil
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst == 0 {
+		return l.Requests
+	}
+	return l.Burst
+}
+
+// RateLimitsConfig has limits of every route and additional limits of routes by their names
+type RateLimitsConfig struct {
+	Default []RateLimit            `cfg:"default"`
+	Routes  map[string][]RateLimit `cfg:"routes"`
+	// Addresses or CIDR prefixes of reverse proxies. X-Forwarded-For of their requests
+	// is used to find the client IP.
+	TrustedProxies []string `cfg:"trusted_proxies"`
+}
+
+func (c *RateLimitsConfig) Validate() error {
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		return err
+	}
+	for _, l := range c.Default {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+	}
+	for route, limits := range c.Routes {
+		for _, l := range limits {
+			if err := l.Validate(); err != nil {
+				return fmt.Errorf("route %s: %w", route, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultRateLimits are used if limits are not configured
+func DefaultRateLimits() RateLimitsConfig {
+	booking := []RateLimit{{Key: RateLimitByCustomer, Requests: 10, Per: time.Minute, Burst: 5}}
+	return RateLimitsConfig{
+		Default: []RateLimit{
+			{Key: RateLimitByIP, Requests: 600, Per: time.Minute, Burst: 100},
+			{Key: RateLimitByClient, Requests: 1200, Per: time.Minute, Burst: 200},
+			{Key: RateLimitByBusiness, Requests: 1200, Per: time.Minute, Burst: 200},
+		},
+		Routes: map[string][]RateLimit{
+			"SlotsBusinessIdPostFromBot":    booking,
+			"SlotsBusinessIdPostFromWebApp": booking,
+			"SlotsHoldPostFromBot":          booking,
+			"SlotsHoldPostFromWebApp":       booking,
+			"SlotsHoldConfirmFromBot":       booking,
+			"SlotsHoldConfirmFromWebApp":    booking,
+			"SeriesPostFromBot":             booking,
+			"SeriesPostFromWebApp":          booking,
+			"WaitlistPostFromWebApp":        booking,
+			"SlotsBusinessIdPostOneOff":     {{Key: RateLimitByIP, Requests: 10, Per: time.Minute, Burst: 5}},
+			"BatchSlotsPost":                {{Key: RateLimitByIP, Requests: 30, Per: time.Minute, Burst: 10}},
+			"SlotsBusinessIdPost":           {{Key: RateLimitByBusiness, Requests: 120, Per: time.Minute}},
+			"UserBotAdd":                    {{Key: RateLimitByBusiness, Requests: 10, Per: time.Hour}},
+		},
+	}
+}
+
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", p, err)
+			}
+			out = append(out, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", p, err)
+		}
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out, nil
+}
+
+// limitScope separates buckets of the same rule of different routes
+type limitScope struct {
+	route string
+	limit RateLimit
+}
+
+type limitCheck struct {
+	limitScope
+	table *common.LimitsTable[string]
+}
+
+// rateLimiter checks limits of routes. Buckets of default limits are shared by all routes,
+// limits of a route are counted for the route only. Idle keys are evicted.
+type rateLimiter struct {
+	checks         map[string][]limitCheck
+	tables         map[limitScope]*common.LimitsTable[string]
+	trustedProxies []netip.Prefix
+	idleTTL        time.Duration
+	sweeper        *common.PeriodicCallback
+	now            func() time.Time
+}
+
+// newRateLimiter expects the validated config
+func newRateLimiter(config RateLimitsConfig) *rateLimiter {
+	l := &rateLimiter{
+		checks: make(map[string][]limitCheck),
+		tables: make(map[limitScope]*common.LimitsTable[string]),
+		now:    time.Now,
+	}
+	l.trustedProxies, _ = parseTrustedProxies(config.TrustedProxies)
+	check := func(scope limitScope) limitCheck {
+		table, ok := l.tables[scope]
+		if !ok {
+			limit := scope.limit
+			table = common.NewLimitsTable[string](common.RequestLimitUpdateFunc(func(*rate.Limiter) *rate.Limiter {
+				return rate.NewLimiter(rate.Every(limit.Per/time.Duration(limit.Requests)), limit.burst())
+			}))
+			l.tables[scope] = table
+			// A key is forgotten after its bucket is full again
+			l.idleTTL = max(l.idleTTL, limit.Per*time.Duration(limit.burst())/time.Duration(limit.Requests))
+		}
+		return limitCheck{scope, table}
+	}
+
+	for _, limit := range config.Default {
+		l.checks[""] = append(l.checks[""], check(limitScope{limit: limit}))
+	}
+	for route, limits := range config.Routes {
+		for _, limit := range limits {
+			l.checks[route] = append(l.checks[route], check(limitScope{route: route, limit: limit}))
+		}
+	}
+	l.idleTTL = max(l.idleTTL, time.Minute)
+	l.sweeper = common.NewPeriodicCallback(l.idleTTL, l.forgetIdle)
+	return l
+}
+
+func (l *rateLimiter) forgetIdle() {
+	n := 0
+	for _, table := range l.tables {
+		n += table.ForgetIdle(l.now(), l.idleTTL)
+	}
+	slog.Debug("[RateLimiter] forget idle", "cleared", n)
+}
+
+func (l *rateLimiter) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return slices.ContainsFunc(l.trustedProxies, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// clientIP returns the address of the client. X-Forwarded-For of a trusted proxy is read
+// from the right up to the first address which is not a trusted proxy, addresses added
+// by the client itself are never used.
+func (l *rateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !l.isTrustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		host = hop.Unmap().String()
+		if !l.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+// limitKey returns the key of the request, false if the request has no such subject yet.
+// Keys of the client and the customer are known after authorization only.
+func (l *rateLimiter) limitKey(r *http.Request, key RateLimitKey) (string, bool) {
+	customer, authorized := authorizedCustomer(r)
+	var k string
+	switch key {
+	case RateLimitByIP:
+		k = l.clientIP(r)
+	case RateLimitByClient:
+		if authorized {
+			k = customer.Client
+		}
+	case RateLimitByBusiness:
+		k = mux.Vars(r)["business_id"]
+		if k == "" {
+			k, _ = GetUserID(r.Context())
+		}
+		if k == "" && authorized {
+			k = customer.Business
+		}
+	case RateLimitByCustomer:
+		if authorized && customer.Customer != "" {
+			k = customer.Business + "/" + customer.Customer
+		}
+	}
+	return k, k != ""
+}
+
+// take checks limits of the request. Checks without a key are returned as pending.
+// The result of the most exhausted limit is returned.
+func (l *rateLimiter) take(r *http.Request, checks []limitCheck) (result common.LimitResult, pending []limitCheck) {
+	result = common.LimitResult{Allowed: true, Remaining: math.MaxInt}
+	now := l.now()
+	for _, c := range checks {
+		k, ok := l.limitKey(r, c.limit.Key)
+		if !ok {
+			pending = append(pending, c)
+			continue
+		}
+		res := c.table.Take(k, now)
+		if !res.Allowed {
+			slog.WarnContext(r.Context(), "[RateLimit] exceeded", "route", c.route, "key", c.limit.Key, "value", k)
+			if result.Allowed || res.RetryAfter > result.RetryAfter {
+				result = res
+			}
+			continue
+		}
+		if result.Allowed && res.Remaining < result.Remaining {
+			result = res
+		}
+	}
+	return result, pending
+}
+
+func writeRateLimitHeaders(w http.ResponseWriter, result common.LimitResult) {
+	if result.Remaining == math.MaxInt {
+		return
+	}
+	seconds := func(d time.Duration) string {
+		return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+	}
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", seconds(result.Reset))
+	if !result.Allowed {
+		retryAfter := result.RetryAfter
+		if retryAfter == rate.InfDuration {
+			retryAfter = result.Reset
+		}
+		h.Set("Retry-After", seconds(max(retryAfter, time.Second)))
+	}
+}
+
+// check writes rate limit headers and 429 if the request is refused
+func (l *rateLimiter) check(w http.ResponseWriter, r *http.Request, checks []limitCheck) (pending []limitCheck, ok bool) {
+	result, pending := l.take(r, checks)
+	writeRateLimitHeaders(w, result)
+	if !result.Allowed {
+		writeProblem(w, r, http.StatusTooManyRequests, codeTooManyRequests, "rate limit exceeded")
+		return nil, false
+	}
+	return pending, true
+}
+
+type pendingLimitsKey struct{}
+
+// pendingLimits are not checked yet, every check is done once per request
+type pendingLimits struct {
+	limiter *rateLimiter
+	checks  []limitCheck
+}
+
+// Middleware limits requests by limits of the route. Limits of the business without
+// business_id in the path are checked by AuthHandler after the owner is authenticated,
+// limits of the client and the customer after the customer is authorized.
+func (l *rateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route = current.GetName()
+		}
+		checks := slices.Concat(l.checks[""], l.checks[route])
+		pending, ok := l.check(w, r, checks)
+		if !ok {
+			return
+		}
+		if len(pending) != 0 {
+			r = r.WithContext(context.WithValue(r.Context(), pendingLimitsKey{}, &pendingLimits{l, pending}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkPendingRateLimits checks limits whose keys became known after authorization
+func checkPendingRateLimits(w http.ResponseWriter, r *http.Request) bool {
+	p, ok := r.Context().Value(pendingLimitsKey{}).(*pendingLimits)
+	if !ok {
+		return true
+	}
+	var checks, rest []limitCheck
+	for _, c := range p.checks {
+		if _, ok := p.limiter.limitKey(r, c.limit.Key); ok {
+			checks = append(checks, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	p.checks = rest
+	if len(checks) == 0 {
+		return true
+	}
+	_, ok = p.limiter.check(w, r, checks)
+	return ok
+}
+
+// unknownRoutes returns configured route names which are not registered in the router
+func (l *rateLimiter) unknownRoutes(r *mux.Router) []string {
+	known := make(map[string]struct{})
+	r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		known[route.GetName()] = struct{}{}
+		return nil
+	})
+	var unknown []string
+	for name := range l.checks {
+		if _, ok := known[name]; !ok && name != "" {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(RateLimitsConfig{
+		Default: []RateLimit{{Key: RateLimitByIP, Requests: 3, Per: time.Minute}},
+		Routes: map[string][]RateLimit{
+			"Book":     {{Key: RateLimitByCustomer, Requests: 1, Per: time.Minute}},
+			"Settings": {{Key: RateLimitByBusiness, Requests: 1, Per: time.Minute}},
+		},
+	})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	owner := AuthorizationMethodFunc(func(_ http.ResponseWriter, r *http.Request) (common.ID, error) {
+		if r.Header.Get("X-Owner") == "" {
+			return "", common.ErrUnauthorized
+		}
+		return common.ID(r.Header.Get("X-Owner")), nil
+	})
+	a := newTestAPI(t)
+	book := a.customerAuth(slotsdb.ChannelTelegram, AddSlotsAuthFromUrl{}).handler(func(au AddSlotsAuth) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if _, err := au.Authorization(r); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}
+	})
+	r := mux.NewRouter()
+	addRoutes(r,
+		Route{"Book", "POST", "/slots/bt", AuthHandler(owner, book, nil)},
+		Route{"Settings", "GET", "/slots/settings", AuthHandler(owner, ok, nil)},
+	)
+	r.Use(PassRequestIdToCtx)
+	r.Use(limiter.Middleware)
+
+	do := func(method, target, ip, owner string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-Owner", owner)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Unauthorized requests do not spend limits of the customer
+	if w := do("POST", "/slots/bt?customer_id=c1", "10.0.0.9", ""); w.Code != http.StatusNetworkAuthenticationRequired {
+		t.Fatalf("unauthorized booking: %d", w.Code)
+	}
+	w := do("POST", "/slots/bt?customer_id=c1", "10.0.0.1", "b1")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("most exhausted limit expected: %d %v", w.Code, w.Header())
+	}
+	w = do("POST", "/slots/bt?customer_id=c1", "10.0.0.1", "b1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" ||
+		w.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("customer limit expected: %d %v", w.Code, w.Header())
+	}
+	if w := do("POST", "/slots/bt?customer_id=c2", "10.0.0.1", "b1"); w.Code != http.StatusOK {
+		t.Fatalf("another customer: %d", w.Code)
+	}
+	// Refused requests spend tokens of other limits, the IP is exhausted now
+	if w := do("POST", "/slots/bt?customer_id=c3", "10.0.0.1", "b1"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("IP limit expected: %d", w.Code)
+	}
+
+	// Business of the owner is known after authentication
+	if w := do("GET", "/slots/settings", "10.0.0.2", "b1"); w.Code != http.StatusOK {
+		t.Fatalf("first owner request: %d", w.Code)
+	}
+	if w := do("GET", "/slots/settings", "10.0.0.3", "b1"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("business limit expected: %d", w.Code)
+	}
+	if w := do("GET", "/slots/settings", "10.0.0.3", "b2"); w.Code != http.StatusOK {
+		t.Fatalf("another business: %d", w.Code)
+	}
+
+	now = now.Add(time.Minute)
+	if w := do("POST", "/slots/bt?customer_id=c1", "10.0.0.1", "b1"); w.Code != http.StatusOK {
+		t.Fatalf("limits are refilled: %d", w.Code)
+	}
+
+	now = now.Add(limiter.idleTTL)
+	limiter.forgetIdle()
+	for scope, table := range limiter.tables {
+		if n := table.ForgetIdle(now, 0); n != 0 {
+			t.Fatalf("%+v: idle keys are not forgotten", scope)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	config := RateLimitsConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	limiter := newRateLimiter(config)
+	defer limiter.sweeper.Stop()
+
+	for _, c := range []struct {
+		remote, forwarded, ip string
+	}{
+		{"203.0.113.5:1234", "198.51.100.1", "203.0.113.5"},
+		{"10.1.1.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"10.1.1.1:1234", "198.51.100.7, 198.51.100.1, 192.0.2.1", "198.51.100.1"},
+		{"10.1.1.1:1234", "", "10.1.1.1"},
+		{"10.1.1.1:1234", "garbage", "10.1.1.1"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if ip := limiter.clientIP(req); ip != c.ip {
+			t.Fatalf("%s %q: expected %s, got %s", c.remote, c.forwarded, c.ip, ip)
+		}
+	}
+
+	if err := (&RateLimitsConfig{TrustedProxies: []string{"proxy"}}).Validate(); err == nil {
+		t.Fatal("invalid trusted proxy must be rejected")
+	}
+}
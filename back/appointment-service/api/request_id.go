@@ -2,28 +2,26 @@ package api
 
 import (
 	"context"
-	"log/slog"
 	"net/http"
 
-	common "scheduler/appointment-service/internal"
-
-	"github.com/google/uuid"
+	"scheduler/appointment-service/internal/tracing"
 )
 
-type RequestIdKey struct{}
+type RequestIdKey = tracing.RequestIDKey
 
+// PassRequestIdToCtx takes the request ID from X-Request-ID or generates a new one
+// if it is missing or not valid. The ID is echoed in the response header.
 func PassRequestIdToCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//TODO check if request_id already set
-		// Maybe also need to set
-		uuid := uuid.New().String()
-		ctx := context.WithValue(r.Context(), RequestIdKey{}, uuid)
-		ctx = common.AppendSlogCtx(ctx, slog.String("request_id", uuid))
-		next.ServeHTTP(w, r.WithContext(ctx))
+		id := r.Header.Get(tracing.RequestIDHeader)
+		if !tracing.ValidRequestID(id) {
+			id = tracing.NewRequestID()
+		}
+		w.Header().Set(tracing.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(tracing.WithRequestID(r.Context(), id)))
 	})
 }
 
 func GetRequestID(c context.Context) string {
-	id, _ := c.Value(RequestIdKey{}).(string)
-	return id
+	return tracing.RequestID(c)
 }
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"scheduler/appointment-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestIdEchoed(t *testing.T) {
+	var got string
+	h := PassRequestIdToCtx(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetRequestID(r.Context())
+	}))
+
+	for incoming, keep := range map[string]bool{
+		"bot-update-42": true,
+		"":              false,
+		"bad id\r\n":    false,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if incoming != "" {
+			req.Header.Set(tracing.RequestIDHeader, incoming)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		echoed := w.Header().Get(tracing.RequestIDHeader)
+		if echoed == "" || echoed != got {
+			t.Fatalf("request ID %q must be echoed, got %q", got, echoed)
+		}
+		if keep != (echoed == incoming) {
+			t.Fatalf("incoming %q, echoed %q", incoming, echoed)
+		}
+	}
+}
+
+func TestTracingContinuesTraceparent(t *testing.T) {
+	shutdown, err := tracing.Setup(context.Background(), "test", tracing.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var got trace.SpanContext
+	h := Tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got.TraceID().String() != traceID || got.IsRemote() {
+		t.Fatalf("span of trace %s expected, got %v", traceID, got)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !got.IsValid() || got.TraceID().String() == traceID {
+		t.Fatalf("new trace expected, got %v", got)
+	}
+}
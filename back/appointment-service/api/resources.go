@@ -0,0 +1,320 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	swagger "scheduler/appointment-service/api/types"
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+type resourcePayload struct {
+	Name string `json:"name"`
+}
+
+type idResult struct {
+	Id common.ID `json:"id"`
+}
+
+type publicService struct {
+	Id        common.ID          `json:"id"`
+	Name      string             `json:"name"`
+	Resources []slotsdb.Resource `json:"resources"`
+}
+
+// requestedResources returns resources which may serve the request.
+// Query parameters:
+//   - resource_id - resource chosen by customer
+//   - service_id - service. Without resource_id all service resources are returned
+//
+// Without both parameters the business-wide calendar is used.
+func (a *api) requestedResources(businessID common.ID, query url.Values) ([]common.ID, error) {
+	resourceID := query.Get("resource_id")
+	serviceID := query.Get("service_id")
+
+	var candidates []common.ID
+	if serviceID != "" {
+		service, err := a.storages.TimeSlots.GetService(businessID, serviceID)
+		if err != nil {
+			return nil, fmt.Errorf("service_id: %w", err)
+		}
+		candidates = slotsdb.ServiceResources(service)
+	}
+
+	if resourceID == "" {
+		if candidates == nil {
+			candidates = []common.ID{slotsdb.DefaultResource}
+		}
+		return candidates, nil
+	}
+
+	if _, err := a.storages.TimeSlots.GetResource(businessID, resourceID); err != nil {
+		return nil, fmt.Errorf("resource_id: %w", err)
+	}
+	if candidates != nil && !slices.Contains(candidates, resourceID) {
+		return nil, fmt.Errorf("resource_id: %w: resource does not perform the service", common.ErrInvalidArgument)
+	}
+	return []common.ID{resourceID}, nil
+}
+
+// unitedSlots returns slots which at least one of resources is able to serve.
+// Resources are chunked separately, chunk of united free time may cross
+// the border between two resources. Seats of equal group sessions of
+// different resources are summed, regular slot has one seat.
+func unitedSlots(byResource map[common.ID]slotsdb.Availability, chunk time.Duration) []swagger.Slot {
+	seats := make(map[common.Interval]int)
+	for _, availability := range byResource {
+		for _, c := range common.ChunkIntervals(availability.Free, chunk) {
+			seats[c] = max(seats[c], 1)
+		}
+	}
+
+	sessionSeats := make(map[common.Interval]int)
+	for _, availability := range byResource {
+		for _, session := range availability.Sessions {
+			sessionSeats[session.Interval] += session.SeatsLeft
+		}
+	}
+	for interval, n := range sessionSeats {
+		seats[interval] = max(seats[interval], n)
+	}
+
+	out := make([]swagger.Slot, 0, len(seats))
+	for interval, n := range seats {
+		out = append(out, swagger.Slot{
+			TpStart:   interval.Start,
+			Len:       int32(interval.End.Sub(interval.Start).Minutes()),
+			SeatsLeft: int32(n),
+		})
+	}
+	slices.SortFunc(out, func(a, b swagger.Slot) int {
+		if c := a.TpStart.Compare(b.TpStart); c != 0 {
+			return c
+		}
+		return int(a.Len - b.Len)
+	})
+	return out
+}
+
+// sessionResources returns resources having group session exactly matching
+// the interval with free seats
+func sessionResources(byResource map[common.ID]slotsdb.Availability, interval common.Interval) map[common.ID]slotsdb.Session {
+	out := make(map[common.ID]slotsdb.Session)
+	for resource, availability := range byResource {
+		if session, ok := availableSession(availability, interval); ok {
+			out[resource] = session
+		}
+	}
+	return out
+}
+
+// availableSession returns the group session exactly matching the interval if it has free seats
+func availableSession(availability slotsdb.Availability, interval common.Interval) (slotsdb.Session, bool) {
+	for _, session := range availability.Sessions {
+		if session.Start.Equal(interval.Start) && session.End.Equal(interval.End) && session.SeatsLeft > 0 {
+			return session, true
+		}
+	}
+	return slotsdb.Session{}, false
+}
+
+func AddResourceHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req resourcePayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "AddResource decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.AddResource(uid, req.Name)
+		if err != nil {
+			slog.WarnContext(r.Context(), "AddResource", "err", err.Error())
+			if errors.Is(err, common.ErrInvalidArgument) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(idResult{Id: id}); err != nil {
+			slog.WarnContext(r.Context(), "AddResource encode", "err", err.Error())
+		}
+	}
+}
+
+func GetResourcesHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		resources, err := s.GetResources(uid)
+		if err != nil {
+			slog.WarnContext(r.Context(), "GetResources", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(resources); err != nil {
+			slog.WarnContext(r.Context(), "GetResources encode", "err", err.Error())
+		}
+	}
+}
+
+func DeleteResourceHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		err := s.DeleteResource(uid, mux.Vars(r)["id"])
+		if err != nil {
+			slog.WarnContext(r.Context(), "DeleteResource", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func AddServiceHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		var req slotsdb.Service
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "AddService decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.AddService(uid, req)
+		if err != nil {
+			slog.WarnContext(r.Context(), "AddService", "err", err.Error())
+			if errors.Is(err, common.ErrInvalidArgument) || errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(idResult{Id: id}); err != nil {
+			slog.WarnContext(r.Context(), "AddService encode", "err", err.Error())
+		}
+	}
+}
+
+func GetServicesHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		services, err := s.GetServices(uid)
+		if err != nil {
+			slog.WarnContext(r.Context(), "GetServices", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(services); err != nil {
+			slog.WarnContext(r.Context(), "GetServices encode", "err", err.Error())
+		}
+	}
+}
+
+func DeleteServiceHandler(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		err := s.DeleteService(uid, mux.Vars(r)["id"])
+		if err != nil {
+			slog.WarnContext(r.Context(), "DeleteService", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// PublicServicesGetFunc lists services of the business with resources
+// so customer can choose who performs the service
+func PublicServicesGetFunc(s *slotsdb.TimeSlotsStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		businessID := mux.Vars(r)["business_id"]
+
+		services, err := s.GetServices(businessID)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[PublicServicesGet]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		resources, err := s.GetResources(businessID)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[PublicServicesGet]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		names := make(map[common.ID]slotsdb.Resource, len(resources))
+		for _, res := range resources {
+			names[res.Id] = res
+		}
+
+		out := make([]publicService, 0, len(services))
+		for _, service := range services {
+			ps := publicService{Id: service.Id, Name: service.Name, Resources: []slotsdb.Resource{}}
+			for _, id := range service.Resources {
+				if res, ok := names[id]; ok {
+					ps.Resources = append(ps.Resources, res)
+				}
+			}
+			out = append(out, ps)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			slog.WarnContext(r.Context(), "[PublicServicesGet] encode", "err", err.Error())
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"scheduler/appointment-service/internal/auth"
 	"scheduler/appointment-service/internal/auth/oidc"
 	authdb "scheduler/appointment-service/internal/dbase/auth"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
 
 	"github.com/gorilla/mux"
 )
@@ -34,23 +35,23 @@ func (uc userCheckWrap) Check(username string, password string) (UserID, error)
 	return uc.CheckUserPassword(username, password)
 }
 
+// botAuthMethod returns the bearer authorization of bots. The token cache is shared
+// by all routes, expired tokens are forgotten by a.botTokensSweeper.
 // TODO Move logic to internal
-func botAuthMethod(storage *auth.BotTokenStorage) AuthorizationMethodFunc {
-	cache := auth.NewTokenCacheDefault(storage)
-	type bearerAuthWrap struct {
-		auth.BearerAuth
-		shed *common.PeriodicCallback
-	}
-	wrp := bearerAuthWrap{
-		BearerAuth: auth.BearerAuth{TC: cache},
-		shed: common.NewPeriodicCallback(time.Minute*5, func() {
+func (a *api) botAuthMethod() AuthorizationMethodFunc {
+	a.botAuthOnce.Do(func() {
+		cache := auth.NewTokenCacheDefault(&auth.BotTokenStorage{BotsStorage: a.storages.Bots})
+		bearer := auth.BearerAuth{TC: cache}
+		a.botTokensSweeper = common.NewPeriodicCallback(time.Minute*5, func() {
 			cleared := cache.ForgetExpired()
 			slog.Debug("[BotAuthMethod] PeriodicCallback", "cleared", cleared)
-		})}
-	wrp.shed.Start()
-	return AuthorizationMethodFunc(func(_ http.ResponseWriter, r *http.Request) (common.ID, error) {
-		return wrp.Authorization(r)
+		})
+		a.botTokensSweeper.Start()
+		a.botAuth = AuthorizationMethodFunc(func(_ http.ResponseWriter, r *http.Request) (common.ID, error) {
+			return bearer.Authorization(r)
+		})
 	})
+	return a.botAuth
 }
 
 func newUserSignIn(storage *authdb.AuthStorage, sesStore *auth.UserSessionStore, configPath string) (*oidc.UserSignIn, error) {
@@ -107,15 +108,31 @@ func (a *api) addUserAccountHandlers(r *mux.Router) {
 		})
 }
 
+// Router mounts every API version under its prefix. Unversioned legacy paths are
+// aliases of v1 until the sunset.
 func (a *api) Router() *mux.Router {
 	r := mux.NewRouter().StrictSlash(true)
 
-	a.addTimeSlotsHandlers(r)
-	a.addBusinessRulesHandlers(r)
-	a.addUserAccountHandlers(r)
-	a.addOIDCHandlers(r)
+	a.addHealthHandlers(r)
+	a.addV1Handlers(r.PathPrefix(apiV1).Subrouter())
+
+	legacy := r.NewRoute().Subrouter()
+	legacy.Use(DeprecatedAlias(apiV1, legacyPathsDeprecatedAt, legacyPathsSunset))
+	a.addV1Handlers(legacy)
 
+	r.NotFoundHandler = PassRequestIdToCtx(Tracing(AccessLog(http.HandlerFunc(notFoundProblem))))
+	r.MethodNotAllowedHandler = PassRequestIdToCtx(Tracing(AccessLog(http.HandlerFunc(methodNotAllowedProblem))))
 	r.Use(PassRequestIdToCtx)
+	r.Use(Tracing)
+	r.Use(AccessLog)
+	if a.rateLimiter != nil {
+		if unknown := a.rateLimiter.unknownRoutes(r); len(unknown) != 0 {
+			slog.Warn("[Router] rate limits of unknown routes", "routes", unknown)
+		}
+		r.Use(a.rateLimiter.Middleware)
+	}
+	r.Use(a.IdempotentRequests)
+	r.Use(ProblemResponses)
 	return r
 }
 
@@ -136,18 +153,39 @@ func (a *api) addOIDCHandlers(r *mux.Router) {
 }
 
 func (a *api) addTimeSlotsHandlers(r *mux.Router) {
-	oneOffAuth := (*AddSlotsAuthOneOffToken)(a.storages.Auth)
-	bs := auth.BotTokenStorage{BotsStorage: a.storages.Bots}
+	oneOffAuth := a.customerAuth(slotsdb.ChannelToken, (*AddSlotsAuthOneOffToken)(a.storages.Auth))
+	botAuth := a.botAuthMethod()
+	botCustomerAuth := a.customerAuth(slotsdb.ChannelTelegram, AddSlotsAuthFromUrl{})
+	webAppAuth := a.customerAuth(slotsdb.ChannelTelegram, AddSlotsAuthTgWebApp{
+		BotsStorage: a.storages.Bots,
+		Validator:   auth.NewTelegramWebAppInitDataValidator(),
+	})
 	addRoutes(
 		r,
 		Route{
 			"SlotsBusinessIdGetFromWebApp",
 			"GET",
 			"/slots/webapp",
-			a.SlotsBusinessWebAppGetFunc(AddSlotsAuthTgWebApp{
-				BotsStorage: a.storages.Bots,
-				Validator:   auth.NewTelegramWebAppInitDataValidator(),
-			}),
+			webAppAuth.handler(a.SlotsBusinessWebAppGetFunc),
+		},
+		Route{
+			"NextSlotsGetFromWebApp",
+			"GET",
+			"/slots/webapp/next",
+			webAppAuth.handler(a.NextSlotsWebAppGetFunc),
+		},
+		Route{
+			"SlotsSummaryGetFromWebApp",
+			"GET",
+			"/slots/webapp/summary",
+			webAppAuth.handler(a.SlotsSummaryWebAppGetFunc),
+		},
+		// Must be registered before /slots/{business_id}
+		Route{
+			"GetBusinessSlotSettings",
+			"GET",
+			"/slots/settings",
+			AuthHandler(a.cookieAuth, a.GetBusinessSlotSettingsHandler(), http.HandlerFunc(LoginRequired)),
 		},
 		//TODO GET /slots/{business_id} is deprecated
 		Route{
@@ -156,54 +194,132 @@ func (a *api) addTimeSlotsHandlers(r *mux.Router) {
 			"/slots/{business_id}",
 			a.SlotsBusinessIdGetFunc(),
 		},
+		Route{
+			"NextSlotsGet",
+			"GET",
+			"/slots/{business_id}/next",
+			a.NextSlotsGetFunc(),
+		},
+		Route{
+			"SlotsSummaryGet",
+			"GET",
+			"/slots/{business_id}/summary",
+			a.SlotsSummaryGetFunc(),
+		},
+		Route{
+			"BatchSlotsPost",
+			"POST",
+			"/slots/batch",
+			a.BatchSlotsPostFunc(),
+		},
 		Route{
 			"SlotsBusinessIdPostOneOff",
 			"POST",
 			"/slots/once",
-			a.SlotsBusinessIdPostFunc(oneOffAuth),
+			oneOffAuth.handler(a.SlotsBusinessIdPostFunc),
 		},
 		Route{
 			"SlotsBusinessIdPostFromBot",
 			"POST",
 			"/slots/bt",
 			//SlotsBusinessIdPostFunc(&oneOffAuth, ts),
-			AuthHandler(botAuthMethod(&bs), a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{}), http.HandlerFunc(LoginRequired)),
+			AuthHandler(botAuth, botCustomerAuth.handler(a.SlotsBusinessIdPostFunc), http.HandlerFunc(LoginRequired)),
 		},
 		Route{
 			"SlotsBusinessIdPostFromWebApp",
 			"POST",
 			"/slots/webapp",
-			a.SlotsBusinessIdPostFunc(AddSlotsAuthTgWebApp{
-				BotsStorage: a.storages.Bots,
-				Validator:   auth.NewTelegramWebAppInitDataValidator(),
-			}),
+			webAppAuth.handler(a.SlotsBusinessIdPostFunc),
+		},
+		Route{
+			"SlotsHoldPostFromWebApp",
+			"POST",
+			"/slots/webapp/holds",
+			webAppAuth.handler(a.SlotsHoldPostFunc),
+		},
+		Route{
+			"SlotsHoldConfirmFromWebApp",
+			"POST",
+			"/slots/webapp/holds/{token}/confirm",
+			webAppAuth.handler(a.SlotsHoldConfirmFunc),
+		},
+		Route{
+			"SlotsHoldDeleteFromWebApp",
+			"DELETE",
+			"/slots/webapp/holds/{token}",
+			webAppAuth.handler(a.SlotsHoldDeleteFunc),
+		},
+		Route{
+			"SlotsHoldPostFromBot",
+			"POST",
+			"/slots/bt/holds",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.SlotsHoldPostFunc), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"SlotsHoldConfirmFromBot",
+			"POST",
+			"/slots/bt/holds/{token}/confirm",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.SlotsHoldConfirmFunc), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"SlotsHoldDeleteFromBot",
+			"DELETE",
+			"/slots/bt/holds/{token}",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.SlotsHoldDeleteFunc), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"SeriesPostFromWebApp",
+			"POST",
+			"/slots/webapp/series",
+			webAppAuth.handler(a.SeriesPostFunc),
+		},
+		Route{
+			"SeriesPostFromBot",
+			"POST",
+			"/slots/bt/series",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.SeriesPostFunc), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"SeriesDeleteFromWebApp",
+			"DELETE",
+			"/customer/series/{id}",
+			webAppAuth.handler(a.SeriesDeleteFunc),
+		},
+		Route{
+			"SeriesDeleteFromBot",
+			"DELETE",
+			"/customer/series/bt/{id}",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.SeriesDeleteFunc), http.HandlerFunc(LoginRequired)),
 		},
 		Route{
 			"CustomerAppointmentsGetFromWebApp",
 			"GET",
 			"/customer/appointments",
-			a.CustomerAppointmentsGetFunc(AddSlotsAuthTgWebApp{
-				BotsStorage: a.storages.Bots,
-				Validator:   auth.NewTelegramWebAppInitDataValidator(),
-			}),
+			webAppAuth.handler(a.CustomerAppointmentsGetFunc),
 		},
 		Route{
 			"CustomerAppointmentsGetFromBot",
 			"GET",
 			"/customer/appointments/bt",
-			AuthHandler(botAuthMethod(&bs), a.CustomerAppointmentsGetFunc(AddSlotsAuthFromUrl{}), http.HandlerFunc(LoginRequired)),
+			AuthHandler(botAuth, botCustomerAuth.handler(a.CustomerAppointmentsGetFunc), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"CustomerAppointmentDeleteFromWebApp",
+			"DELETE",
+			"/customer/appointments",
+			webAppAuth.handler(a.CustomerAppointmentDeleteFunc),
+		},
+		Route{
+			"CustomerAppointmentDeleteFromBot",
+			"DELETE",
+			"/customer/appointments/bt",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.CustomerAppointmentDeleteFunc), http.HandlerFunc(LoginRequired)),
 		},
 		Route{
 			"SlotsBusinessIdPost",
 			"POST",
 			"/slots",
-			AuthHandler(a.cookieAuth, a.SlotsBusinessIdPostFunc(AddSlotsAuthFromUrl{}), http.HandlerFunc(LoginRequired)),
-		},
-		Route{
-			"GetBusinessSlotSettings",
-			"GET",
-			"/slots/settings",
-			AuthHandler(a.cookieAuth, a.GetBusinessSlotSettingsHandler(), http.HandlerFunc(LoginRequired)),
+			AuthHandler(a.cookieAuth, a.OwnerSlotsPostFunc(AddSlotsAuthFromUrl{}), http.HandlerFunc(LoginRequired)),
 		},
 		Route{
 			"SetBusinessSlotSettings",
@@ -213,6 +329,95 @@ func (a *api) addTimeSlotsHandlers(r *mux.Router) {
 		})
 }
 
+func (a *api) addApprovalsHandlers(r *mux.Router) {
+	botAuth := a.botAuthMethod()
+	addRoutes(
+		r,
+		Route{
+			"BookingRequestsGet",
+			"GET",
+			"/booking_requests",
+			AuthHandler(a.cookieAuth, a.BookingRequestsGetHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"BookingRequestApprove",
+			"POST",
+			"/booking_requests/{id}/approve",
+			AuthHandler(a.cookieAuth, a.BookingRequestApproveHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"BookingRequestReject",
+			"POST",
+			"/booking_requests/{id}/reject",
+			AuthHandler(a.cookieAuth, a.BookingRequestRejectHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"BookingRequestsGetFromBot",
+			"GET",
+			"/booking_requests/bt",
+			AuthHandler(botAuth, a.BookingRequestsGetHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"BookingRequestApproveFromBot",
+			"POST",
+			"/booking_requests/bt/{id}/approve",
+			AuthHandler(botAuth, a.BookingRequestApproveHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"BookingRequestRejectFromBot",
+			"POST",
+			"/booking_requests/bt/{id}/reject",
+			AuthHandler(botAuth, a.BookingRequestRejectHandler(), http.HandlerFunc(LoginRequired)),
+		})
+}
+
+func (a *api) addWaitlistHandlers(r *mux.Router) {
+	botAuth := a.botAuthMethod()
+	botCustomerAuth := a.customerAuth(slotsdb.ChannelTelegram, AddSlotsAuthFromUrl{})
+	webAppAuth := a.customerAuth(slotsdb.ChannelTelegram, AddSlotsAuthTgWebApp{
+		BotsStorage: a.storages.Bots,
+		Validator:   auth.NewTelegramWebAppInitDataValidator(),
+	})
+	addRoutes(
+		r,
+		Route{
+			"WaitlistPostFromWebApp",
+			"POST",
+			"/waitlist/webapp",
+			webAppAuth.handler(a.WaitlistPostFunc),
+		},
+		Route{
+			"WaitlistDeleteFromWebApp",
+			"DELETE",
+			"/waitlist/webapp/{id}",
+			webAppAuth.handler(a.WaitlistDeleteFunc),
+		},
+		Route{
+			"WaitlistPostFromBot",
+			"POST",
+			"/waitlist/bt",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.WaitlistPostFunc), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"WaitlistDeleteFromBot",
+			"DELETE",
+			"/waitlist/bt/{id}",
+			AuthHandler(botAuth, botCustomerAuth.handler(a.WaitlistDeleteFunc), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"NotificationsGetFromBot",
+			"GET",
+			"/notifications/bt",
+			AuthHandler(botAuth, a.NotificationsGetHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"NotificationAckFromBot",
+			"POST",
+			"/notifications/bt/{id}/ack",
+			AuthHandler(botAuth, a.NotificationAckHandler(), http.HandlerFunc(LoginRequired)),
+		})
+}
+
 func (a *api) addBusinessRulesHandlers(r *mux.Router) {
 	addRoutes(
 		r,
@@ -220,7 +425,7 @@ func (a *api) addBusinessRulesHandlers(r *mux.Router) {
 			"AddBusinessRulePost",
 			"POST",
 			"/rrules",
-			AuthHandler(a.cookieAuth, AddBusinessRuleHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+			AuthHandler(a.cookieAuth, AddBusinessRuleHandler(a.storages.TimeSlots, a), http.HandlerFunc(LoginRequired)),
 		},
 		Route{
 			"GetBusinessRule",
@@ -232,7 +437,189 @@ func (a *api) addBusinessRulesHandlers(r *mux.Router) {
 			"DelBusinessRule",
 			"DELETE",
 			"/rrules/{id}",
-			AuthHandler(a.cookieAuth, DelBusinessRuleHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+			AuthHandler(a.cookieAuth, DelBusinessRuleHandler(a.storages.TimeSlots, a), http.HandlerFunc(LoginRequired)),
+		})
+}
+
+func (a *api) addResourcesHandlers(r *mux.Router) {
+	addRoutes(
+		r,
+		Route{
+			"PublicServicesGet",
+			"GET",
+			"/business/{business_id}/services",
+			PublicServicesGetFunc(a.storages.TimeSlots),
+		},
+		Route{
+			"PublicBookingFieldsGet",
+			"GET",
+			"/business/{business_id}/booking_fields",
+			PublicBookingFieldsGetFunc(a.storages.TimeSlots),
+		},
+		Route{
+			"AddResourcePost",
+			"POST",
+			"/resources",
+			AuthHandler(a.cookieAuth, AddResourceHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"GetResources",
+			"GET",
+			"/resources",
+			AuthHandler(a.cookieAuth, GetResourcesHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"DelResource",
+			"DELETE",
+			"/resources/{id}",
+			AuthHandler(a.cookieAuth, DeleteResourceHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"AddServicePost",
+			"POST",
+			"/services",
+			AuthHandler(a.cookieAuth, AddServiceHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"GetServices",
+			"GET",
+			"/services",
+			AuthHandler(a.cookieAuth, GetServicesHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"DelService",
+			"DELETE",
+			"/services/{id}",
+			AuthHandler(a.cookieAuth, DeleteServiceHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"AddBookingFieldPost",
+			"POST",
+			"/booking_fields",
+			AuthHandler(a.cookieAuth, AddBookingFieldHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"GetBookingFields",
+			"GET",
+			"/booking_fields",
+			AuthHandler(a.cookieAuth, GetBookingFieldsHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"DelBookingField",
+			"DELETE",
+			"/booking_fields/{id}",
+			AuthHandler(a.cookieAuth, DeleteBookingFieldHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"BusinessAppointmentsGet",
+			"GET",
+			"/appointments",
+			AuthHandler(a.cookieAuth, BusinessAppointmentsGetHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		})
+}
+
+func (a *api) addCustomersHandlers(r *mux.Router) {
+	addRoutes(
+		r,
+		Route{
+			"GetCustomers",
+			"GET",
+			"/customers",
+			AuthHandler(a.cookieAuth, GetCustomersHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"GetCustomer",
+			"GET",
+			"/customers/{id}",
+			AuthHandler(a.cookieAuth, GetCustomerHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"UpdateCustomer",
+			"PUT",
+			"/customers/{id}",
+			AuthHandler(a.cookieAuth, UpdateCustomerHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"MergeCustomers",
+			"POST",
+			"/customers/{id}/merge",
+			AuthHandler(a.cookieAuth, MergeCustomersHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		})
+}
+
+func (a *api) addTimeOffHandlers(r *mux.Router) {
+	addRoutes(
+		r,
+		Route{
+			"TimeOffPreview",
+			"POST",
+			"/time_off/preview",
+			AuthHandler(a.cookieAuth, a.TimeOffPreviewHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"TimeOff",
+			"POST",
+			"/time_off",
+			AuthHandler(a.cookieAuth, a.TimeOffHandler(), http.HandlerFunc(LoginRequired)),
+		})
+}
+
+func (a *api) addAttentionHandlers(r *mux.Router) {
+	addRoutes(
+		r,
+		Route{
+			"AttentionItemsGet",
+			"GET",
+			"/attention",
+			AuthHandler(a.cookieAuth, a.AttentionItemsGetHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"AttentionKeep",
+			"POST",
+			"/attention/{code}/keep",
+			AuthHandler(a.cookieAuth, a.AttentionKeepHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"AttentionReschedule",
+			"POST",
+			"/attention/{code}/reschedule",
+			AuthHandler(a.cookieAuth, a.AttentionRescheduleHandler(), http.HandlerFunc(LoginRequired)),
+		})
+}
+
+func (a *api) addAttendanceHandlers(r *mux.Router) {
+	botAuth := a.botAuthMethod()
+	addRoutes(
+		r,
+		Route{
+			"CheckIn",
+			"POST",
+			"/appointments/check_in",
+			AuthHandler(a.cookieAuth, a.CheckInHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"CheckInFromBot",
+			"POST",
+			"/appointments/bt/check_in",
+			AuthHandler(botAuth, a.CheckInHandler(), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"GetBlocklist",
+			"GET",
+			"/blocklist",
+			AuthHandler(a.cookieAuth, GetBlocklistHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"BlockCustomer",
+			"PUT",
+			"/blocklist/{customer_id}",
+			AuthHandler(a.cookieAuth, BlockCustomerHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
+		},
+		Route{
+			"UnblockCustomer",
+			"DELETE",
+			"/blocklist/{customer_id}",
+			AuthHandler(a.cookieAuth, UnblockCustomerHandler(a.storages.TimeSlots), http.HandlerFunc(LoginRequired)),
 		})
 }
 
@@ -244,15 +631,14 @@ func (a *api) AppendFileServerLogic(dir string, r *mux.Router) {
 
 func LoginRequired(w http.ResponseWriter, r *http.Request) {
 	slog.WarnContext(r.Context(), "[LoginRequired]", "RemoteAddr", r.RemoteAddr)
-	http.Error(w, "Please login first", http.StatusNetworkAuthenticationRequired)
+	writeProblem(w, r, http.StatusNetworkAuthenticationRequired, codeLoginRequired, "please login first")
 }
 
 func addRoutes(r *mux.Router, routes ...Route) {
 	for _, route := range routes {
-		handler := Logger(route.HandlerFunc, route.Name)
 		r.Methods(route.Method).
 			Path(route.Pattern).
 			Name(route.Name).
-			Handler(handler)
+			Handler(route.HandlerFunc)
 	}
 }
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+type seriesPayload struct {
+	TpStart time.Time      `json:"tp_start"`
+	Len     int32          `json:"len"`
+	RRule   string         `json:"rrule"`
+	Count   int            `json:"count,omitempty"`
+	Until   time.Time      `json:"until,omitempty"`
+	Partial bool           `json:"partial"`
+	Answers common.Answers `json:"answers,omitempty"`
+}
+
+// Reasons of series conflicts in addition to common.PolicyReason values
+const (
+	seriesConflictUnavailable = "unavailable"
+	seriesConflictInPast      = "in_past"
+)
+
+type seriesConflict struct {
+	TpStart time.Time `json:"tp_start"`
+	Reason  string    `json:"reason"`
+}
+
+type seriesResult struct {
+	SeriesId   common.ID        `json:"series_id,omitempty"`
+	ResourceId common.ID        `json:"resource_id,omitempty"`
+	Booked     []time.Time      `json:"booked"`
+	Conflicts  []seriesConflict `json:"conflicts"`
+}
+
+// seriesConflictResult is the problem with conflicts of the rejected series
+type seriesConflictResult struct {
+	problem
+	seriesResult
+}
+
+type seriesCancelResult struct {
+	Cancelled []time.Time `json:"cancelled"`
+}
+
+func writeSeriesResult(w http.ResponseWriter, r *http.Request, status int, result seriesResult) {
+	if result.Booked == nil {
+		result.Booked = []time.Time{}
+	}
+	if result.Conflicts == nil {
+		result.Conflicts = []seriesConflict{}
+	}
+	slices.SortStableFunc(result.Conflicts, func(a, b seriesConflict) int { return a.TpStart.Compare(b.TpStart) })
+
+	if status >= http.StatusBadRequest {
+		writeProblemBody(w, r, status, seriesConflictResult{
+			problem:      newProblem(r, status, codeSeriesConflict, "occurrences of the series conflict"),
+			seriesResult: result,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.WarnContext(r.Context(), "[SeriesPost] encode", "err", err.Error())
+	}
+}
+
+// SeriesPostFunc books recurring appointments of the customer on one resource.
+// Occurrences matching group sessions of the resource take a seat.
+// All occurrences are checked first. With partial=false nothing is booked if any
+// occurrence conflicts, otherwise free occurrences are booked. Conflicts are reported
+// per occurrence in both cases.
+func (a *api) SeriesPostFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !a.checkCustomerAllowed(w, r, authResult) {
+			return
+		}
+
+		var req seriesPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "[SeriesPost] decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		rule := common.SeriesRule{
+			RRule: req.RRule,
+			Start: req.TpStart,
+			Len:   time.Duration(req.Len) * time.Minute,
+			Count: req.Count,
+			Until: req.Until,
+		}
+		occurrences, err := rule.Occurrences()
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		serviceID := query.Get("service_id")
+		strategy, err := common.ParsePickStrategy(query.Get("strategy"))
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		candidates, err := a.requestedResources(authResult.Business, query)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fields, err := a.storages.TimeSlots.GetServiceBookingFields(authResult.Business, serviceID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		answers, fieldErrors := common.ValidateAnswers(fields, req.Answers)
+		if len(fieldErrors) != 0 {
+			writeFieldErrors(w, r, fieldErrors)
+			return
+		}
+
+		settings, err := a.getBusinessSlotSettings(authResult.Business)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// Series are booked at once, occurrences can not wait for approval one by one
+		if settings.RequiresApproval {
+			slog.WarnContext(r.Context(), "[SeriesPost] business requires approval of bookings")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if settings.IsRangeMode() && !settings.Duration.Allows(rule.Len) {
+			slog.WarnContext(r.Context(), "[SeriesPost] appointment duration is not allowed", "duration", rule.Len)
+			writeError(w, r, fmt.Errorf("%w: %v", errDurationNotAllowed, rule.Len))
+			return
+		}
+		if !settings.IsRangeMode() && rule.Len > settings.MaxChunk {
+			slog.WarnContext(r.Context(), "[SeriesPost] appointment is too long", "max", settings.MaxChunk)
+			writeError(w, r, fmt.Errorf("%w: longer than %v", errBookingTooLong, settings.MaxChunk))
+			return
+		}
+		now := time.Now()
+		booked, err := a.customerBookings(authResult.Business, authResult.Customer, settings.Location(), now)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		between := common.Interval{Start: occurrences[0].Start, End: occurrences[len(occurrences)-1].End}
+		availability, err := a.storages.TimeSlots.GetResourcesAvailabilityInRange(authResult.Business, candidates, between)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var conflicts []seriesConflict
+		conflicting := make(map[time.Time]struct{})
+		addConflict := func(start time.Time, reason string) {
+			conflicts = append(conflicts, seriesConflict{TpStart: start.UTC(), Reason: reason})
+			conflicting[start] = struct{}{}
+		}
+		for _, o := range occurrences {
+			if o.Start.Before(now) {
+				addConflict(o.Start, seriesConflictInPast)
+			}
+		}
+		for _, v := range settings.Policy.CheckBooking(now, settings.Location(), booked, occurrences) {
+			addConflict(v.TpStart, string(v.Reason))
+		}
+
+		resource, err := a.pickSeriesResource(authResult.Business, serviceID, strategy, availability, occurrences, between)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "[SeriesPost]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var sessions []slotsdb.Session
+		for _, o := range occurrences {
+			if session, ok := availableSession(availability[resource], o); ok {
+				sessions = append(sessions, session)
+			} else if !availability[resource].Free.IsFit(o) {
+				addConflict(o.Start, seriesConflictUnavailable)
+			}
+		}
+
+		var free common.Intervals
+		for _, o := range occurrences {
+			if _, ok := conflicting[o.Start]; !ok {
+				free = append(free, o)
+			}
+		}
+		if len(free) == 0 || (len(conflicts) != 0 && !req.Partial) {
+			slog.WarnContext(r.Context(), "[SeriesPost] series conflicts", "conflicts", len(conflicts))
+			writeSeriesResult(w, r, http.StatusConflict, seriesResult{Conflicts: conflicts})
+			return
+		}
+
+		seriesID, taken, err := a.storages.TimeSlots.AddSeries(slotsdb.SeriesData{
+			Business: authResult.Business,
+			Customer: authResult.Customer,
+			Resource: resource,
+			Rule:     req.RRule,
+			Slots:    free,
+			Sessions: sessions,
+			Answers:  answers,
+			Partial:  req.Partial,
+		})
+		for _, t := range taken {
+			addConflict(t.Start, seriesConflictUnavailable)
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesPost] AddSeries", "err", err.Error())
+			if errors.Is(err, slotsdb.ErrSlotTaken) {
+				writeSeriesResult(w, r, http.StatusConflict, seriesResult{Conflicts: conflicts})
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		result := seriesResult{SeriesId: seriesID, ResourceId: resource, Conflicts: conflicts}
+		for _, o := range free {
+			if !slices.ContainsFunc(taken, func(t common.Interval) bool { return t.Start.Equal(o.Start) }) {
+				result.Booked = append(result.Booked, o.Start.UTC())
+			}
+		}
+		writeSeriesResult(w, r, http.StatusOK, result)
+	}
+}
+
+// pickSeriesResource selects the resource whose free time or group sessions with free seats
+// fit most occurrences, ties are resolved by the pick strategy
+func (a *api) pickSeriesResource(businessID common.ID, serviceID common.ID, strategy common.PickStrategy,
+	availability map[common.ID]slotsdb.Availability, occurrences common.Intervals, between common.Interval) (common.ID, error) {
+	best := -1
+	var bestResources []common.ID
+	for resource, av := range availability {
+		fit := 0
+		for _, o := range occurrences {
+			if _, ok := availableSession(av, o); ok || av.Free.IsFit(o) {
+				fit++
+			}
+		}
+		switch {
+		case fit > best:
+			best = fit
+			bestResources = []common.ID{resource}
+		case fit == best:
+			bestResources = append(bestResources, resource)
+		}
+	}
+	if len(bestResources) == 0 {
+		return slotsdb.DefaultResource, nil
+	}
+	slices.Sort(bestResources)
+	return a.pickResource(businessID, serviceID, strategy, bestResources, between)
+}
+
+// SeriesDeleteFunc cancels occurrences of the series which may still be cancelled
+// under the business cancellation cutoff. Single occurrence is cancelled as a regular appointment.
+func (a *api) SeriesDeleteFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesDelete]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		settings, err := a.getBusinessSlotSettings(authResult.Business)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesDelete]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		from := time.Now().Add(settings.Policy.CancellationCutoff)
+		cancelled, err := a.storages.TimeSlots.CancelSeries(authResult.Business, authResult.Customer, mux.Vars(r)["id"], from)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SeriesDelete]", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if len(cancelled) != 0 {
+			a.evaluateWaitlist(r.Context(), authResult.Business)
+		}
+
+		result := seriesCancelResult{Cancelled: make([]time.Time, 0, len(cancelled))}
+		for _, c := range cancelled {
+			result.Cancelled = append(result.Cancelled, c.Start.UTC())
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.WarnContext(r.Context(), "[SeriesDelete] encode", "err", err.Error())
+		}
+	}
+}
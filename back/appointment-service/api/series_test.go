@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSeriesHandlers(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	addWorkingHours(t, a, "b1", day.Add(9*time.Hour), 3, 3*time.Hour)
+	class := dailyRule(t, day.Add(18*time.Hour), 2, time.Hour, common.Inclusion)
+	class.Capacity = 1
+	if _, err := a.storages.TimeSlots.AddBusinessRule("b1", class); err != nil {
+		t.Fatal(err)
+	}
+	taken := day.Add(24*time.Hour + 10*time.Hour)
+	err := a.storages.TimeSlots.AddSlots(slotsdb.AddSlotsData{
+		Business: "b1",
+		Customer: "c2",
+		Slots:    common.Intervals{{Start: taken, End: taken.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(customer string, start time.Time, count int, partial bool) (int, seriesResult) {
+		t.Helper()
+		body := fmt.Sprintf(`{"tp_start":%q,"len":60,"rrule":"FREQ=DAILY","count":%d,"partial":%t}`,
+			start.Format(time.RFC3339), count, partial)
+		req := withBusiness(httptest.NewRequest("POST", "/slots/series?customer_id="+customer, bytes.NewBufferString(body)))
+		w := httptest.NewRecorder()
+		a.SeriesPostFunc(AddSlotsAuthFromUrl{})(w, req)
+		var result seriesResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, result
+	}
+
+	code, result := post("c1", day.Add(10*time.Hour), 3, false)
+	if code != http.StatusConflict || len(result.Conflicts) != 1 || !result.Conflicts[0].TpStart.Equal(taken) ||
+		result.Conflicts[0].Reason != seriesConflictUnavailable {
+		t.Fatalf("atomic series must be rejected with the conflict: %d %+v", code, result)
+	}
+
+	code, result = post("c1", day.Add(10*time.Hour), 3, true)
+	if code != http.StatusOK || len(result.Booked) != 2 || len(result.Conflicts) != 1 || result.SeriesId == "" {
+		t.Fatalf("partial series must book free occurrences: %d %+v", code, result)
+	}
+
+	// Occurrences on group sessions take seats
+	code, classes := post("c3", day.Add(18*time.Hour), 2, false)
+	if code != http.StatusOK || len(classes.Booked) != 2 {
+		t.Fatalf("series of group sessions: %d %+v", code, classes)
+	}
+	if code, result := post("c4", day.Add(18*time.Hour), 2, false); code != http.StatusConflict || len(result.Conflicts) != 2 {
+		t.Fatalf("series of full sessions must be rejected: %d %+v", code, result)
+	}
+
+	req := mux.SetURLVars(withBusiness(httptest.NewRequest("DELETE", "/slots/series/"+classes.SeriesId+"?customer_id=c3", nil)),
+		map[string]string{"id": classes.SeriesId})
+	w := httptest.NewRecorder()
+	a.SeriesDeleteFunc(AddSlotsAuthFromUrl{})(w, req)
+	var cancelled seriesCancelResult
+	if err := json.NewDecoder(w.Body).Decode(&cancelled); err != nil || w.Code != http.StatusOK || len(cancelled.Cancelled) != 2 {
+		t.Fatalf("cancel series: %d %+v %v", w.Code, cancelled, err)
+	}
+	if code, _ := post("c4", day.Add(18*time.Hour), 2, false); code != http.StatusOK {
+		t.Fatalf("seats of the cancelled series must be free: %d", code)
+	}
+
+	// Occurrences are limited by the max chunk as one-off bookings
+	body := fmt.Sprintf(`{"tp_start":%q,"len":120,"rrule":"FREQ=DAILY","count":2}`, day.Add(9*time.Hour).Format(time.RFC3339))
+	req = withBusiness(httptest.NewRequest("POST", "/slots/series?customer_id=c5", bytes.NewBufferString(body)))
+	w = httptest.NewRecorder()
+	a.SeriesPostFunc(AddSlotsAuthFromUrl{})(w, req)
+	var p problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil || w.Code != http.StatusBadRequest || p.Code != codeBookingTooLong {
+		t.Fatalf("too long occurrences must be rejected: %d %+v %v", w.Code, p, err)
+	}
+}
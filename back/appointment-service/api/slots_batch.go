@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+)
+
+const (
+	maxBatchBusinesses = 50
+	maxBatchRange      = 31 * 24 * time.Hour
+	// Availability of so many businesses is computed at once
+	batchSlotsParallelism = 4
+)
+
+type batchBusiness struct {
+	BusinessId common.ID `json:"business_id"`
+	ServiceId  common.ID `json:"service_id,omitempty"`
+	ResourceId common.ID `json:"resource_id,omitempty"`
+}
+
+type batchSlotsPayload struct {
+	DateStart    time.Time       `json:"date_start"`
+	DateEnd      time.Time       `json:"date_end"`
+	ChunkMinutes int             `json:"chunk_minutes,omitempty"`
+	Businesses   []batchBusiness `json:"businesses"`
+}
+
+// batchBusinessResult has either availability or an error of one business
+type batchBusinessResult struct {
+	batchBusiness
+	Status       int       `json:"status"`
+	Availability any       `json:"availability,omitempty"`
+	Code         errorCode `json:"code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type batchSlotsResult struct {
+	QueryId string                `json:"query_id,omitempty"`
+	Results []batchBusinessResult `json:"results"`
+}
+
+func (req batchSlotsPayload) validate() error {
+	if !req.DateEnd.After(req.DateStart) {
+		return errInvalidRange
+	}
+	if d := req.DateEnd.Sub(req.DateStart); d > maxBatchRange {
+		return fmt.Errorf("%w: %v is longer than %v", errRangeTooLong, d, maxBatchRange)
+	}
+	if len(req.Businesses) == 0 || len(req.Businesses) > maxBatchBusinesses {
+		return fmt.Errorf("%w: 1 to %d businesses are expected", common.ErrInvalidArgument, maxBatchBusinesses)
+	}
+	return nil
+}
+
+// query returns query parameters of the slots endpoint for the business
+func (req batchSlotsPayload) query(b batchBusiness) url.Values {
+	v := url.Values{}
+	v.Set("date_start", req.DateStart.Format(time.RFC3339))
+	v.Set("date_end", req.DateEnd.Format(time.RFC3339))
+	if req.ChunkMinutes != 0 {
+		v.Set("chunk_minutes", strconv.Itoa(req.ChunkMinutes))
+	}
+	if b.ServiceId != "" {
+		v.Set("service_id", b.ServiceId)
+	}
+	if b.ResourceId != "" {
+		v.Set("resource_id", b.ResourceId)
+	}
+	return v
+}
+
+// batchSlots computes availability of every business with bounded parallelism.
+// Results are in the order of businesses, failure of one business does not affect others.
+func (a *api) batchSlots(ctx context.Context, req batchSlotsPayload, queryID string) []batchBusinessResult {
+	results := make([]batchBusinessResult, len(req.Businesses))
+	sem := make(chan struct{}, batchSlotsParallelism)
+	var wg sync.WaitGroup
+	for i, b := range req.Businesses {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result := batchBusinessResult{batchBusiness: b, Status: http.StatusOK}
+			availability, err := a.businessSlots(b.BusinessId, req.query(b), "", queryID)
+			if err == nil {
+				result.Availability = availability
+			} else {
+				result.Status, result.Code = errorStatus(err)
+				result.Error = err.Error()
+				if result.Status >= http.StatusInternalServerError {
+					slog.WarnContext(ctx, "[BatchSlots]", "business", b.BusinessId, "err", err.Error())
+					result.Error = http.StatusText(result.Status)
+				}
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// BatchSlotsPostFunc returns availability of several businesses in one range
+func (a *api) BatchSlotsPostFunc() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req batchSlotsPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "[BatchSlots] decode", "err", err.Error())
+			writeError(w, r, fmt.Errorf("%w: %s", errInvalidBody, err.Error()))
+			return
+		}
+		if err := req.validate(); err != nil {
+			slog.WarnContext(r.Context(), "[BatchSlots]", "err", err.Error())
+			writeError(w, r, err)
+			return
+		}
+
+		queryID := r.Context().Value(RequestIdKey{}).(string)
+		response := batchSlotsResult{
+			QueryId: queryID,
+			Results: a.batchSlots(r.Context(), req, queryID),
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.WarnContext(r.Context(), "[BatchSlots] encode", "err", err.Error())
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+)
+
+func TestBatchSlots(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	for i, business := range []common.ID{"b1", "b2"} {
+		addWorkingHours(t, a, business, day.Add(9*time.Hour), 1, time.Duration(i+1)*time.Hour)
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/slots/batch", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), RequestIdKey{}, "q1"))
+		w := httptest.NewRecorder()
+		a.BatchSlotsPostFunc()(w, req)
+		return w
+	}
+
+	body := fmt.Sprintf(`{"date_start":%q,"date_end":%q,"chunk_minutes":60,"businesses":[
+		{"business_id":"b1"},{"business_id":"b2"},{"business_id":"b2","service_id":"unknown"}]}`,
+		day.Format(time.RFC3339), day.Add(24*time.Hour).Format(time.RFC3339))
+	w := post(body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+
+	var result struct {
+		Results []struct {
+			BusinessId   common.ID `json:"business_id"`
+			Status       int       `json:"status"`
+			Availability struct {
+				Slots []json.RawMessage `json:"slots"`
+			} `json:"availability"`
+			Error string `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results) != 3 {
+		t.Fatalf("result for every business expected: %+v", result)
+	}
+	for i, slots := range []int{1, 2} {
+		r := result.Results[i]
+		if r.Status != http.StatusOK || len(r.Availability.Slots) != slots {
+			t.Fatalf("business %d: unexpected result %+v", i, r)
+		}
+	}
+	if r := result.Results[2]; r.BusinessId != "b2" || r.Status != http.StatusBadRequest || r.Error == "" {
+		t.Fatalf("failure of one business must be reported: %+v", r)
+	}
+
+	if w := post(`{"date_start":"2024-01-01T00:00:00Z","date_end":"2024-03-01T00:00:00Z","businesses":[{"business_id":"b1"}]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("too long range must be rejected: %d", w.Code)
+	}
+}
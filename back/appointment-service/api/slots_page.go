@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	swagger "scheduler/appointment-service/api/types"
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+)
+
+// slotsPage is a page of slots, the next page is requested with NextCursor
+type slotsPage struct {
+	swagger.AvailableSlots
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	// MaxChunkMinutes limits adjacent slots booked as one appointment, unset in range mode
+	MaxChunkMinutes int32 `json:"max_chunk_minutes,omitempty"`
+}
+
+// slotsCursor points to the last slot of the previous page.
+// Slots are ordered by start and then by length.
+type slotsCursor struct {
+	start time.Time
+	len   int32
+}
+
+func (c slotsCursor) String() string {
+	raw := strconv.FormatInt(c.start.Unix(), 10) + ":" + strconv.Itoa(int(c.len))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseSlotsCursor(s string) (slotsCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return slotsCursor{}, errInvalidCursor
+	}
+	startStr, lenStr, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return slotsCursor{}, errInvalidCursor
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return slotsCursor{}, errInvalidCursor
+	}
+	length, err := strconv.ParseInt(lenStr, 10, 32)
+	if err != nil {
+		return slotsCursor{}, errInvalidCursor
+	}
+	return slotsCursor{start: time.Unix(start, 0).UTC(), len: int32(length)}, nil
+}
+
+// precedes reports whether the slot was returned on previous pages
+func (c slotsCursor) precedes(slot swagger.Slot) bool {
+	if cmp := slot.TpStart.Compare(c.start); cmp != 0 {
+		return cmp < 0
+	}
+	return slot.Len <= c.len
+}
+
+// slotsPageQuery is the limit and the cursor of the requested page
+type slotsPageQuery struct {
+	limit int
+	after *slotsCursor
+}
+
+func getSlotsPageFromURL(v url.Values) (slotsPageQuery, error) {
+	q := slotsPageQuery{limit: common.DefaultSlotsPageSize}
+	if limitStr := v.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > common.MaxSlotsPageSize {
+			return slotsPageQuery{}, fmt.Errorf("%w: %q is not in [1, %d]", errLimitOutOfRange, limitStr, common.MaxSlotsPageSize)
+		}
+		q.limit = limit
+	}
+	if cursorStr := v.Get("cursor"); cursorStr != "" {
+		cursor, err := parseSlotsCursor(cursorStr)
+		if err != nil {
+			return slotsPageQuery{}, err
+		}
+		q.after = &cursor
+	}
+	return q, nil
+}
+
+// page returns ordered slots after the cursor up to the limit and the cursor of the next page.
+// Slots of the whole range are computed for every page, chunks are aligned to the start of
+// free time, so slots after the cursor can't be computed alone. The query span of the business
+// bounds the work, the page only bounds the response.
+func (q slotsPageQuery) page(slots []swagger.Slot) ([]swagger.Slot, string) {
+	if q.after != nil {
+		first := slices.IndexFunc(slots, func(slot swagger.Slot) bool { return !q.after.precedes(slot) })
+		if first < 0 {
+			return []swagger.Slot{}, ""
+		}
+		slots = slots[first:]
+	}
+	if len(slots) <= q.limit {
+		return slots, ""
+	}
+	slots = slots[:q.limit]
+	last := slots[len(slots)-1]
+	return slots, slotsCursor{start: last.TpStart, len: last.Len}.String()
+}
+
+// pageFree keeps free intervals with starts of the page slots only
+func pageFree(free []freeInterval, page []swagger.Slot) []freeInterval {
+	out := make([]freeInterval, 0)
+	if len(page) == 0 {
+		return out
+	}
+	first, last := page[0].TpStart, page[len(page)-1].TpStart
+	for _, fi := range free {
+		var starts []time.Time
+		for _, start := range fi.Starts {
+			if !start.Before(first) && !start.After(last) {
+				starts = append(starts, start)
+			}
+		}
+		if len(starts) != 0 {
+			fi.Starts = starts
+			out = append(out, fi)
+		}
+	}
+	return out
+}
+
+// getSlotsRangeFromURL returns the queried range. The range must not be empty
+// and must not be longer than the query span of the business.
+func getSlotsRangeFromURL(v url.Values, settings slotsdb.BusinessSlotSettings) (common.Interval, error) {
+	dateStart, err := getTimeFromURL("date_start", v)
+	if err != nil {
+		return common.Interval{}, fmt.Errorf("%w: %w", common.ErrInvalidArgument, err)
+	}
+	dateEnd, err := getTimeFromURL("date_end", v)
+	if err != nil {
+		return common.Interval{}, fmt.Errorf("%w: %w", common.ErrInvalidArgument, err)
+	}
+
+	interval := common.Interval{Start: dateStart, End: dateEnd}
+	if !dateEnd.After(dateStart) {
+		return common.Interval{}, errInvalidRange
+	}
+	if span := settings.QuerySpan(); interval.Duration() > span {
+		return common.Interval{}, fmt.Errorf("%w: %v is longer than %v", errRangeTooLong, interval.Duration(), span)
+	}
+	return interval, nil
+}
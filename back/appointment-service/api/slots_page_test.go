@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+)
+
+func TestSlotsPagination(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	addWorkingHours(t, a, "b1", day.Add(9*time.Hour), 1, 5*time.Hour)
+
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/slots/b1?"+query.Encode(), nil)
+		req = req.WithContext(context.WithValue(req.Context(), RequestIdKey{}, "q1"))
+		w := httptest.NewRecorder()
+		a.getSlotsByBusinessID(w, req, "b1", "")
+		return w
+	}
+	query := url.Values{}
+	query.Set("date_start", day.Format(time.RFC3339))
+	query.Set("date_end", day.Add(24*time.Hour).Format(time.RFC3339))
+	query.Set("chunk_minutes", "60")
+	query.Set("limit", "2")
+
+	var starts []time.Time
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination does not end")
+		}
+		w := get(query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body)
+		}
+		var page slotsPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		for _, slot := range page.Slots {
+			starts = append(starts, slot.TpStart)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	if len(starts) != 5 {
+		t.Fatalf("every slot once expected: %v", starts)
+	}
+	for i := 1; i < len(starts); i++ {
+		if !starts[i].After(starts[i-1]) {
+			t.Fatalf("slots are not ordered: %v", starts)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query map[string]string
+		err   error
+	}{
+		{"end before start", map[string]string{"date_end": day.Add(-time.Hour).Format(time.RFC3339)}, errInvalidRange},
+		{"empty range", map[string]string{"date_end": day.Format(time.RFC3339)}, errInvalidRange},
+		{"too long", map[string]string{"date_end": day.Add(common.MaxSlotsQuerySpan + time.Hour).Format(time.RFC3339)}, errRangeTooLong},
+		{"zero limit", map[string]string{"limit": "0"}, errLimitOutOfRange},
+		{"big limit", map[string]string{"limit": "100000"}, errLimitOutOfRange},
+		{"bad cursor", map[string]string{"cursor": "???"}, errInvalidCursor},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v := url.Values{}
+			v.Set("date_start", day.Format(time.RFC3339))
+			v.Set("date_end", day.Add(24*time.Hour).Format(time.RFC3339))
+			for k, value := range tc.query {
+				v.Set(k, value)
+			}
+			_, err := a.businessSlots("b1", v, "", "q1")
+			if !errors.Is(err, tc.err) || !errors.Is(err, common.ErrInvalidArgument) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	settings := defaultBusinessSlotSettings()
+	settings.MaxQuerySpan = 12 * time.Hour
+	if err := a.storages.TimeSlots.SetBusinessSlotSettings("b1", settings); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(query); w.Code != http.StatusBadRequest {
+		t.Fatalf("span of the business must be enforced: %d", w.Code)
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+	"scheduler/appointment-service/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+const maxSummaryDays = 62
+
+type dayStatus string
+
+const (
+	dayOpen dayStatus = "open"
+	// dayFullyBooked has bookable working time without free slots
+	dayFullyBooked dayStatus = "fully_booked"
+	// dayClosed has no bookable working time: a day off, the past or beyond the horizon
+	dayClosed dayStatus = "closed"
+)
+
+type daySummary struct {
+	Date      string    `json:"date"`
+	Status    dayStatus `json:"status"`
+	FreeSlots int       `json:"free_slots"`
+	// Start of the first free slot
+	FirstFree *time.Time `json:"first_free,omitempty"`
+	// End of the last free slot
+	LastFree *time.Time `json:"last_free,omitempty"`
+}
+
+type slotsSummary struct {
+	TimeZone string       `json:"time_zone"`
+	Days     []daySummary `json:"days"`
+}
+
+// getDateRangeFromURL returns midnights of date_from and the day after date_to in the location
+func getDateRangeFromURL(v url.Values, loc *time.Location) (common.Interval, error) {
+	from, err := time.ParseInLocation(time.DateOnly, v.Get("date_from"), loc)
+	if err != nil {
+		return common.Interval{}, fmt.Errorf("%w: date_from: %s", common.ErrInvalidArgument, err.Error())
+	}
+	to, err := time.ParseInLocation(time.DateOnly, v.Get("date_to"), loc)
+	if err != nil {
+		return common.Interval{}, fmt.Errorf("%w: date_to: %s", common.ErrInvalidArgument, err.Error())
+	}
+	if to.Before(from) || to.After(from.AddDate(0, 0, maxSummaryDays-1)) {
+		return common.Interval{}, fmt.Errorf("%w: date range must have 1 to %d days", common.ErrInvalidArgument, maxSummaryDays)
+	}
+	return common.Interval{Start: from, End: to.AddDate(0, 0, 1)}, nil
+}
+
+// summarizeDays counts free slots of every day without chunking free time into intervals.
+// Free time is not united across resources, a slot must fit free time of a single resource.
+// A slot offered by several resources is counted once as in the slots endpoint.
+func summarizeDays(days common.Interval, byResource map[common.ID]slotsdb.Availability, working common.Intervals,
+	settings slotsdb.BusinessSlotSettings, chunk time.Duration, now time.Time) []daySummary {
+	step, length := chunk, chunk
+	if settings.IsRangeMode() {
+		step, length = settings.Duration.Step, settings.Duration.Min
+	}
+
+	var free common.Intervals
+	sessions := make(map[common.Interval]struct{})
+	for _, availability := range byResource {
+		free = append(free, availability.Free...)
+		for _, session := range availability.Sessions {
+			sessions[session.Interval] = struct{}{}
+		}
+	}
+	free.SortByStart()
+
+	// Starts allowed by the policy, the past is never bookable
+	bookable := common.Interval{Start: now.Add(settings.Policy.MinLeadTime), End: days.End}
+	if settings.Policy.MaxHorizon != 0 {
+		bookable.End = now.Add(settings.Policy.MaxHorizon + time.Nanosecond)
+	}
+
+	out := make([]daySummary, 0)
+	for dayStart := days.Start; dayStart.Before(days.End); dayStart = dayStart.AddDate(0, 0, 1) {
+		day := common.Interval{Start: dayStart, End: dayStart.AddDate(0, 0, 1)}
+		summary := daySummary{Date: dayStart.Format(time.DateOnly), Status: dayClosed}
+
+		starts := day.Intersection(bookable)
+		if !starts.IsValid() || !working.IsOverlap(common.Interval{Start: starts.Start, End: day.End}) {
+			out = append(out, summary)
+			continue
+		}
+		summary.Status = dayFullyBooked
+
+		// Slots of several resources are the same slot
+		slots := make(map[common.Interval]struct{})
+		for _, interval := range free {
+			if !interval.End.After(starts.Start) {
+				continue
+			}
+			if !interval.Start.Before(starts.End) {
+				break
+			}
+			n, firstStart, _ := interval.StepsBetween(step, length, starts.Start, starts.End)
+			for k := range n {
+				start := firstStart.Add(time.Duration(k) * step)
+				slots[common.Interval{Start: start, End: start.Add(length)}] = struct{}{}
+			}
+		}
+		if !settings.IsRangeMode() {
+			for session := range sessions {
+				if !session.Start.Before(starts.Start) && session.Start.Before(starts.End) {
+					slots[session] = struct{}{}
+				}
+			}
+		}
+
+		var first, last time.Time
+		for slot := range slots {
+			if first.IsZero() || slot.Start.Before(first) {
+				first = slot.Start
+			}
+			if slot.End.After(last) {
+				last = slot.End
+			}
+		}
+		summary.FreeSlots = len(slots)
+		if summary.FreeSlots != 0 {
+			summary.Status = dayOpen
+			first, last = first.UTC(), last.UTC()
+			summary.FirstFree, summary.LastFree = &first, &last
+		}
+		out = append(out, summary)
+	}
+	return out
+}
+
+// writeJSONWithETag responds with 304 if the client has the same representation
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match := r.Header.Get("If-None-Match"); match == etag || match == "W/"+etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// getSlotsSummary writes per-day aggregates of free slots in the time zone of the business
+func (a *api) getSlotsSummary(w http.ResponseWriter, r *http.Request, businessID string) {
+	if businessID == "" {
+		slog.WarnContext(r.Context(), "business_id not found")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	settings, err := a.getBusinessSlotSettings(businessID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[SlotsSummary]", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	loc := settings.Location()
+	days, err := getDateRangeFromURL(query, loc)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[SlotsSummary]", "err", err.Error())
+		writeError(w, r, err)
+		return
+	}
+	chunk, err := getSlotChunkFromURL(query, settings)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[SlotsSummary]", "err", err.Error())
+		writeError(w, r, err)
+		return
+	}
+	resources, err := a.requestedResources(businessID, query)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[SlotsSummary]", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	timer := metrics.AvailabilityTimer("summary")
+	byResource, err := a.storages.TimeSlots.GetResourcesAvailabilityInRange(businessID, resources, days)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[SlotsSummary]", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	working, err := a.storages.TimeSlots.GetWorkingTimeInRange(businessID, resources, days)
+	if err != nil {
+		slog.WarnContext(r.Context(), "[SlotsSummary]", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := slotsSummary{
+		TimeZone: loc.String(),
+		Days:     summarizeDays(days, byResource, working, settings, chunk, time.Now()),
+	}
+	timer.ObserveDuration()
+	if err := writeJSONWithETag(w, r, response); err != nil {
+		slog.WarnContext(r.Context(), "[SlotsSummary] encode", "err", err.Error())
+	}
+}
+
+func (a *api) SlotsSummaryGetFunc() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.getSlotsSummary(w, r, mux.Vars(r)["business_id"])
+	}
+}
+
+func (a *api) SlotsSummaryWebAppGetFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[SlotsSummaryWebAppGet]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		a.getSlotsSummary(w, r, string(authResult.Business))
+	}
+}
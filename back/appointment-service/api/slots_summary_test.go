@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSlotsSummary(t *testing.T) {
+	a := newTestAPI(t)
+
+	settings := defaultBusinessSlotSettings()
+	settings.DefaultChunk = time.Hour
+	settings.TimeZone = "Nowhere/City"
+	if err := a.storages.TimeSlots.SetBusinessSlotSettings("b1", settings); err == nil {
+		t.Fatal("unknown time zone must be rejected")
+	}
+	settings.TimeZone = "Asia/Almaty"
+	if err := a.storages.TimeSlots.SetBusinessSlotSettings("b1", settings); err != nil {
+		t.Fatal(err)
+	}
+	loc := settings.Location()
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	day := today.AddDate(0, 0, 1)
+	addWorkingHours(t, a, "b1", day.Add(9*time.Hour), 3, 3*time.Hour)
+	booked := day.AddDate(0, 0, 1).Add(9 * time.Hour)
+	err := a.storages.TimeSlots.AddSlots(slotsdb.AddSlotsData{
+		Business: "b1",
+		Customer: "c1",
+		Slots:    common.Intervals{{Start: booked, End: booked.Add(3 * time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		t.Helper()
+		query := "?date_from=" + today.Format(time.DateOnly) + "&date_to=" + today.AddDate(0, 0, 4).Format(time.DateOnly)
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/slots/b1/summary"+query, nil), map[string]string{"business_id": "b1"})
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		a.SlotsSummaryGetFunc()(w, req)
+		return w
+	}
+
+	w := get("")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	var summary slotsSummary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.TimeZone != "Asia/Almaty" || len(summary.Days) != 5 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	expected := []struct {
+		status dayStatus
+		free   int
+	}{{dayClosed, 0}, {dayOpen, 3}, {dayFullyBooked, 0}, {dayOpen, 3}, {dayClosed, 0}}
+	for i, e := range expected {
+		d := summary.Days[i]
+		if d.Date != today.AddDate(0, 0, i).Format(time.DateOnly) || d.Status != e.status || d.FreeSlots != e.free {
+			t.Fatalf("day %d: unexpected summary %+v", i, d)
+		}
+	}
+	open := summary.Days[1]
+	if !open.FirstFree.Equal(day.Add(9*time.Hour)) || !open.LastFree.Equal(day.Add(12*time.Hour)) {
+		t.Fatalf("unexpected free time bounds: %v %v", open.FirstFree, open.LastFree)
+	}
+
+	if w := get(w.Header().Get("ETag")); w.Code != http.StatusNotModified {
+		t.Fatalf("unchanged summary must not be sent again: %d", w.Code)
+	}
+}
+
+func TestSummarizeDaysPerResource(t *testing.T) {
+	day := tomorrow()
+	workStart := day.Add(9 * time.Hour)
+	byResource := map[common.ID]slotsdb.Availability{
+		"r1": {Free: common.Intervals{{Start: workStart, End: workStart.Add(45 * time.Minute)}}},
+		"r2": {Free: common.Intervals{{Start: workStart.Add(45 * time.Minute), End: workStart.Add(90 * time.Minute)}}},
+		"r3": {Free: common.Intervals{{Start: workStart, End: workStart.Add(45 * time.Minute)}}},
+	}
+	working := common.Intervals{{Start: workStart, End: workStart.Add(90 * time.Minute)}}
+
+	// 09:30 - 10:00 is free in the united time only, equal free time of r1 and r3 is counted once
+	days := summarizeDays(common.Interval{Start: day, End: day.Add(24 * time.Hour)}, byResource, working,
+		defaultBusinessSlotSettings(), 30*time.Minute, day)
+	if len(days) != 1 || days[0].Status != dayOpen || days[0].FreeSlots != 2 {
+		t.Fatalf("unexpected summary: %+v", days)
+	}
+	if !days[0].FirstFree.Equal(workStart) || !days[0].LastFree.Equal(workStart.Add(75*time.Minute)) {
+		t.Fatalf("unexpected free time: %v %v", days[0].FirstFree, days[0].LastFree)
+	}
+
+	// Slots common to overlapping free time of r1 and r2 are counted once as in the slot list
+	byResource = map[common.ID]slotsdb.Availability{
+		"r1": {Free: common.Intervals{{Start: workStart, End: workStart.Add(8 * time.Hour)}}},
+		"r2": {Free: common.Intervals{{Start: workStart, End: workStart.Add(7 * time.Hour)}}},
+	}
+	working = common.Intervals{{Start: workStart, End: workStart.Add(8 * time.Hour)}}
+	days = summarizeDays(common.Interval{Start: day, End: day.Add(24 * time.Hour)}, byResource, working,
+		defaultBusinessSlotSettings(), time.Hour, day)
+	if len(days) != 1 || days[0].FreeSlots != len(unitedSlots(byResource, time.Hour)) || days[0].FreeSlots != 8 {
+		t.Fatalf("unexpected summary: %+v", days)
+	}
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	swagger "scheduler/appointment-service/api/types"
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+)
+
+const (
+	// Alternatives for cancelled appointments are searched after the time off within the window
+	timeOffAlternativesWindow = 14 * 24 * time.Hour
+	timeOffAlternativesCount  = 3
+)
+
+type timeOffPayload struct {
+	DateStart          time.Time `json:"date_start"`
+	DateEnd            time.Time `json:"date_end"`
+	ResourceId         common.ID `json:"resource_id,omitempty"`
+	CancelAppointments bool      `json:"cancel_appointments,omitempty"`
+	// BookingCodes of appointments listed by the preview, other affected appointments are not cancelled
+	BookingCodes []string `json:"booking_codes,omitempty"`
+	Comment      string   `json:"comment,omitempty"`
+}
+
+type affectedAppointment struct {
+	CustomerId   common.ID      `json:"customer_id"`
+	ResourceId   common.ID      `json:"resource_id,omitempty"`
+	TpStart      time.Time      `json:"tp_start"`
+	Len          int32          `json:"len"`
+	BookingCode  string         `json:"booking_code"`
+	Alternatives []swagger.Slot `json:"alternatives"`
+}
+
+type timeOffResult struct {
+	RuleId    slotsdb.RuleID        `json:"rule_id,omitempty"`
+	Cancelled bool                  `json:"cancelled"`
+	Affected  []affectedAppointment `json:"affected"`
+}
+
+func decodeTimeOff(r *http.Request) (timeOffPayload, common.Interval, error) {
+	var req timeOffPayload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, common.Interval{}, err
+	}
+	interval := common.Interval{Start: req.DateStart, End: req.DateEnd}
+	if !interval.IsValid() {
+		return req, interval, common.ErrInvalidArgument
+	}
+	return req, interval, nil
+}
+
+// suggestAlternatives returns free slots of the same length on the same resource
+// after the time off, keyed by booking code of the appointment. Customers are offered
+// different slots, so they do not compete for the same time.
+func (a *api) suggestAlternatives(businessID common.ID, affected []common.BusySlot, after time.Time) (map[string]common.Intervals, error) {
+	resources := make([]common.ID, 0)
+	seen := make(map[common.ID]bool)
+	for _, slot := range affected {
+		if !seen[slot.Resource] {
+			seen[slot.Resource] = true
+			resources = append(resources, slot.Resource)
+		}
+	}
+	if len(resources) == 0 {
+		return nil, nil
+	}
+
+	between := common.Interval{Start: after, End: after.Add(timeOffAlternativesWindow)}
+	free, err := a.storages.TimeSlots.GetResourcesAvailableSlotsInRange(businessID, resources, between)
+	if err != nil {
+		return nil, err
+	}
+
+	offered := make(map[common.ID]common.Intervals, len(resources))
+	out := make(map[string]common.Intervals, len(affected))
+	for _, slot := range affected {
+		var alternatives common.Intervals
+		for _, chunk := range common.ChunkIntervals(free[slot.Resource], slot.Duration()) {
+			if len(alternatives) == timeOffAlternativesCount {
+				break
+			}
+			if !offered[slot.Resource].IsOverlap(chunk) {
+				alternatives = append(alternatives, chunk)
+			}
+		}
+		offered[slot.Resource] = append(offered[slot.Resource], alternatives...)
+		out[slot.BookingCode] = alternatives
+	}
+	return out, nil
+}
+
+func encodeAffected(affected []common.BusySlot, alternatives map[string]common.Intervals) []affectedAppointment {
+	out := make([]affectedAppointment, 0, len(affected))
+	for _, slot := range affected {
+		appt := affectedAppointment{
+			CustomerId:   slot.Customer,
+			ResourceId:   slot.Resource,
+			TpStart:      slot.Start.UTC(),
+			Len:          int32(slot.Duration().Minutes()),
+			BookingCode:  slot.BookingCode,
+			Alternatives: make([]swagger.Slot, 0),
+		}
+		for _, alt := range alternatives[slot.BookingCode] {
+			appt.Alternatives = append(appt.Alternatives, swagger.Slot{TpStart: alt.Start.UTC(), Len: int32(alt.Duration().Minutes())})
+		}
+		out = append(out, appt)
+	}
+	return out
+}
+
+// TimeOffPreviewHandler lists appointments affected by the time off with suggested alternatives.
+// Nothing is changed.
+func (a *api) TimeOffPreviewHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		req, interval, err := decodeTimeOff(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[TimeOffPreview] decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		affected, err := a.storages.TimeSlots.GetTimeOffImpact(uid, req.ResourceId, interval)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[TimeOffPreview]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		alternatives, err := a.suggestAlternatives(uid, affected, interval.End)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[TimeOffPreview] alternatives", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		result := timeOffResult{Affected: encodeAffected(affected, alternatives)}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.WarnContext(r.Context(), "[TimeOffPreview] encode", "err", err.Error())
+		}
+	}
+}
+
+// TimeOffHandler blocks the interval and, if requested, cancels affected appointments
+// notifying customers with suggested alternatives. The block and the cancellations are atomic.
+// Cancellation is refused if affected appointments differ from the booking codes of the preview.
+func (a *api) TimeOffHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		req, interval, err := decodeTimeOff(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[TimeOff] decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var alternatives map[string]common.Intervals
+		if req.CancelAppointments {
+			affected, err := a.storages.TimeSlots.GetTimeOffImpact(uid, req.ResourceId, interval)
+			if err == nil {
+				alternatives, err = a.suggestAlternatives(uid, affected, interval.End)
+			}
+			if err != nil {
+				slog.WarnContext(r.Context(), "[TimeOff] alternatives", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		ruleID, affected, err := a.storages.TimeSlots.ApplyTimeOff(slotsdb.TimeOff{
+			Business:     uid,
+			Resource:     req.ResourceId,
+			Interval:     interval,
+			Cancel:       req.CancelAppointments,
+			Expected:     req.BookingCodes,
+			Comment:      req.Comment,
+			Alternatives: alternatives,
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "[TimeOff]", "err", err.Error())
+			switch {
+			case errors.Is(err, slotsdb.ErrImpactChanged):
+				writeError(w, r, err)
+			case errors.Is(err, common.ErrNotFound):
+				writeProblem(w, r, http.StatusBadRequest, codeUnknownResource, "")
+			case errors.Is(err, common.ErrInvalidArgument):
+				w.WriteHeader(http.StatusBadRequest)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		// Kept appointments are outside working time now
+		if len(affected) != 0 && !req.CancelAppointments {
+			a.runInBackground(r.Context(), func(ctx context.Context) { a.checkRuleCoverage(ctx, uid) })
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		result := timeOffResult{RuleId: ruleID, Cancelled: req.CancelAppointments, Affected: encodeAffected(affected, alternatives)}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.WarnContext(r.Context(), "[TimeOff] encode", "err", err.Error())
+		}
+	}
+}
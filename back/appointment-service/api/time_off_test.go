@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+)
+
+func TestTimeOffPreviewAndApply(t *testing.T) {
+	a := newTestAPI(t)
+
+	day := tomorrow()
+	workStart := day.Add(9 * time.Hour)
+	addWorkingHours(t, a, "b1", workStart, 3, 3*time.Hour)
+	book := func(customer common.ID, start time.Time) {
+		t.Helper()
+		err := a.storages.TimeSlots.AddSlots(slotsdb.AddSlotsData{
+			Business: "b1",
+			Customer: customer,
+			Slots:    common.Intervals{{Start: start, End: start.Add(time.Hour)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	book("c1", workStart)
+	book("c2", workStart.Add(time.Hour))
+
+	post := func(handler http.HandlerFunc, codes []string) *httptest.ResponseRecorder {
+		t.Helper()
+		body, err := json.Marshal(timeOffPayload{DateStart: day, DateEnd: day.Add(24 * time.Hour), CancelAppointments: true, BookingCodes: codes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/time_off", bytes.NewBuffer(body))
+		req = withBusiness(req)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) timeOffResult {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d", w.Code)
+		}
+		var result timeOffResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	preview := decode(post(a.TimeOffPreviewHandler(), nil))
+	if preview.RuleId != "" || len(preview.Affected) != 2 {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	offered := make(map[time.Time]bool)
+	for _, appt := range preview.Affected {
+		alternatives := appt.Alternatives
+		if len(alternatives) != timeOffAlternativesCount || alternatives[0].TpStart.Before(day.Add(24*time.Hour)) || alternatives[0].Len != 60 {
+			t.Fatalf("alternatives must be found after the time off: %+v", alternatives)
+		}
+		for _, alt := range alternatives {
+			if offered[alt.TpStart] {
+				t.Fatalf("customers must be offered different alternatives: %+v", preview.Affected)
+			}
+			offered[alt.TpStart] = true
+		}
+	}
+	if !preview.Affected[0].Alternatives[0].TpStart.Equal(workStart.Add(24 * time.Hour)) {
+		t.Fatalf("the earliest alternative must be offered first: %+v", preview.Affected[0].Alternatives)
+	}
+	if busy, _ := a.storages.TimeSlots.GetBusySlotsInRange("b1", common.Interval{Start: day, End: day.Add(24 * time.Hour)}); len(busy) != 2 {
+		t.Fatalf("preview must not change appointments: %v", busy)
+	}
+	codes := []string{preview.Affected[0].BookingCode, preview.Affected[1].BookingCode}
+
+	// Appointment booked after the preview must be seen by the owner before cancellation
+	book("c3", workStart.Add(2*time.Hour))
+	if w := post(a.TimeOffHandler(), codes); w.Code != http.StatusConflict {
+		t.Fatalf("changed appointments must be rejected, got %d", w.Code)
+	}
+	if busy, _ := a.storages.TimeSlots.GetBusySlotsInRange("b1", common.Interval{Start: day, End: day.Add(24 * time.Hour)}); len(busy) != 3 {
+		t.Fatalf("rejected time off must not change appointments: %v", busy)
+	}
+
+	preview = decode(post(a.TimeOffPreviewHandler(), nil))
+	codes = nil
+	for _, appt := range preview.Affected {
+		codes = append(codes, appt.BookingCode)
+	}
+	result := decode(post(a.TimeOffHandler(), codes))
+	if result.RuleId == "" || !result.Cancelled || len(result.Affected) != 3 {
+		t.Fatalf("unexpected time off: %+v", result)
+	}
+	if busy, _ := a.storages.TimeSlots.GetBusySlotsInRange("b1", common.Interval{Start: day, End: day.Add(24 * time.Hour)}); len(busy) != 0 {
+		t.Fatalf("affected appointments must be cancelled: %v", busy)
+	}
+}
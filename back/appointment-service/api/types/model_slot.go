@@ -16,4 +16,6 @@ type Slot struct {
 	TpStart time.Time `json:"tp_start,omitempty"`
 
 	Len int32 `json:"len,omitempty"`
+
+	SeatsLeft int32 `json:"seats_left,omitempty"`
 }
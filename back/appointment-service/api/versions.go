@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// apiV1 is the path prefix of the current API version
+	apiV1 = "/v1"
+)
+
+var (
+	// Unversioned paths are aliases of v1 deprecated since versioning
+	legacyPathsDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacyPathsSunset       = time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// addV1Handlers registers routes of API v1. A next version gets its own function
+// and reuses handlers which are not changed.
+func (a *api) addV1Handlers(r *mux.Router) {
+	a.addTimeSlotsHandlers(r)
+	a.addBusinessRulesHandlers(r)
+	a.addTimeOffHandlers(r)
+	a.addAttentionHandlers(r)
+	a.addResourcesHandlers(r)
+	a.addWaitlistHandlers(r)
+	a.addApprovalsHandlers(r)
+	a.addCustomersHandlers(r)
+	a.addAttendanceHandlers(r)
+	a.addUserAccountHandlers(r)
+	a.addOIDCHandlers(r)
+}
+
+// DeprecatedAlias marks responses of deprecated paths with Deprecation, Sunset and
+// the successor Link headers (RFC 9745, RFC 8594) and logs their use
+func DeprecatedAlias(successorPrefix string, deprecatedAt, sunset time.Time) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route = current.GetName()
+			}
+			slog.WarnContext(r.Context(), "[DeprecatedPath]", "path", r.URL.Path, "route", route,
+				"client", r.Header.Get("X-Client-ID"), "user_agent", r.UserAgent())
+
+			h := w.Header()
+			h.Set("Deprecation", "@"+strconv.FormatInt(deprecatedAt.Unix(), 10))
+			h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			h.Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successorPrefix, r.URL.Path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLegacyPathsAreDeprecatedAliases(t *testing.T) {
+	a := newTestAPI(t)
+	r := a.Router()
+
+	query := "?date_from=" + time.Now().Format(time.DateOnly) + "&date_to=" + time.Now().Format(time.DateOnly)
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	w := get("/v1/slots/b1/summary" + query)
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" {
+		t.Fatalf("v1 path: %d %v", w.Code, w.Header())
+	}
+
+	w = get("/slots/b1/summary" + query)
+	if w.Code != http.StatusOK {
+		t.Fatalf("legacy path: %d", w.Code)
+	}
+	if w.Header().Get("Deprecation") == "" || w.Header().Get("Sunset") != legacyPathsSunset.Format(http.TimeFormat) ||
+		w.Header().Get("Link") != `</v1/slots/b1/summary>; rel="successor-version"` {
+		t.Fatalf("deprecation headers expected: %v", w.Header())
+	}
+
+	if w := get("/v2/slots/b1/summary" + query); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown version: %d", w.Code)
+	}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+
+	"github.com/gorilla/mux"
+)
+
+type waitlistPayload struct {
+	DateStart time.Time `json:"date_start"`
+	DateEnd   time.Time `json:"date_end"`
+	ServiceId common.ID `json:"service_id,omitempty"`
+}
+
+// waitlistSlotFreedPayload is sent to the customer with a one-off booking token
+// usable with POST /slots/once until ExpiresAt
+type waitlistSlotFreedPayload struct {
+	WaitlistId common.ID `json:"waitlist_id"`
+	DateStart  time.Time `json:"date_start"`
+	DateEnd    time.Time `json:"date_end"`
+	ServiceId  common.ID `json:"service_id,omitempty"`
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+const notificationsBatchLimit = 100
+
+func (a *api) WaitlistPostFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[WaitlistPost]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !a.checkCustomerAllowed(w, r, authResult) {
+			return
+		}
+
+		var req waitlistPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "[WaitlistPost] decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !req.DateEnd.After(time.Now()) {
+			slog.WarnContext(r.Context(), "[WaitlistPost] date_end in the past")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if req.ServiceId != "" {
+			if _, err := a.storages.TimeSlots.GetService(authResult.Business, req.ServiceId); err != nil {
+				slog.WarnContext(r.Context(), "[WaitlistPost] service_id", "err", err.Error())
+				if errors.Is(err, common.ErrNotFound) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		id, err := a.storages.TimeSlots.AddWaitlistEntry(slotsdb.WaitlistEntry{
+			Business: authResult.Business,
+			Customer: authResult.Customer,
+			Service:  req.ServiceId,
+			Interval: common.Interval{Start: req.DateStart, End: req.DateEnd},
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "[WaitlistPost]", "err", err.Error())
+			if errors.Is(err, common.ErrInvalidArgument) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(idResult{Id: id}); err != nil {
+			slog.WarnContext(r.Context(), "[WaitlistPost] encode", "err", err.Error())
+		}
+	}
+}
+
+func (a *api) WaitlistDeleteFunc(au AddSlotsAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authResult, err := au.Authorization(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[WaitlistDelete]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = a.storages.TimeSlots.DeleteWaitlistEntry(authResult.Business, authResult.Customer, mux.Vars(r)["id"])
+		if err != nil {
+			slog.WarnContext(r.Context(), "[WaitlistDelete]", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// NotificationsGetHandler returns notifications which the business bot should deliver to customers
+func (a *api) NotificationsGetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		notifications, err := a.storages.TimeSlots.GetPendingNotifications(uid, notificationsBatchLimit)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[NotificationsGet]", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if err := json.NewEncoder(w).Encode(notifications); err != nil {
+			slog.WarnContext(r.Context(), "[NotificationsGet] encode", "err", err.Error())
+		}
+	}
+}
+
+func (a *api) NotificationAckHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := GetUserID(r.Context())
+		if !ok {
+			panic("uid not found")
+		}
+
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			slog.WarnContext(r.Context(), "[NotificationAck]", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := a.storages.TimeSlots.AckNotification(uid, id); err != nil {
+			slog.WarnContext(r.Context(), "[NotificationAck]", "err", err.Error())
+			if errors.Is(err, common.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// evaluateWaitlist notifies the first customer in FIFO order whose waitlist
+// interval has time bookable by the customer. It is called when time of the business is freed.
+func (a *api) evaluateWaitlist(ctx context.Context, businessID common.ID) {
+	now := time.Now()
+	entries, err := a.storages.TimeSlots.GetPendingWaitlist(businessID, now)
+	if err != nil {
+		slog.WarnContext(ctx, "[EvaluateWaitlist]", "err", err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	settings, err := a.getBusinessSlotSettings(businessID)
+	if err != nil {
+		slog.WarnContext(ctx, "[EvaluateWaitlist]", "err", err.Error())
+		return
+	}
+
+	for _, entry := range entries {
+		between := entry.Interval
+		if between.Start.Before(now) {
+			between.Start = now
+		}
+
+		resources := []common.ID{slotsdb.DefaultResource}
+		if entry.Service != "" {
+			service, err := a.storages.TimeSlots.GetService(businessID, entry.Service)
+			if err != nil {
+				slog.WarnContext(ctx, "[EvaluateWaitlist] service", "err", err.Error(), "waitlist_id", entry.Id)
+				continue
+			}
+			resources = slotsdb.ServiceResources(service)
+		}
+
+		availability, err := a.storages.TimeSlots.GetResourcesAvailabilityInRange(businessID, resources, between)
+		if err != nil {
+			slog.WarnContext(ctx, "[EvaluateWaitlist]", "err", err.Error())
+			return
+		}
+		booked, err := a.customerBookings(businessID, entry.Customer, settings.Location(), now)
+		if err != nil {
+			slog.WarnContext(ctx, "[EvaluateWaitlist]", "err", err.Error())
+			return
+		}
+		if len(allowedSlots(unitedSlots(availability, settings.DefaultChunk), settings.Policy, settings.Location(), now, booked, true)) == 0 {
+			continue
+		}
+
+		if err := a.notifyWaitlistEntry(entry); err != nil {
+			slog.WarnContext(ctx, "[EvaluateWaitlist] notify", "err", err.Error(), "waitlist_id", entry.Id)
+			continue
+		}
+		slog.InfoContext(ctx, "[EvaluateWaitlist] customer notified", "waitlist_id", entry.Id)
+		return
+	}
+}
+
+func (a *api) notifyWaitlistEntry(entry slotsdb.WaitlistEntry) error {
+	token := common.GenerateSecretKey(24)
+	expiresAt := time.Now().Add(common.DefaultWaitlistLinkTTL)
+	if err := a.storages.OneOffTokens.AddUserToken(entry.Business, entry.Customer, token, expiresAt); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(waitlistSlotFreedPayload{
+		WaitlistId: entry.Id,
+		DateStart:  entry.Interval.Start.UTC(),
+		DateEnd:    entry.Interval.End.UTC(),
+		ServiceId:  entry.Service,
+		Token:      token,
+		ExpiresAt:  expiresAt.UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.storages.TimeSlots.NotifyWaitlistEntry(entry, slotsdb.Notification{
+		Business: entry.Business,
+		Customer: entry.Customer,
+		Kind:     slotsdb.NotificationWaitlistSlotFreed,
+		Payload:  payload,
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	dbauth "scheduler/appointment-service/internal/dbase/auth"
+	slotsdb "scheduler/appointment-service/internal/dbase/backend/slots"
+)
+
+func TestEvaluateWaitlist(t *testing.T) {
+	a := newTestAPI(t)
+	a.storages.OneOffTokens = &dbauth.OneOffTokenStorage{DB: a.storages.TimeSlots.DB}
+
+	day := tomorrow()
+	workStart := day.Add(9 * time.Hour)
+	addWorkingHours(t, a, "b1", workStart, 1, time.Hour)
+	err := a.storages.TimeSlots.AddSlots(slotsdb.AddSlotsData{
+		Business: "b1",
+		Customer: "c0",
+		Slots:    common.Intervals{{Start: workStart, End: workStart.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, customer := range []common.ID{"c1", "c2"} {
+		_, err := a.storages.TimeSlots.AddWaitlistEntry(slotsdb.WaitlistEntry{
+			Business: "b1",
+			Customer: customer,
+			Interval: common.Interval{Start: day, End: day.Add(24 * time.Hour)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fully booked day: nobody is notified
+	a.evaluateWaitlist(context.Background(), "b1")
+	notifications, err := a.storages.TimeSlots.GetPendingNotifications("b1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 0 {
+		t.Fatalf("unexpected notifications: %v", notifications)
+	}
+
+	if err := a.storages.TimeSlots.CancelAppointment("b1", "c0", workStart); err != nil {
+		t.Fatal(err)
+	}
+	a.evaluateWaitlist(context.Background(), "b1")
+
+	notifications, err = a.storages.TimeSlots.GetPendingNotifications("b1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].Customer != "c1" || notifications[0].Kind != slotsdb.NotificationWaitlistSlotFreed {
+		t.Fatalf("expected notification for the first customer: %v", notifications)
+	}
+
+	var payload waitlistSlotFreedPayload
+	if err := json.Unmarshal(notifications[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := a.storages.OneOffTokens.ExchangeToken(payload.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.BusinessID != "b1" || entry.CustomerID != "c1" {
+		t.Fatalf("unexpected token owner: %+v", entry)
+	}
+
+	if err := a.storages.TimeSlots.AckNotification("b1", notifications[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := a.storages.TimeSlots.GetPendingWaitlist("b1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Customer != "c2" {
+		t.Fatalf("second customer must stay in the waitlist: %v", pending)
+	}
+}
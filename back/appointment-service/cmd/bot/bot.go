@@ -13,12 +13,15 @@ import (
 	"scheduler/appointment-service/internal/bot/command"
 	"scheduler/appointment-service/internal/bot/i18n/dicts"
 	"scheduler/appointment-service/internal/bot/i18n/messages"
+	"scheduler/appointment-service/internal/tracing"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"golang.org/x/text/language"
 )
@@ -39,7 +42,18 @@ func main() {
 	logger := common.NewLoggerWithCtxHandler(slog.NewTextHandler(os.Stdout, slogOpts))
 	slog.SetDefault(logger)
 
-	opts := []bot.Option{bot.WithDebug()} //TODO
+	shutdownTracing, err := tracing.Setup(ctx, "appointment-bot", cfg.Tracing)
+	if err != nil {
+		slog.Error("[tracing.Setup]", "err", err.Error())
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("[tracing.Shutdown]", "err", err.Error())
+		}
+	}()
+
+	opts := []bot.Option{bot.WithDebug(), bot.WithMiddlewares(traceUpdates)} //TODO
 
 	b, err := bot.New(cfg.BotAPIConnection, opts...)
 	if err != nil {
@@ -73,25 +87,78 @@ func main() {
 		"slot_id_", //TODO move it to bot package
 		bot.MatchTypePrefix,
 		makeOptionsCallbackHandler(dialogStorage))
+	if cfg.OwnerId != 0 {
+		// Must be registered before the customer messages handler
+		requests := &command.HttpBookingRequests{Connection: &cfg.SchedulerAPI}
+		b.RegisterHandlerMatchFunc(ownerMatchFunc(cfg.OwnerId), makeOwnerHandler(dialogStorage, requests))
+	}
 	b.RegisterHandlerMatchFunc(messageMatchFunc, makeHandler(dialogStorage))
+
+	notifications := &command.HttpNotifications{Connection: &cfg.SchedulerAPI}
+	notificationsPoll := common.NewPeriodicCallback(notificationsPollInterval, func() {
+		ctx, span := tracing.Start(tracing.WithRequestID(ctx, tracing.NewRequestID()), "notifications poll")
+		defer span.End()
+		err := dialogStorage.DeliverNotifications(ctx, notifications, cfg.BookingURL)
+		if err != nil {
+			slog.ErrorContext(ctx, "[DeliverNotifications]", "err", err.Error())
+		}
+	})
+	notificationsPoll.Start()
+	defer notificationsPoll.Stop()
+
 	b.Start(ctx)
 }
 
+const notificationsPollInterval = 30 * time.Second
+
+// traceUpdates gives each update its own request ID and trace, they are passed
+// in all calls to the scheduler made while the update is handled
+func traceUpdates(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		ctx = tracing.WithRequestID(ctx, tracing.NewRequestID())
+		ctx, span := tracing.Start(ctx, "telegram update",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attribute.Int64("telegram.update_id", update.ID)))
+		defer span.End()
+		next(ctx, b, update)
+	}
+}
+
 func messageMatchFunc(update *models.Update) bool {
 	return update.Message != nil
 }
 
+func ownerMatchFunc(ownerID int64) bot.MatchFunc {
+	return func(update *models.Update) bool {
+		return update.Message != nil && update.Message.From != nil &&
+			update.Message.From.ID == ownerID && command.IsOwnerCommand(update.Message.Text)
+	}
+}
+
+func makeOwnerHandler(ds *command.DialogsStorage, requests command.OwnerCommandsProvider) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		chctx := &chat.ChatContext{
+			Ctx:    ctx,
+			ChatID: update.Message.Chat.ID,
+		}
+		err := ds.ProcessOwnerCommand(chctx, requests, update.Message.Text)
+		if err != nil {
+			slog.ErrorContext(ctx, "[OwnerHandler:ProcessOwnerCommand]", "err", err.Error())
+		}
+	}
+}
+
 func makeHandler(ds *command.DialogsStorage) bot.HandlerFunc {
 	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
 		if update.Message == nil {
-			slog.Error("[bot.HandlerFunc]", "err", "message is nil")
+			slog.ErrorContext(ctx, "[bot.HandlerFunc]", "err", "message is nil")
 			return
 		}
 
 		customer := command.Customer(fmt.Sprint(update.Message.From.ID))
 		menu := ds.GetOrCreateMenu(customer, update.Message.Chat.ID, telegramLanguage(update.Message.From), nil)
 		if menu == nil {
-			slog.Error("[bot.HandlerFunc]", "err", "unexpected: menu == nil")
+			slog.ErrorContext(ctx, "[bot.HandlerFunc]", "err", "unexpected: menu == nil")
 			return
 		}
 
@@ -110,7 +177,7 @@ func makeHandler(ds *command.DialogsStorage) bot.HandlerFunc {
 
 		err := menu.Process(r)
 		if err != nil {
-			slog.Error("[Handler:menu.Process]", "err", err.Error())
+			slog.ErrorContext(ctx, "[Handler:menu.Process]", "err", err.Error())
 		}
 	}
 }
@@ -133,13 +200,13 @@ func makeOptionsCallbackHandler(ds *command.DialogsStorage) bot.HandlerFunc {
 			ShowAlert:       false,
 		})
 
-		slog.Debug("[OptionsCallbackHandler]", "choice", update.CallbackQuery.Data,
+		slog.DebugContext(ctx, "[OptionsCallbackHandler]", "choice", update.CallbackQuery.Data,
 			"user", update.CallbackQuery.From.ID)
 
 		customer := command.Customer(fmt.Sprint(update.CallbackQuery.From.ID))
 		dialog := ds.GetDialog(customer)
 		if dialog == nil {
-			slog.Error("[OptionsCallbackHandler]", "err", "dialog not found", "customer", customer)
+			slog.ErrorContext(ctx, "[OptionsCallbackHandler]", "err", "dialog not found", "customer", customer)
 			return
 		}
 
@@ -168,7 +235,7 @@ func makeOptionsCallbackHandler(ds *command.DialogsStorage) bot.HandlerFunc {
 
 		err := dialog.Menu.Process(r)
 		if err != nil {
-			slog.Error("[OptionsCallbackHandler:menu.Process]", "err", err.Error())
+			slog.ErrorContext(ctx, "[OptionsCallbackHandler:menu.Process]", "err", err.Error())
 		}
 	}
 }
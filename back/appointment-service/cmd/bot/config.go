@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"scheduler/appointment-service/internal/bot"
 	"scheduler/appointment-service/internal/config"
+	"scheduler/appointment-service/internal/tracing"
 )
 
 type BotConfig struct {
@@ -12,6 +13,12 @@ type BotConfig struct {
 	LogLevel            slog.Level              `cfg:"log_level"`
 	SchedulerAPI        bot.SchedulerConnection `cfg:"scheduler"`
 	DefaultUserSettings bot.DefaultUserSettings `cfg:"def_user_settings"`
+	// Optional page where customer books a freed slot with the waitlist token
+	BookingURL string `cfg:"booking_url"`
+	// Optional Telegram user ID of the business owner allowed to approve bookings
+	OwnerId int64 `cfg:"owner_telegram_id"`
+	// Optional exporter of traces to an OpenTelemetry collector
+	Tracing tracing.Config `cfg:"tracing"`
 }
 
 func (c *BotConfig) Validate() error {
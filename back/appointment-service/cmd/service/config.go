@@ -3,7 +3,10 @@ package main
 import (
 	"errors"
 	"log/slog"
+	"scheduler/appointment-service/api"
 	"scheduler/appointment-service/internal/config"
+	"scheduler/appointment-service/internal/tracing"
+	"time"
 )
 
 type ServiceConfig struct {
@@ -18,6 +21,46 @@ type ServiceConfig struct {
 	} `cfg:"auth"`
 	LogLevel  slog.Level `cfg:"log_level"`
 	FrontPath string     `cfg:"front_path"`
+	// Responses of requests with Idempotency-Key are replayed within this TTL, 24h by default
+	IdempotencyTTL time.Duration `cfg:"idempotency_ttl"`
+	// Limits of routes by their names, api.DefaultRateLimits if only trusted proxies are set
+	RateLimits *api.RateLimitsConfig `cfg:"rate_limits"`
+	Server     ServerConfig          `cfg:"server"`
+	// Prometheus /metrics is served without authentication on this internal address only,
+	// it is not served if the address is empty
+	MetricsAddr string `cfg:"metrics_addr"`
+	// Optional exporter of traces to an OpenTelemetry collector
+	Tracing tracing.Config `cfg:"tracing"`
+}
+
+// ServerConfig has timeouts of the HTTP server, defaults are used for zero values
+type ServerConfig struct {
+	ReadTimeout       time.Duration `cfg:"read_timeout"`
+	ReadHeaderTimeout time.Duration `cfg:"read_header_timeout"`
+	WriteTimeout      time.Duration `cfg:"write_timeout"`
+	IdleTimeout       time.Duration `cfg:"idle_timeout"`
+	// ShutdownTimeout bounds draining of in-flight requests after a signal
+	ShutdownTimeout time.Duration `cfg:"shutdown_timeout"`
+	// DrainDelay keeps serving with failing /readyz before shutdown,
+	// so load balancers stop sending requests
+	DrainDelay time.Duration `cfg:"drain_delay"`
+	// TLS is enabled if both files are set
+	TLS struct {
+		CertFile string `cfg:"cert_file"`
+		KeyFile  string `cfg:"key_file"`
+	} `cfg:"tls"`
+}
+
+func (c *ServerConfig) Validate() error {
+	for _, d := range []time.Duration{c.ReadTimeout, c.ReadHeaderTimeout, c.WriteTimeout, c.IdleTimeout, c.ShutdownTimeout, c.DrainDelay} {
+		if d < 0 {
+			return errors.New("server timeouts must not be negative")
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("server.tls requires both cert_file and key_file")
+	}
+	return nil
 }
 
 func (c *ServiceConfig) Validate() error {
@@ -27,6 +70,9 @@ func (c *ServiceConfig) Validate() error {
 	if c.Addr == "" {
 		return errors.New("addr is required")
 	}
+	if c.MetricsAddr != "" && c.MetricsAddr == c.Addr {
+		return errors.New("metrics_addr must differ from addr")
+	}
 	if c.Auth.OAuthGoogleConfig == "" {
 		return errors.New("google_config is required")
 	}
@@ -39,7 +85,12 @@ func (c *ServiceConfig) Validate() error {
 	if c.FrontPath == "" {
 		return errors.New("front_path is required")
 	}
-	return nil
+	if c.RateLimits != nil {
+		if err := c.RateLimits.Validate(); err != nil {
+			return err
+		}
+	}
+	return c.Server.Validate()
 }
 
 func LoadServiceConfig() (*ServiceConfig, error) {
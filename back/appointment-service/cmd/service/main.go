@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"log/slog"
-	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"scheduler/appointment-service/api"
 	common "scheduler/appointment-service/internal"
 	"scheduler/appointment-service/internal/auth"
+	"scheduler/appointment-service/internal/metrics"
+	"scheduler/appointment-service/internal/tracing"
 
 	"github.com/gorilla/sessions"
 	"github.com/jmoiron/sqlx"
@@ -31,12 +35,27 @@ func main() {
 
 	slog.Info("Server started")
 
+	shutdownTracing, err := tracing.Setup(context.Background(), "appointment-service", cfg.Tracing)
+	if err != nil {
+		slog.Error("[tracing.Setup]", "err", err.Error())
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("[tracing.Shutdown]", "err", err.Error())
+		}
+	}()
+
 	db, err := sqlx.Connect(cfg.DB.Driver, cfg.DB.Connection)
 	if err != nil {
 		slog.Error("Open db error", "err", err.Error())
 		log.Fatal(err)
 	}
 	defer db.Close()
+	if err := metrics.RegisterDB("scheduler", db.DB); err != nil {
+		slog.Error("[metrics.RegisterDB]", "err", err.Error())
+		log.Fatal(err)
+	}
 
 	sessionStore := sessions.NewCookieStore([]byte(cfg.SessionsKey))
 	sessionStore.MaxAge(86400 * 5) // 5 days
@@ -44,7 +63,7 @@ func main() {
 	//TODO move LifeTime to config?
 	userSessionStore := auth.NewUserSessionStore(sessionStore, auth.WithAuthStatusCheck(), auth.WithSessionLifeTime(time.Hour*24*5))
 
-	api, err := api.NewAPI(cfg.Auth.OAuthGoogleConfig, userSessionStore, db)
+	api, err := api.NewAPI(cfg.Auth.OAuthGoogleConfig, userSessionStore, db, cfg.IdempotencyTTL, cfg.RateLimits)
 	if err != nil {
 		slog.Error("[NewAPI]", "err", err.Error())
 		log.Fatal(err)
@@ -53,9 +72,21 @@ func main() {
 	r := api.Router()
 	api.AppendFileServerLogic(cfg.FrontPath, r)
 
-	err = http.ListenAndServe(cfg.Addr, r)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.MetricsAddr != "" {
+		metricsSrv := newHTTPServer(cfg.MetricsAddr, cfg.Server, api.MetricsRouter())
+		go serveMetrics(metricsSrv)
+		defer metricsSrv.Close()
+	}
+
+	srv := newHTTPServer(cfg.Addr, cfg.Server, r)
+	err = serve(ctx, srv, cfg.Server, api)
 	if err != nil {
-		slog.Error("[http.ListenAndServe]", "err", err.Error())
+		slog.Error("[serve]", "err", err.Error())
+		db.Close()
 		log.Fatal(err)
 	}
+	slog.Info("Server stopped")
 }
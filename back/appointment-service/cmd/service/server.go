@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+)
+
+// lifecycle is the part of the API stopped on shutdown
+type lifecycle interface {
+	Drain()
+	Stop()
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+func newHTTPServer(addr string, cfg ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       orDefault(cfg.ReadTimeout, common.DefaultServerReadTimeout),
+		ReadHeaderTimeout: orDefault(cfg.ReadHeaderTimeout, common.DefaultServerReadHeaderTimeout),
+		WriteTimeout:      orDefault(cfg.WriteTimeout, common.DefaultServerWriteTimeout),
+		IdleTimeout:       orDefault(cfg.IdleTimeout, common.DefaultServerIdleTimeout),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// serveMetrics runs the metrics server until it is closed. Metrics are optional,
+// so failures are logged and do not stop the service.
+func serveMetrics(srv *http.Server) {
+	slog.Info("Listening for metrics", "addr", srv.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("[serveMetrics]", "err", err.Error())
+	}
+}
+
+// serve runs the server until ctx is done, then drains in-flight requests and stops
+// background jobs of the API
+func serve(ctx context.Context, srv *http.Server, cfg ServerConfig, api lifecycle) error {
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if cfg.TLS.CertFile != "" {
+			slog.Info("Listening with TLS", "addr", srv.Addr)
+			err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			slog.Info("Listening", "addr", srv.Addr)
+			err = srv.ListenAndServe()
+		}
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		api.Stop()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down", "drain_delay", cfg.DrainDelay)
+	api.Drain()
+	time.Sleep(cfg.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), orDefault(cfg.ShutdownTimeout, common.DefaultServerShutdownTimeout))
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Error("[http.Server.Shutdown]", "err", err.Error())
+	}
+	api.Stop()
+
+	if serveErr := <-errCh; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return err
+}
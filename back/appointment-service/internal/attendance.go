@@ -0,0 +1,19 @@
+package common
+
+import "fmt"
+
+// Attendance is a check-in mark of the appointment, empty until the owner marks it
+type Attendance string
+
+const (
+	Attended Attendance = "attended"
+	NoShow   Attendance = "no_show"
+)
+
+func (a Attendance) Validate() error {
+	switch a {
+	case Attended, NoShow:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown attendance %q", ErrInvalidArgument, a)
+}
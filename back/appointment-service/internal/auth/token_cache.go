@@ -3,6 +3,7 @@ package auth
 import (
 	"errors"
 	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/metrics"
 	"sync"
 	"time"
 )
@@ -43,7 +44,10 @@ func (tokenCache *TokenCache) TokenCheck(clientID common.ID, token string) (comm
 	defer result.mu.Unlock()
 	result.lastRead = time.Now()
 
-	if !result.checked {
+	if result.checked {
+		metrics.ObserveTokenCache(metrics.CacheHit)
+	} else {
+		metrics.ObserveTokenCache(metrics.CacheMiss)
 		result.token = token
 		userID, err := tokenCache.tc.TokenCheck(clientID, token)
 		result.userID = userID
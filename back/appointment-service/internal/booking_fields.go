@@ -0,0 +1,124 @@
+package common
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FieldType is a type of the value the customer enters at booking time
+type FieldType string
+
+const (
+	FieldText   FieldType = "text"
+	FieldPhone  FieldType = "phone"
+	FieldEmail  FieldType = "email"
+	FieldChoice FieldType = "choice"
+)
+
+const maxFieldAnswerLen = 1000
+
+// BookingField is a business-defined question asked at booking time.
+// Empty Service means the field is asked for all bookings of the business.
+type BookingField struct {
+	Id       ID        `json:"id"`
+	Service  ID        `json:"service_id,omitempty"`
+	Key      string    `json:"key"`
+	Label    string    `json:"label"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required"`
+	Choices  []string  `json:"choices,omitempty"`
+}
+
+// Answers are values of booking fields by field key
+type Answers map[string]string
+
+var fieldKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+var phoneRe = regexp.MustCompile(`^\+?[0-9 ()-]{5,20}$`)
+
+func (f BookingField) Validate() error {
+	if !fieldKeyRe.MatchString(f.Key) {
+		return fmt.Errorf("%w: field key must match %s", ErrInvalidArgument, fieldKeyRe.String())
+	}
+	if f.Label == "" {
+		return fmt.Errorf("%w: field label is empty", ErrInvalidArgument)
+	}
+	switch f.Type {
+	case FieldText, FieldPhone, FieldEmail:
+		if len(f.Choices) != 0 {
+			return fmt.Errorf("%w: choices are allowed for choice fields only", ErrInvalidArgument)
+		}
+	case FieldChoice:
+		if len(f.Choices) == 0 {
+			return fmt.Errorf("%w: choice field without choices", ErrInvalidArgument)
+		}
+		if slices.Contains(f.Choices, "") {
+			return fmt.Errorf("%w: empty choice", ErrInvalidArgument)
+		}
+	default:
+		return fmt.Errorf("%w: unknown field type %q", ErrInvalidArgument, f.Type)
+	}
+	return nil
+}
+
+type FieldErrorReason string
+
+const (
+	FieldRequired FieldErrorReason = "required"
+	FieldInvalid  FieldErrorReason = "invalid"
+	FieldUnknown  FieldErrorReason = "unknown"
+)
+
+type FieldError struct {
+	Key    string           `json:"key"`
+	Reason FieldErrorReason `json:"reason"`
+}
+
+// ValidateAnswers checks answers against fields. Returns trimmed answers
+// without empty values or errors for each wrong answer.
+func ValidateAnswers(fields []BookingField, answers Answers) (Answers, []FieldError) {
+	out := make(Answers, len(answers))
+	var errs []FieldError
+
+	for key := range answers {
+		if !slices.ContainsFunc(fields, func(f BookingField) bool { return f.Key == key }) {
+			errs = append(errs, FieldError{Key: key, Reason: FieldUnknown})
+		}
+	}
+
+	for _, f := range fields {
+		value := strings.TrimSpace(answers[f.Key])
+		if value == "" {
+			if f.Required {
+				errs = append(errs, FieldError{Key: f.Key, Reason: FieldRequired})
+			}
+			continue
+		}
+		if !validAnswer(f, value) {
+			errs = append(errs, FieldError{Key: f.Key, Reason: FieldInvalid})
+			continue
+		}
+		out[f.Key] = value
+	}
+
+	slices.SortFunc(errs, func(a, b FieldError) int { return strings.Compare(a.Key, b.Key) })
+	return out, errs
+}
+
+func validAnswer(f BookingField, value string) bool {
+	if len(value) > maxFieldAnswerLen {
+		return false
+	}
+	switch f.Type {
+	case FieldPhone:
+		return phoneRe.MatchString(value)
+	case FieldEmail:
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case FieldChoice:
+		return slices.Contains(f.Choices, value)
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookingFieldValidate(t *testing.T) {
+	assert.NoError(t, BookingField{Key: "car_plate", Label: "Car plate", Type: FieldText}.Validate())
+	assert.ErrorIs(t, BookingField{Key: "Car plate", Label: "Car plate", Type: FieldText}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, BookingField{Key: "reason", Label: "Reason", Type: FieldChoice}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, BookingField{Key: "reason", Label: "Reason", Type: FieldText, Choices: []string{"a"}}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, BookingField{Key: "reason", Label: "Reason", Type: "date"}.Validate(), ErrInvalidArgument)
+}
+
+func TestValidateAnswers(t *testing.T) {
+	fields := []BookingField{
+		{Key: "car_plate", Label: "Car plate", Type: FieldText, Required: true},
+		{Key: "phone", Label: "Phone", Type: FieldPhone},
+		{Key: "email", Label: "Email", Type: FieldEmail},
+		{Key: "reason", Label: "Reason", Type: FieldChoice, Choices: []string{"repair", "checkup"}},
+	}
+
+	answers, errs := ValidateAnswers(fields, Answers{"car_plate": " A123BC ", "phone": "+7 (701) 123-45-67", "email": "a@example.com", "reason": "repair"})
+	assert.Empty(t, errs)
+	assert.Equal(t, Answers{"car_plate": "A123BC", "phone": "+7 (701) 123-45-67", "email": "a@example.com", "reason": "repair"}, answers)
+
+	answers, errs = ValidateAnswers(fields, Answers{"car_plate": "A123BC", "phone": ""})
+	assert.Empty(t, errs)
+	assert.Equal(t, Answers{"car_plate": "A123BC"}, answers)
+
+	_, errs = ValidateAnswers(fields, Answers{"phone": "call me", "email": "Alice <a@example.com>", "reason": "other", "color": "red"})
+	assert.Equal(t, []FieldError{
+		{Key: "car_plate", Reason: FieldRequired},
+		{Key: "color", Reason: FieldUnknown},
+		{Key: "email", Reason: FieldInvalid},
+		{Key: "phone", Reason: FieldInvalid},
+		{Key: "reason", Reason: FieldInvalid},
+	}, errs)
+}
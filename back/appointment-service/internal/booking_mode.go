@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// BookingMode defines how customers choose appointment length
+type BookingMode string
+
+const (
+	// BookingChunks offers free time split into fixed chunks
+	BookingChunks BookingMode = "chunks"
+	// BookingRange lets customers pick any duration allowed by DurationRange
+	BookingRange BookingMode = "range"
+)
+
+func (m BookingMode) Validate() error {
+	switch m {
+	case BookingChunks, BookingRange:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown booking mode %q", ErrInvalidArgument, m)
+}
+
+// DurationRange limits variable-length bookings: Min, Min+Step, ... up to Max.
+// Start times are offered with the same Step.
+type DurationRange struct {
+	Min  time.Duration
+	Max  time.Duration
+	Step time.Duration
+}
+
+func (r DurationRange) Validate() error {
+	if r.Step < MinBookingSlotChunk {
+		return fmt.Errorf("%w: duration step is less than %v", ErrInvalidArgument, MinBookingSlotChunk)
+	}
+	if r.Min < r.Step {
+		return fmt.Errorf("%w: min duration is less than step", ErrInvalidArgument)
+	}
+	if r.Max < r.Min {
+		return fmt.Errorf("%w: max duration is less than min duration", ErrInvalidArgument)
+	}
+	if (r.Max-r.Min)%r.Step != 0 {
+		return fmt.Errorf("%w: max duration is not reachable from min duration by steps", ErrInvalidArgument)
+	}
+	return nil
+}
+
+// Allows reports whether the booking may last d
+func (r DurationRange) Allows(d time.Duration) bool {
+	return d >= r.Min && d <= r.Max && (d-r.Min)%r.Step == 0
+}
+
+// Starts returns start times in free intervals, stepping from the beginning
+// of each interval, which leave room for at least Min duration
+func (r DurationRange) Starts(free Intervals) []time.Time {
+	if r.Step <= 0 {
+		return nil
+	}
+
+	var out []time.Time
+	for _, interval := range free {
+		for start := interval.Start; !start.Add(r.Min).After(interval.End); start = start.Add(r.Step) {
+			out = append(out, start)
+		}
+	}
+	return out
+}
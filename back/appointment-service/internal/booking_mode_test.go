@@ -0,0 +1,37 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationRangeValidate(t *testing.T) {
+	assert.NoError(t, DurationRange{Min: 30 * time.Minute, Max: 4 * time.Hour, Step: 15 * time.Minute}.Validate())
+	assert.ErrorIs(t, DurationRange{}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, DurationRange{Min: 10 * time.Minute, Max: time.Hour, Step: 15 * time.Minute}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, DurationRange{Min: time.Hour, Max: 30 * time.Minute, Step: 15 * time.Minute}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, DurationRange{Min: 30 * time.Minute, Max: 50 * time.Minute, Step: 15 * time.Minute}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, BookingMode("hours").Validate(), ErrInvalidArgument)
+}
+
+func TestDurationRangeAllows(t *testing.T) {
+	r := DurationRange{Min: 30 * time.Minute, Max: 4 * time.Hour, Step: 15 * time.Minute}
+	assert.True(t, r.Allows(30*time.Minute))
+	assert.True(t, r.Allows(105*time.Minute))
+	assert.True(t, r.Allows(4*time.Hour))
+	assert.False(t, r.Allows(15*time.Minute))
+	assert.False(t, r.Allows(40*time.Minute))
+	assert.False(t, r.Allows(4*time.Hour+15*time.Minute))
+}
+
+func TestDurationRangeStarts(t *testing.T) {
+	r := DurationRange{Min: 30 * time.Minute, Max: 4 * time.Hour, Step: 15 * time.Minute}
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	free := Intervals{
+		{Start: start, End: start.Add(time.Hour)},
+		{Start: start.Add(2 * time.Hour), End: start.Add(2*time.Hour + 20*time.Minute)},
+	}
+	assert.Equal(t, []time.Time{start, start.Add(15 * time.Minute), start.Add(30 * time.Minute)}, r.Starts(free))
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// BookingPolicy restricts customer bookings of a business. Zero value of a field disables the restriction.
+// Booking is a contiguous run of customer appointments, so several adjacent slots
+// booked at once are counted as one booking.
+type BookingPolicy struct {
+	MinLeadTime        time.Duration
+	MaxHorizon         time.Duration
+	MaxActiveBookings  int
+	MaxBookingsPerDay  int
+	MaxBookingsPerWeek int
+	OneBookingPerDay   bool
+	MinGap             time.Duration
+	CancellationCutoff time.Duration
+}
+
+type PolicyReason string
+
+const (
+	PolicyMinLeadTime        PolicyReason = "min_lead_time"
+	PolicyMaxHorizon         PolicyReason = "max_horizon"
+	PolicyMaxActiveBookings  PolicyReason = "max_active_bookings"
+	PolicyMaxBookingsPerDay  PolicyReason = "max_bookings_per_day"
+	PolicyMaxBookingsPerWeek PolicyReason = "max_bookings_per_week"
+	PolicyOneBookingPerDay   PolicyReason = "one_booking_per_day"
+	PolicyMinGap             PolicyReason = "min_gap"
+	PolicyCancellationCutoff PolicyReason = "cancellation_cutoff"
+)
+
+type PolicyViolation struct {
+	Reason PolicyReason `json:"reason"`
+	// Start of the violating booking
+	TpStart time.Time `json:"tp_start"`
+}
+
+func (p BookingPolicy) Validate() error {
+	if p.MinLeadTime < 0 || p.MaxHorizon < 0 || p.MinGap < 0 || p.CancellationCutoff < 0 ||
+		p.MaxActiveBookings < 0 || p.MaxBookingsPerDay < 0 || p.MaxBookingsPerWeek < 0 {
+		return fmt.Errorf("%w: negative policy value", ErrInvalidArgument)
+	}
+	if p.MaxHorizon != 0 && p.MaxHorizon < p.MinLeadTime {
+		return fmt.Errorf("%w: max horizon is less than min lead time", ErrInvalidArgument)
+	}
+	return nil
+}
+
+// CheckSlot checks restrictions which do not depend on the customer
+func (p BookingPolicy) CheckSlot(now time.Time, slot Interval) []PolicyViolation {
+	var out []PolicyViolation
+	if p.MinLeadTime != 0 && slot.Start.Sub(now) < p.MinLeadTime {
+		out = append(out, PolicyViolation{Reason: PolicyMinLeadTime, TpStart: slot.Start})
+	}
+	if p.MaxHorizon != 0 && slot.Start.Sub(now) > p.MaxHorizon {
+		out = append(out, PolicyViolation{Reason: PolicyMaxHorizon, TpStart: slot.Start})
+	}
+	return out
+}
+
+// CheckBooking checks requested slots against the policy and the customer appointments.
+// Day and week limits use calendar days of loc, weeks start on Monday.
+// Only bookings containing requested slots are reported.
+func (p BookingPolicy) CheckBooking(now time.Time, loc *time.Location, booked Intervals, requested Intervals) []PolicyViolation {
+	var out []PolicyViolation
+	for _, slot := range requested {
+		out = append(out, p.CheckSlot(now, slot)...)
+	}
+
+	all := append(booked.Copy(), requested...)
+	// Overlapped and touching intervals are one booking
+	bookings := PrepareUnited(all).JoinAdjacent()
+	isNew := func(b Interval) bool {
+		return slices.ContainsFunc(requested, func(r Interval) bool { return b.IsFit(r) })
+	}
+
+	if p.MaxActiveBookings != 0 {
+		active := 0
+		for _, b := range bookings {
+			if b.End.After(now) {
+				active++
+			}
+		}
+		if active > p.MaxActiveBookings {
+			for _, b := range bookings {
+				if isNew(b) {
+					out = append(out, PolicyViolation{Reason: PolicyMaxActiveBookings, TpStart: b.Start})
+				}
+			}
+		}
+	}
+
+	perDay := p.MaxBookingsPerDay
+	dayReason := PolicyMaxBookingsPerDay
+	if p.OneBookingPerDay && (perDay == 0 || perDay > 1) {
+		perDay = 1
+		dayReason = PolicyOneBookingPerDay
+	}
+
+	day := func(t time.Time) time.Time { return DayBeginning(t.In(loc)) }
+	week := func(t time.Time) time.Time {
+		d := day(t)
+		return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+	}
+
+	countBy := func(key func(time.Time) time.Time, limit int, reason PolicyReason) {
+		if limit == 0 {
+			return
+		}
+		counts := make(map[time.Time]int)
+		for _, b := range bookings {
+			counts[key(b.Start)]++
+		}
+		for _, b := range bookings {
+			if isNew(b) && counts[key(b.Start)] > limit {
+				out = append(out, PolicyViolation{Reason: reason, TpStart: b.Start})
+			}
+		}
+	}
+	countBy(day, perDay, dayReason)
+	countBy(week, p.MaxBookingsPerWeek, PolicyMaxBookingsPerWeek)
+
+	if p.MinGap != 0 {
+		for i, b := range bookings {
+			if !isNew(b) {
+				continue
+			}
+			tooClose := i > 0 && b.Start.Sub(bookings[i-1].End) < p.MinGap ||
+				i+1 < len(bookings) && bookings[i+1].Start.Sub(b.End) < p.MinGap
+			if tooClose {
+				out = append(out, PolicyViolation{Reason: PolicyMinGap, TpStart: b.Start})
+			}
+		}
+	}
+
+	return out
+}
+
+// CheckCancellation checks if the appointment started at start can be cancelled at now
+func (p BookingPolicy) CheckCancellation(now time.Time, start time.Time) []PolicyViolation {
+	if p.CancellationCutoff != 0 && start.Sub(now) < p.CancellationCutoff {
+		return []PolicyViolation{{Reason: PolicyCancellationCutoff, TpStart: start}}
+	}
+	return nil
+}
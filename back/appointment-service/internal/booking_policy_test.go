@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reasons(violations []PolicyViolation) []PolicyReason {
+	var out []PolicyReason
+	for _, v := range violations {
+		out = append(out, v.Reason)
+	}
+	return out
+}
+
+func TestBookingPolicyCheckSlot(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC) // Monday
+	p := BookingPolicy{MinLeadTime: time.Hour, MaxHorizon: 24 * time.Hour}
+
+	slot := func(in time.Duration) Interval {
+		return Interval{Start: now.Add(in), End: now.Add(in + 30*time.Minute)}
+	}
+	assert.Equal(t, []PolicyReason{PolicyMinLeadTime}, reasons(p.CheckSlot(now, slot(30*time.Minute))))
+	assert.Empty(t, p.CheckSlot(now, slot(time.Hour)))
+	assert.Empty(t, p.CheckSlot(now, slot(24*time.Hour)))
+	assert.Equal(t, []PolicyReason{PolicyMaxHorizon}, reasons(p.CheckSlot(now, slot(25*time.Hour))))
+
+	assert.Empty(t, BookingPolicy{}.CheckSlot(now, slot(0)))
+}
+
+func TestBookingPolicyCheckBooking(t *testing.T) {
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC) // Monday
+	at := func(day int, hour int) Interval {
+		start := now.AddDate(0, 0, day).Truncate(24 * time.Hour).Add(time.Duration(hour) * time.Hour)
+		return Interval{Start: start, End: start.Add(time.Hour)}
+	}
+
+	// Adjacent slots are one booking
+	p := BookingPolicy{MaxBookingsPerDay: 1}
+	assert.Empty(t, p.CheckBooking(now, time.UTC, Intervals{at(1, 10)}, Intervals{at(1, 11)}))
+	violations := p.CheckBooking(now, time.UTC, Intervals{at(1, 10)}, Intervals{at(1, 14)})
+	assert.Equal(t, []PolicyViolation{{Reason: PolicyMaxBookingsPerDay, TpStart: at(1, 14).Start}}, violations)
+
+	p = BookingPolicy{OneBookingPerDay: true}
+	assert.Equal(t, []PolicyReason{PolicyOneBookingPerDay},
+		reasons(p.CheckBooking(now, time.UTC, Intervals{at(1, 10)}, Intervals{at(1, 14)})))
+	assert.Empty(t, p.CheckBooking(now, time.UTC, Intervals{at(1, 10)}, Intervals{at(2, 14)}))
+
+	// Days are counted in the given location
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	assert.Empty(t, p.CheckBooking(now, loc, Intervals{at(1, 10)}, Intervals{at(1, 20)}))
+
+	p = BookingPolicy{MaxBookingsPerWeek: 2}
+	booked := Intervals{at(0, 10), at(1, 10)}
+	assert.Equal(t, []PolicyReason{PolicyMaxBookingsPerWeek}, reasons(p.CheckBooking(now, time.UTC, booked, Intervals{at(3, 10)})))
+	assert.Empty(t, p.CheckBooking(now, time.UTC, booked, Intervals{at(7, 10)}))
+
+	// Past bookings are not active
+	p = BookingPolicy{MaxActiveBookings: 1}
+	past := Interval{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}
+	assert.Empty(t, p.CheckBooking(now, time.UTC, Intervals{past}, Intervals{at(1, 10)}))
+	assert.Equal(t, []PolicyReason{PolicyMaxActiveBookings},
+		reasons(p.CheckBooking(now, time.UTC, Intervals{at(2, 10)}, Intervals{at(1, 10)})))
+
+	p = BookingPolicy{MinGap: 2 * time.Hour}
+	assert.Equal(t, []PolicyReason{PolicyMinGap}, reasons(p.CheckBooking(now, time.UTC, Intervals{at(1, 10)}, Intervals{at(1, 12)})))
+	assert.Empty(t, p.CheckBooking(now, time.UTC, Intervals{at(1, 10)}, Intervals{at(1, 11)}))
+	assert.Empty(t, p.CheckBooking(now, time.UTC, Intervals{at(1, 10)}, Intervals{at(1, 13)}))
+}
+
+func TestBookingPolicyCheckCancellation(t *testing.T) {
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	p := BookingPolicy{CancellationCutoff: 24 * time.Hour}
+	assert.Equal(t, []PolicyReason{PolicyCancellationCutoff}, reasons(p.CheckCancellation(now, now.Add(time.Hour))))
+	assert.Empty(t, p.CheckCancellation(now, now.Add(48*time.Hour)))
+}
+
+func TestBookingPolicyValidate(t *testing.T) {
+	assert.NoError(t, BookingPolicy{}.Validate())
+	assert.ErrorIs(t, BookingPolicy{MinGap: -time.Minute}.Validate(), ErrInvalidArgument)
+	assert.ErrorIs(t, BookingPolicy{MinLeadTime: 2 * time.Hour, MaxHorizon: time.Hour}.Validate(), ErrInvalidArgument)
+}
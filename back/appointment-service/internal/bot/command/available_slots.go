@@ -6,8 +6,19 @@ import (
 	"time"
 )
 
+// EarliestSlotsCount is the number of slots offered by the "earliest slots" option
+const EarliestSlotsCount = 10
+
+// AvailableSlots are free slots of the business. Adjacent slots are booked as one
+// appointment not longer than MaxChunk, zero MaxChunk allows booking them one by one only.
+type AvailableSlots struct {
+	Slots    []common.Slot
+	MaxChunk time.Duration
+}
+
 type SlotsProvider interface {
-	AvailableSlotsInRange(ctx context.Context, interval common.Interval) ([]common.Slot, error)
+	AvailableSlotsInRange(ctx context.Context, interval common.Interval) (AvailableSlots, error)
+	NextSlots(ctx context.Context, after time.Time, count int) (AvailableSlots, error)
 }
 
 type WeekSlots struct {
@@ -20,20 +31,29 @@ func NewWeekSlots(storage SlotsProvider) *WeekSlots {
 	}
 }
 
-func (ws *WeekSlots) ThisWeek(ctx context.Context, now time.Time) ([]common.Slot, error) {
-	interval := common.Interval{
+func ThisWeekRange(now time.Time) common.Interval {
+	return common.Interval{
 		Start: now,
 		End:   common.NextMonday(now),
 	}
-
-	return ws.storage.AvailableSlotsInRange(ctx, interval)
 }
 
-func (ws *WeekSlots) NextWeek(ctx context.Context, now time.Time) ([]common.Slot, error) {
+func NextWeekRange(now time.Time) common.Interval {
 	interval := common.Interval{
 		Start: common.NextMonday(now),
 	}
 	interval.End = common.NextMonday(interval.Start)
+	return interval
+}
+
+func (ws *WeekSlots) ThisWeek(ctx context.Context, now time.Time) (AvailableSlots, error) {
+	return ws.storage.AvailableSlotsInRange(ctx, ThisWeekRange(now))
+}
+
+func (ws *WeekSlots) NextWeek(ctx context.Context, now time.Time) (AvailableSlots, error) {
+	return ws.storage.AvailableSlotsInRange(ctx, NextWeekRange(now))
+}
 
-	return ws.storage.AvailableSlotsInRange(ctx, interval)
+func (ws *WeekSlots) Earliest(ctx context.Context, now time.Time) (AvailableSlots, error) {
+	return ws.storage.NextSlots(ctx, now, EarliestSlotsCount)
 }
@@ -71,7 +71,9 @@ func LocationToGMTString(loc *time.Location, t time.Time) string {
 	return fmt.Sprintf("GMT%s%02d:%02d", sign, h, m)
 }
 
-func (ca *ChatAdapter) ShowAsOptions(c *chat.ChatContext, me *i18n.Message, ops []LabeledSlot) error {
+// ShowAsOptions offers the slots of a single day one by one and joined into runs of
+// adjacent slots not longer than maxChunk. Slots of several days are offered as days.
+func (ca *ChatAdapter) ShowAsOptions(c *chat.ChatContext, me *i18n.Message, ops []LabeledSlot, maxChunk time.Duration) error {
 	if len(ops) == 0 {
 		return fmt.Errorf("%w: slots array too small =%d", common.ErrInvalidArgument, len(ops))
 	}
@@ -120,10 +122,28 @@ func (ca *ChatAdapter) ShowAsOptions(c *chat.ChatContext, me *i18n.Message, ops
 	} else {
 		chatOptions = make([]chat.ChatOption, len(ops))
 		localized = fmt.Sprintf("%s\n%s:", localized, formatDateShort(ops[0].Start.In(location)))
+		slotText := func(start time.Time, dur time.Duration) string {
+			hour, min, _ := start.In(location).Clock()
+			return fmt.Sprintf("%02d:%02d (%02d %s)", hour, min, int(dur.Minutes()), dateFormatter.MinShort())
+		}
 		for i, v := range ops {
 			chatOptions[i].ID = fmt.Sprintf("%s%d", SlotMarker, v.ID)
-			hour, min, _ := v.Start.In(location).Clock()
-			chatOptions[i].Text = fmt.Sprintf("%02d:%02d (%02d %s)", hour, min, int(v.Dur.Minutes()), dateFormatter.MinShort())
+			chatOptions[i].Text = slotText(v.Start, v.Dur)
+		}
+		// A run of adjacent chunks may be booked as one longer appointment
+		for i, first := range ops {
+			dur := first.Dur
+			for j := i + 1; j < len(ops); j++ {
+				prev, v := ops[j-1], ops[j]
+				if prev.ID+1 != v.ID || !prev.Start.Add(prev.Dur).Equal(v.Start) || dur+v.Dur > maxChunk {
+					break
+				}
+				dur += v.Dur
+				chatOptions = append(chatOptions, chat.ChatOption{
+					ID:   fmt.Sprintf("%s%d-%d", SlotMarker, first.ID, v.ID),
+					Text: slotText(first.Start, dur),
+				})
+			}
 		}
 	}
 
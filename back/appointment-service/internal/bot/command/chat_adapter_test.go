@@ -52,7 +52,7 @@ func TestChatAdapter_ShowAsOptions_AppendsGMTWhenOffsetsAreSame(t *testing.T) {
 		{ID: 3, Slot: common.Slot{Start: time.Date(2026, time.April, 20, 10, 0, 0, 0, time.UTC), Dur: 30 * time.Minute}},
 	}
 
-	err = adapter.ShowAsOptions(&chat.ChatContext{Ctx: context.Background(), ChatID: "c1"}, messages.SelectRequestMessage, ops)
+	err = adapter.ShowAsOptions(&chat.ChatContext{Ctx: context.Background(), ChatID: "c1"}, messages.SelectRequestMessage, ops, 0)
 	if err != nil {
 		t.Fatalf("ShowAsOptions() error = %v", err)
 	}
@@ -87,7 +87,7 @@ func TestChatAdapter_ShowAsOptions_OmitsGMTWhenOffsetsDiffer(t *testing.T) {
 		{ID: 3, Slot: common.Slot{Start: time.Date(2026, time.April, 6, 10, 0, 0, 0, time.UTC), Dur: 30 * time.Minute}},
 	}
 
-	err = adapter.ShowAsOptions(&chat.ChatContext{Ctx: context.Background(), ChatID: "c1"}, messages.SelectRequestMessage, ops)
+	err = adapter.ShowAsOptions(&chat.ChatContext{Ctx: context.Background(), ChatID: "c1"}, messages.SelectRequestMessage, ops, 0)
 	if err != nil {
 		t.Fatalf("ShowAsOptions() error = %v", err)
 	}
@@ -104,3 +104,40 @@ func TestChatAdapter_ShowAsOptions_OmitsGMTWhenOffsetsDiffer(t *testing.T) {
 		t.Fatalf("expected 3 day options, got %d", len(cha.showOptions))
 	}
 }
+
+func TestChatAdapter_ShowAsOptions_OffersAdjacentSlotsTogether(t *testing.T) {
+	cha := &stubChat{}
+	adapter := NewChatAdapter(cha, &bot.UserSettings{Loc: testLocalization(), TimeZone: time.UTC})
+
+	start := time.Date(2026, time.April, 6, 10, 0, 0, 0, time.UTC)
+	ops := []LabeledSlot{
+		{ID: 1, Slot: common.Slot{Start: start, Dur: 30 * time.Minute}},
+		{ID: 2, Slot: common.Slot{Start: start.Add(30 * time.Minute), Dur: 30 * time.Minute}},
+		{ID: 3, Slot: common.Slot{Start: start.Add(time.Hour), Dur: 30 * time.Minute}},
+		{ID: 4, Slot: common.Slot{Start: start.Add(2 * time.Hour), Dur: 30 * time.Minute}},
+	}
+
+	for _, tc := range []struct {
+		maxChunk time.Duration
+		combined map[string]string
+	}{
+		{0, map[string]string{}},
+		{time.Hour, map[string]string{"1-2": "10:00 (60 ", "2-3": "10:30 (60 "}},
+		{2 * time.Hour, map[string]string{"1-2": "10:00 (60 ", "1-3": "10:00 (90 ", "2-3": "10:30 (60 "}},
+	} {
+		err := adapter.ShowAsOptions(&chat.ChatContext{Ctx: context.Background(), ChatID: "c1"}, messages.SelectRequestMessage, ops, tc.maxChunk)
+		if err != nil {
+			t.Fatalf("ShowAsOptions() error = %v", err)
+		}
+
+		if len(cha.showOptions) != len(ops)+len(tc.combined) {
+			t.Fatalf("max chunk %v: expected %d slot options and %d combined, got %v", tc.maxChunk, len(ops), len(tc.combined), cha.showOptions)
+		}
+		for _, option := range cha.showOptions[len(ops):] {
+			text, ok := tc.combined[strings.TrimPrefix(option.ID, SlotMarker)]
+			if !ok || !strings.HasPrefix(option.Text, text) {
+				t.Fatalf("max chunk %v: unexpected combined option: %+v", tc.maxChunk, option)
+			}
+		}
+	}
+}
@@ -16,5 +16,5 @@ func NewDefaultMainMenu(chat chat.Chat, userSettings *bot.UserSettings, connecti
 
 func newDefaultSlotSelectionCommand(md *MenuDeps, connection *bot.SchedulerConnection) *SlotSelectionCommand {
 	ha := &HttpAppointment{Connection: connection}
-	return newSlotSelectionCommand(md, NewWeekSlots(ha), ha)
+	return newSlotSelectionCommand(md, NewWeekSlots(ha), ha, ha)
 }
@@ -1,23 +1,74 @@
 package command
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/tracing"
+	"strings"
 )
 
+// problem is the application/problem+json error body of the appointment service
+type problem struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+func decodeProblem(resp *http.Response) (p problem) {
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") {
+		_ = json.NewDecoder(resp.Body).Decode(&p)
+	}
+	return p
+}
+
 func checkStatusCode(resp *http.Response) error {
-	switch resp.StatusCode {
-	case http.StatusOK:
+	if resp.StatusCode == http.StatusOK {
 		return nil
-	case http.StatusBadRequest:
-		return fmt.Errorf("http response: %w (%s)", common.ErrInvalidArgument, resp.Status)
-	case http.StatusUnauthorized:
-		return fmt.Errorf("http response: %w (%s)", common.ErrUnauthorized, resp.Status)
+	}
+
+	p := decodeProblem(resp)
+	reason := resp.Status
+	if p.Code != "" {
+		reason += ", " + p.Code
+	}
+	if p.Detail != "" {
+		reason += ": " + p.Detail
+	}
+	if id := resp.Header.Get(tracing.RequestIDHeader); id != "" {
+		reason += ", request_id " + id
+	}
+
+	switch {
+	case p.Code == customerBlockedCode:
+		return fmt.Errorf("http response: %w (%s)", ErrCustomerBlocked, reason)
+	case p.Code == invalidAnswersCode:
+		return fmt.Errorf("http response: %w (%s)", ErrAnswersRequired, reason)
+	case resp.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("http response: %w (%s)", common.ErrInvalidArgument, reason)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("http response: %w (%s)", common.ErrUnauthorized, reason)
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("http response: %w (%s)", common.ErrNotFound, reason)
+	case resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("http response: %w (%s)", common.ErrNotAllowed, reason)
 	default:
-		return fmt.Errorf("http response: unexpected response (%s)", resp.Status)
+		return fmt.Errorf("http response: unexpected response (%s)", reason)
 	}
 }
 
 var ErrWrongUserInput = errors.New("wrong user input")
+
+// ErrCustomerBlocked is returned when the business refuses bookings of the customer
+var ErrCustomerBlocked = errors.New("customer is blocked")
+
+const customerBlockedCode = "customer_blocked"
+
+// ErrAnswersRequired is returned when the business requires answers to booking fields
+var ErrAnswersRequired = errors.New("booking fields must be answered")
+
+const invalidAnswersCode = "invalid_answers"
+
+// ErrBookingPending is returned when the booking is accepted but waits for approval of the owner
+var ErrBookingPending = errors.New("booking waits for approval")
@@ -6,23 +6,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	swagger "scheduler/appointment-service/api/types"
 	common "scheduler/appointment-service/internal"
 	"scheduler/appointment-service/internal/bot"
+	"scheduler/appointment-service/internal/tracing"
+	"strconv"
 	"time"
 )
 
+// httpClient passes the request ID and the trace context of the update to the service
+var httpClient = &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+
 type HttpAppointment struct {
 	Connection *bot.SchedulerConnection
 }
 
+// CustomerAppointment is an upcoming appointment with the code shown at check-in
+type CustomerAppointment struct {
+	common.Slot
+	BookingCode string
+}
+
 type AppointmentsProvider interface {
-	CustomerAppointmentsInRange(ctx context.Context, customer common.ID, interval common.Interval) ([]common.Slot, error)
+	CustomerAppointmentsInRange(ctx context.Context, customer common.ID, interval common.Interval) ([]CustomerAppointment, error)
 }
 
-func (a *HttpAppointment) AddSlots(ctx context.Context, customer common.ID, slots []common.Slot) error {
-	u, err := url.JoinPath(a.Connection.URL, "slots/bt")
+func (a *HttpAppointment) AddSlots(ctx context.Context, customer common.ID, slots []common.Slot, key string) error {
+	u, err := a.Connection.Endpoint("slots/bt")
 	if err != nil {
 		return err
 	}
@@ -45,6 +55,8 @@ func (a *HttpAppointment) AddSlots(ctx context.Context, customer common.ID, slot
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
 	req.Header.Set("X-Client-ID", a.Connection.ClientId)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.Connection.Token))
+	// Retried booking gets the response of the first attempt instead of a duplicate
+	req.Header.Set("Idempotency-Key", key)
 
 	q := req.URL.Query()
 	q.Add("customer_id", customer)
@@ -52,60 +64,131 @@ func (a *HttpAppointment) AddSlots(ctx context.Context, customer common.ID, slot
 
 	// TODO with timeout
 	// https://github.com/LevWi/scheduler/issues/19
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusAccepted {
+		return ErrBookingPending
+	}
 	return checkStatusCode(resp)
 }
 
+// AvailableSlotsInRange returns slots of all pages of the range
 // TODO make function swagger.Slot -> common.Slot
-func (p *HttpAppointment) AvailableSlotsInRange(ctx context.Context, interval common.Interval) ([]common.Slot, error) {
-	u, err := url.JoinPath(p.Connection.URL, "slots", p.Connection.BusinessID)
+func (p *HttpAppointment) AvailableSlotsInRange(ctx context.Context, interval common.Interval) (AvailableSlots, error) {
+	var out AvailableSlots
+	cursor := ""
+	for {
+		page, next, err := p.availableSlotsPage(ctx, interval, cursor)
+		if err != nil {
+			return AvailableSlots{}, err
+		}
+		out.Slots = append(out.Slots, page.Slots...)
+		out.MaxChunk = page.MaxChunk
+		if next == "" {
+			return out, nil
+		}
+		cursor = next
+	}
+}
+
+func (p *HttpAppointment) availableSlotsPage(ctx context.Context, interval common.Interval, cursor string) (AvailableSlots, string, error) {
+	u, err := p.Connection.Endpoint("slots", p.Connection.BusinessID)
 	if err != nil {
-		return nil, err
+		return AvailableSlots{}, "", err
 	}
 	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
 	if err != nil {
-		return nil, err
+		return AvailableSlots{}, "", err
 	}
 
 	v := req.URL.Query()
 	v.Set("date_start", interval.Start.Format(time.RFC3339))
 	v.Set("date_end", interval.End.Format(time.RFC3339))
+	if cursor != "" {
+		v.Set("cursor", cursor)
+	}
 	req.URL.RawQuery = v.Encode()
 
 	//TODO with timeout?
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return AvailableSlots{}, "", err
 	}
+	defer resp.Body.Close()
 
 	err = checkStatusCode(resp)
 	if err != nil {
-		return nil, err
+		return AvailableSlots{}, "", err
 	}
 
-	//TODO print request id in log
-	var slots swagger.AvailableSlots
-	err = json.NewDecoder(resp.Body).Decode(&slots)
+	return decodeSlotsPage(resp)
+}
+
+// NextSlots returns the earliest count slots after the time
+func (p *HttpAppointment) NextSlots(ctx context.Context, after time.Time, count int) (AvailableSlots, error) {
+	u, err := p.Connection.Endpoint("slots", p.Connection.BusinessID, "next")
 	if err != nil {
-		return nil, fmt.Errorf("http: unexpected response (%s)", resp.Status)
+		return AvailableSlots{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return AvailableSlots{}, err
+	}
+
+	v := req.URL.Query()
+	v.Set("after", after.Format(time.RFC3339))
+	v.Set("count", strconv.Itoa(count))
+	req.URL.RawQuery = v.Encode()
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return AvailableSlots{}, err
+	}
+	defer resp.Body.Close()
+
+	err = checkStatusCode(resp)
+	if err != nil {
+		return AvailableSlots{}, err
+	}
+	return decodeAvailableSlots(resp)
+}
+
+func decodeAvailableSlots(resp *http.Response) (AvailableSlots, error) {
+	slots, _, err := decodeSlotsPage(resp)
+	return slots, err
+}
+
+// decodeSlotsPage returns slots and the cursor of the next page, empty on the last page
+func decodeSlotsPage(resp *http.Response) (AvailableSlots, string, error) {
+	var slots struct {
+		swagger.AvailableSlots
+		NextCursor      string `json:"next_cursor"`
+		MaxChunkMinutes int    `json:"max_chunk_minutes"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&slots)
+	if err != nil {
+		return AvailableSlots{}, "", fmt.Errorf("http: unexpected response (%s, request_id %s)", resp.Status, resp.Header.Get(tracing.RequestIDHeader))
 	}
 
-	out := make([]common.Slot, 0, len(slots.Slots))
+	out := AvailableSlots{
+		Slots:    make([]common.Slot, 0, len(slots.Slots)),
+		MaxChunk: time.Minute * time.Duration(slots.MaxChunkMinutes),
+	}
 	for _, slot := range slots.Slots {
 		var tmp common.Slot
 		tmp.Start = slot.TpStart
 		tmp.Dur = time.Minute * time.Duration(slot.Len)
-		out = append(out, tmp)
+		out.Slots = append(out.Slots, tmp)
 	}
-	return out, nil
+	return out, slots.NextCursor, nil
 }
 
-func (p *HttpAppointment) CustomerAppointmentsInRange(ctx context.Context, customer common.ID, interval common.Interval) ([]common.Slot, error) {
-	u, err := url.JoinPath(p.Connection.URL, "customer/appointments/bt")
+func (p *HttpAppointment) CustomerAppointmentsInRange(ctx context.Context, customer common.ID, interval common.Interval) ([]CustomerAppointment, error) {
+	u, err := p.Connection.Endpoint("customer/appointments/bt")
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +211,7 @@ func (p *HttpAppointment) CustomerAppointmentsInRange(ctx context.Context, custo
 	}
 	req.URL.RawQuery = v.Encode()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -138,18 +221,62 @@ func (p *HttpAppointment) CustomerAppointmentsInRange(ctx context.Context, custo
 		return nil, err
 	}
 
-	var slots swagger.AvailableSlots
+	var slots struct {
+		Slots []struct {
+			swagger.Slot
+			BookingCode string `json:"booking_code"`
+		} `json:"slots"`
+	}
 	err = json.NewDecoder(resp.Body).Decode(&slots)
 	if err != nil {
 		return nil, fmt.Errorf("http: unexpected response (%s)", resp.Status)
 	}
 
-	out := make([]common.Slot, 0, len(slots.Slots))
+	out := make([]CustomerAppointment, 0, len(slots.Slots))
 	for _, slot := range slots.Slots {
-		out = append(out, common.Slot{
-			Start: slot.TpStart,
-			Dur:   time.Minute * time.Duration(slot.Len),
+		out = append(out, CustomerAppointment{
+			Slot: common.Slot{
+				Start: slot.TpStart,
+				Dur:   time.Minute * time.Duration(slot.Len),
+			},
+			BookingCode: slot.BookingCode,
 		})
 	}
 	return out, nil
 }
+
+func (a *HttpAppointment) JoinWaitlist(ctx context.Context, customer common.ID, interval common.Interval) error {
+	u, err := a.Connection.Endpoint("waitlist/bt")
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(struct {
+		DateStart time.Time `json:"date_start"`
+		DateEnd   time.Time `json:"date_end"`
+	}{interval.Start, interval.End})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Client-ID", a.Connection.ClientId)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.Connection.Token))
+
+	q := req.URL.Query()
+	q.Add("customer_id", string(customer))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatusCode(resp)
+}
@@ -82,8 +82,10 @@ func commandMap(l *i18n.Localizer) (messages.MessageMap, error) {
 		messages.Help,
 		messages.NextWeek,
 		messages.ThisWeek,
+		messages.EarliestSlots,
 		messages.Cancel,
-		messages.Done)
+		messages.Done,
+		messages.JoinWaitlist)
 }
 
 // TODO cancel by timeout if user not reacted. Add mutex
@@ -158,6 +160,15 @@ func (menu *MainMenu) Process(r *Request) error {
 				//This is possible behavior for user. Not an error
 				slog.DebugContext(r.Ctx, "mainMenu", "err", err.Error())
 				return menu.showMessageForce(r.ChatContext, messages.WrongUserInput)
+			} else if errors.Is(err, ErrCustomerBlocked) {
+				slog.InfoContext(r.Ctx, "mainMenu menuSlotSelection", "err", err.Error())
+				return errors.Join(menu.showMessageForce(r.ChatContext, messages.CustomerBlocked),
+					menu.BackToStart(r.ChatContext))
+			} else if errors.Is(err, ErrAnswersRequired) {
+				// The bot does not ask booking fields, the customer books in the web app
+				slog.InfoContext(r.Ctx, "mainMenu menuSlotSelection", "err", err.Error())
+				return errors.Join(menu.showMessageForce(r.ChatContext, messages.BookingAnswersRequired),
+					menu.BackToStart(r.ChatContext))
 			} else {
 				slog.ErrorContext(r.Ctx, "mainMenu menuSlotSelection process", "err", err.Error())
 				return errors.Join(menu.showMessageForce(r.ChatContext, messages.InternalErrorOccurred),
@@ -209,7 +220,7 @@ func (menu *MainMenu) ShowAppointments(ctx context.Context, c *chat.ChatContext,
 	return menu.menuDeps.Chat().Print(c, msg)
 }
 
-func (menu *MainMenu) formatAppointmentsMessage(appointments []common.Slot) string {
+func (menu *MainMenu) formatAppointmentsMessage(appointments []CustomerAppointment) string {
 	l := menu.menuDeps.UserSettings.Loc.Localizer()
 	header, err := l.LocalizeMessage(messages.AppointmentsListHeader)
 	if err != nil {
@@ -224,7 +235,12 @@ func (menu *MainMenu) formatAppointmentsMessage(appointments []common.Slot) stri
 		return noUpcoming
 	}
 
-	apptSorted := make([]common.Slot, len(appointments))
+	codeText, err := l.LocalizeMessage(messages.AppointmentBookingCode)
+	if err != nil {
+		codeText = messages.AppointmentBookingCode.Other
+	}
+
+	apptSorted := make([]CustomerAppointment, len(appointments))
 	copy(apptSorted, appointments)
 	sort.Slice(apptSorted, func(i, j int) bool {
 		return apptSorted[i].Start.Before(apptSorted[j].Start)
@@ -241,6 +257,9 @@ func (menu *MainMenu) formatAppointmentsMessage(appointments []common.Slot) stri
 		hour, min, _ := tp.Clock()
 		fmt.Fprintf(&b, "\n%s %02d %s | %02d:%02d | %d %s", dateFormatter.WeekDayShort(tp.Weekday()), day, dateFormatter.MonthShort(month),
 			hour, min, int(appt.Dur.Minutes()), dateFormatter.MinShort())
+		if appt.BookingCode != "" {
+			fmt.Fprintf(&b, " | %s %s", codeText, appt.BookingCode)
+		}
 	}
 	return b.String()
 }
@@ -0,0 +1,305 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"scheduler/appointment-service/internal/bot"
+	"scheduler/appointment-service/internal/bot/chat"
+	"scheduler/appointment-service/internal/bot/i18n/messages"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	NotificationWaitlistSlotFreed      = "waitlist_slot_freed"
+	NotificationBookingApproved        = "booking_approved"
+	NotificationBookingRejected        = "booking_rejected"
+	NotificationAppointmentCancelled   = "appointment_cancelled"
+	NotificationAppointmentRescheduled = "appointment_rescheduled"
+)
+
+type Notification struct {
+	Id         int64           `json:"id"`
+	CustomerId string          `json:"customer_id"`
+	TelegramId string          `json:"telegram_id"`
+	Kind       string          `json:"kind"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type waitlistSlotFreed struct {
+	DateStart time.Time `json:"date_start"`
+	DateEnd   time.Time `json:"date_end"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type bookingDecision struct {
+	DateStart time.Time `json:"date_start"`
+	DateEnd   time.Time `json:"date_end"`
+	Expired   bool      `json:"expired"`
+	Comment   string    `json:"comment"`
+}
+
+type appointmentCancelled struct {
+	DateStart    time.Time `json:"date_start"`
+	DateEnd      time.Time `json:"date_end"`
+	Comment      string    `json:"comment"`
+	Alternatives []struct {
+		DateStart time.Time `json:"date_start"`
+		DateEnd   time.Time `json:"date_end"`
+	} `json:"alternatives"`
+}
+
+type appointmentRescheduled struct {
+	DateStart     time.Time `json:"date_start"`
+	DateEnd       time.Time `json:"date_end"`
+	PreviousStart time.Time `json:"previous_start"`
+}
+
+type NotificationsProvider interface {
+	PendingNotifications(ctx context.Context) ([]Notification, error)
+	AckNotification(ctx context.Context, id int64) error
+}
+
+type HttpNotifications struct {
+	Connection *bot.SchedulerConnection
+}
+
+func (n *HttpNotifications) PendingNotifications(ctx context.Context) ([]Notification, error) {
+	u, err := n.Connection.Endpoint("notifications/bt")
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Client-ID", n.Connection.ClientId)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", n.Connection.Token))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatusCode(resp); err != nil {
+		return nil, err
+	}
+
+	var out []Notification
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("http: unexpected response (%s)", resp.Status)
+	}
+	return out, nil
+}
+
+func (n *HttpNotifications) AckNotification(ctx context.Context, id int64) error {
+	u, err := n.Connection.Endpoint("notifications/bt", strconv.FormatInt(id, 10), "ack")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Client-ID", n.Connection.ClientId)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", n.Connection.Token))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatusCode(resp)
+}
+
+// DeliverNotifications sends queued notifications to customers and acknowledges them.
+// Settings of the customer dialog are used if the dialog exists.
+// bookingURL is optional, the booking token is appended to it as "token" query parameter.
+func (ds *DialogsStorage) DeliverNotifications(ctx context.Context, provider NotificationsProvider, bookingURL string) error {
+	notifications, err := provider.PendingNotifications(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, n := range notifications {
+		// Customer may be merged with a profile created in another channel
+		telegramID := n.TelegramId
+		if telegramID == "" {
+			telegramID = n.CustomerId
+		}
+
+		var chatID chat.ChatID
+		settings := ds.depsProto.UserSettings
+		if dialog := ds.GetDialog(Customer(telegramID)); dialog != nil {
+			chatID = dialog.ChatID
+			settings = dialog.Menu.menuDeps.UserSettings
+		} else {
+			// Telegram private chat ID equals to user ID
+			id, err := strconv.ParseInt(telegramID, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("notification %d: customer %q: %w", n.Id, telegramID, err))
+				continue
+			}
+			chatID = id
+		}
+
+		text, err := formatNotification(n, settings, bookingURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification %d: %w", n.Id, err))
+			continue
+		}
+
+		c := &chat.ChatContext{Ctx: ctx, ChatID: chatID}
+		if err := ds.depsProto.chat.Print(c, text); err != nil {
+			errs = append(errs, fmt.Errorf("notification %d: %w", n.Id, err))
+			continue
+		}
+
+		if err := provider.AckNotification(ctx, n.Id); err != nil {
+			errs = append(errs, fmt.Errorf("notification %d ack: %w", n.Id, err))
+			continue
+		}
+		slog.DebugContext(ctx, "[DeliverNotifications] delivered", "id", n.Id, "customer", n.CustomerId)
+	}
+	return errors.Join(errs...)
+}
+
+func formatNotificationTime(settings *bot.UserSettings, tp time.Time) string {
+	tp = tp.In(settings.TimeZone)
+	_, month, day := tp.Date()
+	hour, min, _ := tp.Clock()
+	return fmt.Sprintf("%s %02d %s %02d:%02d", settings.Loc.DF.WeekDayShort(tp.Weekday()), day, settings.Loc.DF.MonthShort(month), hour, min)
+}
+
+func formatNotification(n Notification, settings *bot.UserSettings, bookingURL string) (string, error) {
+	formatTime := func(tp time.Time) string { return formatNotificationTime(settings, tp) }
+
+	switch n.Kind {
+	case NotificationBookingApproved, NotificationBookingRejected:
+		var p bookingDecision
+		if err := json.Unmarshal(n.Payload, &p); err != nil {
+			return "", err
+		}
+
+		m := messages.BookingApproved
+		if n.Kind == NotificationBookingRejected {
+			m = messages.BookingRejected
+			if p.Expired {
+				m = messages.BookingRequestExpired
+			}
+		}
+
+		l := settings.Loc.Localizer()
+		text, err := l.Localize(&i18n.LocalizeConfig{
+			DefaultMessage: m,
+			TemplateData:   map[string]string{"Start": formatTime(p.DateStart)},
+		})
+		if err != nil {
+			return "", err
+		}
+		if p.Comment != "" {
+			commentText, err := l.LocalizeMessage(messages.BookingRejectComment)
+			if err != nil {
+				commentText = messages.BookingRejectComment.Other
+			}
+			text = fmt.Sprintf("%s\n%s: %s", text, commentText, p.Comment)
+		}
+		return text, nil
+	case NotificationAppointmentCancelled:
+		var p appointmentCancelled
+		if err := json.Unmarshal(n.Payload, &p); err != nil {
+			return "", err
+		}
+
+		l := settings.Loc.Localizer()
+		text, err := l.Localize(&i18n.LocalizeConfig{
+			DefaultMessage: messages.AppointmentCancelledByBusiness,
+			TemplateData:   map[string]string{"Start": formatTime(p.DateStart)},
+		})
+		if err != nil {
+			return "", err
+		}
+		if p.Comment != "" {
+			reasonText, err := l.LocalizeMessage(messages.AppointmentCancelReason)
+			if err != nil {
+				reasonText = messages.AppointmentCancelReason.Other
+			}
+			text = fmt.Sprintf("%s\n%s: %s", text, reasonText, p.Comment)
+		}
+		if len(p.Alternatives) != 0 {
+			altText, err := l.LocalizeMessage(messages.AppointmentAlternatives)
+			if err != nil {
+				altText = messages.AppointmentAlternatives.Other
+			}
+			text = fmt.Sprintf("%s\n%s:", text, altText)
+			for _, alt := range p.Alternatives {
+				text = fmt.Sprintf("%s\n%s", text, formatTime(alt.DateStart))
+			}
+		}
+		return text, nil
+	case NotificationAppointmentRescheduled:
+		var p appointmentRescheduled
+		if err := json.Unmarshal(n.Payload, &p); err != nil {
+			return "", err
+		}
+
+		return settings.Loc.Localizer().Localize(&i18n.LocalizeConfig{
+			DefaultMessage: messages.AppointmentRescheduled,
+			TemplateData: map[string]string{
+				"From":  formatTime(p.PreviousStart),
+				"Start": formatTime(p.DateStart),
+			},
+		})
+	case NotificationWaitlistSlotFreed:
+		var p waitlistSlotFreed
+		if err := json.Unmarshal(n.Payload, &p); err != nil {
+			return "", err
+		}
+
+		l := settings.Loc.Localizer()
+		text, err := l.Localize(&i18n.LocalizeConfig{
+			DefaultMessage: messages.WaitlistSlotFreed,
+			TemplateData: map[string]string{
+				"Start":     formatTime(p.DateStart),
+				"End":       formatTime(p.DateEnd),
+				"ExpiresAt": formatTime(p.ExpiresAt),
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if bookingURL != "" {
+			u, err := url.Parse(bookingURL)
+			if err != nil {
+				return "", err
+			}
+			q := u.Query()
+			q.Set("token", p.Token)
+			u.RawQuery = q.Encode()
+
+			linkText, err := l.LocalizeMessage(messages.WaitlistBookingLink)
+			if err != nil {
+				linkText = messages.WaitlistBookingLink.Other
+			}
+			text = fmt.Sprintf("%s\n%s: %s", text, linkText, u.String())
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("unknown notification kind %q", n.Kind)
+	}
+}
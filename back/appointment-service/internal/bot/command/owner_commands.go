@@ -0,0 +1,258 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/bot"
+	"scheduler/appointment-service/internal/bot/chat"
+	"scheduler/appointment-service/internal/bot/i18n/messages"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// Commands of the business owner
+const (
+	OwnerRequestsCommand = "/requests"
+	OwnerApproveCommand  = "/approve"
+	OwnerRejectCommand   = "/reject"
+	OwnerCheckInCommand  = "/checkin"
+	OwnerNoShowCommand   = "/noshow"
+)
+
+// Attendance values accepted by the check-in endpoint
+const (
+	attendanceAttended = "attended"
+	attendanceNoShow   = "no_show"
+)
+
+type BookingRequest struct {
+	Id         string    `json:"id"`
+	CustomerId string    `json:"customer_id"`
+	TpStart    time.Time `json:"tp_start"`
+	Len        int32     `json:"len"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type BookingRequestsProvider interface {
+	PendingRequests(ctx context.Context) ([]BookingRequest, error)
+	Approve(ctx context.Context, id string) error
+	Reject(ctx context.Context, id string, comment string) error
+}
+
+type CheckInResult struct {
+	CustomerId  string    `json:"customer_id"`
+	TpStart     time.Time `json:"tp_start"`
+	Len         int32     `json:"len"`
+	BookingCode string    `json:"booking_code"`
+	Attendance  string    `json:"attendance"`
+}
+
+type CheckInProvider interface {
+	CheckIn(ctx context.Context, code string, attendance string) (CheckInResult, error)
+}
+
+type OwnerCommandsProvider interface {
+	BookingRequestsProvider
+	CheckInProvider
+}
+
+type HttpBookingRequests struct {
+	Connection *bot.SchedulerConnection
+}
+
+func (h *HttpBookingRequests) do(ctx context.Context, method string, body []byte, path ...string) (*http.Response, error) {
+	u, err := h.Connection.Endpoint(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	}
+	req.Header.Set("X-Client-ID", h.Connection.ClientId)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", h.Connection.Token))
+
+	return httpClient.Do(req)
+}
+
+func (h *HttpBookingRequests) PendingRequests(ctx context.Context) ([]BookingRequest, error) {
+	resp, err := h.do(ctx, "GET", nil, "booking_requests/bt")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatusCode(resp); err != nil {
+		return nil, err
+	}
+
+	var out []BookingRequest
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("http: unexpected response (%s)", resp.Status)
+	}
+	return out, nil
+}
+
+func (h *HttpBookingRequests) Approve(ctx context.Context, id string) error {
+	resp, err := h.do(ctx, "POST", nil, "booking_requests/bt", id, "approve")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatusCode(resp)
+}
+
+func (h *HttpBookingRequests) Reject(ctx context.Context, id string, comment string) error {
+	b, err := json.Marshal(map[string]string{"comment": comment})
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.do(ctx, "POST", b, "booking_requests/bt", id, "reject")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatusCode(resp)
+}
+
+func (h *HttpBookingRequests) CheckIn(ctx context.Context, code string, attendance string) (CheckInResult, error) {
+	b, err := json.Marshal(map[string]string{"booking_code": code, "attendance": attendance})
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	resp, err := h.do(ctx, "POST", b, "appointments/bt/check_in")
+	if err != nil {
+		return CheckInResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatusCode(resp); err != nil {
+		return CheckInResult{}, err
+	}
+
+	var out CheckInResult
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return CheckInResult{}, fmt.Errorf("http: unexpected response (%s)", resp.Status)
+	}
+	return out, nil
+}
+
+// IsOwnerCommand reports whether the text is one of commands of the business owner
+func IsOwnerCommand(text string) bool {
+	name, _, _ := strings.Cut(strings.TrimSpace(text), " ")
+	switch name {
+	case OwnerRequestsCommand, OwnerApproveCommand, OwnerRejectCommand, OwnerCheckInCommand, OwnerNoShowCommand:
+		return true
+	}
+	return false
+}
+
+// ProcessOwnerCommand lists, approves and rejects pending bookings and checks customers in on behalf of the owner.
+// Default settings of the bot are used for the reply.
+func (ds *DialogsStorage) ProcessOwnerCommand(c *chat.ChatContext, provider OwnerCommandsProvider, text string) error {
+	settings := ds.depsProto.UserSettings
+	l := settings.Loc.Localizer()
+	reply := func(m *i18n.Message) error {
+		localized, err := l.LocalizeMessage(m)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLocalizeMessage, err)
+		}
+		return ds.depsProto.chat.Print(c, localized)
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return reply(messages.OwnerCommandsUsage)
+	}
+
+	switch fields[0] {
+	case OwnerRequestsCommand:
+		requests, err := provider.PendingRequests(c.Ctx)
+		if err != nil {
+			return errors.Join(err, reply(messages.InternalErrorOccurred))
+		}
+		if len(requests) == 0 {
+			return reply(messages.OwnerNoRequests)
+		}
+
+		lines := make([]string, 0, len(requests))
+		for _, r := range requests {
+			line, err := l.Localize(&i18n.LocalizeConfig{
+				DefaultMessage: messages.OwnerPendingRequest,
+				TemplateData: map[string]any{
+					"Start":    formatNotificationTime(settings, r.TpStart),
+					"Len":      r.Len,
+					"Customer": r.CustomerId,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrLocalizeMessage, err)
+			}
+			lines = append(lines, fmt.Sprintf("%s\n%s", r.Id, line))
+		}
+		return ds.depsProto.chat.Print(c, strings.Join(lines, "\n\n"))
+	case OwnerApproveCommand:
+		if len(fields) != 2 {
+			return reply(messages.OwnerCommandsUsage)
+		}
+		if err := provider.Approve(c.Ctx, fields[1]); err != nil {
+			return errors.Join(err, reply(messages.InternalErrorOccurred))
+		}
+		return reply(messages.OwnerRequestApproved)
+	case OwnerRejectCommand:
+		if len(fields) < 2 {
+			return reply(messages.OwnerCommandsUsage)
+		}
+		comment := strings.Join(fields[2:], " ")
+		if err := provider.Reject(c.Ctx, fields[1], comment); err != nil {
+			return errors.Join(err, reply(messages.InternalErrorOccurred))
+		}
+		return reply(messages.OwnerRequestRejected)
+	case OwnerCheckInCommand, OwnerNoShowCommand:
+		if len(fields) != 2 {
+			return reply(messages.OwnerCommandsUsage)
+		}
+		attendance, done := attendanceAttended, messages.OwnerCheckedIn
+		if fields[0] == OwnerNoShowCommand {
+			attendance, done = attendanceNoShow, messages.OwnerMarkedNoShow
+		}
+
+		result, err := provider.CheckIn(c.Ctx, fields[1], attendance)
+		if errors.Is(err, common.ErrNotFound) {
+			return reply(messages.OwnerBookingNotFound)
+		}
+		if err != nil {
+			return errors.Join(err, reply(messages.InternalErrorOccurred))
+		}
+
+		localized, err := l.Localize(&i18n.LocalizeConfig{
+			DefaultMessage: done,
+			TemplateData: map[string]any{
+				"Start":    formatNotificationTime(settings, result.TpStart),
+				"Customer": result.CustomerId,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLocalizeMessage, err)
+		}
+		return ds.depsProto.chat.Print(c, localized)
+	default:
+		return reply(messages.OwnerCommandsUsage)
+	}
+}
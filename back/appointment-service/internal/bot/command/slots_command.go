@@ -7,10 +7,12 @@ import (
 	common "scheduler/appointment-service/internal"
 	"scheduler/appointment-service/internal/bot/chat"
 	"scheduler/appointment-service/internal/bot/i18n/messages"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
@@ -28,12 +30,18 @@ func ToLabeledSlot(slots []common.Slot) []LabeledSlot {
 }
 
 type Appointment interface {
-	AddSlots(ctx context.Context, customer common.ID, slots []common.Slot) error
+	// AddSlots books the slots. key identifies the booking, retries of it use the same key.
+	AddSlots(ctx context.Context, customer common.ID, slots []common.Slot, key string) error
+}
+
+type Waitlist interface {
+	JoinWaitlist(ctx context.Context, customer common.ID, interval common.Interval) error
 }
 
 type commands struct {
 	WeekSlots   *WeekSlots
 	Appointment Appointment
+	Waitlist    Waitlist
 }
 
 type IdentifyMessageFunc func(string) *messages.MessageConstant
@@ -45,7 +53,13 @@ type slotsSmDeps struct {
 
 type SlotSelectionCommand struct {
 	availableSlots []LabeledSlot
-	deps           *slotsSmDeps
+	// maxChunk limits adjacent availableSlots booked as one appointment
+	maxChunk time.Duration
+	// offerID identifies the shown availableSlots, repeated choices of them are one booking
+	offerID string
+	// range without available slots offered to join the waitlist
+	waitlistRange common.Interval
+	deps          *slotsSmDeps
 }
 
 type SlotSelectionResult uint
@@ -58,7 +72,7 @@ const (
 
 // TODO write here or in MainMenu?
 func (sm *SlotSelectionCommand) ShowRangesMenu(c *chat.ChatContext, additional ...*i18n.Message) error {
-	options := []*i18n.Message{messages.NextWeek, messages.ThisWeek}
+	options := []*i18n.Message{messages.EarliestSlots, messages.NextWeek, messages.ThisWeek}
 	options = append(options, additional...)
 	return sm.deps.MD.Chat().ShowMenuMessages(c, messages.SelectRequestMessage, options)
 }
@@ -70,13 +84,28 @@ func (sm *SlotSelectionCommand) Process(r *Request) (SlotSelectionResult, error)
 			return SlotSelectionResultNotSet, errors.Join(ErrWrongUserInput, common.ErrNotFound)
 		}
 
+		if c == messages.JoinWaitlist && !sm.waitlistRange.Start.IsZero() {
+			err := sm.deps.Commands.Waitlist.JoinWaitlist(r.Ctx, common.ID(r.Customer), sm.waitlistRange)
+			if err != nil {
+				return SlotSelectionResultContinue, err
+			}
+			sm.waitlistRange = common.Interval{}
+			return SlotSelectionResultDone, sm.deps.MD.Chat().PrintMessage(r.ChatContext, messages.WaitlistJoined)
+		}
+
 		var err error
-		var slots []common.Slot
+		var slots AvailableSlots
+		var slotsRange common.Interval
+		now := r.Time.In(sm.deps.MD.UserSettings.TimeZone)
 		switch c {
 		case messages.NextWeek:
-			slots, err = sm.deps.Commands.WeekSlots.NextWeek(r.Ctx, r.Time.In(sm.deps.MD.UserSettings.TimeZone))
+			slotsRange = NextWeekRange(now)
+			slots, err = sm.deps.Commands.WeekSlots.NextWeek(r.Ctx, now)
 		case messages.ThisWeek:
-			slots, err = sm.deps.Commands.WeekSlots.ThisWeek(r.Ctx, r.Time.In(sm.deps.MD.UserSettings.TimeZone))
+			slotsRange = ThisWeekRange(now)
+			slots, err = sm.deps.Commands.WeekSlots.ThisWeek(r.Ctx, now)
+		case messages.EarliestSlots:
+			slots, err = sm.deps.Commands.WeekSlots.Earliest(r.Ctx, now)
 		default:
 			err = fmt.Errorf("%w: unexpected message text ID %s (%s)", ErrWrongUserInput, c.ID, r.Text)
 		}
@@ -85,13 +114,22 @@ func (sm *SlotSelectionCommand) Process(r *Request) (SlotSelectionResult, error)
 			return SlotSelectionResultNotSet, err
 		}
 
-		if len(slots) == 0 {
-			return SlotSelectionResultContinue, sm.deps.MD.Chat().PrintMessage(r.ChatContext, messages.NoSlotsAvailable)
+		if len(slots.Slots) == 0 {
+			// Nothing is free up to the horizon, there is no range to wait for
+			if sm.deps.Commands.Waitlist == nil || slotsRange.Start.IsZero() {
+				return SlotSelectionResultContinue, sm.deps.MD.Chat().PrintMessage(r.ChatContext, messages.NoSlotsAvailable)
+			}
+			sm.waitlistRange = slotsRange
+			options := []*i18n.Message{messages.JoinWaitlist, messages.EarliestSlots, messages.NextWeek, messages.ThisWeek, messages.Cancel}
+			return SlotSelectionResultContinue, sm.deps.MD.Chat().ShowMenuMessages(r.ChatContext, messages.NoSlotsAvailable, options)
 		}
+		sm.waitlistRange = common.Interval{}
 
-		sm.availableSlots = ToLabeledSlot(slots)
+		sm.availableSlots = ToLabeledSlot(slots.Slots)
+		sm.maxChunk = slots.MaxChunk
+		sm.offerID = uuid.New().String()
 		return SlotSelectionResultContinue, sm.deps.MD.Chat().ShowAsOptions(r.ChatContext,
-			messages.SelectRequestMessage, sm.availableSlots)
+			messages.SelectRequestMessage, sm.availableSlots, sm.maxChunk)
 	} else {
 		if r.Text != "" {
 			return SlotSelectionResultContinue, fmt.Errorf("%w: input text should be empty", ErrWrongUserInput)
@@ -127,29 +165,17 @@ func (sm *SlotSelectionCommand) Process(r *Request) (SlotSelectionResult, error)
 				}
 			}
 			sm.availableSlots = tmpArray
-			return SlotSelectionResultContinue, sm.deps.MD.Chat().ShowAsOptions(r.ChatContext, messages.SelectRequestMessage, sm.availableSlots)
+			return SlotSelectionResultContinue, sm.deps.MD.Chat().ShowAsOptions(r.ChatContext, messages.SelectRequestMessage, sm.availableSlots, sm.maxChunk)
 		case strings.HasPrefix(r.Choices[0], SlotMarker):
-			//TODO need logic for multiple slot choices
-			if len(r.Choices) != 1 {
-				return SlotSelectionResultContinue, fmt.Errorf("%w: wrong choices len (%v)", ErrWrongUserInput, len(r.Choices))
-			}
-
-			idxStr := r.Choices[0][len(SlotMarker):]
-			id, err := strconv.Atoi(idxStr)
+			tmpArray, err := sm.chosenSlots(r.Choices)
 			if err != nil {
-				return SlotSelectionResultNotSet, fmt.Errorf("%w: for %v", err, idxStr)
+				return SlotSelectionResultContinue, err
 			}
 
-			tmpArray := make([]common.Slot, 0, len(r.Choices))
-			//tmpPrint := make([]LabeledSlot, 0, len(r.Choices))
-			for _, slot := range sm.availableSlots {
-				if slot.ID == id {
-					tmpArray = append(tmpArray, slot.Slot)
-					//tmpPrint = append(tmpPrint, slot)
-				}
+			err = sm.deps.Commands.Appointment.AddSlots(r.Ctx, common.ID(r.Customer), tmpArray, sm.bookingKey(tmpArray))
+			if errors.Is(err, ErrBookingPending) {
+				return SlotSelectionResultDone, sm.deps.MD.Chat().PrintMessage(r.ChatContext, messages.BookingPending)
 			}
-
-			err = sm.deps.Commands.Appointment.AddSlots(r.Ctx, common.ID(r.Customer), tmpArray)
 			if err != nil {
 				return SlotSelectionResultContinue, err
 			}
@@ -162,17 +188,91 @@ func (sm *SlotSelectionCommand) Process(r *Request) (SlotSelectionResult, error)
 	}
 }
 
+// parseSlotChoice returns the range of slot IDs of the choice: "<SlotMarker>3" or "<SlotMarker>3-4"
+func parseSlotChoice(choice ChoiceID) (int, int, error) {
+	if !strings.HasPrefix(choice, SlotMarker) {
+		return 0, 0, fmt.Errorf("%w: unexpected choice %v", ErrWrongUserInput, choice)
+	}
+
+	idxStr := choice[len(SlotMarker):]
+	firstStr, lastStr, isRange := strings.Cut(idxStr, "-")
+	first, err := strconv.Atoi(firstStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: for %v", err, idxStr)
+	}
+	last := first
+	if isRange {
+		last, err = strconv.Atoi(lastStr)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: for %v", err, idxStr)
+		}
+		if last < first {
+			return 0, 0, fmt.Errorf("%w: bad slots range %v", ErrWrongUserInput, idxStr)
+		}
+	}
+	return first, last, nil
+}
+
+// chosenSlots collects slots of all choices. Several slots must be adjacent and
+// not longer than maxChunk together, they are booked as one appointment.
+func (sm *SlotSelectionCommand) chosenSlots(choices []ChoiceID) ([]common.Slot, error) {
+	ids := make(map[int]struct{})
+	for _, choice := range choices {
+		first, last, err := parseSlotChoice(choice)
+		if err != nil {
+			return nil, err
+		}
+		if last-first >= len(sm.availableSlots) {
+			return nil, fmt.Errorf("%w: too many slots chosen", ErrWrongUserInput)
+		}
+		for id := first; id <= last; id++ {
+			ids[id] = struct{}{}
+		}
+	}
+
+	out := make([]common.Slot, 0, len(ids))
+	for _, slot := range sm.availableSlots {
+		if _, ok := ids[slot.ID]; ok {
+			out = append(out, slot.Slot)
+		}
+	}
+	if len(out) != len(ids) {
+		return nil, fmt.Errorf("%w: unknown slot choice", ErrWrongUserInput)
+	}
+
+	slices.SortFunc(out, func(a, b common.Slot) int { return a.Start.Compare(b.Start) })
+	for i := 1; i < len(out); i++ {
+		if !out[i-1].Start.Add(out[i-1].Dur).Equal(out[i].Start) {
+			return nil, fmt.Errorf("%w: chosen slots are not adjacent", ErrWrongUserInput)
+		}
+	}
+	if last := out[len(out)-1]; len(out) > 1 && last.Start.Add(last.Dur).Sub(out[0].Start) > sm.maxChunk {
+		return nil, fmt.Errorf("%w: chosen slots are longer than %v", ErrWrongUserInput, sm.maxChunk)
+	}
+	return out, nil
+}
+
+// bookingKey is the idempotency key of booking the chosen slots of the offer.
+// The key is the same if the customer taps the option again after a lost response.
+func (sm *SlotSelectionCommand) bookingKey(slots []common.Slot) string {
+	last := slots[len(slots)-1]
+	return fmt.Sprintf("%s/%d-%d", sm.offerID, slots[0].Start.Unix(), last.Start.Add(last.Dur).Unix())
+}
+
 func (mm *SlotSelectionCommand) Cancel() {
 	mm.availableSlots = nil
+	mm.maxChunk = 0
+	mm.waitlistRange = common.Interval{}
 }
 
-func newSlotSelectionCommand(md *MenuDeps, weekSlots *WeekSlots, appointment Appointment) *SlotSelectionCommand {
+func newSlotSelectionCommand(md *MenuDeps, weekSlots *WeekSlots, appointment Appointment, waitlist Waitlist) *SlotSelectionCommand {
 	sm := &SlotSelectionCommand{
 		deps: &slotsSmDeps{
 			MD: md,
 			Commands: &commands{
 				WeekSlots:   weekSlots,
 				Appointment: appointment,
+				Waitlist:    waitlist,
 			},
 		},
 	}
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"errors"
+	common "scheduler/appointment-service/internal"
+	"testing"
+	"time"
+)
+
+func TestSlotSelectionCommand_ChosenSlots(t *testing.T) {
+	start := time.Date(2026, time.April, 6, 10, 0, 0, 0, time.UTC)
+	sm := &SlotSelectionCommand{availableSlots: []LabeledSlot{
+		{ID: 0, Slot: common.Slot{Start: start, Dur: 30 * time.Minute}},
+		{ID: 1, Slot: common.Slot{Start: start.Add(30 * time.Minute), Dur: 30 * time.Minute}},
+		{ID: 2, Slot: common.Slot{Start: start.Add(time.Hour), Dur: 30 * time.Minute}},
+		{ID: 3, Slot: common.Slot{Start: start.Add(2 * time.Hour), Dur: 30 * time.Minute}},
+	}, maxChunk: 90 * time.Minute}
+
+	slots, err := sm.chosenSlots([]ChoiceID{SlotMarker + "1"})
+	if err != nil || len(slots) != 1 || !slots[0].Start.Equal(start.Add(30*time.Minute)) {
+		t.Fatalf("single choice: %v %v", slots, err)
+	}
+
+	slots, err = sm.chosenSlots([]ChoiceID{SlotMarker + "2", SlotMarker + "0-1"})
+	if err != nil || len(slots) != 3 || !slots[0].Start.Equal(start) {
+		t.Fatalf("adjacent choices: %v %v", slots, err)
+	}
+
+	sm.maxChunk = time.Hour
+	if _, err := sm.chosenSlots([]ChoiceID{SlotMarker + "0-2"}); !errors.Is(err, ErrWrongUserInput) {
+		t.Fatalf("choice longer than max chunk must be rejected: %v", err)
+	}
+
+	for _, choices := range [][]ChoiceID{
+		{SlotMarker + "2", SlotMarker + "3"},
+		{SlotMarker + "4"},
+		{SlotMarker + "1-0"},
+		{SlotMarker + "0-1000000"},
+	} {
+		if _, err := sm.chosenSlots(choices); !errors.Is(err, ErrWrongUserInput) {
+			t.Fatalf("choices %v must be rejected: %v", choices, err)
+		}
+	}
+}
+
+func TestSlotSelectionCommand_BookingKey(t *testing.T) {
+	start := time.Date(2026, time.April, 6, 10, 0, 0, 0, time.UTC)
+	first := []common.Slot{{Start: start, Dur: 30 * time.Minute}}
+	both := []common.Slot{{Start: start, Dur: 30 * time.Minute}, {Start: start.Add(30 * time.Minute), Dur: 30 * time.Minute}}
+
+	sm := &SlotSelectionCommand{offerID: "offer1"}
+	if sm.bookingKey(first) != sm.bookingKey(first) {
+		t.Fatal("repeated choice must have the same key")
+	}
+	if sm.bookingKey(first) == sm.bookingKey(both) {
+		t.Fatal("other slots must have another key")
+	}
+	other := &SlotSelectionCommand{offerID: "offer2"}
+	if sm.bookingKey(first) == other.bookingKey(first) {
+		t.Fatal("booking of another offer must have another key")
+	}
+}
@@ -23,6 +23,14 @@ type DefaultUserSettings struct {
 	TimeZone string `cfg:"time_zone"`
 }
 
+// APIVersion is the path prefix of the scheduler API used by the bot
+const APIVersion = "v1"
+
+// Endpoint returns the URL of the versioned scheduler API path
+func (s *SchedulerConnection) Endpoint(path ...string) (string, error) {
+	return url.JoinPath(s.URL, append([]string{APIVersion}, path...)...)
+}
+
 func (s *SchedulerConnection) Validate() error {
 	if s.URL == "" {
 		return errors.New("scheduler url is not set")
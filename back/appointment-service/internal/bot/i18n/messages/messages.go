@@ -22,6 +22,11 @@ var NextWeek = &i18n.Message{
 	Other: "Next week",
 }
 
+var EarliestSlots = &i18n.Message{
+	ID:    "EarliestSlots",
+	Other: "Earliest slots",
+}
+
 var Cancel = &i18n.Message{
 	ID:    "Cancel",
 	Other: "Cancel",
@@ -117,6 +122,26 @@ var NoSlotsAvailable = &i18n.Message{
 	Other: "No slots available",
 }
 
+var JoinWaitlist = &i18n.Message{
+	ID:    "JoinWaitlist",
+	Other: "join waitlist",
+}
+
+var WaitlistJoined = &i18n.Message{
+	ID:    "WaitlistJoined",
+	Other: "You are in the waitlist. We will notify you when a slot is freed",
+}
+
+var WaitlistSlotFreed = &i18n.Message{
+	ID:    "WaitlistSlotFreed",
+	Other: "A slot is freed between {{.Start}} and {{.End}}. Book it before {{.ExpiresAt}}",
+}
+
+var WaitlistBookingLink = &i18n.Message{
+	ID:    "WaitlistBookingLink",
+	Other: "Booking link",
+}
+
 var InternalErrorOccurred = &i18n.Message{
 	ID:    "InternalErrorOccurred",
 	Other: "Internal error occurred",
@@ -177,3 +202,103 @@ func LocalizeMessages(l *i18n.Localizer, ms []*i18n.Message) ([]string, error) {
 	}
 	return localized, nil
 }
+
+var BookingAnswersRequired = &i18n.Message{
+	ID:    "BookingAnswersRequired",
+	Other: "This booking needs answers to questions of the business. Please book in the web app or contact the business",
+}
+
+var BookingPending = &i18n.Message{
+	ID:    "BookingPending",
+	Other: "Your request is sent. The booking is confirmed after approval",
+}
+
+var BookingApproved = &i18n.Message{
+	ID:    "BookingApproved",
+	Other: "Your booking {{.Start}} is confirmed",
+}
+
+var BookingRejected = &i18n.Message{
+	ID:    "BookingRejected",
+	Other: "Your booking request {{.Start}} is declined",
+}
+
+var BookingRequestExpired = &i18n.Message{
+	ID:    "BookingRequestExpired",
+	Other: "Your booking request {{.Start}} has expired without a response",
+}
+
+var BookingRejectComment = &i18n.Message{
+	ID:    "BookingRejectComment",
+	Other: "Comment",
+}
+
+var OwnerNoRequests = &i18n.Message{
+	ID:    "OwnerNoRequests",
+	Other: "No pending requests",
+}
+
+var OwnerPendingRequest = &i18n.Message{
+	ID:    "OwnerPendingRequest",
+	Other: "{{.Start}} ({{.Len}} min), customer {{.Customer}}",
+}
+
+var OwnerRequestApproved = &i18n.Message{
+	ID:    "OwnerRequestApproved",
+	Other: "Request approved",
+}
+
+var OwnerRequestRejected = &i18n.Message{
+	ID:    "OwnerRequestRejected",
+	Other: "Request rejected",
+}
+
+var OwnerCommandsUsage = &i18n.Message{
+	ID:    "OwnerCommandsUsage",
+	Other: "Commands: /requests, /approve <id>, /reject <id> [comment], /checkin <code>, /noshow <code>",
+}
+
+var CustomerBlocked = &i18n.Message{
+	ID:    "CustomerBlocked",
+	Other: "Sorry, online booking is not available for you. Please contact the business",
+}
+
+var AppointmentBookingCode = &i18n.Message{
+	ID:    "AppointmentBookingCode",
+	Other: "code",
+}
+
+var OwnerCheckedIn = &i18n.Message{
+	ID:    "OwnerCheckedIn",
+	Other: "Checked in: {{.Start}}, customer {{.Customer}}",
+}
+
+var OwnerMarkedNoShow = &i18n.Message{
+	ID:    "OwnerMarkedNoShow",
+	Other: "No-show: {{.Start}}, customer {{.Customer}}",
+}
+
+var OwnerBookingNotFound = &i18n.Message{
+	ID:    "OwnerBookingNotFound",
+	Other: "No appointment with this code",
+}
+
+var AppointmentCancelReason = &i18n.Message{
+	ID:    "AppointmentCancelReason",
+	Other: "Reason",
+}
+
+var AppointmentCancelledByBusiness = &i18n.Message{
+	ID:    "AppointmentCancelledByBusiness",
+	Other: "Your appointment on {{.Start}} is cancelled by the business",
+}
+
+var AppointmentAlternatives = &i18n.Message{
+	ID:    "AppointmentAlternatives",
+	Other: "Free alternatives",
+}
+
+var AppointmentRescheduled = &i18n.Message{
+	ID:    "AppointmentRescheduled",
+	Other: "Your appointment on {{.From}} is moved to {{.Start}}",
+}
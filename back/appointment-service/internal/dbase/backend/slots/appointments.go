@@ -2,8 +2,11 @@ package slots
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,9 +17,61 @@ type TimeSlotsStorage struct {
 	*sqlx.DB
 }
 
+var (
+	ErrNoSeatsLeft   = errors.New("no seats left")
+	ErrAlreadyBooked = errors.New("already booked")
+)
+
 type BusinessSlotSettings struct {
 	DefaultChunk time.Duration
 	MaxChunk     time.Duration
+	Policy       common.BookingPolicy
+	// Mode is common.BookingChunks if empty
+	Mode common.BookingMode
+	// Duration is used in common.BookingRange mode only
+	Duration common.DurationRange
+	// RequiresApproval makes customer bookings pending until the owner approves them
+	RequiresApproval bool
+	// PendingBlocksSlot makes the slot busy for others while the booking is pending
+	PendingBlocksSlot bool
+	// ApprovalTimeout is common.DefaultApprovalTimeout if zero
+	ApprovalTimeout time.Duration
+	// TimeZone is an IANA name of the business location, UTC if empty
+	TimeZone string
+	// MaxQuerySpan limits date ranges of slot queries, common.MaxSlotsQuerySpan if zero
+	MaxQuerySpan time.Duration
+}
+
+func (s BusinessSlotSettings) mode() common.BookingMode {
+	if s.Mode == "" {
+		return common.BookingChunks
+	}
+	return s.Mode
+}
+
+// Location returns the time zone of the business, UTC if it is not set
+func (s BusinessSlotSettings) Location() *time.Location {
+	if s.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// QuerySpan returns the longest date range of slot queries
+func (s BusinessSlotSettings) QuerySpan() time.Duration {
+	if s.MaxQuerySpan == 0 {
+		return common.MaxSlotsQuerySpan
+	}
+	return s.MaxQuerySpan
+}
+
+// IsRangeMode reports whether customers pick the booking duration themselves
+func (s BusinessSlotSettings) IsRangeMode() bool {
+	return s.Mode == common.BookingRange
 }
 
 func validateBusinessSlotSettings(settings BusinessSlotSettings) error {
@@ -27,17 +82,59 @@ func validateBusinessSlotSettings(settings BusinessSlotSettings) error {
 	if settings.DefaultChunk > settings.MaxChunk {
 		return fmt.Errorf("default chunk is greater than max chunk")
 	}
-	return nil
+	if err := settings.mode().Validate(); err != nil {
+		return err
+	}
+	if settings.IsRangeMode() {
+		if err := settings.Duration.Validate(); err != nil {
+			return err
+		}
+	}
+	if settings.ApprovalTimeout < 0 {
+		return fmt.Errorf("approval timeout is negative")
+	}
+	if settings.TimeZone != "" {
+		if _, err := time.LoadLocation(settings.TimeZone); err != nil {
+			return fmt.Errorf("%w: time zone: %s", common.ErrInvalidArgument, err.Error())
+		}
+	}
+	if settings.MaxQuerySpan < 0 || settings.MaxQuerySpan > common.MaxSlotsQuerySpan {
+		return fmt.Errorf("%w: max query span is not in [0, %v]", common.ErrInvalidArgument, common.MaxSlotsQuerySpan)
+	}
+	return settings.Policy.Validate()
 }
 
 type dbBusinessSlotSettings struct {
-	DefaultChunkMinutes int `db:"default_chunk_minutes"`
-	MaxChunkMinutes     int `db:"max_chunk_minutes"`
+	DefaultChunkMinutes       int    `db:"default_chunk_minutes"`
+	MaxChunkMinutes           int    `db:"max_chunk_minutes"`
+	MinLeadMinutes            int    `db:"min_lead_minutes"`
+	MaxHorizonMinutes         int    `db:"max_horizon_minutes"`
+	MaxActiveBookings         int    `db:"max_active_bookings"`
+	MaxBookingsPerDay         int    `db:"max_bookings_per_day"`
+	MaxBookingsPerWeek        int    `db:"max_bookings_per_week"`
+	OneBookingPerDay          bool   `db:"one_booking_per_day"`
+	MinGapMinutes             int    `db:"min_gap_minutes"`
+	CancellationCutoffMinutes int    `db:"cancellation_cutoff_minutes"`
+	BookingMode               string `db:"booking_mode"`
+	MinDurationMinutes        int    `db:"min_duration_minutes"`
+	MaxDurationMinutes        int    `db:"max_duration_minutes"`
+	DurationStepMinutes       int    `db:"duration_step_minutes"`
+	RequiresApproval          bool   `db:"requires_approval"`
+	PendingBlocksSlot         bool   `db:"pending_blocks_slot"`
+	ApprovalTimeoutMinutes    int    `db:"approval_timeout_minutes"`
+	TimeZone                  string `db:"time_zone"`
+	MaxQuerySpanMinutes       int    `db:"max_query_span_minutes"`
 }
 
 func (db *TimeSlotsStorage) GetBusinessSlotSettings(businessID common.ID) (BusinessSlotSettings, error) {
 	var row dbBusinessSlotSettings
-	err := db.Get(&row, `SELECT default_chunk_minutes, max_chunk_minutes FROM business_slot_settings WHERE business_id = $1`, string(businessID))
+	err := db.Get(&row, `SELECT default_chunk_minutes, max_chunk_minutes,
+		min_lead_minutes, max_horizon_minutes, max_active_bookings, max_bookings_per_day,
+		max_bookings_per_week, one_booking_per_day, min_gap_minutes, cancellation_cutoff_minutes,
+		booking_mode, min_duration_minutes, max_duration_minutes, duration_step_minutes,
+		requires_approval, pending_blocks_slot, approval_timeout_minutes, time_zone,
+		max_query_span_minutes
+		FROM business_slot_settings WHERE business_id = $1`, string(businessID))
 	if err != nil {
 		return BusinessSlotSettings{}, err
 	}
@@ -45,6 +142,27 @@ func (db *TimeSlotsStorage) GetBusinessSlotSettings(businessID common.ID) (Busin
 	settings := BusinessSlotSettings{
 		DefaultChunk: time.Duration(row.DefaultChunkMinutes) * time.Minute,
 		MaxChunk:     time.Duration(row.MaxChunkMinutes) * time.Minute,
+		Policy: common.BookingPolicy{
+			MinLeadTime:        time.Duration(row.MinLeadMinutes) * time.Minute,
+			MaxHorizon:         time.Duration(row.MaxHorizonMinutes) * time.Minute,
+			MaxActiveBookings:  row.MaxActiveBookings,
+			MaxBookingsPerDay:  row.MaxBookingsPerDay,
+			MaxBookingsPerWeek: row.MaxBookingsPerWeek,
+			OneBookingPerDay:   row.OneBookingPerDay,
+			MinGap:             time.Duration(row.MinGapMinutes) * time.Minute,
+			CancellationCutoff: time.Duration(row.CancellationCutoffMinutes) * time.Minute,
+		},
+		Mode: common.BookingMode(row.BookingMode),
+		Duration: common.DurationRange{
+			Min:  time.Duration(row.MinDurationMinutes) * time.Minute,
+			Max:  time.Duration(row.MaxDurationMinutes) * time.Minute,
+			Step: time.Duration(row.DurationStepMinutes) * time.Minute,
+		},
+		RequiresApproval:  row.RequiresApproval,
+		PendingBlocksSlot: row.PendingBlocksSlot,
+		ApprovalTimeout:   time.Duration(row.ApprovalTimeoutMinutes) * time.Minute,
+		TimeZone:          row.TimeZone,
+		MaxQuerySpan:      time.Duration(row.MaxQuerySpanMinutes) * time.Minute,
 	}
 
 	if err := validateBusinessSlotSettings(settings); err != nil {
@@ -59,15 +177,55 @@ func (db *TimeSlotsStorage) SetBusinessSlotSettings(businessID common.ID, settin
 		return err
 	}
 
+	p := settings.Policy
 	_, err := db.Exec(`
-		INSERT INTO business_slot_settings (business_id, default_chunk_minutes, max_chunk_minutes)
-		VALUES ($1, $2, $3)
+		INSERT INTO business_slot_settings (business_id, default_chunk_minutes, max_chunk_minutes,
+			min_lead_minutes, max_horizon_minutes, max_active_bookings, max_bookings_per_day,
+			max_bookings_per_week, one_booking_per_day, min_gap_minutes, cancellation_cutoff_minutes,
+			booking_mode, min_duration_minutes, max_duration_minutes, duration_step_minutes,
+			requires_approval, pending_blocks_slot, approval_timeout_minutes, time_zone,
+			max_query_span_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 		ON CONFLICT (business_id) DO UPDATE
 		SET default_chunk_minutes = EXCLUDED.default_chunk_minutes,
-		    max_chunk_minutes = EXCLUDED.max_chunk_minutes`,
+		    max_chunk_minutes = EXCLUDED.max_chunk_minutes,
+		    min_lead_minutes = EXCLUDED.min_lead_minutes,
+		    max_horizon_minutes = EXCLUDED.max_horizon_minutes,
+		    max_active_bookings = EXCLUDED.max_active_bookings,
+		    max_bookings_per_day = EXCLUDED.max_bookings_per_day,
+		    max_bookings_per_week = EXCLUDED.max_bookings_per_week,
+		    one_booking_per_day = EXCLUDED.one_booking_per_day,
+		    min_gap_minutes = EXCLUDED.min_gap_minutes,
+		    cancellation_cutoff_minutes = EXCLUDED.cancellation_cutoff_minutes,
+		    booking_mode = EXCLUDED.booking_mode,
+		    min_duration_minutes = EXCLUDED.min_duration_minutes,
+		    max_duration_minutes = EXCLUDED.max_duration_minutes,
+		    duration_step_minutes = EXCLUDED.duration_step_minutes,
+		    requires_approval = EXCLUDED.requires_approval,
+		    pending_blocks_slot = EXCLUDED.pending_blocks_slot,
+		    approval_timeout_minutes = EXCLUDED.approval_timeout_minutes,
+		    time_zone = EXCLUDED.time_zone,
+		    max_query_span_minutes = EXCLUDED.max_query_span_minutes`,
 		string(businessID),
 		int(settings.DefaultChunk.Minutes()),
 		int(settings.MaxChunk.Minutes()),
+		int(p.MinLeadTime.Minutes()),
+		int(p.MaxHorizon.Minutes()),
+		p.MaxActiveBookings,
+		p.MaxBookingsPerDay,
+		p.MaxBookingsPerWeek,
+		p.OneBookingPerDay,
+		int(p.MinGap.Minutes()),
+		int(p.CancellationCutoff.Minutes()),
+		string(settings.mode()),
+		int(settings.Duration.Min.Minutes()),
+		int(settings.Duration.Max.Minutes()),
+		int(settings.Duration.Step.Minutes()),
+		settings.RequiresApproval,
+		settings.PendingBlocksSlot,
+		int(settings.ApprovalTimeout.Minutes()),
+		settings.TimeZone,
+		int(settings.MaxQuerySpan.Minutes()),
 	)
 	return err
 }
@@ -75,30 +233,48 @@ func (db *TimeSlotsStorage) SetBusinessSlotSettings(businessID common.ID, settin
 type dbBusySlot struct {
 	Customer  string `db:"customer_id"`
 	Business  string `db:"business_id"` // TODO use integer
+	Resource  string `db:"resource_id"`
+	Seat      int    `db:"seat"`
 	DateStart int64  `db:"date_start"`
 	DateEnd   int64  `db:"date_end"`
+	Answers   string `db:"answers"`
+	Series    string `db:"series_id"`
+	// BookingCode is set by the database trigger on insert
+	BookingCode string `db:"booking_code"`
+	Attendance  string `db:"attendance"`
 }
 
+const appointmentColumns = "customer_id, business_id, resource_id, seat, date_start, date_end, COALESCE(booking_code, '') AS booking_code, attendance"
+
 func (slot dbBusySlot) ToSlot() common.BusySlot {
 	return common.BusySlot{
 		Customer: slot.Customer,
+		Resource: slot.Resource,
 		Interval: common.Interval{
 			Start: time.Unix(slot.DateStart, 0),
 			End:   time.Unix(slot.DateEnd, 0),
 		},
+		BookingCode: slot.BookingCode,
+		Attendance:  common.Attendance(slot.Attendance),
 	}
 }
 
 type RuleID = string
 
+// DefaultResource is the business-wide calendar. Rules and appointments
+// without explicit resource belong to it.
+const DefaultResource common.ID = ""
+
 type DbBusinessRule struct {
-	Id   RuleID
-	Rule common.IntervalRRuleWithType
+	Id         RuleID
+	ResourceId common.ID
+	Rule       common.IntervalRRuleWithType
 }
 
 type dbJsonBusinessRule struct {
-	Id   RuleID
-	Rule string
+	Id         RuleID
+	ResourceId common.ID `db:"resource_id"`
+	Rule       string
 }
 
 func ConvertSlice(in []dbJsonBusinessRule) ([]DbBusinessRule, error) {
@@ -110,6 +286,7 @@ func ConvertSlice(in []dbJsonBusinessRule) ([]DbBusinessRule, error) {
 			return nil, err
 		}
 		tmp.Id = el.Id
+		tmp.ResourceId = el.ResourceId
 		intervalsRRules = append(intervalsRRules, tmp)
 	}
 	return intervalsRRules, nil
@@ -117,7 +294,7 @@ func ConvertSlice(in []dbJsonBusinessRule) ([]DbBusinessRule, error) {
 
 func (db *TimeSlotsStorage) GetBusinessRules(business_id common.ID) ([]DbBusinessRule, error) {
 	var rules []dbJsonBusinessRule
-	err := db.Select(&rules, "SELECT id, rule FROM business_work_rule WHERE business_id = $1", string(business_id))
+	err := db.Select(&rules, "SELECT id, resource_id, rule FROM business_work_rule WHERE business_id = $1", string(business_id))
 	if err != nil {
 		return nil, err
 	}
@@ -129,11 +306,32 @@ func (db *TimeSlotsStorage) GetBusinessRules(business_id common.ID) ([]DbBusines
 			return DbBusinessRule{}, err
 		}
 		tmp.Id = in.Id
+		tmp.ResourceId = in.ResourceId
 		return tmp, nil
 	})
 }
 
 func (db *TimeSlotsStorage) AddBusinessRule(businessID string, rule common.IntervalRRuleWithType) (RuleID, error) {
+	return db.AddResourceRule(businessID, DefaultResource, rule)
+}
+
+// AddResourceRule stores working rule of the business resource.
+// common.ErrNotFound is returned if the resource doesn't belong to the business.
+func (db *TimeSlotsStorage) AddResourceRule(businessID common.ID, resourceID common.ID, rule common.IntervalRRuleWithType) (RuleID, error) {
+	if err := rule.Validate(); err != nil {
+		return "", err
+	}
+
+	if resourceID != DefaultResource {
+		if _, err := db.GetResource(businessID, resourceID); err != nil {
+			return "", err
+		}
+	}
+
+	return insertRule(db, businessID, resourceID, rule)
+}
+
+func insertRule(e sqlx.Execer, businessID common.ID, resourceID common.ID, rule common.IntervalRRuleWithType) (RuleID, error) {
 	b, err := json.Marshal(rule)
 	if err != nil {
 		return "", err
@@ -141,10 +339,10 @@ func (db *TimeSlotsStorage) AddBusinessRule(businessID string, rule common.Inter
 
 	newID := uuid.New().String()
 
-	_, err = db.Exec(`
-		INSERT INTO business_work_rule (id, business_id, rule)
-		VALUES ($1, $2, $3)
-	`, newID, businessID, string(b))
+	_, err = e.Exec(`
+		INSERT INTO business_work_rule (id, business_id, resource_id, rule)
+		VALUES ($1, $2, $3, $4)
+	`, newID, businessID, resourceID, string(b))
 	return newID, err
 }
 
@@ -156,50 +354,192 @@ func (db *TimeSlotsStorage) DeleteBusinessRule(business_id common.ID, id string)
 	return err
 }
 
-// TODO add test
 func (db *TimeSlotsStorage) GetAvailableSlotsInRange(business_id common.ID, between common.Interval) (common.Intervals, error) {
-	var jsonRules []string
-	err := db.Select(&jsonRules, "SELECT rule FROM business_work_rule WHERE business_id = $1", string(business_id))
+	return db.GetResourceAvailableSlotsInRange(business_id, DefaultResource, between)
+}
+
+func (db *TimeSlotsStorage) GetResourceAvailableSlotsInRange(businessID common.ID, resourceID common.ID, between common.Interval) (common.Intervals, error) {
+	available, err := db.GetResourcesAvailableSlotsInRange(businessID, []common.ID{resourceID}, between)
 	if err != nil {
 		return nil, err
 	}
+	return available[resourceID], nil
+}
 
-	intervalsRRules, err := common.MapE(jsonRules, func(in string) (common.IntervalRRuleWithType, error) {
-		var tmp common.IntervalRRuleWithType
-		err := json.Unmarshal([]byte(in), &tmp)
-		if err != nil {
-			return common.IntervalRRuleWithType{}, err
+// GetResourcesAvailableSlotsInRange returns free time of every requested resource.
+// Exclusion rules of the DefaultResource (holidays, days off) are applied to all
+// resources of the business. Resources without free time are absent in the result.
+// Group sessions are not included, see GetResourcesAvailabilityInRange.
+func (db *TimeSlotsStorage) GetResourcesAvailableSlotsInRange(businessID common.ID, resources []common.ID, between common.Interval) (map[common.ID]common.Intervals, error) {
+	availability, err := db.GetResourcesAvailabilityInRange(businessID, resources, between)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[common.ID]common.Intervals, len(availability))
+	for resource, a := range availability {
+		if len(a.Free) != 0 {
+			out[resource] = a.Free
 		}
-		return tmp, nil
-	})
+	}
+	return out, nil
+}
+
+// Session is an occurrence of a group rule (see common.IntervalRRuleWithType.Capacity)
+type Session struct {
+	common.Interval
+	Capacity  int
+	SeatsLeft int
+}
+
+type Availability struct {
+	// Free time of individual booking
+	Free common.Intervals
+	// Group sessions with at least one free seat, sorted by start
+	Sessions []Session
+}
+
+// GetResourcesAvailabilityInRange returns free time and group sessions of every requested resource.
+// Exclusion rules of the DefaultResource (holidays, days off) are applied to all
+// resources of the business. Resources without availability are absent in the result.
+//
+// Group session occupies its time on the resource: it is never a part of Free time.
+// Seats are counted per occurrence: appointment with exactly the session interval takes a seat,
+// any other overlapping appointment blocks the whole session.
+func (db *TimeSlotsStorage) GetResourcesAvailabilityInRange(businessID common.ID, resources []common.ID, between common.Interval) (map[common.ID]Availability, error) {
+	var dbRules []dbJsonBusinessRule
+	err := db.Select(&dbRules, "SELECT id, resource_id, rule FROM business_work_rule WHERE business_id = $1", string(businessID))
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO Not optimal
-	intervals := common.CalculateIntervals(intervalsRRules)
-	intervals = intervals.UnitedBetween(between)
-	if len(intervals) == 0 {
-		return nil, nil
+	rules, err := ConvertSlice(dbRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var businessExclusions []common.IntervalRRuleWithType
+	rulesByResource := make(map[common.ID][]common.IntervalRRuleWithType, len(resources))
+	groupsByResource := make(map[common.ID][]common.IntervalRRuleWithType)
+	for _, r := range rules {
+		if r.Rule.IsGroup() {
+			groupsByResource[r.ResourceId] = append(groupsByResource[r.ResourceId], r.Rule)
+			continue
+		}
+		rulesByResource[r.ResourceId] = append(rulesByResource[r.ResourceId], r.Rule)
+		if r.ResourceId == DefaultResource && r.Rule.Type == common.Exclusion {
+			businessExclusions = append(businessExclusions, r.Rule)
+		}
+	}
+
+	busy, err := db.getBusyIntervalsOverlapping(businessID, between)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[common.ID]Availability, len(resources))
+	for _, resource := range resources {
+		resourceRules := rulesByResource[resource]
+		if resource != DefaultResource {
+			resourceRules = append(resourceRules, businessExclusions...)
+		}
+
+		var exclusions common.Intervals
+		for _, r := range resourceRules {
+			if r.Type == common.Exclusion {
+				exclusions = append(exclusions, r.Rule.GetIntervals()...)
+			}
+		}
+		exclusions = common.PrepareUnited(exclusions)
+
+		var sessions []Session
+		var sessionsTime common.Intervals
+		for _, group := range groupsByResource[resource] {
+			for _, occurrence := range group.Rule.GetIntervals() {
+				if !between.IsFit(occurrence) || exclusions.IsOverlap(occurrence) {
+					continue
+				}
+				sessionsTime = append(sessionsTime, occurrence)
+
+				session := Session{Interval: occurrence, Capacity: group.Capacity, SeatsLeft: group.Capacity}
+				for _, appointment := range busy[resource] {
+					if appointment.Start.Equal(occurrence.Start) && appointment.End.Equal(occurrence.End) {
+						session.SeatsLeft--
+					} else if appointment.IsOverlap(occurrence) {
+						session.SeatsLeft = 0
+						break
+					}
+				}
+				if session.SeatsLeft > 0 {
+					sessions = append(sessions, session)
+				}
+			}
+		}
+		slices.SortFunc(sessions, func(a, b Session) int { return a.Start.Compare(b.Start) })
+
+		// TODO Not optimal
+		intervals := common.CalculateIntervals(resourceRules)
+		intervals = intervals.UnitedBetween(between)
+		if len(intervals) != 0 {
+			busyTime := append(busy[resource].Copy(), sessionsTime...)
+			intervals = intervals.PassedIntervals(common.PrepareUnited(busyTime))
+		}
+
+		if len(intervals) != 0 || len(sessions) != 0 {
+			out[resource] = Availability{Free: intervals, Sessions: sessions}
+		}
+	}
+	return out, nil
+}
+
+// getBusyIntervalsOverlapping returns appointments and active holds by resources.
+// Unlike GetBusySlotsInRange it also returns appointments started before between.Start
+func (db *TimeSlotsStorage) getBusyIntervalsOverlapping(businessID common.ID, between common.Interval) (map[common.ID]common.Intervals, error) {
+	var dbSlots []dbBusySlot
+	err := db.Select(&dbSlots, "SELECT "+appointmentColumns+" FROM appointments WHERE business_id = $1 AND date_end > $2 AND date_start < $3",
+		string(businessID), between.Start.Unix(), between.End.Unix())
+	if err != nil {
+		return nil, err
 	}
 
-	slots, err := db.GetBusySlotsInRange(business_id, between)
+	out, err := db.getActiveHoldsOverlapping(businessID, between)
 	if err != nil {
 		return nil, err
 	}
+	for _, dbSlot := range dbSlots {
+		slot := dbSlot.ToSlot()
+		out[slot.Resource] = append(out[slot.Resource], slot.Interval)
+	}
+	return out, nil
+}
 
-	var exclusions common.Intervals
-	for _, slot := range slots {
-		exclusions = append(exclusions, slot.Interval)
+// GetResourcesLoad returns booked time of resources in the interval.
+// Resources without appointments are absent in the result.
+func (db *TimeSlotsStorage) GetResourcesLoad(businessID common.ID, between common.Interval) (map[common.ID]time.Duration, error) {
+	var rows []struct {
+		Resource string `db:"resource_id"`
+		Seconds  int64  `db:"seconds"`
+	}
+	err := db.Select(&rows, `
+		SELECT resource_id, COALESCE(SUM(date_end - date_start), 0) AS seconds
+		FROM appointments
+		WHERE business_id = $1 AND date_end > $2 AND date_start < $3
+		GROUP BY resource_id`,
+		string(businessID), between.Start.Unix(), between.End.Unix())
+	if err != nil {
+		return nil, err
 	}
 
-	intervals = intervals.PassedIntervals(exclusions)
-	return intervals, nil
+	out := make(map[common.ID]time.Duration, len(rows))
+	for _, row := range rows {
+		out[row.Resource] = time.Duration(row.Seconds) * time.Second
+	}
+	return out, nil
 }
 
 func (db *TimeSlotsStorage) GetBusySlotsInRange(business_id common.ID, between common.Interval) ([]common.BusySlot, error) {
 	var dbSlots []dbBusySlot
-	err := db.Select(&dbSlots, "SELECT * FROM appointments WHERE business_id = $1 AND date_start BETWEEN $2 AND $3",
+	err := db.Select(&dbSlots, "SELECT "+appointmentColumns+" FROM appointments WHERE business_id = $1 AND date_start BETWEEN $2 AND $3 ORDER BY date_start",
 		string(business_id), between.Start.Unix(), between.End.Unix())
 	if err != nil {
 		return nil, err
@@ -234,7 +574,7 @@ func (db *TimeSlotsStorage) GetCustomerAppointmentsInRange(businessID common.ID,
 	if between.End.IsZero() {
 		err = db.Select(
 			&dbSlots,
-			`SELECT * FROM appointments
+			`SELECT `+appointmentColumns+` FROM appointments
 			 WHERE business_id = $1 AND customer_id = $2 AND date_end >= $3
 			 ORDER BY date_start`,
 			string(businessID), string(customerID), between.Start.Unix(),
@@ -242,7 +582,7 @@ func (db *TimeSlotsStorage) GetCustomerAppointmentsInRange(businessID common.ID,
 	} else {
 		err = db.Select(
 			&dbSlots,
-			`SELECT * FROM appointments
+			`SELECT `+appointmentColumns+` FROM appointments
 			 WHERE business_id = $1 AND customer_id = $2 AND date_end >= $3 AND date_start <= $4
 			 ORDER BY date_start`,
 			string(businessID), string(customerID), between.Start.Unix(), between.End.Unix(),
@@ -259,6 +599,20 @@ func (db *TimeSlotsStorage) GetCustomerAppointmentsInRange(businessID common.ID,
 	return slotsOut, nil
 }
 
+// CancelAppointment removes the customer appointment started at start.
+// common.ErrNotFound is returned if there is no such appointment.
+func (db *TimeSlotsStorage) CancelAppointment(businessID common.ID, customerID common.ID, start time.Time) error {
+	res, err := db.Exec("DELETE FROM appointments WHERE business_id = $1 AND customer_id = $2 AND date_start = $3",
+		string(businessID), string(customerID), start.Unix())
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("appointment at %v: %w", start, common.ErrNotFound)
+	}
+	return nil
+}
+
 func (db *TimeSlotsStorage) DeleteSlots(business_id common.ID, customerID common.ID, start time.Time, end time.Time) error {
 	_, err := db.Exec("DELETE FROM appointments WHERE business_id = $1 AND customer_id = $2 AND date_start BETWEEN $3 AND $4",
 		business_id, customerID, start.Unix(), end.Unix())
@@ -268,20 +622,116 @@ func (db *TimeSlotsStorage) DeleteSlots(business_id common.ID, customerID common
 type AddSlotsData struct {
 	Business common.ID
 	Customer common.ID
+	Resource common.ID
 	Slots    common.Intervals
+	Answers  common.Answers
 }
 
-// expected that no intersections in range
+// AddSlots books the slots on the resource at once. ErrSlotTaken is returned if any slot
+// overlaps an appointment, an active hold or a pending request blocking the slot.
 func (db *TimeSlotsStorage) AddSlots(in AddSlotsData) error {
-	dbSlots := make([]dbBusySlot, 0, len(in.Slots))
+	tx, err := db.Beginx()
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
 	for _, slot := range in.Slots {
-		dbSlots = append(dbSlots, dbBusySlot{
-			Customer:  in.Customer,
-			Business:  in.Business,
-			DateStart: slot.Start.Unix(),
-			DateEnd:   slot.End.Unix(),
-		})
-	}
-	_, err := db.NamedExec("INSERT INTO appointments (business_id, date_start, customer_id, date_end) VALUES (:business_id, :date_start, :customer_id, :date_end)", dbSlots)
-	return err
+		taken, err := isTaken(tx, in.Business, in.Resource, slot, now)
+		if err != nil {
+			return dbase.DbError(err)
+		}
+		if taken {
+			return ErrSlotTaken
+		}
+
+		_, err = tx.Exec(`INSERT INTO appointments (business_id, resource_id, date_start, customer_id, date_end, answers) VALUES ($1, $2, $3, $4, $5, $6)`,
+			string(in.Business), string(in.Resource), slot.Start.Unix(), string(in.Customer), slot.End.Unix(), encodeAnswers(in.Answers))
+		if err != nil {
+			if dbase.IsConstraintError(err) {
+				return ErrSlotTaken
+			}
+			return dbase.DbError(err)
+		}
+	}
+
+	return dbase.DbError(tx.Commit())
+}
+
+type BookSeatData struct {
+	Business common.ID
+	Customer common.ID
+	Resource common.ID
+	Session  Session
+	Answers  common.Answers
+	// Series is set for occurrences of the appointment series
+	Series common.ID
+}
+
+// BookSeat takes a free seat of the group session. Returns index of the taken seat.
+// ErrNoSeatsLeft is returned if the session is full or blocked by other appointment,
+// ErrAlreadyBooked if the customer already has a seat in the session.
+func (db *TimeSlotsStorage) BookSeat(in BookSeatData) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	seat, err := bookSeat(tx, in)
+	if err != nil {
+		return 0, err
+	}
+	return seat, dbase.DbError(tx.Commit())
+}
+
+// freeSeat returns index of the first free seat of the session, errors are as of BookSeat
+func freeSeat(tx *sqlx.Tx, businessID common.ID, customerID common.ID, resourceID common.ID, session Session) (int, error) {
+	var taken []dbBusySlot
+	err := tx.Select(&taken, "SELECT "+appointmentColumns+" FROM appointments WHERE business_id = $1 AND resource_id = $2 AND date_end > $3 AND date_start < $4",
+		string(businessID), string(resourceID), session.Start.Unix(), session.End.Unix())
+	if err != nil {
+		return 0, dbase.DbError(err)
+	}
+
+	seats := make(map[int]struct{}, len(taken))
+	for _, t := range taken {
+		if t.DateStart != session.Start.Unix() || t.DateEnd != session.End.Unix() {
+			return 0, ErrNoSeatsLeft
+		}
+		if t.Customer == customerID {
+			return 0, ErrAlreadyBooked
+		}
+		seats[t.Seat] = struct{}{}
+	}
+
+	if len(seats) >= session.Capacity {
+		return 0, ErrNoSeatsLeft
+	}
+
+	seat := 0
+	for ; ; seat++ {
+		if _, ok := seats[seat]; !ok {
+			return seat, nil
+		}
+	}
+}
+
+func bookSeat(tx *sqlx.Tx, in BookSeatData) (int, error) {
+	seat, err := freeSeat(tx, in.Business, in.Customer, in.Resource, in.Session)
+	if err != nil {
+		return 0, err
+	}
+
+	// UNIQUE (business_id, resource_id, date_start, seat) protects from concurrent booking of the same seat
+	_, err = tx.Exec("INSERT INTO appointments (business_id, resource_id, seat, date_start, customer_id, date_end, answers, series_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		string(in.Business), string(in.Resource), seat, in.Session.Start.Unix(), string(in.Customer), in.Session.End.Unix(), encodeAnswers(in.Answers), string(in.Series))
+	if err != nil {
+		if dbase.IsConstraintError(err) {
+			return 0, ErrNoSeatsLeft
+		}
+		return 0, dbase.DbError(err)
+	}
+	return seat, nil
 }
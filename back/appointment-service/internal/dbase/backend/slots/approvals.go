@@ -0,0 +1,329 @@
+package slots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrRequestExpired = errors.New("booking request expired")
+
+// ErrOutsideWorkingTime is returned when the requested time left working time of the resource,
+// e.g. by a time off or a deleted rule
+var ErrOutsideWorkingTime = errors.New("outside working time")
+
+const (
+	NotificationBookingApproved NotificationKind = "booking_approved"
+	NotificationBookingRejected NotificationKind = "booking_rejected"
+)
+
+// BookingRequest is a customer booking waiting for approval of the owner.
+// It becomes an appointment when approved and is removed when rejected or expired.
+type BookingRequest struct {
+	Id       common.ID
+	Business common.ID
+	Customer common.ID
+	Resource common.ID
+	Interval common.Interval
+	Answers  common.Answers
+	// BlocksSlot makes the slot busy for others while the request is pending
+	BlocksSlot bool
+	// Capacity of the group session if a seat is requested, 0 for individual bookings.
+	// Seat requests never block the session.
+	Capacity  int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type dbBookingRequest struct {
+	Id         string `db:"id"`
+	Business   string `db:"business_id"`
+	Customer   string `db:"customer_id"`
+	Resource   string `db:"resource_id"`
+	DateStart  int64  `db:"date_start"`
+	DateEnd    int64  `db:"date_end"`
+	Answers    string `db:"answers"`
+	BlocksSlot bool   `db:"blocks_slot"`
+	Capacity   int    `db:"capacity"`
+	CreatedAt  int64  `db:"created_at"`
+	ExpiresAt  int64  `db:"expires_at"`
+}
+
+func (r dbBookingRequest) toRequest() BookingRequest {
+	return BookingRequest{
+		Id:         r.Id,
+		Business:   r.Business,
+		Customer:   r.Customer,
+		Resource:   r.Resource,
+		Interval:   common.Interval{Start: time.Unix(r.DateStart, 0), End: time.Unix(r.DateEnd, 0)},
+		Answers:    decodeAnswers(r.Answers),
+		BlocksSlot: r.BlocksSlot,
+		Capacity:   r.Capacity,
+		CreatedAt:  time.Unix(r.CreatedAt, 0),
+		ExpiresAt:  time.Unix(r.ExpiresAt, 0),
+	}
+}
+
+const bookingRequestColumns = `id, business_id, customer_id, resource_id, date_start, date_end, answers, blocks_slot, capacity, created_at, expires_at`
+
+// bookingDecision is a payload of notifications about approved and rejected requests
+type bookingDecision struct {
+	DateStart time.Time `json:"date_start"`
+	DateEnd   time.Time `json:"date_end"`
+	// Expired is set if the owner has not acted on the request in time
+	Expired bool   `json:"expired,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// AddBookingRequest stores the pending booking and returns its ID.
+// ErrSlotTaken is returned if the slot overlaps an appointment, an active hold or a blocking request.
+// For seat requests ErrNoSeatsLeft and ErrAlreadyBooked are returned as by BookSeat.
+func (db *TimeSlotsStorage) AddBookingRequest(in BookingRequest) (common.ID, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if in.Capacity > 0 {
+		in.BlocksSlot = false
+		if _, err := freeSeat(tx, in.Business, in.Customer, in.Resource, Session{Interval: in.Interval, Capacity: in.Capacity}); err != nil {
+			return "", err
+		}
+	} else {
+		taken, err := isTaken(tx, in.Business, in.Resource, in.Interval, now)
+		if err != nil {
+			return "", dbase.DbError(err)
+		}
+		if taken {
+			return "", ErrSlotTaken
+		}
+	}
+
+	newID := uuid.New().String()
+	_, err = tx.Exec(`INSERT INTO booking_requests (`+bookingRequestColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		newID, string(in.Business), string(in.Customer), string(in.Resource), in.Interval.Start.Unix(), in.Interval.End.Unix(),
+		encodeAnswers(in.Answers), in.BlocksSlot, in.Capacity, now, in.ExpiresAt.Unix())
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	return newID, dbase.DbError(tx.Commit())
+}
+
+// GetBookingRequests returns not expired requests of the business ordered by start.
+// No errors if no requests found
+func (db *TimeSlotsStorage) GetBookingRequests(businessID common.ID) ([]BookingRequest, error) {
+	var rows []dbBookingRequest
+	err := db.Select(&rows, `SELECT `+bookingRequestColumns+` FROM booking_requests
+		WHERE business_id = $1 AND expires_at > $2 ORDER BY date_start, created_at`,
+		string(businessID), time.Now().Unix())
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]BookingRequest, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.toRequest())
+	}
+	return out, nil
+}
+
+// takeBookingRequest removes the request of the business from the table and returns it
+func takeBookingRequest(tx *sqlx.Tx, businessID common.ID, requestID common.ID) (dbBookingRequest, error) {
+	var row dbBookingRequest
+	err := tx.Get(&row, `SELECT `+bookingRequestColumns+` FROM booking_requests WHERE id = $1 AND business_id = $2`,
+		string(requestID), string(businessID))
+	if err != nil {
+		return row, fmt.Errorf("booking request %s: %w", requestID, dbase.DbError(err))
+	}
+	_, err = tx.Exec(`DELETE FROM booking_requests WHERE id = $1`, row.Id)
+	return row, dbase.DbError(err)
+}
+
+func addDecisionNotification(tx *sqlx.Tx, row dbBookingRequest, kind NotificationKind, decision bookingDecision) error {
+	decision.DateStart = time.Unix(row.DateStart, 0).UTC()
+	decision.DateEnd = time.Unix(row.DateEnd, 0).UTC()
+	payload, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+	return addNotification(tx, Notification{
+		Business: row.Business,
+		Customer: row.Customer,
+		Kind:     kind,
+		Payload:  payload,
+	})
+}
+
+// ApproveBookingRequest turns the request into an appointment and notifies the customer.
+// ErrRequestExpired is returned for expired requests, ErrSlotTaken if the slot is booked meanwhile,
+// ErrCustomerBlocked if the customer was blocked after the request, ErrOutsideWorkingTime
+// if the time is not working time of the resource anymore.
+// A seat request takes a seat of the session, errors are returned as by BookSeat.
+func (db *TimeSlotsStorage) ApproveBookingRequest(businessID common.ID, requestID common.ID) (BookingRequest, error) {
+	working, err := db.getWorkingTime(businessID)
+	if err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	row, err := takeBookingRequest(tx, businessID, requestID)
+	if err != nil {
+		return BookingRequest{}, err
+	}
+	now := time.Now().Unix()
+	if row.ExpiresAt <= now {
+		return BookingRequest{}, ErrRequestExpired
+	}
+
+	var blocked int
+	err = tx.Get(&blocked, `SELECT COUNT(*) FROM customer_blocklist WHERE business_id = $1 AND customer_id = $2`,
+		row.Business, row.Customer)
+	if err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+	if blocked != 0 {
+		return BookingRequest{}, ErrCustomerBlocked
+	}
+
+	request := row.toRequest()
+	if !working[request.Resource].IsFit(request.Interval) {
+		return BookingRequest{}, ErrOutsideWorkingTime
+	}
+	if request.Capacity > 0 {
+		_, err = bookSeat(tx, BookSeatData{
+			Business: request.Business,
+			Customer: request.Customer,
+			Resource: request.Resource,
+			Session:  Session{Interval: request.Interval, Capacity: request.Capacity},
+			Answers:  request.Answers,
+		})
+		if err != nil {
+			return BookingRequest{}, err
+		}
+	} else {
+		taken, err := isTaken(tx, businessID, request.Resource, request.Interval, now)
+		if err != nil {
+			return BookingRequest{}, dbase.DbError(err)
+		}
+		if taken {
+			return BookingRequest{}, ErrSlotTaken
+		}
+
+		_, err = tx.Exec(`INSERT INTO appointments (business_id, resource_id, date_start, customer_id, date_end, answers) VALUES ($1, $2, $3, $4, $5, $6)`,
+			row.Business, row.Resource, row.DateStart, row.Customer, row.DateEnd, row.Answers)
+		if err != nil {
+			return BookingRequest{}, dbase.DbError(err)
+		}
+	}
+
+	if err := addDecisionNotification(tx, row, NotificationBookingApproved, bookingDecision{}); err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+	return request, dbase.DbError(tx.Commit())
+}
+
+// RejectBookingRequest removes the request and notifies the customer.
+// comment is optional and passed to the customer.
+func (db *TimeSlotsStorage) RejectBookingRequest(businessID common.ID, requestID common.ID, comment string) (BookingRequest, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	row, err := takeBookingRequest(tx, businessID, requestID)
+	if err != nil {
+		return BookingRequest{}, err
+	}
+	if row.ExpiresAt <= time.Now().Unix() {
+		return BookingRequest{}, ErrRequestExpired
+	}
+
+	if err := addDecisionNotification(tx, row, NotificationBookingRejected, bookingDecision{Comment: comment}); err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+	return row.toRequest(), dbase.DbError(tx.Commit())
+}
+
+// ExpireBookingRequests removes requests expired before now and notifies customers.
+// Returns removed requests.
+func (db *TimeSlotsStorage) ExpireBookingRequests(now time.Time) ([]BookingRequest, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	var rows []dbBookingRequest
+	err = tx.Select(&rows, `SELECT `+bookingRequestColumns+` FROM booking_requests WHERE expires_at <= $1`, now.Unix())
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]BookingRequest, 0, len(rows))
+	for _, row := range rows {
+		if err := addDecisionNotification(tx, row, NotificationBookingRejected, bookingDecision{Expired: true}); err != nil {
+			return nil, dbase.DbError(err)
+		}
+		_, err = tx.Exec(`DELETE FROM booking_requests WHERE id = $1`, row.Id)
+		if err != nil {
+			return nil, dbase.DbError(err)
+		}
+		out = append(out, row.toRequest())
+	}
+	return out, dbase.DbError(tx.Commit())
+}
+
+// RequestHold turns the hold of the customer into a booking request.
+// Holds of several slots are not supported, common.ErrInvalidArgument is returned for them.
+func (db *TimeSlotsStorage) RequestHold(businessID common.ID, customerID common.ID, token string, blocksSlot bool, expiresAt time.Time) (BookingRequest, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	holds, err := takeHold(tx, businessID, customerID, token)
+	if err != nil {
+		return BookingRequest{}, err
+	}
+	if len(holds) != 1 {
+		return BookingRequest{}, fmt.Errorf("%w: hold of %d slots can not be requested", common.ErrInvalidArgument, len(holds))
+	}
+
+	h := holds[0]
+	now := time.Now().Unix()
+	row := dbBookingRequest{
+		Id:         uuid.New().String(),
+		Business:   h.Business,
+		Customer:   h.Customer,
+		Resource:   h.Resource,
+		DateStart:  h.DateStart,
+		DateEnd:    h.DateEnd,
+		Answers:    h.Answers,
+		BlocksSlot: blocksSlot,
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt.Unix(),
+	}
+	_, err = tx.NamedExec(`INSERT INTO booking_requests (`+bookingRequestColumns+`)
+		VALUES (:id, :business_id, :customer_id, :resource_id, :date_start, :date_end, :answers, :blocks_slot, :capacity, :created_at, :expires_at)`, row)
+	if err != nil {
+		return BookingRequest{}, dbase.DbError(err)
+	}
+	return row.toRequest(), dbase.DbError(tx.Commit())
+}
@@ -0,0 +1,192 @@
+package slots
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestBookingRequests(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	workStart := day.Add(9 * time.Hour)
+	if _, err := storage.AddBusinessRule("b1", dailyRule(t, workStart, 1, 4*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+
+	between := common.Interval{Start: day, End: day.Add(24 * time.Hour)}
+	slot := common.Interval{Start: workStart, End: workStart.Add(time.Hour)}
+	request := BookingRequest{
+		Business:   "b1",
+		Customer:   "c1",
+		Resource:   DefaultResource,
+		Interval:   slot,
+		BlocksSlot: true,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+
+	id, err := storage.AddBookingRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	available, err := storage.GetAvailableSlotsInRange("b1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available.IsOverlap(slot) {
+		t.Fatalf("slot of blocking request must be busy: %v", available)
+	}
+	other := request
+	other.Customer = "c2"
+	if _, err := storage.AddBookingRequest(other); !errors.Is(err, ErrSlotTaken) {
+		t.Fatalf("expected slot taken, got %v", err)
+	}
+
+	// Not blocking request leaves the slot available
+	second := request
+	second.Interval = common.Interval{Start: slot.End, End: slot.End.Add(time.Hour)}
+	second.BlocksSlot = false
+	secondID, err := storage.AddBookingRequest(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	available, err = storage.GetAvailableSlotsInRange("b1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !available.IsFit(second.Interval) {
+		t.Fatalf("slot of not blocking request must be free: %v", available)
+	}
+
+	requests, err := storage.GetBookingRequests("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[0].Id != id || requests[1].Id != secondID || !sameInterval(requests[0].Interval, slot) {
+		t.Fatalf("unexpected requests: %+v", requests)
+	}
+
+	if _, err := storage.ApproveBookingRequest("b2", id); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found for foreign business, got %v", err)
+	}
+	if _, err := storage.ApproveBookingRequest("b1", id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.ApproveBookingRequest("b1", id); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("request must be removed after approval, got %v", err)
+	}
+	busy, err := storage.GetBusySlotsInRange("b1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 1 || busy[0].Customer != "c1" || !sameInterval(busy[0].Interval, slot) {
+		t.Fatalf("unexpected appointments: %v", busy)
+	}
+
+	// Time off after the request leaves the time outside working time
+	late := second
+	late.Interval = common.Interval{Start: slot.End.Add(time.Hour), End: slot.End.Add(2 * time.Hour)}
+	lateID, err := storage.AddBookingRequest(late)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := storage.ApplyTimeOff(TimeOff{Business: "b1", Resource: DefaultResource, Interval: late.Interval}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.ApproveBookingRequest("b1", lateID); !errors.Is(err, ErrOutsideWorkingTime) {
+		t.Fatalf("expected outside working time, got %v", err)
+	}
+
+	if _, err := storage.RejectBookingRequest("b1", secondID, "busy day"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expired request can not be approved and is removed by the sweep
+	expired := second
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	expiredID, err := storage.AddBookingRequest(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.ApproveBookingRequest("b1", expiredID); !errors.Is(err, ErrRequestExpired) {
+		t.Fatalf("expected request expired, got %v", err)
+	}
+	removed, err := storage.ExpireBookingRequests(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Id != expiredID {
+		t.Fatalf("unexpected expired requests: %+v", removed)
+	}
+
+	notifications, err := storage.GetPendingNotifications("b1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 3 {
+		t.Fatalf("unexpected notifications: %+v", notifications)
+	}
+	kinds := []NotificationKind{NotificationBookingApproved, NotificationBookingRejected, NotificationBookingRejected}
+	for i, n := range notifications {
+		if n.Kind != kinds[i] || n.Customer != "c1" {
+			t.Fatalf("unexpected notification %d: %+v", i, n)
+		}
+	}
+	var decision bookingDecision
+	if err := json.Unmarshal(notifications[1].Payload, &decision); err != nil || decision.Comment != "busy day" || decision.Expired {
+		t.Fatalf("unexpected rejection payload: %s %v", notifications[1].Payload, err)
+	}
+	if err := json.Unmarshal(notifications[2].Payload, &decision); err != nil || !decision.Expired {
+		t.Fatalf("unexpected expiration payload: %s %v", notifications[2].Payload, err)
+	}
+}
+
+func TestRequestHold(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	start := time.Now().UTC().Truncate(time.Hour).Add(24 * time.Hour)
+	slot := common.Interval{Start: start, End: start.Add(time.Hour)}
+	token, err := storage.AddHold(HoldData{
+		Business:  "b1",
+		Customer:  "c1",
+		Resource:  DefaultResource,
+		Slots:     common.Intervals{slot},
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := storage.RequestHold("b1", "c1", token, true, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Customer != "c1" || !sameInterval(request.Interval, slot) || !request.BlocksSlot {
+		t.Fatalf("unexpected request: %+v", request)
+	}
+	if _, err := storage.ConfirmHold("b1", "c1", token); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("hold must be removed after request, got %v", err)
+	}
+
+	token, err = storage.AddHold(HoldData{
+		Business:  "b1",
+		Customer:  "c1",
+		Resource:  DefaultResource,
+		Slots:     common.Intervals{{Start: slot.End, End: slot.End.Add(time.Hour)}, {Start: slot.End.Add(2 * time.Hour), End: slot.End.Add(3 * time.Hour)}},
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.RequestHold("b1", "c1", token, true, time.Now().Add(time.Hour)); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument for several slots, got %v", err)
+	}
+}
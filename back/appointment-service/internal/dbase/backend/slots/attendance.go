@@ -0,0 +1,168 @@
+package slots
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+)
+
+var ErrCustomerBlocked = errors.New("customer is blocked by the business")
+
+// CheckIn marks attendance of the appointment found by BookingCode
+// or, if the code is empty, by Customer and Start
+type CheckIn struct {
+	Business    common.ID
+	BookingCode string
+	Customer    common.ID
+	Start       time.Time
+	Attendance  common.Attendance
+}
+
+var attendanceCounters = map[common.Attendance]string{
+	common.Attended: "attended_count",
+	common.NoShow:   "no_show_count",
+}
+
+func normalizeBookingCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// MarkAttendance sets attendance of the appointment and updates counters of the customer.
+// Changing the mark moves the appointment from one counter to another. No-show can not
+// be marked before the appointment start, common.ErrInvalidArgument is returned then.
+func (db *TimeSlotsStorage) MarkAttendance(in CheckIn, now time.Time) (common.BusySlot, error) {
+	if err := in.Attendance.Validate(); err != nil {
+		return common.BusySlot{}, err
+	}
+	code := normalizeBookingCode(in.BookingCode)
+	if code == "" && (in.Customer == "" || in.Start.IsZero()) {
+		return common.BusySlot{}, fmt.Errorf("%w: booking code or customer with start required", common.ErrInvalidArgument)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	var row struct {
+		RowId int64 `db:"rowid"`
+		dbBusySlot
+	}
+	if code != "" {
+		err = tx.Get(&row, `SELECT rowid, `+appointmentColumns+` FROM appointments
+			WHERE business_id = $1 AND booking_code = $2`, string(in.Business), code)
+	} else {
+		err = tx.Get(&row, `SELECT rowid, `+appointmentColumns+` FROM appointments
+			WHERE business_id = $1 AND customer_id = $2 AND date_start = $3`,
+			string(in.Business), string(in.Customer), in.Start.Unix())
+	}
+	if err != nil {
+		return common.BusySlot{}, fmt.Errorf("appointment: %w", dbase.DbError(err))
+	}
+
+	slot := row.ToSlot()
+	if in.Attendance == common.NoShow && slot.Start.After(now) {
+		return common.BusySlot{}, fmt.Errorf("%w: appointment has not started", common.ErrInvalidArgument)
+	}
+	if slot.Attendance == in.Attendance {
+		return slot, nil
+	}
+
+	_, err = tx.Exec(`INSERT OR IGNORE INTO customers (business_id, id, created_at) VALUES ($1, $2, $3)`,
+		row.Business, row.Customer, now.Unix())
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+	if prev, ok := attendanceCounters[slot.Attendance]; ok {
+		_, err = tx.Exec(`UPDATE customers SET `+prev+` = `+prev+` - 1 WHERE business_id = $1 AND id = $2`,
+			row.Business, row.Customer)
+		if err != nil {
+			return common.BusySlot{}, dbase.DbError(err)
+		}
+	}
+	counter := attendanceCounters[in.Attendance]
+	_, err = tx.Exec(`UPDATE customers SET `+counter+` = `+counter+` + 1 WHERE business_id = $1 AND id = $2`,
+		row.Business, row.Customer)
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+
+	_, err = tx.Exec(`UPDATE appointments SET attendance = $1 WHERE rowid = $2`, string(in.Attendance), row.RowId)
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+
+	slot.Attendance = in.Attendance
+	return slot, dbase.DbError(tx.Commit())
+}
+
+type BlockedCustomer struct {
+	Customer  common.ID
+	Reason    string
+	CreatedAt time.Time
+}
+
+// BlockCustomer refuses further bookings of the customer. Reason of the blocked customer is updated.
+func (db *TimeSlotsStorage) BlockCustomer(businessID common.ID, customerID common.ID, reason string) error {
+	if customerID == "" {
+		return fmt.Errorf("customer id: %w", common.ErrInvalidArgument)
+	}
+	_, err := db.Exec(`INSERT INTO customer_blocklist (business_id, customer_id, reason, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (business_id, customer_id) DO UPDATE SET reason = excluded.reason`,
+		string(businessID), string(customerID), reason, time.Now().Unix())
+	return dbase.DbError(err)
+}
+
+// UnblockCustomer removes the customer from the blocklist.
+// common.ErrNotFound is returned if the customer is not blocked.
+func (db *TimeSlotsStorage) UnblockCustomer(businessID common.ID, customerID common.ID) error {
+	res, err := db.Exec(`DELETE FROM customer_blocklist WHERE business_id = $1 AND customer_id = $2`,
+		string(businessID), string(customerID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("blocked customer %s: %w", customerID, common.ErrNotFound)
+	}
+	return nil
+}
+
+// GetBlockedCustomers returns the blocklist of the business ordered by customer ID
+func (db *TimeSlotsStorage) GetBlockedCustomers(businessID common.ID) ([]BlockedCustomer, error) {
+	var rows []struct {
+		Customer  string `db:"customer_id"`
+		Reason    string `db:"reason"`
+		CreatedAt int64  `db:"created_at"`
+	}
+	err := db.Select(&rows, `SELECT customer_id, reason, created_at FROM customer_blocklist
+		WHERE business_id = $1 ORDER BY customer_id`, string(businessID))
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]BlockedCustomer, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, BlockedCustomer{Customer: row.Customer, Reason: row.Reason, CreatedAt: time.Unix(row.CreatedAt, 0)})
+	}
+	return out, nil
+}
+
+// CheckCustomerAllowed returns ErrCustomerBlocked if the customer is in the blocklist of the business
+func (db *TimeSlotsStorage) CheckCustomerAllowed(businessID common.ID, customerID common.ID) error {
+	var one int
+	err := db.Get(&one, `SELECT 1 FROM customer_blocklist WHERE business_id = $1 AND customer_id = $2`,
+		string(businessID), string(customerID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	return fmt.Errorf("customer %s: %w", customerID, ErrCustomerBlocked)
+}
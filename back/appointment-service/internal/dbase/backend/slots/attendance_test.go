@@ -0,0 +1,172 @@
+package slots
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestMarkAttendance(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	now := time.Now().Truncate(time.Minute)
+	past := now.Add(-2 * time.Hour)
+	future := now.Add(24 * time.Hour)
+	err := storage.AddSlots(AddSlotsData{
+		Business: "b1",
+		Customer: "c1",
+		Slots: common.Intervals{
+			{Start: past, End: past.Add(time.Hour)},
+			{Start: future, End: future.Add(time.Hour)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appointments, err := storage.GetCustomerAppointmentsInRange("b1", "c1", common.Interval{Start: past})
+	if err != nil || len(appointments) != 2 {
+		t.Fatalf("unexpected appointments: %v %v", appointments, err)
+	}
+	codes := make(map[string]bool)
+	for _, appt := range appointments {
+		if len(appt.BookingCode) != 16 || appt.Attendance != "" {
+			t.Fatalf("new appointment must have a code of 8 bytes and no attendance: %+v", appt)
+		}
+		codes[appt.BookingCode] = true
+	}
+	if len(codes) != 2 {
+		t.Fatalf("booking codes must be unique: %v", codes)
+	}
+	pastCode, futureCode := appointments[0].BookingCode, appointments[1].BookingCode
+
+	// Code is matched regardless of case and surrounding spaces
+	slot, err := storage.MarkAttendance(CheckIn{Business: "b1", BookingCode: " " + strings.ToLower(pastCode), Attendance: common.Attended}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot.Customer != "c1" || !slot.Start.Equal(past) || slot.Attendance != common.Attended {
+		t.Fatalf("unexpected checked in appointment: %+v", slot)
+	}
+
+	checkCounters := func(attended, noShows int) {
+		t.Helper()
+		customer, err := storage.GetCustomer("b1", "c1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if customer.Attended != attended || customer.NoShows != noShows {
+			t.Fatalf("expected %d attended and %d no-shows, got %+v", attended, noShows, customer)
+		}
+	}
+	checkCounters(1, 0)
+
+	// Repeated mark does not change counters
+	if _, err := storage.MarkAttendance(CheckIn{Business: "b1", BookingCode: pastCode, Attendance: common.Attended}, now); err != nil {
+		t.Fatal(err)
+	}
+	checkCounters(1, 0)
+
+	// Changed mark moves the appointment to another counter
+	if _, err := storage.MarkAttendance(CheckIn{Business: "b1", Customer: "c1", Start: past, Attendance: common.NoShow}, now); err != nil {
+		t.Fatal(err)
+	}
+	checkCounters(0, 1)
+
+	_, err = storage.MarkAttendance(CheckIn{Business: "b1", BookingCode: futureCode, Attendance: common.NoShow}, now)
+	if !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("no-show before start must be rejected: %v", err)
+	}
+	if _, err := storage.MarkAttendance(CheckIn{Business: "b1", BookingCode: futureCode, Attendance: "late"}, now); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("unknown attendance must be rejected: %v", err)
+	}
+	if _, err := storage.MarkAttendance(CheckIn{Business: "b2", BookingCode: futureCode, Attendance: common.Attended}, now); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("code of another business must not be found: %v", err)
+	}
+	if _, err := storage.MarkAttendance(CheckIn{Business: "b1", Attendance: common.Attended}, now); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("appointment must be identified: %v", err)
+	}
+}
+
+func TestCustomerBlocklist(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	if err := storage.CheckCustomerAllowed("b1", "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.BlockCustomer("b1", "c1", "no-shows"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.BlockCustomer("b1", "c1", "repeated no-shows"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.CheckCustomerAllowed("b1", "c1"); !errors.Is(err, ErrCustomerBlocked) {
+		t.Fatalf("blocked customer must be refused: %v", err)
+	}
+	if err := storage.CheckCustomerAllowed("b2", "c1"); err != nil {
+		t.Fatalf("blocklist is separated by business: %v", err)
+	}
+
+	blocked, err := storage.GetBlockedCustomers("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocked) != 1 || blocked[0].Customer != "c1" || blocked[0].Reason != "repeated no-shows" {
+		t.Fatalf("unexpected blocklist: %+v", blocked)
+	}
+
+	if err := storage.UnblockCustomer("b1", "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UnblockCustomer("b1", "c1"); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("customer is not blocked: %v", err)
+	}
+	if err := storage.CheckCustomerAllowed("b1", "c1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeCustomersAttendance(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	now := time.Now().Truncate(time.Minute)
+	for i, customer := range []common.ID{"c1", "c2"} {
+		start := now.Add(-time.Duration(i+2) * time.Hour)
+		err := storage.AddSlots(AddSlotsData{
+			Business: "b1",
+			Customer: customer,
+			Slots:    common.Intervals{{Start: start, End: start.Add(time.Hour)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := storage.MarkAttendance(CheckIn{Business: "b1", Customer: customer, Start: start, Attendance: common.NoShow}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.BlockCustomer("b1", "c2", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.MergeCustomers("b1", "c1", "c2"); err != nil {
+		t.Fatal(err)
+	}
+
+	customer, err := storage.GetCustomer("b1", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customer.NoShows != 2 {
+		t.Fatalf("no-shows must be summed: %+v", customer)
+	}
+	if err := storage.CheckCustomerAllowed("b1", "c1"); !errors.Is(err, ErrCustomerBlocked) {
+		t.Fatalf("merged customer must stay blocked: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package slots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const NotificationAppointmentRescheduled NotificationKind = "appointment_rescheduled"
+
+type AttentionStatus string
+
+const (
+	// AttentionOpen items wait for the owner decision
+	AttentionOpen AttentionStatus = "open"
+	// AttentionKept items are accepted by the owner and not reported again
+	AttentionKept AttentionStatus = "kept"
+)
+
+// AttentionItem is a future appointment outside working time of its resource,
+// e.g. after the covering rule was deleted
+type AttentionItem struct {
+	Business    common.ID
+	BookingCode string
+	Customer    common.ID
+	Resource    common.ID
+	Interval    common.Interval
+	Status      AttentionStatus
+	DetectedAt  time.Time
+}
+
+type dbAttentionItem struct {
+	Business    string `db:"business_id"`
+	BookingCode string `db:"booking_code"`
+	Customer    string `db:"customer_id"`
+	Resource    string `db:"resource_id"`
+	DateStart   int64  `db:"date_start"`
+	DateEnd     int64  `db:"date_end"`
+	Status      string `db:"status"`
+	DetectedAt  int64  `db:"detected_at"`
+}
+
+func (row dbAttentionItem) toItem() AttentionItem {
+	return AttentionItem{
+		Business:    row.Business,
+		BookingCode: row.BookingCode,
+		Customer:    row.Customer,
+		Resource:    row.Resource,
+		Interval:    common.Interval{Start: time.Unix(row.DateStart, 0), End: time.Unix(row.DateEnd, 0)},
+		Status:      AttentionStatus(row.Status),
+		DetectedAt:  time.Unix(row.DetectedAt, 0),
+	}
+}
+
+// appointmentRescheduled is a payload of notifications about appointments moved by the business
+type appointmentRescheduled struct {
+	DateStart     time.Time `json:"date_start"`
+	DateEnd       time.Time `json:"date_end"`
+	PreviousStart time.Time `json:"previous_start"`
+}
+
+// getWorkingTime returns working time of every resource with rules: inclusions and group
+// sessions without exclusions. Exclusions of the DefaultResource are applied to all resources.
+// Adjacent intervals are joined so an appointment spanning two rules is covered.
+func (db *TimeSlotsStorage) getWorkingTime(businessID common.ID) (map[common.ID]common.Intervals, error) {
+	rules, err := db.GetBusinessRules(businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	var businessExclusions []common.IntervalRRuleWithType
+	rulesByResource := make(map[common.ID][]common.IntervalRRuleWithType)
+	for _, r := range rules {
+		rulesByResource[r.ResourceId] = append(rulesByResource[r.ResourceId], r.Rule)
+		if r.ResourceId == DefaultResource && r.Rule.Type == common.Exclusion {
+			businessExclusions = append(businessExclusions, r.Rule)
+		}
+	}
+
+	out := make(map[common.ID]common.Intervals, len(rulesByResource))
+	for resource, resourceRules := range rulesByResource {
+		if resource != DefaultResource {
+			resourceRules = append(resourceRules, businessExclusions...)
+		}
+
+		var inclusion, exclusion common.Intervals
+		for _, r := range resourceRules {
+			if r.Type == common.Exclusion {
+				exclusion = append(exclusion, r.Rule.GetIntervals()...)
+			} else {
+				inclusion = append(inclusion, r.Rule.GetIntervals()...)
+			}
+		}
+		inclusion = common.PrepareUnited(inclusion)
+		exclusion = common.PrepareUnited(exclusion)
+		out[resource] = inclusion.PassedIntervals(exclusion).JoinAdjacent()
+	}
+	return out, nil
+}
+
+// GetWorkingTimeInRange returns united working time of resources within the range,
+// regardless of appointments
+func (db *TimeSlotsStorage) GetWorkingTimeInRange(businessID common.ID, resources []common.ID, between common.Interval) (common.Intervals, error) {
+	working, err := db.getWorkingTime(businessID)
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	var out common.Intervals
+	for _, resource := range resources {
+		out = append(out, working[resource].UnitedBetween(between)...)
+	}
+	return common.PrepareUnited(out), nil
+}
+
+// CheckRuleCoverage finds future appointments outside working time and stores them as attention items.
+// Items of appointments covered again, cancelled or passed are removed, kept items stay kept.
+// Returns open items ordered by start.
+func (db *TimeSlotsStorage) CheckRuleCoverage(businessID common.ID, now time.Time) ([]AttentionItem, error) {
+	working, err := db.getWorkingTime(businessID)
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	var appointments []dbBusySlot
+	err = tx.Select(&appointments, `SELECT `+appointmentColumns+` FROM appointments
+		WHERE business_id = $1 AND date_end > $2 AND booking_code IS NOT NULL`, string(businessID), now.Unix())
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	orphaned := make([]string, 0)
+	for _, appointment := range appointments {
+		slot := appointment.ToSlot()
+		if working[slot.Resource].IsFit(slot.Interval) {
+			continue
+		}
+		orphaned = append(orphaned, slot.BookingCode)
+		_, err = tx.Exec(`INSERT INTO appointment_attention
+			(business_id, booking_code, customer_id, resource_id, date_start, date_end, status, detected_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (business_id, booking_code) DO UPDATE SET
+				customer_id = excluded.customer_id, resource_id = excluded.resource_id,
+				date_start = excluded.date_start, date_end = excluded.date_end`,
+			appointment.Business, slot.BookingCode, appointment.Customer, appointment.Resource,
+			appointment.DateStart, appointment.DateEnd, string(AttentionOpen), now.Unix())
+		if err != nil {
+			return nil, dbase.DbError(err)
+		}
+	}
+
+	if len(orphaned) == 0 {
+		_, err = tx.Exec(`DELETE FROM appointment_attention WHERE business_id = ?`, string(businessID))
+	} else {
+		var query string
+		var args []any
+		query, args, err = sqlx.In(`DELETE FROM appointment_attention WHERE business_id = ? AND booking_code NOT IN (?)`,
+			string(businessID), orphaned)
+		if err == nil {
+			_, err = tx.Exec(query, args...)
+		}
+	}
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, dbase.DbError(err)
+	}
+	return db.GetAttentionItems(businessID)
+}
+
+// CheckAllRuleCoverage runs CheckRuleCoverage for every business with future appointments or open items.
+// A failed business does not stop checks of others. Returns the number of open items and errors of all
+// failed businesses.
+func (db *TimeSlotsStorage) CheckAllRuleCoverage(now time.Time) (int, error) {
+	var businesses []string
+	err := db.Select(&businesses, `SELECT business_id FROM appointments WHERE date_end > $1
+		UNION SELECT business_id FROM appointment_attention`, now.Unix())
+	if err != nil {
+		return 0, dbase.DbError(err)
+	}
+
+	open := 0
+	var errs []error
+	for _, business := range businesses {
+		items, err := db.CheckRuleCoverage(business, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("business %s: %w", business, err))
+			continue
+		}
+		open += len(items)
+	}
+	return open, errors.Join(errs...)
+}
+
+// GetAttentionItems returns open items of the business ordered by start
+func (db *TimeSlotsStorage) GetAttentionItems(businessID common.ID) ([]AttentionItem, error) {
+	var rows []dbAttentionItem
+	err := db.Select(&rows, `SELECT business_id, booking_code, customer_id, resource_id, date_start, date_end, status, detected_at
+		FROM appointment_attention WHERE business_id = $1 AND status = $2 ORDER BY date_start, booking_code`,
+		string(businessID), string(AttentionOpen))
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]AttentionItem, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.toItem())
+	}
+	return out, nil
+}
+
+// KeepAttentionItem accepts the appointment outside working time.
+// common.ErrNotFound is returned if there is no open item with the booking code.
+func (db *TimeSlotsStorage) KeepAttentionItem(businessID common.ID, bookingCode string) error {
+	res, err := db.Exec(`UPDATE appointment_attention SET status = $1 WHERE business_id = $2 AND booking_code = $3 AND status = $4`,
+		string(AttentionKept), string(businessID), normalizeBookingCode(bookingCode), string(AttentionOpen))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("attention item %s: %w", bookingCode, common.ErrNotFound)
+	}
+	return nil
+}
+
+// RescheduleAppointment moves the appointment to start keeping its length and resource,
+// removes its attention item and notifies the customer.
+// common.ErrInvalidArgument is returned if the new time is in the past or outside working time,
+// ErrSlotTaken if it overlaps another appointment, an active hold or a blocking request.
+func (db *TimeSlotsStorage) RescheduleAppointment(businessID common.ID, bookingCode string, start time.Time, now time.Time) (common.BusySlot, error) {
+	if !start.After(now) {
+		return common.BusySlot{}, fmt.Errorf("%w: new start is in the past", common.ErrInvalidArgument)
+	}
+	working, err := db.getWorkingTime(businessID)
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	var row struct {
+		RowId int64 `db:"rowid"`
+		dbBusySlot
+	}
+	err = tx.Get(&row, `SELECT rowid, `+appointmentColumns+` FROM appointments WHERE business_id = $1 AND booking_code = $2`,
+		string(businessID), normalizeBookingCode(bookingCode))
+	if err != nil {
+		return common.BusySlot{}, fmt.Errorf("appointment: %w", dbase.DbError(err))
+	}
+
+	slot := row.ToSlot()
+	previous := slot.Interval
+	slot.Interval = common.Interval{Start: start, End: start.Add(previous.Duration())}
+	if !working[slot.Resource].IsFit(slot.Interval) {
+		return common.BusySlot{}, fmt.Errorf("%w: new time is outside working time", common.ErrInvalidArgument)
+	}
+	taken, err := isTakenExcept(tx, businessID, slot.Resource, slot.Interval, now.Unix(), row.RowId)
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+	if taken {
+		return common.BusySlot{}, ErrSlotTaken
+	}
+
+	_, err = tx.Exec(`UPDATE appointments SET date_start = $1, date_end = $2 WHERE rowid = $3`,
+		slot.Start.Unix(), slot.End.Unix(), row.RowId)
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+	_, err = tx.Exec(`DELETE FROM appointment_attention WHERE business_id = $1 AND booking_code = $2`,
+		string(businessID), slot.BookingCode)
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+
+	payload, err := json.Marshal(appointmentRescheduled{
+		DateStart:     slot.Start.UTC(),
+		DateEnd:       slot.End.UTC(),
+		PreviousStart: previous.Start.UTC(),
+	})
+	if err != nil {
+		return common.BusySlot{}, err
+	}
+	err = addNotification(tx, Notification{
+		Business: businessID,
+		Customer: slot.Customer,
+		Kind:     NotificationAppointmentRescheduled,
+		Payload:  payload,
+	})
+	if err != nil {
+		return common.BusySlot{}, dbase.DbError(err)
+	}
+	return slot, dbase.DbError(tx.Commit())
+}
@@ -0,0 +1,121 @@
+package slots
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestRuleCoverage(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	now := time.Now()
+	day := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	morning := day.Add(9 * time.Hour)
+	evening := day.Add(17 * time.Hour)
+	if _, err := storage.AddBusinessRule("b1", dailyRule(t, morning, 2, 4*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+	eveningRule, err := storage.AddBusinessRule("b1", dailyRule(t, evening, 1, 2*time.Hour, common.Inclusion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Adjacent rules cover an appointment spanning both
+	if _, err := storage.AddBusinessRule("b1", dailyRule(t, morning.Add(4*time.Hour), 1, time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+
+	book := func(customer common.ID, start time.Time) string {
+		t.Helper()
+		err := storage.AddSlots(AddSlotsData{
+			Business: "b1",
+			Customer: customer,
+			Slots:    common.Intervals{{Start: start, End: start.Add(time.Hour)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		appointments, err := storage.GetCustomerAppointmentsInRange("b1", customer, common.Interval{Start: start, End: start})
+		if err != nil || len(appointments) != 1 {
+			t.Fatalf("unexpected appointments: %v %v", appointments, err)
+		}
+		return appointments[0].BookingCode
+	}
+	book("c1", morning)
+	book("c2", morning.Add(3*time.Hour+30*time.Minute))
+	eveningCode := book("c3", evening)
+	nextDayCode := book("c4", evening.Add(24*time.Hour))
+
+	items, err := storage.CheckRuleCoverage("b1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].BookingCode != nextDayCode || items[0].Status != AttentionOpen {
+		t.Fatalf("only the appointment outside working time needs attention: %+v", items)
+	}
+
+	if err := storage.DeleteBusinessRule("b1", eveningRule); err != nil {
+		t.Fatal(err)
+	}
+	items, err = storage.CheckRuleCoverage("b1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].BookingCode != eveningCode || items[0].Customer != "c3" {
+		t.Fatalf("appointment of the deleted rule needs attention: %+v", items)
+	}
+
+	// Kept item is not reported again
+	if err := storage.KeepAttentionItem("b1", eveningCode); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.KeepAttentionItem("b1", eveningCode); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("item is already kept: %v", err)
+	}
+	if n, err := storage.CheckAllRuleCoverage(now); err != nil || n != 1 {
+		t.Fatalf("unexpected open items: %d %v", n, err)
+	}
+
+	// Reschedule into working time
+	if _, err := storage.RescheduleAppointment("b1", nextDayCode, evening, now); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("time outside working hours must be rejected: %v", err)
+	}
+	if _, err := storage.RescheduleAppointment("b1", nextDayCode, morning, now); !errors.Is(err, ErrSlotTaken) {
+		t.Fatalf("taken time must be rejected: %v", err)
+	}
+	newStart := morning.Add(24 * time.Hour)
+	slot, err := storage.RescheduleAppointment("b1", nextDayCode, newStart, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slot.Start.Equal(newStart) || slot.Duration() != time.Hour || slot.BookingCode != nextDayCode {
+		t.Fatalf("unexpected rescheduled appointment: %+v", slot)
+	}
+	// The appointment may be moved within its own time
+	if _, err := storage.RescheduleAppointment("b1", nextDayCode, newStart.Add(30*time.Minute), now); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err = storage.GetAttentionItems("b1")
+	if err != nil || len(items) != 0 {
+		t.Fatalf("rescheduled item must be removed: %+v %v", items, err)
+	}
+
+	notifications, err := storage.GetPendingNotifications("b1", 10)
+	if err != nil || len(notifications) != 2 {
+		t.Fatalf("customer must be notified: %v %v", notifications, err)
+	}
+	var payload appointmentRescheduled
+	if err := json.Unmarshal(notifications[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if notifications[0].Kind != NotificationAppointmentRescheduled || notifications[0].Customer != "c4" ||
+		!payload.PreviousStart.Equal(evening.Add(24*time.Hour)) || !payload.DateStart.Equal(newStart) {
+		t.Fatalf("unexpected notification: %+v %+v", notifications[0], payload)
+	}
+}
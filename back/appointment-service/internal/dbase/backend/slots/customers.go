@@ -0,0 +1,427 @@
+package slots
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CustomerChannel is a way the customer reaches the business
+type CustomerChannel string
+
+const (
+	// ChannelTelegram identifies Telegram user ID from the Mini App or the business bot
+	ChannelTelegram CustomerChannel = "telegram"
+	// ChannelToken identifies customer ID stored with a one-off token
+	ChannelToken CustomerChannel = "token"
+	ChannelEmail CustomerChannel = "email"
+)
+
+func (c CustomerChannel) Validate() error {
+	switch c {
+	case ChannelTelegram, ChannelToken, ChannelEmail:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown channel %q", common.ErrInvalidArgument, c)
+}
+
+var ErrIdentityTaken = errors.New("identity is linked to another customer")
+
+// ErrSharedBooking is returned when customers can not be merged because both hold
+// the same session, one of the bookings has to be cancelled first
+var ErrSharedBooking = errors.New("customers hold the same booking")
+
+type CustomerIdentity struct {
+	Channel    CustomerChannel `json:"channel"`
+	ExternalId string          `json:"external_id"`
+}
+
+// Customer is a profile of a person booking the business. Customer ID is the
+// value stored as customer_id with appointments, waitlist entries and notifications.
+type Customer struct {
+	Id          common.ID
+	Business    common.ID
+	DisplayName string
+	Phone       string
+	Email       string
+	Language    string
+	TimeZone    string
+	CreatedAt   time.Time
+	Identities  []CustomerIdentity
+	// Attended and NoShows count appointments marked at check-in
+	Attended int
+	NoShows  int
+}
+
+func (c Customer) Validate() error {
+	if c.TimeZone != "" {
+		if _, err := time.LoadLocation(c.TimeZone); err != nil {
+			return fmt.Errorf("%w: time zone: %s", common.ErrInvalidArgument, err.Error())
+		}
+	}
+	if c.Email != "" && !strings.Contains(c.Email, "@") {
+		return fmt.Errorf("%w: email", common.ErrInvalidArgument)
+	}
+	return nil
+}
+
+type dbCustomer struct {
+	Business    string `db:"business_id"`
+	Id          string `db:"id"`
+	DisplayName string `db:"display_name"`
+	Phone       string `db:"phone"`
+	Email       string `db:"email"`
+	Language    string `db:"language"`
+	TimeZone    string `db:"time_zone"`
+	CreatedAt   int64  `db:"created_at"`
+	Attended    int    `db:"attended_count"`
+	NoShows     int    `db:"no_show_count"`
+}
+
+const customerColumns = "business_id, id, display_name, phone, email, language, time_zone, created_at, attended_count, no_show_count"
+
+func (c dbCustomer) toCustomer() Customer {
+	return Customer{
+		Id:          c.Id,
+		Business:    c.Business,
+		DisplayName: c.DisplayName,
+		Phone:       c.Phone,
+		Email:       c.Email,
+		Language:    c.Language,
+		TimeZone:    c.TimeZone,
+		CreatedAt:   time.Unix(c.CreatedAt, 0),
+		Attended:    c.Attended,
+		NoShows:     c.NoShows,
+	}
+}
+
+func normalizeExternalId(channel CustomerChannel, externalId string) string {
+	if channel == ChannelEmail {
+		return strings.ToLower(strings.TrimSpace(externalId))
+	}
+	return externalId
+}
+
+// ResolveCustomer returns ID of the customer linked to the channel identity.
+// Unknown identity is linked to the customer with the same ID, the customer
+// is created if it does not exist. Merged customers are followed to the merge target.
+func (db *TimeSlotsStorage) ResolveCustomer(businessID common.ID, channel CustomerChannel, externalId string) (common.ID, error) {
+	if err := channel.Validate(); err != nil {
+		return "", err
+	}
+	externalId = normalizeExternalId(channel, externalId)
+	if externalId == "" {
+		return "", fmt.Errorf("external id: %w", common.ErrInvalidArgument)
+	}
+
+	var customerID string
+	err := db.Get(&customerID, `SELECT customer_id FROM customer_identities
+		WHERE business_id = $1 AND channel = $2 AND external_id = $3`,
+		string(businessID), string(channel), externalId)
+	if err == nil {
+		return customerID, nil
+	}
+	if err = dbase.DbError(err); !errors.Is(err, common.ErrNotFound) {
+		return "", err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	customerID, err = followMerged(tx, businessID, externalId)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`INSERT OR IGNORE INTO customers (business_id, id, created_at) VALUES ($1, $2, $3)`,
+		string(businessID), customerID, time.Now().Unix())
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+
+	// Concurrent request may link the identity first
+	_, err = tx.Exec(`INSERT OR IGNORE INTO customer_identities (business_id, channel, external_id, customer_id)
+		VALUES ($1, $2, $3, $4)`, string(businessID), string(channel), externalId, customerID)
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	err = tx.Get(&customerID, `SELECT customer_id FROM customer_identities
+		WHERE business_id = $1 AND channel = $2 AND external_id = $3`,
+		string(businessID), string(channel), externalId)
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+
+	return customerID, dbase.DbError(tx.Commit())
+}
+
+// followMerged returns ID of the customer which the customer was merged into or
+// the same ID if the customer was not merged or does not exist
+func followMerged(tx *sqlx.Tx, businessID common.ID, customerID common.ID) (common.ID, error) {
+	var mergedInto string
+	err := tx.Get(&mergedInto, `SELECT merged_into FROM customers WHERE business_id = $1 AND id = $2`,
+		string(businessID), string(customerID))
+	if err != nil {
+		if err = dbase.DbError(err); errors.Is(err, common.ErrNotFound) {
+			return customerID, nil
+		}
+		return "", err
+	}
+	if mergedInto == "" {
+		return customerID, nil
+	}
+	return mergedInto, nil
+}
+
+func getIdentities(q sqlx.Queryer, businessID common.ID, customerID common.ID) ([]CustomerIdentity, error) {
+	var rows []struct {
+		Channel    string `db:"channel"`
+		ExternalId string `db:"external_id"`
+	}
+	err := sqlx.Select(q, &rows, `SELECT channel, external_id FROM customer_identities
+		WHERE business_id = $1 AND customer_id = $2 ORDER BY channel, external_id`,
+		string(businessID), string(customerID))
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]CustomerIdentity, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, CustomerIdentity{Channel: CustomerChannel(row.Channel), ExternalId: row.ExternalId})
+	}
+	return out, nil
+}
+
+// GetCustomer returns the customer profile with linked identities.
+// common.ErrNotFound is returned for unknown and merged customers.
+func (db *TimeSlotsStorage) GetCustomer(businessID common.ID, customerID common.ID) (Customer, error) {
+	var row dbCustomer
+	err := db.Get(&row, `SELECT `+customerColumns+` FROM customers
+		WHERE business_id = $1 AND id = $2 AND merged_into = ''`, string(businessID), string(customerID))
+	if err != nil {
+		return Customer{}, dbase.DbError(err)
+	}
+
+	customer := row.toCustomer()
+	customer.Identities, err = getIdentities(db, businessID, customerID)
+	return customer, err
+}
+
+// CustomerFilter selects customers by any of set fields. Name matches a part of the display name.
+type CustomerFilter struct {
+	Name     string
+	Phone    string
+	Email    string
+	Identity *CustomerIdentity
+}
+
+// FindCustomers returns not merged customers matching the filter ordered by ID.
+// All customers of the business are returned for the empty filter.
+func (db *TimeSlotsStorage) FindCustomers(businessID common.ID, filter CustomerFilter) ([]Customer, error) {
+	args := []any{string(businessID)}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	where := `business_id = $1 AND merged_into = ''`
+	if filter.Name != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.Name)
+		where += ` AND display_name LIKE ` + arg("%"+escaped+"%") + ` ESCAPE '\'`
+	}
+	if filter.Phone != "" {
+		where += ` AND phone = ` + arg(filter.Phone)
+	}
+	if filter.Email != "" {
+		where += ` AND email = ` + arg(normalizeExternalId(ChannelEmail, filter.Email))
+	}
+	if filter.Identity != nil {
+		where += ` AND id IN (SELECT customer_id FROM customer_identities
+			WHERE business_id = $1 AND channel = ` + arg(string(filter.Identity.Channel)) +
+			` AND external_id = ` + arg(normalizeExternalId(filter.Identity.Channel, filter.Identity.ExternalId)) + `)`
+	}
+
+	var rows []dbCustomer
+	if err := db.Select(&rows, `SELECT `+customerColumns+` FROM customers WHERE `+where+` ORDER BY id`, args...); err != nil {
+		return nil, dbase.DbError(err)
+	}
+	if len(rows) == 0 {
+		return []Customer{}, nil
+	}
+
+	// Identities of all found customers are loaded at once
+	var identities []struct {
+		Customer   string `db:"customer_id"`
+		Channel    string `db:"channel"`
+		ExternalId string `db:"external_id"`
+	}
+	err := db.Select(&identities, `SELECT customer_id, channel, external_id FROM customer_identities
+		WHERE business_id = $1 AND customer_id IN (SELECT id FROM customers WHERE `+where+`)
+		ORDER BY channel, external_id`, args...)
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+	byCustomer := make(map[common.ID][]CustomerIdentity, len(rows))
+	for _, row := range identities {
+		id := common.ID(row.Customer)
+		byCustomer[id] = append(byCustomer[id], CustomerIdentity{Channel: CustomerChannel(row.Channel), ExternalId: row.ExternalId})
+	}
+
+	out := make([]Customer, 0, len(rows))
+	for _, row := range rows {
+		customer := row.toCustomer()
+		customer.Identities = byCustomer[customer.Id]
+		if customer.Identities == nil {
+			customer.Identities = []CustomerIdentity{}
+		}
+		out = append(out, customer)
+	}
+	return out, nil
+}
+
+// UpdateCustomer sets the customer profile. Email is linked as the customer identity,
+// ErrIdentityTaken is returned if it is linked to another customer.
+func (db *TimeSlotsStorage) UpdateCustomer(in Customer) error {
+	if err := in.Validate(); err != nil {
+		return err
+	}
+	email := normalizeExternalId(ChannelEmail, in.Email)
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE customers SET display_name = $1, phone = $2, email = $3, language = $4, time_zone = $5
+		WHERE business_id = $6 AND id = $7 AND merged_into = ''`,
+		in.DisplayName, in.Phone, email, in.Language, in.TimeZone, string(in.Business), string(in.Id))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("customer %s: %w", in.Id, common.ErrNotFound)
+	}
+
+	_, err = tx.Exec(`DELETE FROM customer_identities WHERE business_id = $1 AND customer_id = $2 AND channel = $3`,
+		string(in.Business), string(in.Id), string(ChannelEmail))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if email != "" {
+		_, err = tx.Exec(`INSERT INTO customer_identities (business_id, channel, external_id, customer_id)
+			VALUES ($1, $2, $3, $4)`, string(in.Business), string(ChannelEmail), email, string(in.Id))
+		if dbase.IsConstraintError(err) {
+			return fmt.Errorf("email %s: %w", email, ErrIdentityTaken)
+		}
+		if err != nil {
+			return dbase.DbError(err)
+		}
+	}
+
+	return dbase.DbError(tx.Commit())
+}
+
+// MergeCustomers moves identities, appointments, holds, waitlist entries and
+// notifications of the source customer to the target one. Empty profile fields
+// of the target are filled from the source, attendance counters are summed and
+// the target is blocked if the source was. The source ID keeps resolving to the target.
+// Customers holding seats of the same session are not merged, see ErrSharedBooking.
+func (db *TimeSlotsStorage) MergeCustomers(businessID common.ID, targetID common.ID, sourceID common.ID) error {
+	if targetID == sourceID {
+		return fmt.Errorf("%w: customer can not be merged into itself", common.ErrInvalidArgument)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	var rows []dbCustomer
+	err = tx.Select(&rows, `SELECT `+customerColumns+` FROM customers
+		WHERE business_id = $1 AND id IN ($2, $3) AND merged_into = ''`,
+		string(businessID), string(targetID), string(sourceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if len(rows) != 2 {
+		return fmt.Errorf("customers %s, %s: %w", targetID, sourceID, common.ErrNotFound)
+	}
+
+	target, source := rows[0], rows[1]
+	if target.Id != string(targetID) {
+		target, source = source, target
+	}
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&target.DisplayName, source.DisplayName)
+	fill(&target.Phone, source.Phone)
+	fill(&target.Email, source.Email)
+	fill(&target.Language, source.Language)
+	fill(&target.TimeZone, source.TimeZone)
+
+	_, err = tx.Exec(`UPDATE customers SET display_name = $1, phone = $2, email = $3, language = $4, time_zone = $5,
+		attended_count = $6, no_show_count = $7
+		WHERE business_id = $8 AND id = $9`,
+		target.DisplayName, target.Phone, target.Email, target.Language, target.TimeZone,
+		target.Attended+source.Attended, target.NoShows+source.NoShows,
+		string(businessID), string(targetID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+
+	// A customer holds at most one seat of a session
+	var shared []string
+	err = tx.Select(&shared, `SELECT s.booking_code FROM appointments s
+		JOIN appointments t ON t.business_id = s.business_id AND t.resource_id = s.resource_id AND t.date_start = s.date_start
+		WHERE s.business_id = $1 AND s.customer_id = $2 AND t.customer_id = $3
+		ORDER BY s.date_start`,
+		string(businessID), string(sourceID), string(targetID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if len(shared) > 0 {
+		return fmt.Errorf("booking codes %s: %w", strings.Join(shared, ", "), ErrSharedBooking)
+	}
+
+	for _, table := range []string{"customer_identities", "appointments", "appointment_series", "slot_holds", "booking_requests", "waitlist", "customer_notifications"} {
+		_, err = tx.Exec(`UPDATE `+table+` SET customer_id = $1 WHERE business_id = $2 AND customer_id = $3`,
+			string(targetID), string(businessID), string(sourceID))
+		if err != nil {
+			return dbase.DbError(err)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT OR IGNORE INTO customer_blocklist (business_id, customer_id, reason, created_at)
+		SELECT business_id, $1, reason, created_at FROM customer_blocklist WHERE business_id = $2 AND customer_id = $3`,
+		string(targetID), string(businessID), string(sourceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	_, err = tx.Exec(`DELETE FROM customer_blocklist WHERE business_id = $1 AND customer_id = $2`,
+		string(businessID), string(sourceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+
+	_, err = tx.Exec(`UPDATE customers SET merged_into = $1 WHERE business_id = $2 AND (id = $3 OR merged_into = $3)`,
+		string(targetID), string(businessID), string(sourceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+
+	return dbase.DbError(tx.Commit())
+}
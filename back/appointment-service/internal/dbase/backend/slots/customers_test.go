@@ -0,0 +1,169 @@
+package slots
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestResolveCustomer(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	tg, err := storage.ResolveCustomer("b1", ChannelTelegram, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tg != "100" {
+		t.Fatalf("new customer must keep the channel ID: %s", tg)
+	}
+
+	again, err := storage.ResolveCustomer("b1", ChannelTelegram, "100")
+	if err != nil || again != tg {
+		t.Fatalf("identity must resolve to the same customer: %s %v", again, err)
+	}
+
+	other, err := storage.ResolveCustomer("b2", ChannelTelegram, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	customers, err := storage.FindCustomers("b2", CustomerFilter{})
+	if err != nil || len(customers) != 1 || customers[0].Id != other {
+		t.Fatalf("customers are separated by business: %v %v", customers, err)
+	}
+
+	if _, err := storage.ResolveCustomer("b1", "sms", "100"); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("unknown channel must be rejected: %v", err)
+	}
+}
+
+func TestMergeCustomers(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	tg, err := storage.ResolveCustomer("b1", ChannelTelegram, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	magic, err := storage.ResolveCustomer("b1", ChannelToken, "magic-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.UpdateCustomer(Customer{Business: "b1", Id: magic, DisplayName: "Alice", Email: "Alice@example.com", TimeZone: "Europe/Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.UpdateCustomer(Customer{Business: "b1", Id: tg, Email: "alice@example.com"})
+	if !errors.Is(err, ErrIdentityTaken) {
+		t.Fatalf("email is linked to another customer: %v", err)
+	}
+	if err := storage.UpdateCustomer(Customer{Business: "b1", Id: magic, TimeZone: "Mars/Olympus"}); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("invalid time zone must be rejected: %v", err)
+	}
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	err = storage.AddSlots(AddSlotsData{
+		Business: "b1",
+		Customer: magic,
+		Slots:    common.Intervals{{Start: start, End: start.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.MergeCustomers("b1", tg, magic); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := storage.ResolveCustomer("b1", ChannelToken, "magic-1")
+	if err != nil || resolved != tg {
+		t.Fatalf("magic link identity must resolve to the merged customer: %s %v", resolved, err)
+	}
+	// New identities equal to the merged customer ID are followed to the merge target
+	resolved, err = storage.ResolveCustomer("b1", ChannelTelegram, magic)
+	if err != nil || resolved != tg {
+		t.Fatalf("merged customer ID must resolve to the target: %s %v", resolved, err)
+	}
+
+	customer, err := storage.GetCustomer("b1", tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customer.DisplayName != "Alice" || customer.Email != "alice@example.com" || customer.TimeZone != "Europe/Berlin" {
+		t.Fatalf("profile must be filled from the merged customer: %+v", customer)
+	}
+	if len(customer.Identities) != 4 {
+		t.Fatalf("unexpected identities: %v", customer.Identities)
+	}
+
+	found, err := storage.FindCustomers("b1", CustomerFilter{Identity: &CustomerIdentity{Channel: ChannelEmail, ExternalId: "ALICE@example.com"}})
+	if err != nil || len(found) != 1 || found[0].Id != tg {
+		t.Fatalf("lookup by email identity: %v %v", found, err)
+	}
+	found, err = storage.FindCustomers("b1", CustomerFilter{Name: "lic"})
+	if err != nil || len(found) != 1 || found[0].Id != tg {
+		t.Fatalf("lookup by name: %v %v", found, err)
+	}
+	if !slices.Equal(found[0].Identities, customer.Identities) {
+		t.Fatalf("found customer must have identities: %v", found[0].Identities)
+	}
+
+	appointments, err := storage.GetCustomerAppointmentsInRange("b1", tg, common.Interval{Start: time.Now()})
+	if err != nil || len(appointments) != 1 {
+		t.Fatalf("appointments must be moved to the merged customer: %v %v", appointments, err)
+	}
+
+	if _, err := storage.GetCustomer("b1", magic); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("merged customer must be hidden: %v", err)
+	}
+	if err := storage.MergeCustomers("b1", tg, magic); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("customer can not be merged twice: %v", err)
+	}
+}
+
+func TestMergeCustomersSharedSession(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	classStart := day.Add(18 * time.Hour)
+	class := dailyRule(t, classStart, 1, time.Hour, common.Inclusion)
+	class.Capacity = 3
+	if _, err := storage.AddBusinessRule("b1", class); err != nil {
+		t.Fatal(err)
+	}
+	session := Session{Interval: common.Interval{Start: classStart, End: classStart.Add(time.Hour)}, Capacity: 3}
+
+	tg, err := storage.ResolveCustomer("b1", ChannelTelegram, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	magic, err := storage.ResolveCustomer("b1", ChannelToken, "magic-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, customer := range []common.ID{tg, magic} {
+		if _, err := storage.BookSeat(BookSeatData{Business: "b1", Customer: customer, Resource: DefaultResource, Session: session}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := storage.MergeCustomers("b1", tg, magic); !errors.Is(err, ErrSharedBooking) {
+		t.Fatalf("customers holding the same session must not be merged: %v", err)
+	}
+	if _, err := storage.GetCustomer("b1", magic); err != nil {
+		t.Fatalf("refused merge must keep the source customer: %v", err)
+	}
+
+	if err := storage.CancelAppointment("b1", magic, classStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.MergeCustomers("b1", tg, magic); err != nil {
+		t.Fatalf("merge after cancelling the shared booking: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package slots
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/google/uuid"
+)
+
+type dbBookingField struct {
+	Id       string `db:"id"`
+	Service  string `db:"service_id"`
+	Key      string `db:"key"`
+	Label    string `db:"label"`
+	Type     string `db:"type"`
+	Required bool   `db:"required"`
+	Choices  string `db:"choices"`
+}
+
+func (f dbBookingField) toField() (common.BookingField, error) {
+	field := common.BookingField{
+		Id:       f.Id,
+		Service:  f.Service,
+		Key:      f.Key,
+		Label:    f.Label,
+		Type:     common.FieldType(f.Type),
+		Required: f.Required,
+	}
+	if err := json.Unmarshal([]byte(f.Choices), &field.Choices); err != nil {
+		return common.BookingField{}, fmt.Errorf("field %s choices: %w", f.Id, err)
+	}
+	return field, nil
+}
+
+// AddBookingField adds the field to the end of the business booking form.
+// Field key must be unique within fields asked for a service: services may use the same key,
+// but not a key of a business-wide field. Service must belong to the business.
+func (db *TimeSlotsStorage) AddBookingField(businessID common.ID, field common.BookingField) (common.ID, error) {
+	if err := field.Validate(); err != nil {
+		return "", err
+	}
+	if field.Service != "" {
+		if _, err := db.GetService(businessID, field.Service); err != nil {
+			return "", fmt.Errorf("service %s: %w", field.Service, err)
+		}
+	}
+
+	choices, err := json.Marshal(field.Choices)
+	if err != nil {
+		return "", err
+	}
+	if field.Choices == nil {
+		choices = []byte("[]")
+	}
+
+	// Business-wide fields are asked with fields of every service
+	var taken int
+	err = db.Get(&taken, `SELECT COUNT(*) FROM booking_fields
+		WHERE business_id = $1 AND key = $2 AND ($3 = '' OR service_id = '')`,
+		string(businessID), field.Key, string(field.Service))
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	if taken != 0 {
+		return "", fmt.Errorf("%w: field key %s already exists", common.ErrInvalidArgument, field.Key)
+	}
+
+	newID := uuid.New().String()
+	_, err = db.Exec(`INSERT INTO booking_fields (id, business_id, service_id, key, label, type, required, choices, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+			(SELECT COALESCE(MAX(position), 0) + 1 FROM booking_fields WHERE business_id = $2))`,
+		newID, string(businessID), string(field.Service), field.Key, field.Label, string(field.Type), field.Required, string(choices))
+	if dbase.IsConstraintError(err) {
+		return "", fmt.Errorf("%w: field key %s already exists", common.ErrInvalidArgument, field.Key)
+	}
+	return newID, dbase.DbError(err)
+}
+
+// GetBookingFields returns all fields of the business in form order.
+// No errors if no fields found
+func (db *TimeSlotsStorage) GetBookingFields(businessID common.ID) ([]common.BookingField, error) {
+	return db.selectBookingFields(`SELECT id, service_id, key, label, type, required, choices FROM booking_fields
+		WHERE business_id = $1 ORDER BY position`, string(businessID))
+}
+
+// GetServiceBookingFields returns fields asked when the service is booked: business-wide
+// fields and fields of the service. Empty serviceID selects business-wide fields only.
+func (db *TimeSlotsStorage) GetServiceBookingFields(businessID common.ID, serviceID common.ID) ([]common.BookingField, error) {
+	return db.selectBookingFields(`SELECT id, service_id, key, label, type, required, choices FROM booking_fields
+		WHERE business_id = $1 AND service_id IN ('', $2) ORDER BY position`, string(businessID), string(serviceID))
+}
+
+func (db *TimeSlotsStorage) selectBookingFields(query string, args ...any) ([]common.BookingField, error) {
+	var rows []dbBookingField
+	if err := db.Select(&rows, query, args...); err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]common.BookingField, 0, len(rows))
+	for _, row := range rows {
+		field, err := row.toField()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, field)
+	}
+	return out, nil
+}
+
+func (db *TimeSlotsStorage) DeleteBookingField(businessID common.ID, fieldID common.ID) error {
+	res, err := db.Exec(`DELETE FROM booking_fields WHERE business_id = $1 AND id = $2`, string(businessID), string(fieldID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("booking field %s: %w", fieldID, common.ErrNotFound)
+	}
+	return nil
+}
+
+func encodeAnswers(answers common.Answers) string {
+	if len(answers) == 0 {
+		return "{}"
+	}
+	out, err := json.Marshal(answers)
+	if err != nil {
+		// map of strings is always encodable
+		panic(err)
+	}
+	return string(out)
+}
+
+func decodeAnswers(s string) common.Answers {
+	var out common.Answers
+	if err := json.Unmarshal([]byte(s), &out); err != nil || len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// Appointment is a booked slot as seen by the business
+type Appointment struct {
+	Customer common.ID
+	Resource common.ID
+	Seat     int
+	Interval common.Interval
+	Answers  common.Answers
+	// Series is empty for appointments booked one by one
+	Series      common.ID
+	BookingCode string
+	Attendance  common.Attendance
+}
+
+// GetBusinessAppointmentsInRange returns appointments of all resources overlapping between,
+// ordered by start, resource and seat. No errors if no appointments found
+func (db *TimeSlotsStorage) GetBusinessAppointmentsInRange(businessID common.ID, between common.Interval) ([]Appointment, error) {
+	var rows []dbBusySlot
+	err := db.Select(&rows, `SELECT `+appointmentColumns+`, answers, series_id FROM appointments
+		WHERE business_id = $1 AND date_end > $2 AND date_start < $3
+		ORDER BY date_start, resource_id, seat`,
+		string(businessID), between.Start.Unix(), between.End.Unix())
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]Appointment, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Appointment{
+			Customer:    row.Customer,
+			Resource:    row.Resource,
+			Seat:        row.Seat,
+			Interval:    common.Interval{Start: time.Unix(row.DateStart, 0), End: time.Unix(row.DateEnd, 0)},
+			Answers:     decodeAnswers(row.Answers),
+			Series:      row.Series,
+			BookingCode: row.BookingCode,
+			Attendance:  common.Attendance(row.Attendance),
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,127 @@
+package slots
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestBookingFields(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	serviceID, err := storage.AddService("b1", Service{Name: "Repair"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storage.AddBookingField("b1", common.BookingField{Key: "car_plate", Label: "Car plate", Type: common.FieldText, Required: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.AddBookingField("b1", common.BookingField{
+		Service: serviceID, Key: "reason", Label: "Reason", Type: common.FieldChoice, Choices: []string{"engine", "tires"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storage.AddBookingField("b1", common.BookingField{Key: "car_plate", Label: "Plate", Type: common.FieldText})
+	if !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("duplicated key must be rejected: %v", err)
+	}
+	_, err = storage.AddBookingField("b1", common.BookingField{Service: serviceID, Key: "car_plate", Label: "Plate", Type: common.FieldText})
+	if !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("key of business-wide field must be rejected for services: %v", err)
+	}
+	otherService, err := storage.AddService("b1", Service{Name: "Wash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.AddBookingField("b1", common.BookingField{Service: otherService, Key: "reason", Label: "Reason", Type: common.FieldText})
+	if err != nil {
+		t.Fatalf("services may use the same key: %v", err)
+	}
+	_, err = storage.AddBookingField("b1", common.BookingField{Key: "reason", Label: "Reason", Type: common.FieldText})
+	if !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("key of service field must be rejected for business-wide fields: %v", err)
+	}
+	_, err = storage.AddBookingField("b1", common.BookingField{Service: "unknown", Key: "other", Label: "Other", Type: common.FieldText})
+	if !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("unknown service must be rejected: %v", err)
+	}
+
+	fields, err := storage.GetServiceBookingFields("b1", "")
+	if err != nil || len(fields) != 1 || fields[0].Key != "car_plate" {
+		t.Fatalf("business-wide fields: %v %v", fields, err)
+	}
+	fields, err = storage.GetServiceBookingFields("b1", serviceID)
+	if err != nil || len(fields) != 2 || fields[1].Key != "reason" || !reflect.DeepEqual(fields[1].Choices, []string{"engine", "tires"}) {
+		t.Fatalf("service fields: %v %v", fields, err)
+	}
+
+	if err := storage.DeleteService("b1", serviceID); err != nil {
+		t.Fatal(err)
+	}
+	fields, err = storage.GetBookingFields("b1")
+	if err != nil || len(fields) != 2 {
+		t.Fatalf("fields of the deleted service must be removed: %v %v", fields, err)
+	}
+	if err := storage.DeleteBookingField("b1", fields[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DeleteBookingField("b1", fields[0].Id); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found: %v", err)
+	}
+}
+
+func TestAppointmentAnswers(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	first := common.Interval{Start: start, End: start.Add(time.Hour)}
+	second := common.Interval{Start: first.End, End: first.End.Add(time.Hour)}
+
+	err := storage.AddSlots(AddSlotsData{
+		Business: "b1",
+		Customer: "c1",
+		Slots:    common.Intervals{first},
+		Answers:  common.Answers{"car_plate": "A123BC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := storage.AddHold(HoldData{
+		Business:  "b1",
+		Customer:  "c2",
+		Slots:     common.Intervals{second},
+		ExpiresAt: time.Now().Add(time.Minute),
+		Answers:   common.Answers{"car_plate": "B456CD"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.ConfirmHold("b1", "c2", token); err != nil {
+		t.Fatal(err)
+	}
+
+	appointments, err := storage.GetBusinessAppointmentsInRange("b1", common.Interval{Start: first.Start, End: second.End})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appointments) != 2 {
+		t.Fatalf("unexpected appointments: %v", appointments)
+	}
+	if appointments[0].Customer != "c1" || appointments[0].Answers["car_plate"] != "A123BC" {
+		t.Fatalf("unexpected first appointment: %+v", appointments[0])
+	}
+	if appointments[1].Customer != "c2" || appointments[1].Answers["car_plate"] != "B456CD" {
+		t.Fatalf("answers must be kept after hold confirmation: %+v", appointments[1])
+	}
+}
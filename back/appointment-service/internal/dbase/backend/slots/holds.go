@@ -0,0 +1,187 @@
+package slots
+
+import (
+	"errors"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrSlotTaken   = errors.New("slot taken")
+	ErrHoldExpired = errors.New("hold expired")
+)
+
+// HoldData is a short-lived reservation of slots. While the hold is active
+// the slots are busy for everyone, including the customer who holds them.
+type HoldData struct {
+	Business  common.ID
+	Customer  common.ID
+	Resource  common.ID
+	Slots     common.Intervals
+	ExpiresAt time.Time
+	// Answers are stored with appointments when the hold is confirmed
+	Answers common.Answers
+}
+
+type dbHold struct {
+	Token     string `db:"token"`
+	Business  string `db:"business_id"`
+	Customer  string `db:"customer_id"`
+	Resource  string `db:"resource_id"`
+	DateStart int64  `db:"date_start"`
+	DateEnd   int64  `db:"date_end"`
+	ExpiresAt int64  `db:"expires_at"`
+	Answers   string `db:"answers"`
+}
+
+// AddHold reserves slots until in.ExpiresAt and returns the hold token.
+// ErrSlotTaken is returned if any slot overlaps an appointment or an active hold of the resource.
+func (db *TimeSlotsStorage) AddHold(in HoldData) (string, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	token := uuid.New().String()
+	for _, slot := range in.Slots {
+		taken, err := isTaken(tx, in.Business, in.Resource, slot, now)
+		if err != nil {
+			return "", dbase.DbError(err)
+		}
+		if taken {
+			return "", ErrSlotTaken
+		}
+
+		_, err = tx.Exec(`INSERT INTO slot_holds (token, business_id, customer_id, resource_id, date_start, date_end, expires_at, answers)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			token, string(in.Business), string(in.Customer), string(in.Resource), slot.Start.Unix(), slot.End.Unix(), in.ExpiresAt.Unix(),
+			encodeAnswers(in.Answers))
+		if err != nil {
+			return "", dbase.DbError(err)
+		}
+	}
+
+	return token, dbase.DbError(tx.Commit())
+}
+
+// ConfirmHold turns the hold of the customer into appointments.
+// Returns resource of the appointments.
+func (db *TimeSlotsStorage) ConfirmHold(businessID common.ID, customerID common.ID, token string) (common.ID, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	holds, err := takeHold(tx, businessID, customerID, token)
+	if err != nil {
+		return "", err
+	}
+
+	for _, h := range holds {
+		_, err = tx.Exec(`INSERT INTO appointments (business_id, resource_id, date_start, customer_id, date_end, answers) VALUES ($1, $2, $3, $4, $5, $6)`,
+			h.Business, h.Resource, h.DateStart, h.Customer, h.DateEnd, h.Answers)
+		if err != nil {
+			if dbase.IsConstraintError(err) {
+				return "", ErrSlotTaken
+			}
+			return "", dbase.DbError(err)
+		}
+	}
+
+	return holds[0].Resource, dbase.DbError(tx.Commit())
+}
+
+// takeHold removes active hold of the customer and returns its slots
+func takeHold(tx *sqlx.Tx, businessID common.ID, customerID common.ID, token string) ([]dbHold, error) {
+	var holds []dbHold
+	err := tx.Select(&holds, `SELECT token, business_id, customer_id, resource_id, date_start, date_end, expires_at, answers
+		FROM slot_holds WHERE token = $1 AND business_id = $2 AND customer_id = $3`,
+		token, string(businessID), string(customerID))
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+	if len(holds) == 0 {
+		return nil, common.ErrNotFound
+	}
+	if holds[0].ExpiresAt <= time.Now().Unix() {
+		return nil, ErrHoldExpired
+	}
+
+	_, err = tx.Exec(`DELETE FROM slot_holds WHERE token = $1`, token)
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+	return holds, nil
+}
+
+// CancelHold releases the hold before expiration
+func (db *TimeSlotsStorage) CancelHold(businessID common.ID, customerID common.ID, token string) error {
+	res, err := db.Exec(`DELETE FROM slot_holds WHERE token = $1 AND business_id = $2 AND customer_id = $3`,
+		token, string(businessID), string(customerID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return common.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteExpiredHolds removes holds expired before now. Returns number of removed slots.
+func (db *TimeSlotsStorage) DeleteExpiredHolds(now time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM slot_holds WHERE expires_at <= $1`, now.Unix())
+	if err != nil {
+		return 0, dbase.DbError(err)
+	}
+	return res.RowsAffected()
+}
+
+func isTaken(tx *sqlx.Tx, businessID common.ID, resourceID common.ID, slot common.Interval, now int64) (bool, error) {
+	return isTakenExcept(tx, businessID, resourceID, slot, now, 0)
+}
+
+// isTakenExcept is isTaken ignoring the appointment with the rowid, e.g. the one being moved
+func isTakenExcept(tx *sqlx.Tx, businessID common.ID, resourceID common.ID, slot common.Interval, now int64, rowID int64) (bool, error) {
+	var count int
+	err := tx.Get(&count, `
+		SELECT
+			(SELECT COUNT(*) FROM appointments
+				WHERE business_id = $1 AND resource_id = $2 AND date_end > $3 AND date_start < $4 AND rowid != $5)
+			+ (SELECT COUNT(*) FROM slot_holds
+				WHERE business_id = $1 AND resource_id = $2 AND date_end > $3 AND date_start < $4 AND expires_at > $6)
+			+ (SELECT COUNT(*) FROM booking_requests
+				WHERE business_id = $1 AND resource_id = $2 AND date_end > $3 AND date_start < $4 AND expires_at > $6 AND blocks_slot = 1)`,
+		string(businessID), string(resourceID), slot.Start.Unix(), slot.End.Unix(), rowID, now)
+	return count != 0, err
+}
+
+// getActiveHoldsOverlapping returns time of active holds and pending booking requests blocking slots
+func (db *TimeSlotsStorage) getActiveHoldsOverlapping(businessID common.ID, between common.Interval) (map[common.ID]common.Intervals, error) {
+	var holds []dbHold
+	err := db.Select(&holds, `SELECT token, business_id, customer_id, resource_id, date_start, date_end, expires_at
+		FROM slot_holds WHERE business_id = $1 AND date_end > $2 AND date_start < $3 AND expires_at > $4
+		UNION ALL
+		SELECT id, business_id, customer_id, resource_id, date_start, date_end, expires_at
+		FROM booking_requests WHERE business_id = $1 AND date_end > $2 AND date_start < $3 AND expires_at > $4 AND blocks_slot = 1`,
+		string(businessID), between.Start.Unix(), between.End.Unix(), time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[common.ID]common.Intervals)
+	for _, h := range holds {
+		out[h.Resource] = append(out[h.Resource], common.Interval{
+			Start: time.Unix(h.DateStart, 0),
+			End:   time.Unix(h.DateEnd, 0),
+		})
+	}
+	return out, nil
+}
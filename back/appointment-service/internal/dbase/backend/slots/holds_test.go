@@ -0,0 +1,101 @@
+package slots
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestSlotHolds(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	workStart := day.Add(9 * time.Hour)
+	if _, err := storage.AddBusinessRule("b1", dailyRule(t, workStart, 1, 4*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+
+	slot := common.Interval{Start: workStart, End: workStart.Add(time.Hour)}
+	between := common.Interval{Start: day, End: day.Add(24 * time.Hour)}
+	hold := HoldData{
+		Business:  "b1",
+		Customer:  "c1",
+		Resource:  DefaultResource,
+		Slots:     common.Intervals{slot},
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	token, err := storage.AddHold(hold)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	available, err := storage.GetAvailableSlotsInRange("b1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available.IsOverlap(slot) {
+		t.Fatalf("held slot must be busy: %v", available)
+	}
+
+	other := hold
+	other.Customer = "c2"
+	if _, err := storage.AddHold(other); !errors.Is(err, ErrSlotTaken) {
+		t.Fatalf("expected slot taken, got %v", err)
+	}
+	// Direct booking does not take the held slot either
+	err = storage.AddSlots(AddSlotsData{Business: "b1", Customer: "c2", Resource: DefaultResource, Slots: common.Intervals{slot}})
+	if !errors.Is(err, ErrSlotTaken) {
+		t.Fatalf("expected slot taken by the hold, got %v", err)
+	}
+
+	if _, err := storage.ConfirmHold("b1", "c2", token); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found for foreign hold, got %v", err)
+	}
+	if _, err := storage.ConfirmHold("b1", "c1", token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.ConfirmHold("b1", "c1", token); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("hold must be removed after confirm, got %v", err)
+	}
+
+	busy, err := storage.GetBusySlotsInRange("b1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 1 || busy[0].Customer != "c1" || !sameInterval(busy[0].Interval, slot) {
+		t.Fatalf("unexpected appointments: %v", busy)
+	}
+
+	// Expired hold does not block the slot and can not be confirmed
+	expired := hold
+	expired.Slots = common.Intervals{{Start: slot.End, End: slot.End.Add(time.Hour)}}
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	token, err = storage.AddHold(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.ConfirmHold("b1", "c1", token); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("expected hold expired, got %v", err)
+	}
+
+	available, err = storage.GetAvailableSlotsInRange("b1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !available.IsFit(expired.Slots[0]) {
+		t.Fatalf("expired hold must not block the slot: %v", available)
+	}
+
+	n, err := storage.DeleteExpiredHolds(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected one expired hold removed, got %d", n)
+	}
+}
@@ -0,0 +1,89 @@
+package slots
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type NotificationKind string
+
+const (
+	NotificationWaitlistSlotFreed NotificationKind = "waitlist_slot_freed"
+)
+
+// Notification is a message for the customer. Notifications are queued
+// and delivered by the business bot which polls and acknowledges them.
+type Notification struct {
+	Id       int64     `json:"id"`
+	Business common.ID `json:"-"`
+	Customer common.ID `json:"customer_id"`
+	// TelegramId is a Telegram identity of the customer, empty if the customer has not used Telegram
+	TelegramId string           `json:"telegram_id,omitempty"`
+	Kind       NotificationKind `json:"kind"`
+	Payload    json.RawMessage  `json:"payload"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+type dbNotification struct {
+	Id        int64  `db:"id"`
+	Business  string `db:"business_id"`
+	Customer  string `db:"customer_id"`
+	Telegram  string `db:"telegram_id"`
+	Kind      string `db:"kind"`
+	Payload   string `db:"payload"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+func addNotification(tx *sqlx.Tx, n Notification) error {
+	_, err := tx.Exec(`INSERT INTO customer_notifications (business_id, customer_id, kind, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		string(n.Business), string(n.Customer), string(n.Kind), string(n.Payload), time.Now().Unix())
+	return err
+}
+
+// GetPendingNotifications returns not acknowledged notifications, oldest first.
+// No errors if no notifications found
+func (db *TimeSlotsStorage) GetPendingNotifications(businessID common.ID, limit int) ([]Notification, error) {
+	var rows []dbNotification
+	err := db.Select(&rows, `SELECT n.id, n.business_id, n.customer_id, n.kind, n.payload, n.created_at,
+			COALESCE((SELECT MIN(i.external_id) FROM customer_identities i
+				WHERE i.business_id = n.business_id AND i.customer_id = n.customer_id AND i.channel = $1), '') AS telegram_id
+		FROM customer_notifications n WHERE n.business_id = $2 AND n.delivered_at = 0
+		ORDER BY n.id LIMIT $3`, string(ChannelTelegram), string(businessID), limit)
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]Notification, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Notification{
+			Id:         row.Id,
+			Business:   row.Business,
+			Customer:   row.Customer,
+			TelegramId: row.Telegram,
+			Kind:       NotificationKind(row.Kind),
+			Payload:    json.RawMessage(row.Payload),
+			CreatedAt:  time.Unix(row.CreatedAt, 0),
+		})
+	}
+	return out, nil
+}
+
+// AckNotification marks the notification as delivered
+func (db *TimeSlotsStorage) AckNotification(businessID common.ID, id int64) error {
+	res, err := db.Exec(`UPDATE customer_notifications SET delivered_at = $1 WHERE business_id = $2 AND id = $3`,
+		time.Now().Unix(), string(businessID), id)
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("notification %d: %w", id, common.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package slots
+
+import (
+	"fmt"
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/google/uuid"
+)
+
+// Resource is a staff member, room or anything else with own calendar
+type Resource struct {
+	Id   common.ID `db:"id" json:"id"`
+	Name string    `db:"name" json:"name"`
+}
+
+// Service is performed by one of its Resources.
+// Service without resources is served by the DefaultResource.
+type Service struct {
+	Id        common.ID   `json:"id"`
+	Name      string      `json:"name"`
+	Resources []common.ID `json:"resources"`
+}
+
+type dbService struct {
+	Id   string `db:"id"`
+	Name string `db:"name"`
+}
+
+func (db *TimeSlotsStorage) AddResource(businessID common.ID, name string) (common.ID, error) {
+	if name == "" {
+		return "", fmt.Errorf("resource name: %w", common.ErrInvalidArgument)
+	}
+
+	newID := uuid.New().String()
+	_, err := db.Exec(`INSERT INTO business_resources (id, business_id, name) VALUES ($1, $2, $3)`,
+		newID, string(businessID), name)
+	return newID, dbase.DbError(err)
+}
+
+// No errors if no resources found
+func (db *TimeSlotsStorage) GetResources(businessID common.ID) ([]Resource, error) {
+	resources := []Resource{}
+	err := db.Select(&resources, `SELECT id, name FROM business_resources WHERE business_id = $1 ORDER BY name`, string(businessID))
+	return resources, dbase.DbError(err)
+}
+
+func (db *TimeSlotsStorage) GetResource(businessID common.ID, resourceID common.ID) (Resource, error) {
+	var resource Resource
+	err := db.Get(&resource, `SELECT id, name FROM business_resources WHERE business_id = $1 AND id = $2`,
+		string(businessID), string(resourceID))
+	return resource, dbase.DbError(err)
+}
+
+// DeleteResource removes resource with its working rules.
+// Existing appointments of the resource are kept.
+func (db *TimeSlotsStorage) DeleteResource(businessID common.ID, resourceID common.ID) error {
+	if resourceID == DefaultResource {
+		return fmt.Errorf("resource id: %w", common.ErrInvalidArgument)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM business_resources WHERE business_id = $1 AND id = $2`,
+		string(businessID), string(resourceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("resource %s: %w", resourceID, common.ErrNotFound)
+	}
+
+	_, err = tx.Exec(`DELETE FROM business_work_rule WHERE business_id = $1 AND resource_id = $2`,
+		string(businessID), string(resourceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM service_resources WHERE resource_id = $1`, string(resourceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+
+	return dbase.DbError(tx.Commit())
+}
+
+// AddService stores service. All service resources must belong to the business.
+func (db *TimeSlotsStorage) AddService(businessID common.ID, service Service) (common.ID, error) {
+	if service.Name == "" {
+		return "", fmt.Errorf("service name: %w", common.ErrInvalidArgument)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	for _, resourceID := range service.Resources {
+		var count int
+		err = tx.Get(&count, `SELECT COUNT(*) FROM business_resources WHERE business_id = $1 AND id = $2`,
+			string(businessID), string(resourceID))
+		if err != nil {
+			return "", dbase.DbError(err)
+		}
+		if count == 0 {
+			return "", fmt.Errorf("resource %s: %w", resourceID, common.ErrNotFound)
+		}
+	}
+
+	newID := uuid.New().String()
+	_, err = tx.Exec(`INSERT INTO business_services (id, business_id, name) VALUES ($1, $2, $3)`,
+		newID, string(businessID), service.Name)
+	if err != nil {
+		return "", dbase.DbError(err)
+	}
+
+	for _, resourceID := range service.Resources {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO service_resources (service_id, resource_id) VALUES ($1, $2)`,
+			newID, string(resourceID))
+		if err != nil {
+			return "", dbase.DbError(err)
+		}
+	}
+
+	return newID, dbase.DbError(tx.Commit())
+}
+
+func (db *TimeSlotsStorage) GetService(businessID common.ID, serviceID common.ID) (Service, error) {
+	var row dbService
+	err := db.Get(&row, `SELECT id, name FROM business_services WHERE business_id = $1 AND id = $2`,
+		string(businessID), string(serviceID))
+	if err != nil {
+		return Service{}, dbase.DbError(err)
+	}
+
+	service := Service{Id: row.Id, Name: row.Name, Resources: []common.ID{}}
+	err = db.Select(&service.Resources, `SELECT resource_id FROM service_resources WHERE service_id = $1 ORDER BY resource_id`, row.Id)
+	return service, dbase.DbError(err)
+}
+
+// No errors if no services found
+func (db *TimeSlotsStorage) GetServices(businessID common.ID) ([]Service, error) {
+	var rows []dbService
+	err := db.Select(&rows, `SELECT id, name FROM business_services WHERE business_id = $1 ORDER BY name`, string(businessID))
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	var links []struct {
+		Service  string `db:"service_id"`
+		Resource string `db:"resource_id"`
+	}
+	err = db.Select(&links, `
+		SELECT sr.service_id, sr.resource_id
+		FROM service_resources sr JOIN business_services s ON s.id = sr.service_id
+		WHERE s.business_id = $1
+		ORDER BY sr.resource_id`, string(businessID))
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	resources := make(map[string][]common.ID, len(rows))
+	for _, l := range links {
+		resources[l.Service] = append(resources[l.Service], l.Resource)
+	}
+
+	services := make([]Service, 0, len(rows))
+	for _, row := range rows {
+		s := Service{Id: row.Id, Name: row.Name, Resources: resources[row.Id]}
+		if s.Resources == nil {
+			s.Resources = []common.ID{}
+		}
+		services = append(services, s)
+	}
+	return services, nil
+}
+
+func (db *TimeSlotsStorage) DeleteService(businessID common.ID, serviceID common.ID) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM business_services WHERE business_id = $1 AND id = $2`,
+		string(businessID), string(serviceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("service %s: %w", serviceID, common.ErrNotFound)
+	}
+
+	_, err = tx.Exec(`DELETE FROM service_resources WHERE service_id = $1`, string(serviceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM booking_fields WHERE business_id = $1 AND service_id = $2`,
+		string(businessID), string(serviceID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+
+	return dbase.DbError(tx.Commit())
+}
+
+// ServiceResources returns resources able to perform the service.
+// If the service has no resources the DefaultResource is returned.
+func ServiceResources(s Service) []common.ID {
+	if len(s.Resources) == 0 {
+		return []common.ID{DefaultResource}
+	}
+	return s.Resources
+}
@@ -0,0 +1,197 @@
+package slots
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+
+	"github.com/teambition/rrule-go"
+)
+
+func dailyRule(t *testing.T, start time.Time, count int, length time.Duration, ruleType common.IntervalType) common.IntervalRRuleWithType {
+	t.Helper()
+	rr, err := rrule.NewRRule(rrule.ROption{Dtstart: start, Freq: rrule.DAILY, Count: count})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return common.IntervalRRuleWithType{
+		Rule: common.IntervalRRule{RRule: rr, Len: common.Seconds(length / time.Second)},
+		Type: ruleType,
+	}
+}
+
+func sameInterval(a, b common.Interval) bool {
+	return a.Start.Equal(b.Start) && a.End.Equal(b.End)
+}
+
+func TestResourcesAndServices(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	anna, err := storage.AddResource("b1", "Anna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := storage.AddResource("b1", "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := storage.AddResource("b2", "Foreign")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := storage.AddResource("b1", ""); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+
+	resources, err := storage.GetResources("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 || resources[0].Name != "Anna" || resources[1].Name != "Bob" {
+		t.Fatalf("unexpected resources: %+v", resources)
+	}
+
+	if _, err := storage.AddService("b1", Service{Name: "Haircut", Resources: []common.ID{anna, foreign}}); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found for foreign resource, got %v", err)
+	}
+
+	haircut, err := storage.AddService("b1", Service{Name: "Haircut", Resources: []common.ID{anna, bob}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consult, err := storage.AddService("b1", Service{Name: "Consult"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := storage.GetService("b1", haircut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(service.Resources) != 2 {
+		t.Fatalf("unexpected service resources: %+v", service)
+	}
+
+	service, err = storage.GetService("b1", consult)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ServiceResources(service); len(got) != 1 || got[0] != DefaultResource {
+		t.Fatalf("expected default resource, got %v", got)
+	}
+
+	if _, err := storage.GetService("b2", haircut); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found for foreign business, got %v", err)
+	}
+
+	if err := storage.DeleteResource("b1", bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DeleteResource("b1", bob); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	services, err := storage.GetServices("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+	for _, s := range services {
+		if s.Id == haircut && (len(s.Resources) != 1 || s.Resources[0] != anna) {
+			t.Fatalf("deleted resource is still linked: %+v", s)
+		}
+	}
+
+	if err := storage.DeleteService("b1", consult); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetService("b1", consult); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestResourcesAvailableSlotsInRange(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	workStart := day.Add(9 * time.Hour)
+
+	anna, err := storage.AddResource("b1", "Anna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := storage.AddResource("b1", "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := storage.AddResourceRule("b1", anna, dailyRule(t, workStart, 2, 4*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.AddResourceRule("b1", bob, dailyRule(t, workStart.Add(2*time.Hour), 2, 4*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+	// Business day off on the second day applies to all resources
+	if _, err := storage.AddBusinessRule("b1", dailyRule(t, day.Add(24*time.Hour), 1, 24*time.Hour, common.Exclusion)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.AddResourceRule("b2", anna, dailyRule(t, workStart, 1, time.Hour, common.Inclusion)); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("expected not found for foreign resource, got %v", err)
+	}
+
+	err = storage.AddSlots(AddSlotsData{
+		Business: "b1",
+		Customer: "c1",
+		Resource: anna,
+		Slots:    common.Intervals{{Start: workStart, End: workStart.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same time for another resource is not a conflict
+	err = storage.AddSlots(AddSlotsData{
+		Business: "b1",
+		Customer: "c2",
+		Resource: bob,
+		Slots:    common.Intervals{{Start: workStart, End: workStart.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	between := common.Interval{Start: day, End: day.Add(48 * time.Hour)}
+	available, err := storage.GetResourcesAvailableSlotsInRange("b1", []common.ID{anna, bob, DefaultResource}, between)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := available[DefaultResource]; ok {
+		t.Fatalf("default resource has no inclusion rules: %v", available[DefaultResource])
+	}
+
+	expectedAnna := common.Interval{Start: workStart.Add(time.Hour), End: workStart.Add(4 * time.Hour)}
+	if len(available[anna]) != 1 || !sameInterval(available[anna][0], expectedAnna) {
+		t.Fatalf("unexpected anna availability: %v", available[anna])
+	}
+
+	expectedBob := common.Interval{Start: workStart.Add(2 * time.Hour), End: workStart.Add(6 * time.Hour)}
+	if len(available[bob]) != 1 || !sameInterval(available[bob][0], expectedBob) {
+		t.Fatalf("unexpected bob availability: %v", available[bob])
+	}
+
+	load, err := storage.GetResourcesLoad("b1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if load[anna] != time.Hour || load[bob] != time.Hour {
+		t.Fatalf("unexpected load: %v", load)
+	}
+}
@@ -0,0 +1,138 @@
+package slots
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/google/uuid"
+)
+
+// SeriesData is a recurring booking of the customer on one resource.
+// Rule is stored for reference only, Slots are the occurrences to book.
+type SeriesData struct {
+	Business common.ID
+	Customer common.ID
+	Resource common.ID
+	Rule     string
+	Slots    common.Intervals
+	// Sessions are group sessions among Slots, a seat is booked for each of them
+	Sessions []Session
+	Answers  common.Answers
+	// Partial allows to book free occurrences if some of them are taken
+	Partial bool
+}
+
+// AddSeries books occurrences of the series in one transaction. Returns the series ID
+// and occurrences which are taken by other appointments or holds, or group sessions without
+// free seats. ErrSlotTaken is returned if any occurrence is taken and partial booking
+// is not allowed, or all of them are taken.
+func (db *TimeSlotsStorage) AddSeries(in SeriesData) (common.ID, common.Intervals, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", nil, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	newID := uuid.New().String()
+	var taken common.Intervals
+	for _, slot := range in.Slots {
+		if i := slices.IndexFunc(in.Sessions, func(s Session) bool { return s.Start.Equal(slot.Start) && s.End.Equal(slot.End) }); i >= 0 {
+			_, err := bookSeat(tx, BookSeatData{
+				Business: in.Business,
+				Customer: in.Customer,
+				Resource: in.Resource,
+				Session:  in.Sessions[i],
+				Answers:  in.Answers,
+				Series:   newID,
+			})
+			switch {
+			case errors.Is(err, ErrNoSeatsLeft) || errors.Is(err, ErrAlreadyBooked):
+				if !in.Partial {
+					return "", common.Intervals{slot}, ErrSlotTaken
+				}
+				taken = append(taken, slot)
+			case err != nil:
+				return "", nil, err
+			}
+			continue
+		}
+
+		isSlotTaken, err := isTaken(tx, in.Business, in.Resource, slot, now)
+		if err != nil {
+			return "", nil, dbase.DbError(err)
+		}
+		if isSlotTaken {
+			if !in.Partial {
+				return "", common.Intervals{slot}, ErrSlotTaken
+			}
+			taken = append(taken, slot)
+			continue
+		}
+
+		_, err = tx.Exec(`INSERT INTO appointments (business_id, resource_id, date_start, customer_id, date_end, answers, series_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			string(in.Business), string(in.Resource), slot.Start.Unix(), string(in.Customer), slot.End.Unix(),
+			encodeAnswers(in.Answers), newID)
+		if err != nil {
+			return "", nil, dbase.DbError(err)
+		}
+	}
+	if len(taken) == len(in.Slots) {
+		return "", taken, ErrSlotTaken
+	}
+
+	_, err = tx.Exec(`INSERT INTO appointment_series (id, business_id, customer_id, resource_id, rrule, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		newID, string(in.Business), string(in.Customer), string(in.Resource), in.Rule, now)
+	if err != nil {
+		return "", nil, dbase.DbError(err)
+	}
+
+	return newID, taken, dbase.DbError(tx.Commit())
+}
+
+// CancelSeries removes occurrences of the customer series started at or after from.
+// Returns cancelled occurrences, common.ErrNotFound if the series does not belong to the customer.
+func (db *TimeSlotsStorage) CancelSeries(businessID common.ID, customerID common.ID, seriesID common.ID, from time.Time) (common.Intervals, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.Get(&count, `SELECT COUNT(*) FROM appointment_series WHERE id = $1 AND business_id = $2 AND customer_id = $3`,
+		string(seriesID), string(businessID), string(customerID))
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("series %s: %w", seriesID, common.ErrNotFound)
+	}
+
+	var rows []dbBusySlot
+	err = tx.Select(&rows, `SELECT `+appointmentColumns+` FROM appointments
+		WHERE business_id = $1 AND series_id = $2 AND date_start >= $3 ORDER BY date_start`,
+		string(businessID), string(seriesID), from.Unix())
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM appointments WHERE business_id = $1 AND series_id = $2 AND date_start >= $3`,
+		string(businessID), string(seriesID), from.Unix())
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make(common.Intervals, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.ToSlot().Interval)
+	}
+	return out, dbase.DbError(tx.Commit())
+}
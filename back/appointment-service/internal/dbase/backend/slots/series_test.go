@@ -0,0 +1,123 @@
+package slots
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestAppointmentSeries(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	start := time.Now().UTC().Truncate(24 * time.Hour).Add(24*time.Hour + 9*time.Hour)
+	week := 7 * 24 * time.Hour
+	var occurrences common.Intervals
+	for i := range 4 {
+		s := start.Add(time.Duration(i) * week)
+		occurrences = append(occurrences, common.Interval{Start: s, End: s.Add(time.Hour)})
+	}
+
+	err := storage.AddSlots(AddSlotsData{Business: "b1", Customer: "c2", Resource: DefaultResource, Slots: occurrences[1:2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series := SeriesData{
+		Business: "b1",
+		Customer: "c1",
+		Resource: DefaultResource,
+		Rule:     "FREQ=WEEKLY",
+		Slots:    occurrences,
+		Answers:  common.Answers{"car_plate": "A123BC"},
+	}
+	if _, taken, err := storage.AddSeries(series); !errors.Is(err, ErrSlotTaken) || len(taken) != 1 || !taken[0].Start.Equal(occurrences[1].Start) {
+		t.Fatalf("atomic series must fail on taken occurrence: %v %v", taken, err)
+	}
+	appointments, err := storage.GetBusinessAppointmentsInRange("b1", common.Interval{Start: start, End: start.Add(4 * week)})
+	if err != nil || len(appointments) != 1 {
+		t.Fatalf("atomic series must not book anything: %v %v", appointments, err)
+	}
+
+	series.Partial = true
+	seriesID, taken, err := storage.AddSeries(series)
+	if err != nil || len(taken) != 1 || !taken[0].Start.Equal(occurrences[1].Start) {
+		t.Fatalf("partial series: %v %v", taken, err)
+	}
+	appointments, err = storage.GetBusinessAppointmentsInRange("b1", common.Interval{Start: start, End: start.Add(4 * week)})
+	if err != nil || len(appointments) != 4 {
+		t.Fatalf("partial series must book free occurrences: %v %v", appointments, err)
+	}
+	for _, appt := range appointments {
+		if appt.Customer == "c1" && (appt.Series != seriesID || appt.Answers["car_plate"] != "A123BC") {
+			t.Fatalf("series occurrence: %+v", appt)
+		}
+	}
+
+	if _, _, err := storage.AddSeries(SeriesData{Business: "b1", Customer: "c3", Resource: DefaultResource, Slots: occurrences, Partial: true}); !errors.Is(err, ErrSlotTaken) {
+		t.Fatalf("series without free occurrences must fail: %v", err)
+	}
+
+	if _, err := storage.CancelSeries("b1", "c2", seriesID, start); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("foreign series must not be cancelled: %v", err)
+	}
+	cancelled, err := storage.CancelSeries("b1", "c1", seriesID, occurrences[2].Start)
+	if err != nil || len(cancelled) != 2 || !cancelled[0].Start.Equal(occurrences[2].Start) {
+		t.Fatalf("cancel series: %v %v", cancelled, err)
+	}
+	appointments, err = storage.GetBusinessAppointmentsInRange("b1", common.Interval{Start: start, End: start.Add(4 * week)})
+	if err != nil || len(appointments) != 2 {
+		t.Fatalf("past occurrences must stay: %v %v", appointments, err)
+	}
+}
+
+func TestSessionSeries(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	classStart := time.Now().UTC().Truncate(24 * time.Hour).Add(24*time.Hour + 18*time.Hour)
+	class := dailyRule(t, classStart, 2, time.Hour, common.Inclusion)
+	class.Capacity = 2
+	if _, err := storage.AddBusinessRule("b1", class); err != nil {
+		t.Fatal(err)
+	}
+	var sessions []Session
+	var occurrences common.Intervals
+	for i := range 2 {
+		s := classStart.Add(time.Duration(i) * 24 * time.Hour)
+		sessions = append(sessions, Session{Interval: common.Interval{Start: s, End: s.Add(time.Hour)}, Capacity: 2})
+		occurrences = append(occurrences, sessions[i].Interval)
+	}
+
+	for _, customer := range []common.ID{"c2", "c3"} {
+		if _, err := storage.BookSeat(BookSeatData{Business: "b1", Customer: customer, Resource: DefaultResource, Session: sessions[0]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	series := SeriesData{Business: "b1", Customer: "c1", Resource: DefaultResource, Rule: "FREQ=DAILY", Slots: occurrences, Sessions: sessions}
+	if _, taken, err := storage.AddSeries(series); !errors.Is(err, ErrSlotTaken) || len(taken) != 1 || !taken[0].Start.Equal(classStart) {
+		t.Fatalf("atomic series must fail on the full session: %v %v", taken, err)
+	}
+
+	series.Partial = true
+	seriesID, taken, err := storage.AddSeries(series)
+	if err != nil || len(taken) != 1 {
+		t.Fatalf("partial series: %v %v", taken, err)
+	}
+	appointments, err := storage.GetBusinessAppointmentsInRange("b1", occurrences[1])
+	if err != nil || len(appointments) != 1 || appointments[0].Customer != "c1" || appointments[0].Series != seriesID {
+		t.Fatalf("seat of the free session must be booked in the series: %+v %v", appointments, err)
+	}
+
+	availability, err := storage.GetResourcesAvailabilityInRange("b1", []common.ID{DefaultResource}, occurrences[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := availability[DefaultResource].Sessions; len(s) != 1 || s[0].SeatsLeft != 1 {
+		t.Fatalf("series occurrence must take one seat: %+v", s)
+	}
+}
@@ -0,0 +1,82 @@
+package slots
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestGroupSessions(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	classStart := day.Add(18 * time.Hour)
+
+	invalid := dailyRule(t, classStart, 1, time.Hour, common.Exclusion)
+	invalid.Capacity = 3
+	if _, err := storage.AddBusinessRule("b1", invalid); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument for exclusion with capacity, got %v", err)
+	}
+
+	if _, err := storage.AddBusinessRule("b1", dailyRule(t, day.Add(9*time.Hour), 1, 4*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+	class := dailyRule(t, classStart, 1, time.Hour, common.Inclusion)
+	class.Capacity = 2
+	if _, err := storage.AddBusinessRule("b1", class); err != nil {
+		t.Fatal(err)
+	}
+
+	between := common.Interval{Start: day, End: day.Add(24 * time.Hour)}
+	session := Session{Interval: common.Interval{Start: classStart, End: classStart.Add(time.Hour)}, Capacity: 2}
+
+	availability, err := storage.GetResourcesAvailabilityInRange("b1", []common.ID{DefaultResource}, between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := availability[DefaultResource]
+	if len(got.Sessions) != 1 || !sameInterval(got.Sessions[0].Interval, session.Interval) || got.Sessions[0].SeatsLeft != 2 {
+		t.Fatalf("unexpected sessions: %v", got.Sessions)
+	}
+	if got.Free.IsOverlap(session.Interval) {
+		t.Fatalf("session time must not be free for regular booking: %v", got.Free)
+	}
+
+	book := func(customer common.ID) (int, error) {
+		return storage.BookSeat(BookSeatData{Business: "b1", Customer: customer, Resource: DefaultResource, Session: session})
+	}
+
+	if seat, err := book("c1"); err != nil || seat != 0 {
+		t.Fatalf("unexpected first seat %d: %v", seat, err)
+	}
+	if _, err := book("c1"); !errors.Is(err, ErrAlreadyBooked) {
+		t.Fatalf("expected already booked, got %v", err)
+	}
+
+	availability, err = storage.GetResourcesAvailabilityInRange("b1", []common.ID{DefaultResource}, between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := availability[DefaultResource].Sessions; len(s) != 1 || s[0].SeatsLeft != 1 {
+		t.Fatalf("expected one seat left: %v", s)
+	}
+
+	if seat, err := book("c2"); err != nil || seat != 1 {
+		t.Fatalf("unexpected second seat %d: %v", seat, err)
+	}
+	if _, err := book("c3"); !errors.Is(err, ErrNoSeatsLeft) {
+		t.Fatalf("expected no seats left, got %v", err)
+	}
+
+	availability, err = storage.GetResourcesAvailabilityInRange("b1", []common.ID{DefaultResource}, between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := availability[DefaultResource].Sessions; len(s) != 0 {
+		t.Fatalf("full session must not be available: %v", s)
+	}
+}
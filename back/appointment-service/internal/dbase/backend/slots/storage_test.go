@@ -224,6 +224,79 @@ func TestSetBusinessSlotSettings(t *testing.T) {
 	if settings.DefaultChunk != 30*time.Minute || settings.MaxChunk != 60*time.Minute {
 		t.Fatalf("unexpected updated settings: %+v", settings)
 	}
+
+	policy := common.BookingPolicy{
+		MinLeadTime:        time.Hour,
+		MaxHorizon:         30 * 24 * time.Hour,
+		MaxActiveBookings:  3,
+		MaxBookingsPerDay:  2,
+		MaxBookingsPerWeek: 5,
+		OneBookingPerDay:   true,
+		MinGap:             15 * time.Minute,
+		CancellationCutoff: 24 * time.Hour,
+	}
+	err = storage.SetBusinessSlotSettings("b1", BusinessSlotSettings{DefaultChunk: 30 * time.Minute, MaxChunk: 60 * time.Minute, Policy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err = storage.GetBusinessSlotSettings("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Policy != policy {
+		t.Fatalf("unexpected policy: %+v", settings.Policy)
+	}
+
+	err = storage.SetBusinessSlotSettings("b1", BusinessSlotSettings{DefaultChunk: 30 * time.Minute, MaxChunk: 60 * time.Minute, Policy: common.BookingPolicy{MinGap: -time.Minute}})
+	if err == nil {
+		t.Fatal("expected validation error for negative policy value")
+	}
+
+	if settings.Mode != common.BookingChunks {
+		t.Fatalf("chunks mode expected by default: %+v", settings)
+	}
+	duration := common.DurationRange{Min: 30 * time.Minute, Max: 4 * time.Hour, Step: 15 * time.Minute}
+	err = storage.SetBusinessSlotSettings("b1", BusinessSlotSettings{DefaultChunk: 30 * time.Minute, MaxChunk: 60 * time.Minute, Mode: common.BookingRange, Duration: duration})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err = storage.GetBusinessSlotSettings("b1")
+	if err != nil || !settings.IsRangeMode() || settings.Duration != duration {
+		t.Fatalf("unexpected range mode settings: %+v %v", settings, err)
+	}
+
+	err = storage.SetBusinessSlotSettings("b1", BusinessSlotSettings{DefaultChunk: 30 * time.Minute, MaxChunk: 60 * time.Minute, Mode: common.BookingRange})
+	if err == nil {
+		t.Fatal("expected validation error for range mode without durations")
+	}
+
+	approval := BusinessSlotSettings{DefaultChunk: 30 * time.Minute, MaxChunk: 60 * time.Minute,
+		RequiresApproval: true, PendingBlocksSlot: true, ApprovalTimeout: 2 * time.Hour}
+	if err := storage.SetBusinessSlotSettings("b1", approval); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = storage.GetBusinessSlotSettings("b1")
+	if err != nil || !settings.RequiresApproval || !settings.PendingBlocksSlot || settings.ApprovalTimeout != approval.ApprovalTimeout {
+		t.Fatalf("unexpected approval settings: %+v %v", settings, err)
+	}
+	approval.ApprovalTimeout = -time.Minute
+	if err := storage.SetBusinessSlotSettings("b1", approval); err == nil {
+		t.Fatal("expected validation error for negative approval timeout")
+	}
+
+	span := BusinessSlotSettings{DefaultChunk: 30 * time.Minute, MaxChunk: 60 * time.Minute, MaxQuerySpan: 7 * 24 * time.Hour}
+	if err := storage.SetBusinessSlotSettings("b1", span); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = storage.GetBusinessSlotSettings("b1")
+	if err != nil || settings.QuerySpan() != span.MaxQuerySpan {
+		t.Fatalf("unexpected query span: %+v %v", settings, err)
+	}
+	span.MaxQuerySpan = common.MaxSlotsQuerySpan + time.Hour
+	if err := storage.SetBusinessSlotSettings("b1", span); err == nil {
+		t.Fatal("expected validation error for query span above the global limit")
+	}
 }
 
 func TestBusinessSlotSettingsValidation(t *testing.T) {
@@ -0,0 +1,195 @@
+package slots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teambition/rrule-go"
+)
+
+const NotificationAppointmentCancelled NotificationKind = "appointment_cancelled"
+
+// ErrImpactChanged is returned if appointments affected by the time off differ from the previewed ones
+var ErrImpactChanged = errors.New("affected appointments changed")
+
+// TimeOff blocks the interval with a one-off exclusion rule.
+// Time off of the DefaultResource applies to all resources of the business.
+type TimeOff struct {
+	Business common.ID
+	Resource common.ID
+	Interval common.Interval
+	// Cancel removes affected appointments and notifies their customers.
+	// Affected appointments must have exactly the Expected booking codes.
+	Cancel   bool
+	Expected []string
+	Comment  string
+	// Alternatives are suggested to customers of cancelled appointments, keyed by booking code
+	Alternatives map[string]common.Intervals
+}
+
+// appointmentCancelled is a payload of notifications about appointments cancelled by the business
+type appointmentCancelled struct {
+	DateStart    time.Time         `json:"date_start"`
+	DateEnd      time.Time         `json:"date_end"`
+	Comment      string            `json:"comment,omitempty"`
+	Alternatives []alternativeSlot `json:"alternatives,omitempty"`
+}
+
+type alternativeSlot struct {
+	DateStart time.Time `json:"date_start"`
+	DateEnd   time.Time `json:"date_end"`
+}
+
+// TimeOffRule returns the exclusion rule covering exactly the interval
+func TimeOffRule(interval common.Interval) (common.IntervalRRuleWithType, error) {
+	if !interval.IsValid() {
+		return common.IntervalRRuleWithType{}, fmt.Errorf("%w: invalid time off interval", common.ErrInvalidArgument)
+	}
+	rr, err := rrule.NewRRule(rrule.ROption{Dtstart: interval.Start.UTC(), Freq: rrule.DAILY, Count: 1})
+	if err != nil {
+		return common.IntervalRRuleWithType{}, fmt.Errorf("%w: %w", common.ErrInvalidArgument, err)
+	}
+	return common.IntervalRRuleWithType{
+		Rule: common.IntervalRRule{RRule: rr, Len: common.Seconds(interval.Duration() / time.Second)},
+		Type: common.Exclusion,
+	}, nil
+}
+
+type dbAffectedAppointment struct {
+	RowId int64 `db:"rowid"`
+	dbBusySlot
+}
+
+func selectTimeOffAppointments(q sqlx.Queryer, businessID common.ID, resourceID common.ID, interval common.Interval) ([]dbAffectedAppointment, error) {
+	query := `SELECT rowid, ` + appointmentColumns + ` FROM appointments
+		WHERE business_id = $1 AND date_end > $2 AND date_start < $3`
+	args := []any{string(businessID), interval.Start.Unix(), interval.End.Unix()}
+	if resourceID != DefaultResource {
+		query += ` AND resource_id = $4`
+		args = append(args, string(resourceID))
+	}
+	query += ` ORDER BY date_start, customer_id`
+
+	var rows []dbAffectedAppointment
+	err := sqlx.Select(q, &rows, query, args...)
+	return rows, err
+}
+
+// GetTimeOffImpact returns appointments overlapping the time off, ordered by start
+func (db *TimeSlotsStorage) GetTimeOffImpact(businessID common.ID, resourceID common.ID, interval common.Interval) ([]common.BusySlot, error) {
+	rows, err := selectTimeOffAppointments(db, businessID, resourceID, interval)
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]common.BusySlot, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.ToSlot())
+	}
+	return out, nil
+}
+
+// ApplyTimeOff adds the exclusion rule and, if requested, cancels affected appointments
+// notifying their customers. Returns ID of the rule and affected appointments.
+// common.ErrNotFound is returned if the resource doesn't belong to the business.
+func (db *TimeSlotsStorage) ApplyTimeOff(in TimeOff) (RuleID, []common.BusySlot, error) {
+	rule, err := TimeOffRule(in.Interval)
+	if err != nil {
+		return "", nil, err
+	}
+	if in.Resource != DefaultResource {
+		if _, err := db.GetResource(in.Business, in.Resource); err != nil {
+			return "", nil, err
+		}
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", nil, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	ruleID, err := insertRule(tx, in.Business, in.Resource, rule)
+	if err != nil {
+		return "", nil, dbase.DbError(err)
+	}
+
+	rows, err := selectTimeOffAppointments(tx, in.Business, in.Resource, in.Interval)
+	if err != nil {
+		return "", nil, dbase.DbError(err)
+	}
+	if in.Cancel {
+		if err := checkImpact(rows, in.Expected); err != nil {
+			return "", nil, err
+		}
+	}
+
+	affected := make([]common.BusySlot, 0, len(rows))
+	for _, row := range rows {
+		slot := row.ToSlot()
+		affected = append(affected, slot)
+		if !in.Cancel {
+			continue
+		}
+
+		if _, err := tx.Exec(`DELETE FROM appointments WHERE rowid = $1`, row.RowId); err != nil {
+			return "", nil, dbase.DbError(err)
+		}
+		cancelled := appointmentCancelled{
+			DateStart: slot.Start.UTC(),
+			DateEnd:   slot.End.UTC(),
+			Comment:   in.Comment,
+		}
+		for _, alt := range in.Alternatives[slot.BookingCode] {
+			cancelled.Alternatives = append(cancelled.Alternatives, alternativeSlot{DateStart: alt.Start.UTC(), DateEnd: alt.End.UTC()})
+		}
+		payload, err := json.Marshal(cancelled)
+		if err != nil {
+			return "", nil, err
+		}
+		err = addNotification(tx, Notification{
+			Business: in.Business,
+			Customer: slot.Customer,
+			Kind:     NotificationAppointmentCancelled,
+			Payload:  payload,
+		})
+		if err != nil {
+			return "", nil, dbase.DbError(err)
+		}
+	}
+	return ruleID, affected, dbase.DbError(tx.Commit())
+}
+
+// checkImpact returns ErrImpactChanged listing booking codes missed in the expected ones
+// or expected but no longer affected
+func checkImpact(rows []dbAffectedAppointment, expected []string) error {
+	expectedSet := make(map[string]bool, len(expected))
+	for _, code := range expected {
+		expectedSet[strings.ToUpper(strings.TrimSpace(code))] = true
+	}
+
+	var changed []string
+	for _, row := range rows {
+		if expectedSet[row.BookingCode] {
+			delete(expectedSet, row.BookingCode)
+		} else {
+			changed = append(changed, row.BookingCode)
+		}
+	}
+	for code := range expectedSet {
+		changed = append(changed, code)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	slices.Sort(changed)
+	return fmt.Errorf("booking codes %s: %w", strings.Join(changed, ", "), ErrImpactChanged)
+}
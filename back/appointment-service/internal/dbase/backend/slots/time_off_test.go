@@ -0,0 +1,121 @@
+package slots
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestTimeOff(t *testing.T) {
+	storage := TimeSlotsStorage{test.InitTmpDB(t)}
+	defer storage.Close()
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	workStart := day.Add(9 * time.Hour)
+	if _, err := storage.AddBusinessRule("b1", dailyRule(t, workStart, 3, 8*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+	anna, err := storage.AddResource("b1", "Anna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.AddResourceRule("b1", anna, dailyRule(t, workStart, 3, 8*time.Hour, common.Inclusion)); err != nil {
+		t.Fatal(err)
+	}
+
+	book := func(customer common.ID, resource common.ID, start time.Time) {
+		t.Helper()
+		err := storage.AddSlots(AddSlotsData{
+			Business: "b1",
+			Customer: customer,
+			Resource: resource,
+			Slots:    common.Intervals{{Start: start, End: start.Add(time.Hour)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	book("c1", DefaultResource, workStart)
+	book("c2", anna, workStart.Add(2*time.Hour))
+	book("c3", DefaultResource, workStart.Add(24*time.Hour))
+
+	off := common.Interval{Start: day, End: day.Add(24 * time.Hour)}
+	affected, err := storage.GetTimeOffImpact("b1", anna, off)
+	if err != nil || len(affected) != 1 || affected[0].Customer != "c2" {
+		t.Fatalf("time off of the resource affects its appointments only: %v %v", affected, err)
+	}
+	affected, err = storage.GetTimeOffImpact("b1", DefaultResource, off)
+	if err != nil || len(affected) != 2 || affected[0].Customer != "c1" || affected[1].Customer != "c2" {
+		t.Fatalf("business time off affects all resources: %v %v", affected, err)
+	}
+
+	// Time off without cancellation keeps appointments
+	annaOff := common.Interval{Start: workStart.Add(24 * time.Hour), End: workStart.Add(26 * time.Hour)}
+	if _, _, err := storage.ApplyTimeOff(TimeOff{Business: "b1", Resource: anna, Interval: annaOff}); err != nil {
+		t.Fatal(err)
+	}
+	available, err := storage.GetResourceAvailableSlotsInRange("b1", anna, annaOff)
+	if err != nil || len(available) != 0 {
+		t.Fatalf("time off must be blocked: %v %v", available, err)
+	}
+
+	// Appointment booked after the preview is not cancelled without the owner seeing it
+	expected := []string{affected[0].BookingCode, affected[1].BookingCode}
+	book("c4", DefaultResource, workStart.Add(4*time.Hour))
+	_, _, err = storage.ApplyTimeOff(TimeOff{Business: "b1", Interval: off, Cancel: true, Expected: expected})
+	if !errors.Is(err, ErrImpactChanged) {
+		t.Fatalf("changed appointments must be rejected: %v", err)
+	}
+	if err := storage.CancelAppointment("b1", "c4", workStart.Add(4*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	alternative := common.Interval{Start: workStart.Add(48 * time.Hour), End: workStart.Add(49 * time.Hour)}
+	ruleID, affected, err := storage.ApplyTimeOff(TimeOff{
+		Business:     "b1",
+		Interval:     off,
+		Cancel:       true,
+		Expected:     expected,
+		Comment:      "vacation",
+		Alternatives: map[string]common.Intervals{affected[0].BookingCode: {alternative}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ruleID == "" || len(affected) != 2 {
+		t.Fatalf("unexpected time off result: %q %v", ruleID, affected)
+	}
+
+	busy, err := storage.GetBusySlotsInRange("b1", common.Interval{Start: day, End: day.Add(72 * time.Hour)})
+	if err != nil || len(busy) != 1 || busy[0].Customer != "c3" {
+		t.Fatalf("affected appointments must be cancelled: %v %v", busy, err)
+	}
+	available, err = storage.GetAvailableSlotsInRange("b1", off)
+	if err != nil || len(available) != 0 {
+		t.Fatalf("time off must be blocked: %v %v", available, err)
+	}
+
+	notifications, err := storage.GetPendingNotifications("b1", 10)
+	if err != nil || len(notifications) != 2 {
+		t.Fatalf("customers must be notified: %v %v", notifications, err)
+	}
+	var payload appointmentCancelled
+	if err := json.Unmarshal(notifications[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if notifications[0].Kind != NotificationAppointmentCancelled || notifications[0].Customer != "c1" ||
+		payload.Comment != "vacation" || len(payload.Alternatives) != 1 || !payload.Alternatives[0].DateStart.Equal(alternative.Start) {
+		t.Fatalf("unexpected notification: %+v %+v", notifications[0], payload)
+	}
+
+	if _, _, err := storage.ApplyTimeOff(TimeOff{Business: "b1", Interval: common.Interval{Start: off.End, End: off.Start}}); !errors.Is(err, common.ErrInvalidArgument) {
+		t.Fatalf("invalid interval must be rejected: %v", err)
+	}
+	if _, _, err := storage.ApplyTimeOff(TimeOff{Business: "b1", Resource: "unknown", Interval: off}); !errors.Is(err, common.ErrNotFound) {
+		t.Fatalf("unknown resource must be rejected: %v", err)
+	}
+}
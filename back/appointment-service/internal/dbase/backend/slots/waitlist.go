@@ -0,0 +1,118 @@
+package slots
+
+import (
+	"fmt"
+	"time"
+
+	common "scheduler/appointment-service/internal"
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/google/uuid"
+)
+
+// WaitlistEntry is a customer request to be notified when time becomes
+// available in the interval. Empty Service means the business-wide calendar.
+type WaitlistEntry struct {
+	Id        common.ID
+	Business  common.ID
+	Customer  common.ID
+	Service   common.ID
+	Interval  common.Interval
+	CreatedAt time.Time
+}
+
+type dbWaitlistEntry struct {
+	Id         string `db:"id"`
+	Business   string `db:"business_id"`
+	Customer   string `db:"customer_id"`
+	Service    string `db:"service_id"`
+	DateStart  int64  `db:"date_start"`
+	DateEnd    int64  `db:"date_end"`
+	CreatedAt  int64  `db:"created_at"`
+	NotifiedAt int64  `db:"notified_at"`
+}
+
+func (e dbWaitlistEntry) toEntry() WaitlistEntry {
+	return WaitlistEntry{
+		Id:       e.Id,
+		Business: e.Business,
+		Customer: e.Customer,
+		Service:  e.Service,
+		Interval: common.Interval{
+			Start: time.Unix(e.DateStart, 0),
+			End:   time.Unix(e.DateEnd, 0),
+		},
+		CreatedAt: time.Unix(0, e.CreatedAt),
+	}
+}
+
+func (db *TimeSlotsStorage) AddWaitlistEntry(in WaitlistEntry) (common.ID, error) {
+	if in.Customer == "" {
+		return "", fmt.Errorf("customer: %w", common.ErrInvalidArgument)
+	}
+	if !in.Interval.End.After(in.Interval.Start) {
+		return "", fmt.Errorf("waitlist interval: %w", common.ErrInvalidArgument)
+	}
+
+	newID := uuid.New().String()
+	_, err := db.Exec(`INSERT INTO waitlist (id, business_id, customer_id, service_id, date_start, date_end, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		newID, string(in.Business), string(in.Customer), string(in.Service),
+		in.Interval.Start.Unix(), in.Interval.End.Unix(), time.Now().UnixNano())
+	return newID, dbase.DbError(err)
+}
+
+func (db *TimeSlotsStorage) DeleteWaitlistEntry(businessID common.ID, customerID common.ID, id common.ID) error {
+	res, err := db.Exec(`DELETE FROM waitlist WHERE id = $1 AND business_id = $2 AND customer_id = $3`,
+		string(id), string(businessID), string(customerID))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("waitlist entry %s: %w", id, common.ErrNotFound)
+	}
+	return nil
+}
+
+// GetPendingWaitlist returns not notified entries which end after now in FIFO order.
+// No errors if no entries found
+func (db *TimeSlotsStorage) GetPendingWaitlist(businessID common.ID, now time.Time) ([]WaitlistEntry, error) {
+	var rows []dbWaitlistEntry
+	err := db.Select(&rows, `SELECT id, business_id, customer_id, service_id, date_start, date_end, created_at, notified_at
+		FROM waitlist WHERE business_id = $1 AND notified_at = 0 AND date_end > $2
+		ORDER BY created_at, rowid`, string(businessID), now.Unix())
+	if err != nil {
+		return nil, dbase.DbError(err)
+	}
+
+	out := make([]WaitlistEntry, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.toEntry())
+	}
+	return out, nil
+}
+
+// NotifyWaitlistEntry marks the entry as notified and queues the notification for the customer.
+// common.ErrNotFound is returned if the entry was removed or already notified.
+func (db *TimeSlotsStorage) NotifyWaitlistEntry(entry WaitlistEntry, notification Notification) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	res, err := tx.Exec(`UPDATE waitlist SET notified_at = $1 WHERE id = $2 AND notified_at = 0`, now, string(entry.Id))
+	if err != nil {
+		return dbase.DbError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("waitlist entry %s: %w", entry.Id, common.ErrNotFound)
+	}
+
+	if err := addNotification(tx, notification); err != nil {
+		return dbase.DbError(err)
+	}
+
+	return dbase.DbError(tx.Commit())
+}
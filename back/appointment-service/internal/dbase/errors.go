@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	common "scheduler/appointment-service/internal"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 func DbError(e error) error {
@@ -16,3 +18,9 @@ func DbError(e error) error {
 	}
 	return fmt.Errorf("db error: %w", e)
 }
+
+// IsConstraintError reports whether e is caused by UNIQUE or other constraint violation
+func IsConstraintError(e error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(e, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint
+}
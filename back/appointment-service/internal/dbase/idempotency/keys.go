@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"time"
+
+	"scheduler/appointment-service/internal/dbase"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IdempotencyStorage struct {
+	*sqlx.DB
+}
+
+// Entry is a request seen with the idempotency key. Status is 0 while the request is processed.
+type Entry struct {
+	Key         string `db:"key"`
+	Scope       string `db:"scope"`
+	Fingerprint string `db:"fingerprint"`
+	Status      int    `db:"status"`
+	ContentType string `db:"content_type"`
+	Body        []byte `db:"body"`
+	ExpiresAt   int64  `db:"expires_at"`
+}
+
+func (e Entry) IsPending() bool {
+	return e.Status == 0
+}
+
+// Begin reserves the key for the request. If the key is already used and not expired,
+// the existing entry is returned with started=false.
+func (db *IdempotencyStorage) Begin(key, scope, fingerprint string, expiresAt time.Time) (entry Entry, started bool, err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return entry, false, dbase.DbError(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM idempotency_keys WHERE key = $1 AND scope = $2 AND expires_at <= $3`,
+		key, scope, time.Now().Unix())
+	if err != nil {
+		return entry, false, dbase.DbError(err)
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO idempotency_keys (key, scope, fingerprint, expires_at)
+			VALUES ($1, $2, $3, $4)
+		ON CONFLICT(key, scope) DO NOTHING`, key, scope, fingerprint, expiresAt.Unix())
+	if err != nil {
+		return entry, false, dbase.DbError(err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 1 {
+		if err = tx.Commit(); err != nil {
+			return entry, false, dbase.DbError(err)
+		}
+		return Entry{Key: key, Scope: scope, Fingerprint: fingerprint, ExpiresAt: expiresAt.Unix()}, true, nil
+	}
+
+	err = tx.Get(&entry, `SELECT * FROM idempotency_keys WHERE key = $1 AND scope = $2`, key, scope)
+	return entry, false, dbase.DbError(err)
+}
+
+// Complete stores the response of the request to replay it
+func (db *IdempotencyStorage) Complete(key, scope string, status int, contentType string, body []byte) error {
+	_, err := db.Exec(
+		`UPDATE idempotency_keys SET status = $1, content_type = $2, body = $3
+		WHERE key = $4 AND scope = $5`, status, contentType, body, key, scope)
+	return dbase.DbError(err)
+}
+
+// Release frees the key of the failed request, so it can be retried
+func (db *IdempotencyStorage) Release(key, scope string) error {
+	_, err := db.Exec(`DELETE FROM idempotency_keys WHERE key = $1 AND scope = $2`, key, scope)
+	return dbase.DbError(err)
+}
+
+func (db *IdempotencyStorage) CleanupExpired() (int64, error) {
+	res, err := db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= $1`, time.Now().Unix())
+	if err != nil {
+		return 0, dbase.DbError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
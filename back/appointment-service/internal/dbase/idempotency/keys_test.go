@@ -0,0 +1,60 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"scheduler/appointment-service/internal/dbase/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	st := IdempotencyStorage{test.InitTmpDB(t)}
+	expiresAt := time.Now().Add(time.Hour)
+
+	entry, started, err := st.Begin("k1", "POST /slots", "f1", expiresAt)
+	require.NoError(t, err)
+	assert.True(t, started)
+	assert.True(t, entry.IsPending())
+
+	// Concurrent repeat sees the pending request
+	entry, started, err = st.Begin("k1", "POST /slots", "f1", expiresAt)
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.True(t, entry.IsPending())
+
+	// The same key of another endpoint is independent
+	_, started, err = st.Begin("k1", "POST /slots/bt", "f2", expiresAt)
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	require.NoError(t, st.Complete("k1", "POST /slots", 200, "application/json", []byte(`{"ok":true}`)))
+	entry, started, err = st.Begin("k1", "POST /slots", "f1", expiresAt)
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.Equal(t, 200, entry.Status)
+	assert.Equal(t, "f1", entry.Fingerprint)
+	assert.Equal(t, `{"ok":true}`, string(entry.Body))
+
+	// Released key is reserved again
+	require.NoError(t, st.Release("k1", "POST /slots/bt"))
+	_, started, err = st.Begin("k1", "POST /slots/bt", "f3", expiresAt)
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	// Expired key is reserved by the next request
+	_, started, err = st.Begin("k2", "POST /slots", "f1", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	assert.True(t, started)
+	_, started, err = st.Begin("k2", "POST /slots", "f2", expiresAt)
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	_, _, err = st.Begin("k3", "POST /slots", "f1", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	n, err := st.CleanupExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,59 @@
+package dbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"scheduler/appointment-service/migrations"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+)
+
+// LatestMigration returns the version of the last embedded migration
+func LatestMigration() (uint, error) {
+	src, err := iofs.New(migrations.MigrationFiles, ".")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// SchemaVersion returns the migration version applied to the database.
+// The schema is dirty if the last migration failed.
+func SchemaVersion(ctx context.Context, db *sqlx.DB) (version uint, dirty bool, err error) {
+	err = db.QueryRowxContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	return version, dirty, DbError(err)
+}
+
+// CheckSchema returns an error if migrations up to the latest one are not applied
+func CheckSchema(ctx context.Context, db *sqlx.DB, latest uint) error {
+	version, dirty, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version != latest {
+		return fmt.Errorf("schema version %d, expected %d", version, latest)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package dbase
+
+import (
+	"context"
+	"testing"
+
+	"scheduler/appointment-service/internal/dbase/test"
+)
+
+func TestCheckSchema(t *testing.T) {
+	db := test.InitTmpDB(t)
+	latest, err := LatestMigration()
+	if err != nil || latest == 0 {
+		t.Fatalf("latest migration expected: %d %v", latest, err)
+	}
+
+	ctx := context.Background()
+	if err := CheckSchema(ctx, db, latest); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchema(ctx, db, latest+1); err == nil {
+		t.Fatal("pending migration must be reported")
+	}
+	if _, err := db.Exec(`UPDATE schema_migrations SET dirty = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchema(ctx, db, latest); err == nil {
+		t.Fatal("dirty schema must be reported")
+	}
+}
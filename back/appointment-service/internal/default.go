@@ -6,4 +6,36 @@ const (
 	DefaultBookingSlotChunk    = 15 * time.Minute
 	DefaultMaxBookingSlotChunk = 1 * time.Hour
 	MinBookingSlotChunk        = 5 * time.Minute
+
+	DefaultSlotHoldTTL    = 5 * time.Minute
+	SlotHoldSweepInterval = 1 * time.Minute
+
+	DefaultWaitlistLinkTTL = 30 * time.Minute
+
+	DefaultApprovalTimeout = 24 * time.Hour
+
+	RuleCoverageCheckInterval = 1 * time.Hour
+
+	// Responses of requests with Idempotency-Key are replayed within this TTL
+	DefaultIdempotencyTTL    = 24 * time.Hour
+	IdempotencySweepInterval = 1 * time.Hour
+
+	// Next slots are searched within this horizon if the business policy has no MaxHorizon
+	DefaultNextSlotsHorizon = 90 * 24 * time.Hour
+
+	MaxSeriesOccurrences = 104
+
+	// Slots are listed for date ranges up to this span, a business may lower it
+	MaxSlotsQuerySpan = 92 * 24 * time.Hour
+	// Slots are returned in pages of the requested size up to MaxSlotsPageSize
+	DefaultSlotsPageSize = 1000
+	MaxSlotsPageSize     = 5000
+
+	// Timeouts of the HTTP server if they are not configured
+	DefaultServerReadTimeout       = 15 * time.Second
+	DefaultServerReadHeaderTimeout = 5 * time.Second
+	DefaultServerWriteTimeout      = 30 * time.Second
+	DefaultServerIdleTimeout       = 2 * time.Minute
+	// In-flight requests are drained within this timeout on shutdown
+	DefaultServerShutdownTimeout = 30 * time.Second
 )
@@ -101,6 +101,21 @@ func (intervals Intervals) HasOverlaps() bool {
 	return false
 }
 
+// JoinAdjacent returns the copy where each run of intervals ending where the next one starts
+// is joined into one interval.
+// Note: Expected sorted slice
+func (intervals Intervals) JoinAdjacent() Intervals {
+	out := make(Intervals, 0, len(intervals))
+	for _, el := range intervals {
+		if last := len(out) - 1; last >= 0 && out[last].End.Equal(el.Start) {
+			out[last].End = el.End
+			continue
+		}
+		out = append(out, el)
+	}
+	return out
+}
+
 func (intervals Intervals) IsFit(other Interval) bool {
 	for i := 0; i < len(intervals); i++ {
 		if intervals[i].IsFit(other) {
@@ -262,6 +277,25 @@ func ChunkIntervals(intervals Intervals, chunkSize time.Duration) Intervals {
 	return result
 }
 
+// StepsBetween counts starts i.Start + k*step, k >= 0, which leave room for length
+// within the interval and lie in [from, to). Starts are computed, not enumerated.
+// first and last are the earliest and the latest of counted starts.
+func (i Interval) StepsBetween(step, length time.Duration, from, to time.Time) (n int, first, last time.Time) {
+	if step <= 0 || !to.After(i.Start) || i.Duration() < length {
+		return 0, time.Time{}, time.Time{}
+	}
+
+	kMin := time.Duration(0)
+	if from.After(i.Start) {
+		kMin = (from.Sub(i.Start) + step - 1) / step
+	}
+	kMax := min((i.Duration()-length)/step, (to.Sub(i.Start)-1)/step)
+	if kMin > kMax {
+		return 0, time.Time{}, time.Time{}
+	}
+	return int(kMax - kMin + 1), i.Start.Add(kMin * step), i.Start.Add(kMax * step)
+}
+
 func (i Interval) ToSlot() Slot {
 	return Slot{Start: i.Start, Dur: i.End.Sub(i.Start)}
 }
@@ -113,13 +113,30 @@ func (i *IntervalType) UnmarshalJSON(in []byte) error {
 	return nil
 }
 
+// Capacity greater than 1 turns inclusion rule into group sessions:
+// every occurrence is booked as a whole by up to Capacity customers
 type IntervalRRuleWithType struct {
-	Rule IntervalRRule
-	Type IntervalType
+	Rule     IntervalRRule
+	Type     IntervalType
+	Capacity int `json:",omitempty"`
 }
 
 func (v1 IntervalRRuleWithType) Equal(v2 IntervalRRuleWithType) bool {
-	return v1.Rule.Equal(v2.Rule) && v1.Type == v2.Type
+	return v1.Rule.Equal(v2.Rule) && v1.Type == v2.Type && v1.Capacity == v2.Capacity
+}
+
+func (v IntervalRRuleWithType) IsGroup() bool {
+	return v.Capacity > 1
+}
+
+func (v IntervalRRuleWithType) Validate() error {
+	if v.Capacity < 0 {
+		return fmt.Errorf("%w: negative capacity", ErrInvalidArgument)
+	}
+	if v.IsGroup() && v.Type != Inclusion {
+		return fmt.Errorf("%w: capacity is allowed only for inclusion", ErrInvalidArgument)
+	}
+	return nil
 }
 
 func CalculateIntervals(in []IntervalRRuleWithType) Intervals {
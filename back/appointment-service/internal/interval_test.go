@@ -151,6 +151,12 @@ func TestIntervalSlices(t *testing.T) {
 	if intervals.HasOverlaps() {
 		t.Fatalf("intervals should has overlap %v", intervals)
 	}
+	if joined := intervals.JoinAdjacent(); len(joined) != 1 || !joined[0].Start.Equal(start) || !joined[0].End.Equal(start.Add(10*time.Minute)) {
+		t.Fatalf("adjacent intervals should be joined %v", joined)
+	}
+	if joined := slices.Delete(intervals.Copy(), 3, 4).JoinAdjacent(); len(joined) != 2 || !joined[0].End.Equal(start.Add(3*time.Minute)) || !joined[1].Start.Equal(start.Add(4*time.Minute)) {
+		t.Fatalf("intervals with gap should be joined into two %v", joined)
+	}
 
 	intervals = append(intervals, intervals[0])
 	if intervals.IsSorted() {
@@ -399,3 +405,32 @@ func TestChunkIntervalsSkipsShortAndInvalidIntervals(t *testing.T) {
 		t.Fatalf("unexpected second chunk: %v", got[1])
 	}
 }
+
+func TestStepsBetween(t *testing.T) {
+	start := time.Date(2024, 10, 10, 9, 0, 0, 0, time.UTC)
+	interval := Interval{Start: start, End: start.Add(2*time.Hour + 10*time.Minute)}
+
+	for _, step := range []time.Duration{15 * time.Minute, 20 * time.Minute, time.Hour} {
+		for _, from := range []time.Time{start.Add(-time.Hour), start, start.Add(7 * time.Minute), start.Add(time.Hour)} {
+			to := start.Add(100 * time.Minute)
+			var want []time.Time
+			for _, chunk := range ChunkIntervals(Intervals{interval}, step) {
+				if !chunk.Start.Before(from) && chunk.Start.Before(to) {
+					want = append(want, chunk.Start)
+				}
+			}
+
+			n, first, last := interval.StepsBetween(step, step, from, to)
+			if n != len(want) {
+				t.Fatalf("step %v from %v: expected %d starts, got %d", step, from, len(want), n)
+			}
+			if n != 0 && (!first.Equal(want[0]) || !last.Equal(want[n-1])) {
+				t.Fatalf("step %v from %v: unexpected bounds %v %v", step, from, first, last)
+			}
+		}
+	}
+
+	if n, _, _ := interval.StepsBetween(15*time.Minute, 3*time.Hour, start, interval.End); n != 0 {
+		t.Fatalf("interval is shorter than length: %d", n)
+	}
+}
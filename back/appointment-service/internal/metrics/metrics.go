@@ -0,0 +1,110 @@
+// Package metrics has Prometheus metrics of the service, exposed by Handler
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "scheduler"
+
+// Booking outcomes
+const (
+	BookingBooked   = "booked"
+	BookingPending  = "pending"
+	BookingConflict = "conflict"
+	BookingRejected = "rejected"
+	BookingFailed   = "failed"
+)
+
+// Token cache lookup results
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route name, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by route name, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	bookings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bookings_total",
+		Help:      "Booking attempts by route name and outcome.",
+	}, []string{"route", "outcome"})
+
+	availabilityDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "availability_computation_seconds",
+		Help:      "Time of availability computation by query.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"query"})
+
+	tokenCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_cache_lookups_total",
+		Help:      "Lookups of the bot token cache by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		bookings,
+		availabilityDuration,
+		tokenCacheLookups,
+	)
+}
+
+// Handler exposes metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exposes pool stats of the database
+func RegisterDB(name string, db *sql.DB) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveRequest counts the served request
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	s := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, s).Inc()
+	httpRequestDuration.WithLabelValues(route, method, s).Observe(duration.Seconds())
+}
+
+// ObserveBooking counts the booking attempt
+func ObserveBooking(route, outcome string) {
+	bookings.WithLabelValues(route, outcome).Inc()
+}
+
+// AvailabilityTimer measures availability computation of the query
+func AvailabilityTimer(query string) *prometheus.Timer {
+	return prometheus.NewTimer(availabilityDuration.WithLabelValues(query))
+}
+
+// ObserveTokenCache counts the lookup of the token cache, CacheHit or CacheMiss
+func ObserveTokenCache(result string) {
+	tokenCacheLookups.WithLabelValues(result).Inc()
+}
@@ -10,6 +10,7 @@ type PeriodicCallback struct {
 	callback func()
 	ticker   *time.Ticker
 	stopChan chan struct{}
+	done     chan struct{}
 
 	mu sync.Mutex
 }
@@ -31,19 +32,23 @@ func (p *PeriodicCallback) Start() {
 
 	p.ticker = time.NewTicker(p.interval)
 	p.stopChan = make(chan struct{})
+	p.done = make(chan struct{})
 
+	ticker, stop, done := p.ticker, p.stopChan, p.done
 	go func() {
+		defer close(done)
 		for {
 			select {
-			case <-p.ticker.C:
+			case <-ticker.C:
 				p.callback()
-			case <-p.stopChan:
+			case <-stop:
 				return
 			}
 		}
 	}()
 }
 
+// Stop stops the ticker and waits for the running callback to return
 func (p *PeriodicCallback) Stop() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -54,6 +59,8 @@ func (p *PeriodicCallback) Stop() {
 
 	p.ticker.Stop()
 	close(p.stopChan)
+	<-p.done
 	p.ticker = nil
 	p.stopChan = nil
+	p.done = nil
 }
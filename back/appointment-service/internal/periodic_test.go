@@ -0,0 +1,28 @@
+package common
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPeriodicCallbackStopWaitsForCallback(t *testing.T) {
+	var running, finished atomic.Bool
+	p := NewPeriodicCallback(time.Millisecond, func() {
+		running.Store(true)
+		time.Sleep(20 * time.Millisecond)
+		finished.Store(true)
+	})
+	p.Start()
+	for !running.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	p.Stop()
+	if !finished.Load() {
+		t.Fatal("Stop returned before the callback")
+	}
+	p.Stop()
+
+	p.Start()
+	p.Stop()
+}
@@ -2,13 +2,18 @@ package common
 
 import (
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
-// TODO remove not needed keys function
+type limitEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
 type LimitsTable[Key comparable] struct {
-	m        map[Key]*rate.Limiter
+	m        map[Key]*limitEntry
 	mu       sync.Mutex
 	producer RequestLimitUpdate
 }
@@ -23,31 +28,80 @@ func (fn RequestLimitUpdateFunc) Update(in *rate.Limiter) *rate.Limiter {
 	return fn(in)
 }
 
+// LimitResult is the state of the key limiter after the request
+type LimitResult struct {
+	Allowed bool
+	// Limit is the burst size of the limiter
+	Limit     int
+	Remaining int
+	// RetryAfter is the delay until the refused request is allowed
+	RetryAfter time.Duration
+	// Reset is the delay until the limiter is full again
+	Reset time.Duration
+}
+
 func (l *LimitsTable[Key]) Allow(k Key) bool {
+	return l.Take(k, time.Now()).Allowed
+}
+
+// Take spends one token of the key limiter if it is available
+func (l *LimitsTable[Key]) Take(k Key, now time.Time) LimitResult {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	limiter, exists := l.m[k]
+	entry, exists := l.m[k]
 	if !exists {
-		limiter = l.producer.Update(nil)
-		l.m[k] = limiter
+		entry = &limitEntry{limiter: l.producer.Update(nil)}
+		l.m[k] = entry
+	}
+	entry.lastSeen = now
+	limiter := entry.limiter
+
+	result := LimitResult{Limit: limiter.Burst()}
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+		reservation.CancelAt(now)
+		result.RetryAfter = delay
+	} else {
+		result.Allowed = true
+	}
+
+	tokens := limiter.TokensAt(now)
+	result.Remaining = max(int(tokens), 0)
+	if limit := limiter.Limit(); limit > 0 && limit != rate.Inf {
+		result.Reset = time.Duration((float64(result.Limit) - tokens) / float64(limit) * float64(time.Second))
+	}
+	return result
+}
+
+// ForgetIdle removes limiters of keys not used for ttl. The ttl should be long enough
+// to refill limiters, otherwise forgotten keys get the full burst earlier.
+func (l *LimitsTable[Key]) ForgetIdle(now time.Time, ttl time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for k, entry := range l.m {
+		if now.Sub(entry.lastSeen) >= ttl {
+			delete(l.m, k)
+			n++
+		}
 	}
-	//TODO add info about needed delay?
-	return limiter.Allow()
+	return n
 }
 
 func (l *LimitsTable[Key]) SetProducer(p RequestLimitUpdate) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	for _, limiter := range l.m {
-		l.producer.Update(limiter)
+	for _, entry := range l.m {
+		l.producer.Update(entry.limiter)
 	}
 	l.producer = p
 }
 
 func NewLimitsTable[Key comparable](p RequestLimitUpdate) *LimitsTable[Key] {
 	return &LimitsTable[Key]{
-		m:        make(map[Key]*rate.Limiter),
+		m:        make(map[Key]*limitEntry),
 		producer: p}
 }
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestLimitsTable(t *testing.T) {
+	table := NewLimitsTable[string](RequestLimitUpdateFunc(func(*rate.Limiter) *rate.Limiter {
+		return rate.NewLimiter(rate.Every(time.Second), 2)
+	}))
+	now := time.Now()
+
+	for i, remaining := range []int{1, 0} {
+		r := table.Take("a", now)
+		if !r.Allowed || r.Limit != 2 || r.Remaining != remaining {
+			t.Fatalf("request %d: unexpected result %+v", i, r)
+		}
+	}
+	r := table.Take("a", now)
+	if r.Allowed || r.RetryAfter != time.Second || r.Reset != 2*time.Second {
+		t.Fatalf("refused request expected: %+v", r)
+	}
+	if r := table.Take("b", now); !r.Allowed {
+		t.Fatalf("keys are independent: %+v", r)
+	}
+	if r := table.Take("a", now.Add(time.Second)); !r.Allowed {
+		t.Fatalf("token is refilled: %+v", r)
+	}
+
+	if n := table.ForgetIdle(now.Add(time.Minute), time.Minute); n != 1 {
+		t.Fatalf("only idle key b expected, forgot %d", n)
+	}
+	if n := table.ForgetIdle(now.Add(2*time.Minute), time.Minute); n != 1 {
+		t.Fatalf("key a expected, forgot %d", n)
+	}
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+type PickStrategy string
+
+const (
+	PickLeastLoaded PickStrategy = "least_loaded"
+	PickRoundRobin  PickStrategy = "round_robin"
+)
+
+func ParsePickStrategy(s string) (PickStrategy, error) {
+	switch PickStrategy(s) {
+	case "":
+		return PickLeastLoaded, nil
+	case PickLeastLoaded, PickRoundRobin:
+		return PickStrategy(s), nil
+	}
+	return "", fmt.Errorf("%w: pick strategy %q", ErrInvalidArgument, s)
+}
+
+// ResourcePicker chooses one of the resources able to take a booking.
+// Round-robin position is kept in memory per key (usually business + service).
+type ResourcePicker struct {
+	mu   sync.Mutex
+	last map[string]ID
+}
+
+func NewResourcePicker() *ResourcePicker {
+	return &ResourcePicker{last: make(map[string]ID)}
+}
+
+// Pick returns one of candidates. For PickLeastLoaded the resource with the
+// smallest load is selected, ties are resolved by resource ID order.
+// For PickRoundRobin the resource following the previously picked one is selected.
+func (p *ResourcePicker) Pick(key string, strategy PickStrategy, candidates []ID, load map[ID]time.Duration) (ID, error) {
+	if len(candidates) == 0 {
+		return "", ErrNotFound
+	}
+
+	sorted := slices.Clone(candidates)
+	slices.Sort(sorted)
+
+	switch strategy {
+	case PickLeastLoaded:
+		best := sorted[0]
+		for _, c := range sorted[1:] {
+			if load[c] < load[best] {
+				best = c
+			}
+		}
+		return best, nil
+	case PickRoundRobin:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		last, ok := p.last[key]
+		picked := sorted[0]
+		if ok {
+			for _, c := range sorted {
+				if c > last {
+					picked = c
+					break
+				}
+			}
+		}
+		p.last[key] = picked
+		return picked, nil
+	default:
+		return "", fmt.Errorf("%w: pick strategy %q", ErrInvalidArgument, strategy)
+	}
+}
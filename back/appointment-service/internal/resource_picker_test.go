@@ -0,0 +1,73 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResourcePickerLeastLoaded(t *testing.T) {
+	p := NewResourcePicker()
+
+	got, err := p.Pick("b", PickLeastLoaded, []ID{"c", "a", "b"}, map[ID]time.Duration{
+		"a": time.Hour,
+		"b": 30 * time.Minute,
+		"c": 30 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "b" {
+		t.Fatalf("expected b, got %v", got)
+	}
+
+	got, err = p.Pick("b", PickLeastLoaded, []ID{"c", "a"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "a" {
+		t.Fatalf("expected a, got %v", got)
+	}
+}
+
+func TestResourcePickerRoundRobin(t *testing.T) {
+	p := NewResourcePicker()
+	candidates := []ID{"b", "c", "a"}
+
+	var got []ID
+	for range 4 {
+		r, err := p.Pick("k", PickRoundRobin, candidates, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+
+	expected := []ID{"a", "b", "c", "a"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("unexpected order: %v", got)
+		}
+	}
+
+	// Busy resource is skipped, order continues after it
+	r, err := p.Pick("k", PickRoundRobin, []ID{"a", "c"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != "c" {
+		t.Fatalf("expected c, got %v", r)
+	}
+}
+
+func TestResourcePickerErrors(t *testing.T) {
+	p := NewResourcePicker()
+	if _, err := p.Pick("k", PickRoundRobin, nil, nil); err == nil {
+		t.Fatal("expected error for empty candidates")
+	}
+	if _, err := ParsePickStrategy("random"); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+	if s, err := ParsePickStrategy(""); err != nil || s != PickLeastLoaded {
+		t.Fatalf("unexpected default strategy %v %v", s, err)
+	}
+}
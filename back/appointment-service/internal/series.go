@@ -0,0 +1,82 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// SeriesRule describes recurring appointments of a customer. RRule sets recurrence
+// only ("FREQ=WEEKLY;BYDAY=MO"), the series is limited by exactly one of Count and Until.
+type SeriesRule struct {
+	RRule string
+	Start time.Time
+	Len   time.Duration
+	Count int
+	Until time.Time
+}
+
+func (s SeriesRule) Validate() error {
+	if s.Start.IsZero() {
+		return fmt.Errorf("%w: series start is not set", ErrInvalidArgument)
+	}
+	if s.Len <= 0 {
+		return fmt.Errorf("%w: series appointment length must be positive", ErrInvalidArgument)
+	}
+	if (s.Count == 0) == s.Until.IsZero() {
+		return fmt.Errorf("%w: exactly one of count and until must be set", ErrInvalidArgument)
+	}
+	if s.Count < 0 || s.Count > MaxSeriesOccurrences {
+		return fmt.Errorf("%w: count must be in range [1, %d]", ErrInvalidArgument, MaxSeriesOccurrences)
+	}
+	if !s.Until.IsZero() && s.Until.Before(s.Start) {
+		return fmt.Errorf("%w: until is before start", ErrInvalidArgument)
+	}
+	upper := strings.ToUpper(s.RRule)
+	for _, part := range []string{"COUNT=", "UNTIL=", "DTSTART"} {
+		if strings.Contains(upper, part) {
+			return fmt.Errorf("%w: rrule must not contain %s", ErrInvalidArgument, strings.TrimSuffix(part, "="))
+		}
+	}
+	return nil
+}
+
+// Occurrences returns intervals of all appointments of the series sorted by start
+func (s SeriesRule) Occurrences() (Intervals, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	opt, err := rrule.StrToROption(s.RRule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: rrule: %s", ErrInvalidArgument, err.Error())
+	}
+	opt.Dtstart = s.Start
+	opt.Count = s.Count
+	opt.Until = s.Until
+	if opt.Count == 0 {
+		// Detect too long series without iterating all of it
+		opt.Count = MaxSeriesOccurrences + 1
+	}
+
+	rule, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: rrule: %s", ErrInvalidArgument, err.Error())
+	}
+
+	starts := rule.All()
+	if len(starts) == 0 {
+		return nil, fmt.Errorf("%w: series has no occurrences", ErrInvalidArgument)
+	}
+	if len(starts) > MaxSeriesOccurrences {
+		return nil, fmt.Errorf("%w: series has more than %d occurrences", ErrInvalidArgument, MaxSeriesOccurrences)
+	}
+
+	out := make(Intervals, 0, len(starts))
+	for _, start := range starts {
+		out = append(out, Interval{Start: start, End: start.Add(s.Len)})
+	}
+	return out, nil
+}
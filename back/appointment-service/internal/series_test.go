@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesRuleValidate(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	valid := SeriesRule{RRule: "FREQ=WEEKLY;BYDAY=MO", Start: start, Len: time.Hour, Count: 4}
+	assert.NoError(t, valid.Validate())
+
+	for _, rule := range []SeriesRule{
+		{RRule: "FREQ=WEEKLY", Start: start, Len: time.Hour},
+		{RRule: "FREQ=WEEKLY", Start: start, Len: time.Hour, Count: 2, Until: start.Add(24 * time.Hour)},
+		{RRule: "FREQ=WEEKLY", Start: start, Len: time.Hour, Count: MaxSeriesOccurrences + 1},
+		{RRule: "FREQ=WEEKLY", Start: start, Len: time.Hour, Until: start.Add(-time.Hour)},
+		{RRule: "FREQ=WEEKLY;COUNT=3", Start: start, Len: time.Hour, Count: 3},
+		{RRule: "FREQ=WEEKLY", Start: start, Count: 3},
+		{RRule: "FREQ=WEEKLY", Len: time.Hour, Count: 3},
+	} {
+		assert.ErrorIs(t, rule.Validate(), ErrInvalidArgument, rule)
+	}
+}
+
+func TestSeriesRuleOccurrences(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	occurrences, err := SeriesRule{RRule: "FREQ=WEEKLY;BYDAY=MO,TH", Start: start, Len: time.Hour, Count: 3}.Occurrences()
+	assert.NoError(t, err)
+	assert.Equal(t, Intervals{
+		{Start: start, End: start.Add(time.Hour)},
+		{Start: start.AddDate(0, 0, 3), End: start.AddDate(0, 0, 3).Add(time.Hour)},
+		{Start: start.AddDate(0, 0, 7), End: start.AddDate(0, 0, 7).Add(time.Hour)},
+	}, occurrences)
+
+	occurrences, err = SeriesRule{RRule: "FREQ=WEEKLY", Start: start, Len: time.Hour, Until: start.AddDate(0, 0, 21)}.Occurrences()
+	assert.NoError(t, err)
+	assert.Len(t, occurrences, 4)
+
+	_, err = SeriesRule{RRule: "FREQ=DAILY", Start: start, Len: time.Hour, Until: start.AddDate(1, 0, 0)}.Occurrences()
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	_, err = SeriesRule{RRule: "FREQ=SOMETIMES", Start: start, Len: time.Hour, Count: 2}.Occurrences()
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
@@ -0,0 +1,105 @@
+// Package tracing correlates requests between the bot and the service: request IDs
+// passed in X-Request-ID and W3C trace context passed in traceparent, optionally
+// exported to an OpenTelemetry collector
+package tracing
+
+import (
+	"context"
+	"log/slog"
+
+	common "scheduler/appointment-service/internal"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID between processes and is echoed in responses
+const RequestIDHeader = "X-Request-ID"
+
+// Incoming request IDs longer than this are replaced
+const maxRequestIDLen = 128
+
+const tracerName = "scheduler/appointment-service"
+
+// Config of the exporter, spans are not exported if OTLPEndpoint is empty
+type Config struct {
+	// OTLP/HTTP endpoint of the collector, e.g. http://localhost:4318
+	OTLPEndpoint string `cfg:"otlp_endpoint"`
+}
+
+type RequestIDKey struct{}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// ValidRequestID reports whether the incoming ID can be used as is. IDs are logged,
+// so only short IDs of letters, digits and a few separators are accepted.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns ctx with the request ID, it is also added to log records
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, RequestIDKey{}, id)
+	return common.AppendSlogCtx(ctx, slog.String("request_id", id))
+}
+
+// RequestID returns the request ID of ctx, empty if it is not set
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey{}).(string)
+	return id
+}
+
+// Start starts a span. The trace ID is added to log records if the span is the first
+// one of the trace in this process.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := trace.SpanContextFromContext(ctx)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, opts...)
+	if sc := span.SpanContext(); sc.IsValid() && (!parent.IsValid() || parent.IsRemote()) {
+		ctx = common.AppendSlogCtx(ctx, slog.String("trace_id", sc.TraceID().String()))
+	}
+	if id := RequestID(ctx); id != "" {
+		span.SetAttributes(attribute.String("request_id", id))
+	}
+	return ctx, span
+}
+
+// Setup installs the W3C trace context propagator and the tracer provider of the service.
+// Trace IDs are generated and propagated even if the exporter is not configured.
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, serviceName string, cfg Config) (func(context.Context) error, error) {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	}
+	if cfg.OTLPEndpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
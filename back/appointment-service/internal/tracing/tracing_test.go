@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"":                                     false,
+		"0b6f2a3e-8d6c-4bd4-9c2e-0f3b1f1e6a11": true,
+		"bot:42/update.7":                      true,
+		"with space":                           false,
+		"new\nline":                            false,
+		strings.Repeat("a", maxRequestIDLen):   true,
+		strings.Repeat("a", maxRequestIDLen+1): false,
+	} {
+		if ValidRequestID(id) != valid {
+			t.Errorf("ValidRequestID(%q) != %v", id, valid)
+		}
+	}
+	if !ValidRequestID(NewRequestID()) {
+		t.Error("generated request ID must be valid")
+	}
+}
+
+func TestTransportPassesRequestIDAndTraceContext(t *testing.T) {
+	shutdown, err := Setup(context.Background(), "test", Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	ctx, span := Start(WithRequestID(context.Background(), "update-1"), "test")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: Transport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Get(RequestIDHeader) != "update-1" {
+		t.Errorf("request ID expected, got %q", got.Get(RequestIDHeader))
+	}
+	traceID := span.SpanContext().TraceID().String()
+	if tp := got.Get("traceparent"); !strings.Contains(tp, traceID) {
+		t.Errorf("traceparent of trace %s expected, got %q", traceID, tp)
+	}
+	if req.Header.Get(RequestIDHeader) != "" {
+		t.Error("original request must not be modified")
+	}
+}
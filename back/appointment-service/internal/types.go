@@ -4,7 +4,11 @@ type ID = string
 
 type BusySlot struct {
 	Customer ID
+	Resource ID
 	Interval
+	// BookingCode identifies the appointment at check-in
+	BookingCode string
+	Attendance  Attendance
 }
 
 type Appointment struct {
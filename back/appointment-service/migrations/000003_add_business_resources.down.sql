@@ -0,0 +1,19 @@
+CREATE TABLE appointments_old (
+	date_start	  INTEGER NOT NULL,
+	date_end	  INTEGER NOT NULL,
+	business_id   TEXT NOT NULL,
+	customer_id	  TEXT NOT NULL,
+	UNIQUE (business_id, date_start)
+);
+
+INSERT OR IGNORE INTO appointments_old (date_start, date_end, business_id, customer_id)
+SELECT date_start, date_end, business_id, customer_id FROM appointments;
+
+DROP TABLE appointments;
+ALTER TABLE appointments_old RENAME TO appointments;
+
+ALTER TABLE business_work_rule DROP COLUMN resource_id;
+
+DROP TABLE service_resources;
+DROP TABLE business_services;
+DROP TABLE business_resources;
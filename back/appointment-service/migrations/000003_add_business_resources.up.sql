@@ -0,0 +1,35 @@
+CREATE TABLE business_resources (
+    id              TEXT PRIMARY KEY,
+    business_id     TEXT NOT NULL,
+    name            TEXT NOT NULL
+);
+
+CREATE TABLE business_services (
+    id              TEXT PRIMARY KEY,
+    business_id     TEXT NOT NULL,
+    name            TEXT NOT NULL
+);
+
+CREATE TABLE service_resources (
+    service_id      TEXT NOT NULL,
+    resource_id     TEXT NOT NULL,
+    PRIMARY KEY (service_id, resource_id)
+);
+
+-- Empty resource_id is the business-wide calendar
+ALTER TABLE business_work_rule ADD COLUMN resource_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE appointments_new (
+	date_start	  INTEGER NOT NULL,
+	date_end	  INTEGER NOT NULL,
+	business_id   TEXT NOT NULL,
+	customer_id	  TEXT NOT NULL,
+	resource_id   TEXT NOT NULL DEFAULT '',
+	UNIQUE (business_id, resource_id, date_start)
+);
+
+INSERT INTO appointments_new (date_start, date_end, business_id, customer_id)
+SELECT date_start, date_end, business_id, customer_id FROM appointments;
+
+DROP TABLE appointments;
+ALTER TABLE appointments_new RENAME TO appointments;
@@ -0,0 +1,14 @@
+CREATE TABLE appointments_old (
+	date_start	  INTEGER NOT NULL,
+	date_end	  INTEGER NOT NULL,
+	business_id   TEXT NOT NULL,
+	customer_id	  TEXT NOT NULL,
+	resource_id   TEXT NOT NULL DEFAULT '',
+	UNIQUE (business_id, resource_id, date_start)
+);
+
+INSERT OR IGNORE INTO appointments_old (date_start, date_end, business_id, customer_id, resource_id)
+SELECT date_start, date_end, business_id, customer_id, resource_id FROM appointments;
+
+DROP TABLE appointments;
+ALTER TABLE appointments_old RENAME TO appointments;
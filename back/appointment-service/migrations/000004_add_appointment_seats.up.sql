@@ -0,0 +1,16 @@
+CREATE TABLE appointments_new (
+	date_start	  INTEGER NOT NULL,
+	date_end	  INTEGER NOT NULL,
+	business_id   TEXT NOT NULL,
+	customer_id	  TEXT NOT NULL,
+	resource_id   TEXT NOT NULL DEFAULT '',
+	seat          INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (business_id, resource_id, date_start, seat),
+	UNIQUE (business_id, resource_id, date_start, customer_id)
+);
+
+INSERT INTO appointments_new (date_start, date_end, business_id, customer_id, resource_id)
+SELECT date_start, date_end, business_id, customer_id, resource_id FROM appointments;
+
+DROP TABLE appointments;
+ALTER TABLE appointments_new RENAME TO appointments;
@@ -0,0 +1,13 @@
+CREATE TABLE slot_holds (
+	token         TEXT NOT NULL,
+	business_id   TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	resource_id   TEXT NOT NULL DEFAULT '',
+	date_start    INTEGER NOT NULL,
+	date_end      INTEGER NOT NULL,
+	expires_at    INTEGER NOT NULL,
+	PRIMARY KEY (token, date_start)
+);
+
+CREATE INDEX slot_holds_business_idx ON slot_holds (business_id, date_start);
+CREATE INDEX slot_holds_expires_idx ON slot_holds (expires_at);
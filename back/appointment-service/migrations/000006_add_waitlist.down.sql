@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_notifications;
+DROP TABLE IF EXISTS waitlist;
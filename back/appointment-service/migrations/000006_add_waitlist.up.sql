@@ -0,0 +1,24 @@
+CREATE TABLE waitlist (
+	id            TEXT PRIMARY KEY,
+	business_id   TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	service_id    TEXT NOT NULL DEFAULT '',
+	date_start    INTEGER NOT NULL,
+	date_end      INTEGER NOT NULL,
+	created_at    INTEGER NOT NULL,
+	notified_at   INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX waitlist_business_idx ON waitlist (business_id, notified_at, created_at);
+
+CREATE TABLE customer_notifications (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	business_id   TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	kind          TEXT NOT NULL,
+	payload       TEXT NOT NULL,
+	created_at    INTEGER NOT NULL,
+	delivered_at  INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX customer_notifications_business_idx ON customer_notifications (business_id, delivered_at);
@@ -0,0 +1,8 @@
+ALTER TABLE business_slot_settings DROP COLUMN cancellation_cutoff_minutes;
+ALTER TABLE business_slot_settings DROP COLUMN min_gap_minutes;
+ALTER TABLE business_slot_settings DROP COLUMN one_booking_per_day;
+ALTER TABLE business_slot_settings DROP COLUMN max_bookings_per_week;
+ALTER TABLE business_slot_settings DROP COLUMN max_bookings_per_day;
+ALTER TABLE business_slot_settings DROP COLUMN max_active_bookings;
+ALTER TABLE business_slot_settings DROP COLUMN max_horizon_minutes;
+ALTER TABLE business_slot_settings DROP COLUMN min_lead_minutes;
@@ -0,0 +1,8 @@
+ALTER TABLE business_slot_settings ADD COLUMN min_lead_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN max_horizon_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN max_active_bookings INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN max_bookings_per_day INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN max_bookings_per_week INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN one_booking_per_day INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN min_gap_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN cancellation_cutoff_minutes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_identities;
+DROP TABLE IF EXISTS customers;
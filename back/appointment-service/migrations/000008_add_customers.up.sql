@@ -0,0 +1,27 @@
+CREATE TABLE customers (
+	business_id   TEXT NOT NULL,
+	id            TEXT NOT NULL,
+	display_name  TEXT NOT NULL DEFAULT '',
+	phone         TEXT NOT NULL DEFAULT '',
+	email         TEXT NOT NULL DEFAULT '',
+	language      TEXT NOT NULL DEFAULT '',
+	time_zone     TEXT NOT NULL DEFAULT '',
+	created_at    INTEGER NOT NULL,
+	merged_into   TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (business_id, id)
+);
+
+CREATE TABLE customer_identities (
+	business_id   TEXT NOT NULL,
+	channel       TEXT NOT NULL,
+	external_id   TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	PRIMARY KEY (business_id, channel, external_id)
+);
+
+CREATE INDEX customer_identities_customer_idx ON customer_identities (business_id, customer_id);
+
+INSERT INTO customers (business_id, id, created_at)
+	SELECT DISTINCT business_id, customer_id, CAST(strftime('%s', 'now') AS INTEGER) FROM appointments
+	UNION
+	SELECT DISTINCT business_id, customer_id, CAST(strftime('%s', 'now') AS INTEGER) FROM waitlist;
@@ -0,0 +1,3 @@
+ALTER TABLE slot_holds DROP COLUMN answers;
+ALTER TABLE appointments DROP COLUMN answers;
+DROP TABLE IF EXISTS booking_fields;
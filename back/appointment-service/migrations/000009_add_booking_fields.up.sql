@@ -0,0 +1,15 @@
+CREATE TABLE booking_fields (
+	id            TEXT PRIMARY KEY,
+	business_id   TEXT NOT NULL,
+	service_id    TEXT NOT NULL DEFAULT '',
+	key           TEXT NOT NULL,
+	label         TEXT NOT NULL,
+	type          TEXT NOT NULL,
+	required      BOOLEAN NOT NULL DEFAULT 0,
+	choices       TEXT NOT NULL DEFAULT '[]',
+	position      INTEGER NOT NULL,
+	UNIQUE (business_id, key)
+);
+
+ALTER TABLE appointments ADD COLUMN answers TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE slot_holds ADD COLUMN answers TEXT NOT NULL DEFAULT '{}';
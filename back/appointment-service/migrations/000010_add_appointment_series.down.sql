@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS appointments_series_idx;
+ALTER TABLE appointments DROP COLUMN series_id;
+DROP TABLE IF EXISTS appointment_series;
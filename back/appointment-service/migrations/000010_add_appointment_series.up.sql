@@ -0,0 +1,12 @@
+CREATE TABLE appointment_series (
+	id            TEXT PRIMARY KEY,
+	business_id   TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	resource_id   TEXT NOT NULL DEFAULT '',
+	rrule         TEXT NOT NULL,
+	created_at    INTEGER NOT NULL
+);
+
+ALTER TABLE appointments ADD COLUMN series_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX appointments_series_idx ON appointments (series_id);
@@ -0,0 +1,4 @@
+ALTER TABLE business_slot_settings DROP COLUMN duration_step_minutes;
+ALTER TABLE business_slot_settings DROP COLUMN max_duration_minutes;
+ALTER TABLE business_slot_settings DROP COLUMN min_duration_minutes;
+ALTER TABLE business_slot_settings DROP COLUMN booking_mode;
@@ -0,0 +1,4 @@
+ALTER TABLE business_slot_settings ADD COLUMN booking_mode TEXT NOT NULL DEFAULT 'chunks';
+ALTER TABLE business_slot_settings ADD COLUMN min_duration_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN max_duration_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN duration_step_minutes INTEGER NOT NULL DEFAULT 0;
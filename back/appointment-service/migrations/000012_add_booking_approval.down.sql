@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS booking_requests;
+
+ALTER TABLE business_slot_settings DROP COLUMN approval_timeout_minutes;
+ALTER TABLE business_slot_settings DROP COLUMN pending_blocks_slot;
+ALTER TABLE business_slot_settings DROP COLUMN requires_approval;
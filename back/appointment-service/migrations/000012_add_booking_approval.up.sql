@@ -0,0 +1,19 @@
+ALTER TABLE business_slot_settings ADD COLUMN requires_approval INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN pending_blocks_slot INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_slot_settings ADD COLUMN approval_timeout_minutes INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE booking_requests (
+	id            TEXT NOT NULL PRIMARY KEY,
+	business_id   TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	resource_id   TEXT NOT NULL DEFAULT '',
+	date_start    INTEGER NOT NULL,
+	date_end      INTEGER NOT NULL,
+	answers       TEXT NOT NULL DEFAULT '{}',
+	blocks_slot   INTEGER NOT NULL DEFAULT 0,
+	created_at    INTEGER NOT NULL,
+	expires_at    INTEGER NOT NULL
+);
+
+CREATE INDEX booking_requests_business_idx ON booking_requests (business_id, date_start);
+CREATE INDEX booking_requests_expires_idx ON booking_requests (expires_at);
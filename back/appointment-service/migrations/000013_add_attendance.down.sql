@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS customer_blocklist;
+
+ALTER TABLE customers DROP COLUMN no_show_count;
+ALTER TABLE customers DROP COLUMN attended_count;
+
+DROP TRIGGER IF EXISTS appointments_booking_code;
+DROP INDEX IF EXISTS appointments_booking_code_idx;
+ALTER TABLE appointments DROP COLUMN booking_code;
+ALTER TABLE appointments DROP COLUMN attendance;
@@ -0,0 +1,24 @@
+ALTER TABLE appointments ADD COLUMN attendance TEXT NOT NULL DEFAULT '';
+ALTER TABLE appointments ADD COLUMN booking_code TEXT;
+
+UPDATE appointments SET booking_code = upper(hex(randomblob(4)));
+
+CREATE UNIQUE INDEX appointments_booking_code_idx ON appointments (business_id, booking_code);
+
+-- Booking code is set for appointments inserted by any booking path
+CREATE TRIGGER appointments_booking_code AFTER INSERT ON appointments
+WHEN NEW.booking_code IS NULL
+BEGIN
+	UPDATE appointments SET booking_code = upper(hex(randomblob(4))) WHERE rowid = NEW.rowid;
+END;
+
+ALTER TABLE customers ADD COLUMN attended_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE customers ADD COLUMN no_show_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE customer_blocklist (
+	business_id   TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	reason        TEXT NOT NULL DEFAULT '',
+	created_at    INTEGER NOT NULL,
+	PRIMARY KEY (business_id, customer_id)
+);
@@ -0,0 +1,12 @@
+-- Future appointments found outside working time after rule changes
+CREATE TABLE appointment_attention (
+	business_id   TEXT NOT NULL,
+	booking_code  TEXT NOT NULL,
+	customer_id   TEXT NOT NULL,
+	resource_id   TEXT NOT NULL DEFAULT '',
+	date_start    INTEGER NOT NULL,
+	date_end      INTEGER NOT NULL,
+	status        TEXT NOT NULL DEFAULT 'open',
+	detected_at   INTEGER NOT NULL,
+	PRIMARY KEY (business_id, booking_code)
+);
@@ -0,0 +1 @@
+ALTER TABLE business_slot_settings DROP COLUMN time_zone;
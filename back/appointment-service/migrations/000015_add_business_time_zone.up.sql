@@ -0,0 +1 @@
+ALTER TABLE business_slot_settings ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idempotency_keys_expires_at;
+DROP TABLE IF EXISTS idempotency_keys;
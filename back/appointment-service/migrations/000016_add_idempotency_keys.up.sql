@@ -0,0 +1,13 @@
+-- Responses of mutating requests replayed on retries with the same Idempotency-Key
+CREATE TABLE idempotency_keys (
+	key           TEXT NOT NULL,
+	scope         TEXT NOT NULL,
+	fingerprint   TEXT NOT NULL,
+	status        INTEGER NOT NULL DEFAULT 0,
+	content_type  TEXT NOT NULL DEFAULT '',
+	body          BLOB,
+	expires_at    INTEGER NOT NULL,
+	PRIMARY KEY (key, scope)
+);
+
+CREATE INDEX idempotency_keys_expires_at ON idempotency_keys (expires_at);
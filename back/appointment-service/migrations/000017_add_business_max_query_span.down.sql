@@ -0,0 +1 @@
+ALTER TABLE business_slot_settings DROP COLUMN max_query_span_minutes;
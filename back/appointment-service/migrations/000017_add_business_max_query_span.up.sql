@@ -0,0 +1 @@
+ALTER TABLE business_slot_settings ADD COLUMN max_query_span_minutes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE booking_requests DROP COLUMN capacity;
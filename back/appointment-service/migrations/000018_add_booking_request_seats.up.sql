@@ -0,0 +1 @@
+ALTER TABLE booking_requests ADD COLUMN capacity INTEGER NOT NULL DEFAULT 0;
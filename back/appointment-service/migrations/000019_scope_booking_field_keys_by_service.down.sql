@@ -0,0 +1,18 @@
+CREATE TABLE booking_fields_old (
+	id            TEXT PRIMARY KEY,
+	business_id   TEXT NOT NULL,
+	service_id    TEXT NOT NULL DEFAULT '',
+	key           TEXT NOT NULL,
+	label         TEXT NOT NULL,
+	type          TEXT NOT NULL,
+	required      BOOLEAN NOT NULL DEFAULT 0,
+	choices       TEXT NOT NULL DEFAULT '[]',
+	position      INTEGER NOT NULL,
+	UNIQUE (business_id, key)
+);
+
+INSERT OR IGNORE INTO booking_fields_old (id, business_id, service_id, key, label, type, required, choices, position)
+SELECT id, business_id, service_id, key, label, type, required, choices, position FROM booking_fields ORDER BY position;
+
+DROP TABLE booking_fields;
+ALTER TABLE booking_fields_old RENAME TO booking_fields;
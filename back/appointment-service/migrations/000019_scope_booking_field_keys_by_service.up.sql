@@ -0,0 +1,19 @@
+-- Services may use the same field key, a key of business-wide fields is checked by the storage
+CREATE TABLE booking_fields_new (
+	id            TEXT PRIMARY KEY,
+	business_id   TEXT NOT NULL,
+	service_id    TEXT NOT NULL DEFAULT '',
+	key           TEXT NOT NULL,
+	label         TEXT NOT NULL,
+	type          TEXT NOT NULL,
+	required      BOOLEAN NOT NULL DEFAULT 0,
+	choices       TEXT NOT NULL DEFAULT '[]',
+	position      INTEGER NOT NULL,
+	UNIQUE (business_id, service_id, key)
+);
+
+INSERT INTO booking_fields_new (id, business_id, service_id, key, label, type, required, choices, position)
+SELECT id, business_id, service_id, key, label, type, required, choices, position FROM booking_fields;
+
+DROP TABLE booking_fields;
+ALTER TABLE booking_fields_new RENAME TO booking_fields;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS appointments_booking_code;
+
+CREATE TRIGGER appointments_booking_code AFTER INSERT ON appointments
+WHEN NEW.booking_code IS NULL
+BEGIN
+	UPDATE appointments SET booking_code = upper(hex(randomblob(4))) WHERE rowid = NEW.rowid;
+END;
@@ -0,0 +1,9 @@
+-- Booking codes of 8 random bytes do not collide under the unique index per business,
+-- a collision fails the appointment insert. Codes already shown to customers are kept.
+DROP TRIGGER IF EXISTS appointments_booking_code;
+
+CREATE TRIGGER appointments_booking_code AFTER INSERT ON appointments
+WHEN NEW.booking_code IS NULL
+BEGIN
+	UPDATE appointments SET booking_code = upper(hex(randomblob(8))) WHERE rowid = NEW.rowid;
+END;
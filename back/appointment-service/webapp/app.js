@@ -5,14 +5,17 @@
   tg?.expand();
 
   const state = {
-    apiBase: params.get('api_base') || '',
+    // Versioned API path of the server, api_base is the server origin
+    apiBase: `${params.get('api_base') || ''}/v1`,
     clientId: params.get('telegram_bot_id')
       || params.get('bot_id')
       || '',
     monthDate: new Date(),
     selectedDate: null,
     selectedSlot: null,
-    monthSlots: [],
+    // Idempotency key of booking the selected slot, repeated confirmations reuse it
+    bookingKey: null,
+    monthDays: [],
     daySlots: [],
     isSubmitting: false,
   };
@@ -98,9 +101,9 @@
   async function loadMonth(date) {
     calendarGridEl.classList.add('loading');
     const monthStart = new Date(date.getFullYear(), date.getMonth(), 1);
-    const monthEnd = new Date(date.getFullYear(), date.getMonth() + 1, 1);
-    const resp = await getSlots(monthStart, monthEnd);
-    state.monthSlots = resp.slots || [];
+    const monthLast = new Date(date.getFullYear(), date.getMonth() + 1, 0);
+    const resp = await getSummary(monthStart, monthLast);
+    state.monthDays = resp.days || [];
     monthLabelEl.textContent = capitalize(ruMonth.format(monthStart));
     renderCalendar();
     calendarGridEl.classList.remove('loading');
@@ -113,7 +116,7 @@
     const gridStart = new Date(monthStart);
     gridStart.setDate(monthStart.getDate() - firstDay);
 
-    const daysWithSlots = new Set(state.monthSlots.map((slot) => dateKey(new Date(slot.tp_start))));
+    const daysWithSlots = new Set(state.monthDays.filter((day) => day.status === 'open').map((day) => day.date));
     const todayKey = dateKey(new Date());
 
     for (let i = 0; i < 42; i++) {
@@ -125,7 +128,7 @@
       btn.textContent = String(d.getDate());
 
       if (d.getMonth() !== state.monthDate.getMonth()) btn.classList.add('other');
-      if (daysWithSlots.has(key)) btn.classList.add('has-slots');
+      if (daysWithSlots.has(localDateKey(d))) btn.classList.add('has-slots');
       if (key === todayKey) btn.classList.add('today');
       if (key === state.selectedDate) btn.classList.add('selected');
 
@@ -173,6 +176,7 @@
 
       btn.addEventListener('click', () => {
         if (isBusy) return;
+        if (state.selectedSlot !== slot) state.bookingKey = newBookingKey();
         state.selectedSlot = slot;
         [...daySlotsEl.children].forEach((el) => el.classList.remove('selected'));
         btn.classList.add('selected');
@@ -205,6 +209,7 @@
           'Content-Type': 'application/json; charset=UTF-8',
           Authorization: `tma ${tg.initData}`,
           'X-Client-ID': state.clientId,
+          'Idempotency-Key': state.bookingKey,
         },
         body: JSON.stringify([state.selectedSlot]),
       });
@@ -225,6 +230,14 @@
   }
 
   async function getSlots(dateStart, dateEnd) {
+    const qs = new URLSearchParams({
+      date_start: dateStart.toISOString(),
+      date_end: dateEnd.toISOString(),
+    });
+    return getJSON(`${state.apiBase}/slots/webapp?${qs.toString()}`);
+  }
+
+  async function getJSON(url) {
     if (!state.clientId) {
       throw new Error('Передайте telegram_bot_id или bot_id в query string');
     }
@@ -233,11 +246,6 @@
       throw new Error('Telegram initData отсутствует');
     }
 
-    const qs = new URLSearchParams({
-      date_start: dateStart.toISOString(),
-      date_end: dateEnd.toISOString(),
-    });
-    const url = `${state.apiBase}/slots/webapp?${qs.toString()}`;
     const res = await fetch(url, {
       headers: {
         Accept: 'application/json',
@@ -249,6 +257,24 @@
     return res.json();
   }
 
+  async function getSummary(dateFrom, dateTo) {
+    const qs = new URLSearchParams({
+      date_from: localDateKey(dateFrom),
+      date_to: localDateKey(dateTo),
+    });
+    return getJSON(`${state.apiBase}/slots/webapp/summary?${qs.toString()}`);
+  }
+
+  // Summary days are dates in the business time zone
+  function newBookingKey() {
+    if (window.crypto?.randomUUID) return crypto.randomUUID();
+    return `${Date.now().toString(36)}-${Math.random().toString(36).slice(2)}`;
+  }
+
+  function localDateKey(d) {
+    return `${d.getFullYear()}-${String(d.getMonth() + 1).padStart(2, '0')}-${String(d.getDate()).padStart(2, '0')}`;
+  }
+
   function dateKey(d) {
     const x = new Date(d);
     x.setHours(0, 0, 0, 0);